@@ -8,6 +8,7 @@ import (
 	"philosopher/lib/aba"
 	"philosopher/lib/met"
 	"philosopher/lib/msg"
+	"philosopher/lib/qua"
 	"philosopher/lib/sys"
 
 	"github.com/spf13/cobra"
@@ -25,6 +26,19 @@ var abacusCmd = &cobra.Command{
 			msg.InputNotFound(errors.New("the combined analysis needs at least 2 result files to work"), "fatal")
 		}
 
+		if m.Abacus.Norm == aba.NormIRS {
+			if !m.Abacus.Labels {
+				msg.Custom(errors.New("the IRS normalization needs labeled data sets"), "fatal")
+			}
+		} else {
+			qua.ValidateNormalization(m.Abacus.Norm, m.Abacus.NormRef)
+		}
+		qua.ValidateImputation(m.Abacus.Impute, m.Abacus.ImputeWidth, m.Abacus.ImputeShift, m.Abacus.ImputeK)
+
+		if m.Abacus.MSstats && len(m.Abacus.Design) == 0 {
+			msg.Custom(errors.New("the MSstats export needs an experimental design file"), "fatal")
+		}
+
 		msg.Executing("Abacus", Version)
 		aba.Run(m, args)
 
@@ -49,12 +63,30 @@ func init() {
 		abacusCmd.Flags().Float64VarP(&m.Abacus.PepProb, "pepProb", "", 0.5, "minimum peptide probability")
 		abacusCmd.Flags().BoolVarP(&m.Abacus.Protein, "protein", "", false, "global level protein report")
 		abacusCmd.Flags().BoolVarP(&m.Abacus.Peptide, "peptide", "", false, "global level peptide report")
+		abacusCmd.Flags().BoolVarP(&m.Abacus.Gene, "gene", "", false, "global level gene report")
+		abacusCmd.Flags().BoolVarP(&m.Abacus.MBR, "mbr", "", false, "transfer identifications between data sets using match-between-runs (requires freequant)")
+		abacusCmd.Flags().Float64VarP(&m.Abacus.MBRFDR, "mbrfdr", "", 0.01, "match-between-runs transfer FDR level")
+		abacusCmd.Flags().Float64VarP(&m.Abacus.MBRRTWin, "mbrrtwin", "", 1.0, "match-between-runs retention time window after alignment (minutes)")
+		abacusCmd.Flags().Float64VarP(&m.Abacus.MBRIMWin, "mbrimwin", "", 0.05, "match-between-runs ion mobility window (1/K0)")
+		abacusCmd.Flags().StringVarP(&m.Abacus.Norm, "norm", "", "", "normalize the data set intensities (sum, median, quantile, spikein, irs)")
+		abacusCmd.Flags().StringVarP(&m.Abacus.NormRef, "normref", "", "", "list of spike-in or housekeeping proteins for the spikein normalization")
+		abacusCmd.Flags().StringVarP(&m.Abacus.Impute, "impute", "", "", "impute the missing values of the combined tables (downshift, minprob, knn, na)")
+		abacusCmd.Flags().Float64VarP(&m.Abacus.ImputeWidth, "imputewidth", "", 0.3, "width of the imputation distribution relative to the sample standard deviation")
+		abacusCmd.Flags().Float64VarP(&m.Abacus.ImputeShift, "imputeshift", "", 1.8, "downshift of the imputation distribution in standard deviations")
+		abacusCmd.Flags().IntVarP(&m.Abacus.ImputeK, "imputek", "", 5, "number of neighbours used by the KNN imputation")
+		abacusCmd.Flags().BoolVarP(&m.Abacus.Align, "align", "", false, "align the retention times from all data sets to a common reference")
+		abacusCmd.Flags().StringVarP(&m.Abacus.AlignMethod, "alignmethod", "", "loess", "retention time alignment method (loess or piecewise)")
 		abacusCmd.Flags().BoolVarP(&m.Abacus.Razor, "razor", "", false, "use razor peptides for protein FDR scoring")
+		abacusCmd.Flags().BoolVarP(&m.Abacus.Site, "site", "", false, "global level modified site report (requires PTMProphet)")
+		abacusCmd.Flags().BoolVarP(&m.Abacus.MSstats, "msstats", "", false, "write the MSstats or MSstatsTMT input file of all data sets following the experimental design")
+		abacusCmd.Flags().StringVarP(&m.Abacus.Design, "design", "", "", "experimental design of the MSstats export")
 		abacusCmd.Flags().BoolVarP(&m.Abacus.Picked, "picked", "", false, "apply the picked FDR algorithm before the protein scoring")
 		abacusCmd.Flags().BoolVarP(&m.Abacus.Unique, "uniqueonly", "", false, "report TMT quantification based on only unique peptides")
 		abacusCmd.Flags().BoolVarP(&m.Abacus.Labels, "labels", "", false, "indicates whether the data sets includes TMT labels or not")
 		abacusCmd.Flags().BoolVarP(&m.Abacus.Reprint, "reprint", "", false, "create abacus reports using the Reprint format")
 		abacusCmd.Flags().BoolVarP(&m.Abacus.Full, "full", "", true, "generates combined tables with extra information")
+		abacusCmd.Flags().BoolVarP(&m.Abacus.Parquet, "parquet", "", false, "write the combined tables also in the Parquet format")
+		abacusCmd.Flags().BoolVarP(&m.Abacus.Long, "long", "", false, "write the channel abundances of the Parquet tables in the long format")
 	}
 
 	RootCmd.AddCommand(abacusCmd)
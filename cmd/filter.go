@@ -28,11 +28,12 @@ var filterCmd = &cobra.Command{
 		os.RemoveAll(sys.PepBin())
 		os.RemoveAll(sys.IonBin())
 		os.RemoveAll(sys.ProBin())
+		os.RemoveAll(sys.GeneBin())
 		os.RemoveAll(sys.PepxmlBin())
 
 		// check file existence
-		if len(m.Filter.Pex) < 1 {
-			msg.InputNotFound(errors.New("you must provide a pepXML file or a folder with one or more files, Run 'philosopher filter --help' for more information"), "fatal")
+		if len(m.Filter.Pex) < 1 && len(m.Filter.Mzid) < 1 {
+			msg.InputNotFound(errors.New("you must provide a pepXML or mzIdentML file or a folder with one or more files, Run 'philosopher filter --help' for more information"), "fatal")
 		}
 
 		if len(m.Filter.Pox) == 0 && m.Filter.Razor {
@@ -58,6 +59,7 @@ func init() {
 		m.Restore(sys.Meta())
 
 		filterCmd.Flags().StringVarP(&m.Filter.Pex, "pepxml", "", "", "pepXML file or directory containing a set of pepXML files")
+		filterCmd.Flags().StringVarP(&m.Filter.Mzid, "mzid", "", "", "mzIdentML file or directory containing a set of mzIdentML files, used instead of the pepXML files")
 		filterCmd.Flags().StringVarP(&m.Filter.Pox, "protxml", "", "", "protXML file path")
 		filterCmd.Flags().StringVarP(&m.Filter.Tag, "tag", "", "rev_", "decoy tag")
 		filterCmd.Flags().StringVarP(&m.Filter.Mods, "mods", "", "", "list of modifications for a stratified FDR filtering")
@@ -66,6 +68,7 @@ func init() {
 		filterCmd.Flags().Float64VarP(&m.Filter.PepFDR, "pep", "", 0.01, "peptide FDR level")
 		filterCmd.Flags().Float64VarP(&m.Filter.PsmFDR, "psm", "", 0.01, "psm FDR level")
 		filterCmd.Flags().Float64VarP(&m.Filter.PtFDR, "prot", "", 0.01, "protein FDR level")
+		filterCmd.Flags().Float64VarP(&m.Filter.GeneFDR, "geneFDR", "", 0.01, "gene FDR level")
 		filterCmd.Flags().Float64VarP(&m.Filter.PepProb, "pepProb", "", 0.7, "top peptide probability threshold for the FDR filtering")
 		filterCmd.Flags().Float64VarP(&m.Filter.ProtProb, "protProb", "", 0.5, "protein probability threshold for the FDR filtering (not used with the razor algorithm)")
 		filterCmd.Flags().Float64VarP(&m.Filter.Weight, "weight", "", 1, "threshold for defining peptide uniqueness")
@@ -75,6 +78,10 @@ func init() {
 		filterCmd.Flags().BoolVarP(&m.Filter.Razor, "razor", "", false, "use razor peptides for protein FDR scoring")
 		filterCmd.Flags().BoolVarP(&m.Filter.Picked, "picked", "", false, "apply the picked FDR algorithm before the protein scoring")
 		filterCmd.Flags().BoolVarP(&m.Filter.Mapmods, "mapmods", "", false, "map modifications")
+		filterCmd.Flags().BoolVarP(&m.Filter.Gene, "gene", "", false, "collapse proteins and isoforms to genes and apply a picked gene-level FDR")
+		filterCmd.Flags().BoolVarP(&m.Filter.Remap, "remap", "", false, "re-map all peptides to the workspace database instead of using the search engine protein mapping")
+		filterCmd.Flags().BoolVarP(&m.Filter.ILEq, "ileq", "", true, "treat isoleucine and leucine as equivalent when re-mapping peptides")
+		filterCmd.Flags().BoolVarP(&m.Filter.ClipNMet, "clipnmet", "", true, "consider N-terminal methionine clipping when re-mapping peptides")
 		filterCmd.Flags().BoolVarP(&m.Filter.Inference, "inference", "", false, "extremely fast and efficient protein inference compatible with 2D and Sequential filters")
 		filterCmd.Flags().MarkHidden("mods")
 		filterCmd.Flags().MarkHidden("razorbin")
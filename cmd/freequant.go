@@ -38,6 +38,14 @@ var freequant = &cobra.Command{
 			msg.InputNotFound(errors.New("unknown file format"), "fatal")
 		}
 
+		if m.Quantify.Area && !m.Quantify.Feature {
+			msg.Custom(errors.New("the peak area is only available with the feature detection, use the --feature option"), "fatal")
+		}
+
+		qua.ValidateRollup(m.Quantify)
+		qua.ValidateIBAQ(m.Quantify)
+		qua.ValidateLabel(m.Quantify.Label)
+
 		if m.Quantify.Raw {
 			msg.Custom(errors.New("support for Thermo raw files was temporarily removed, please convert your files to mzML"), "fatal")
 		}
@@ -47,7 +55,7 @@ var freequant = &cobra.Command{
 		m.Quantify.RTWin = m.Quantify.PTWin
 
 		// run label-free quantification
-		qua.RunLabelFreeQuantification(m.Quantify)
+		qua.RunLabelFreeQuantification(m.Quantify, m.Temp)
 
 		// store parameters on meta data
 		m.Serialize()
@@ -70,6 +78,19 @@ func init() {
 		freequant.Flags().Float64VarP(&m.Quantify.PTWin, "ptw", "", 0.4, "specify the time windows for the peak (minute)")
 		freequant.Flags().BoolVarP(&m.Quantify.Raw, "raw", "", false, "read raw files instead of converted XML")
 		freequant.Flags().BoolVarP(&m.Quantify.Faims, "faims", "", false, "Use FAIMS information for the quantification")
+		freequant.Flags().BoolVarP(&m.Quantify.Feature, "feature", "", false, "detect isotope envelope features and integrate the peak areas")
+		freequant.Flags().BoolVarP(&m.Quantify.Area, "area", "", false, "use the integrated peak area as the quantification value (requires --feature)")
+		freequant.Flags().StringVarP(&m.Quantify.Rollup, "rollup", "", "topN", "peptide to protein rollup strategy (topN, sum, mean, median, medianpolish, tukey)")
+		freequant.Flags().IntVarP(&m.Quantify.RollupTopN, "topn", "", 3, "number of peptide ions used by the topN rollup")
+		freequant.Flags().StringVarP(&m.Quantify.RollupPep, "rolluppeptides", "", "all", "peptide ions used by the rollup (all, unique, razor)")
+		freequant.Flags().IntVarP(&m.Quantify.MinPeptides, "minpeptides", "", 1, "minimum number of peptides for a protein to be quantified")
+		freequant.Flags().BoolVarP(&m.Quantify.IBAQ, "ibaq", "", false, "calculate iBAQ, riBAQ and proteomic ruler copy numbers")
+		freequant.Flags().StringVarP(&m.Quantify.Enzyme, "enzyme", "", "trypsin", "enzyme used for the in-silico digestion of the iBAQ calculation")
+		freequant.Flags().Float64VarP(&m.Quantify.Ploidy, "ploidy", "", 2, "ploidy of the cells for the proteomic ruler")
+		freequant.Flags().Float64VarP(&m.Quantify.GenomeSize, "genomesize", "", 3.2e9, "genome size in base pairs for the proteomic ruler")
+		freequant.Flags().BoolVarP(&m.Quantify.Align, "align", "", false, "align the retention times from all runs to a common reference")
+		freequant.Flags().StringVarP(&m.Quantify.Label, "label", "", "", "quantify the MS1 labeled partners (silac, silac3, dimethyl, dimethyl3, psilac)")
+		freequant.Flags().StringVarP(&m.Quantify.AlignMethod, "alignmethod", "", "loess", "retention time alignment method (loess or piecewise)")
 	}
 
 	RootCmd.AddCommand(freequant)
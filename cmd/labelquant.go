@@ -28,8 +28,8 @@ var labelquantCmd = &cobra.Command{
 			msg.InputNotFound(errors.New("you need to provide the path to the mz files and the correct extension"), "fatal")
 		}
 
-		if len(m.Quantify.Plex) < 1 {
-			msg.InputNotFound(errors.New("you need to specify the experiment Plex"), "fatal")
+		if len(m.Quantify.Plex) < 1 && len(m.Quantify.Reagent) < 1 {
+			msg.InputNotFound(errors.New("you need to specify the experiment Plex or a reagent definition file"), "fatal")
 		}
 
 		msg.Executing("Isobaric-label quantification ", Version)
@@ -43,11 +43,17 @@ var labelquantCmd = &cobra.Command{
 			msg.InputNotFound(errors.New("unknown file format"), "fatal")
 		}
 
+		qua.ValidateRollup(m.Quantify)
+
+		if m.Quantify.MinSNR > 0 && m.Quantify.RemoveLow > 0 {
+			msg.Custom(errors.New("the signal to noise threshold replaces the removal of low intensity PSMs, use only one of them"), "fatal")
+		}
+
 		if m.Quantify.Raw {
 			msg.Custom(errors.New("support for Thermo raw files was temporarily removed, please convert your files to mzML"), "fatal")
 		}
 
-		m.Quantify = qua.RunIsobaricLabelQuantification(m.Quantify, m.Filter.Mapmods)
+		m.Quantify = qua.RunIsobaricLabelQuantification(m.Quantify, m.Filter.Mapmods, m.Temp)
 
 		// store parameters on meta data
 		m.Serialize()
@@ -67,6 +73,8 @@ func init() {
 
 		labelquantCmd.Flags().StringVarP(&m.Quantify.Annot, "annot", "", "", "annotation file with custom names for the TMT channels")
 		labelquantCmd.Flags().StringVarP(&m.Quantify.Plex, "plex", "", "", "number of reporter ion channels")
+		labelquantCmd.Flags().StringVarP(&m.Quantify.Reagent, "reagent", "", "", "reagent definition file with the channel names, reporter ion m/z and isotopic neighbours")
+		labelquantCmd.Flags().StringVarP(&m.Quantify.Impurity, "impurity", "", "", "lot-specific reagent purity table (CSV or YAML) used to correct the reporter ion isotopic impurities")
 		labelquantCmd.Flags().StringVarP(&m.Quantify.Dir, "dir", "", "", "folder path containing the raw files")
 		labelquantCmd.Flags().StringVarP(&m.Quantify.Brand, "brand", "", "", "isobaric labeling brand (tmt, itraq)")
 		labelquantCmd.Flags().Float64VarP(&m.Quantify.Tol, "tol", "", 20, "m/z tolerance in ppm")
@@ -74,9 +82,15 @@ func init() {
 		labelquantCmd.Flags().Float64VarP(&m.Quantify.Purity, "purity", "", 0.5, "ion purity threshold")
 		labelquantCmd.Flags().Float64VarP(&m.Quantify.MinProb, "minprob", "", 0.7, "only use PSMs with the specified minimum probability score")
 		labelquantCmd.Flags().Float64VarP(&m.Quantify.RemoveLow, "removelow", "", 0.0, "ignore the lower % of PSMs based on their summed abundances. 0 means no removal, entry value must be a decimal")
+		labelquantCmd.Flags().BoolVarP(&m.Quantify.SNR, "snr", "", false, "report the reporter ion signal to noise instead of the intensity (requires noise data)")
+		labelquantCmd.Flags().Float64VarP(&m.Quantify.MinSNR, "minsnr", "", 0, "ignore PSMs with a mean reporter ion signal to noise below the threshold. 0 means no removal")
 		labelquantCmd.Flags().BoolVarP(&m.Quantify.Unique, "uniqueonly", "", false, "report quantification based only on unique peptides")
 		labelquantCmd.Flags().BoolVarP(&m.Quantify.BestPSM, "bestpsm", "", false, "select the best PSMs for protein quantification")
 		labelquantCmd.Flags().BoolVarP(&m.Quantify.Raw, "raw", "", false, "read raw files instead of converted XML")
+		labelquantCmd.Flags().StringVarP(&m.Quantify.Rollup, "rollup", "", "sum", "peptide to protein rollup strategy (topN, sum, mean, median, medianpolish, tukey)")
+		labelquantCmd.Flags().IntVarP(&m.Quantify.RollupTopN, "topn", "", 3, "number of peptide ions used by the topN rollup")
+		labelquantCmd.Flags().StringVarP(&m.Quantify.RollupPep, "rolluppeptides", "", "all", "peptide ions used by the rollup (all, unique, razor)")
+		labelquantCmd.Flags().IntVarP(&m.Quantify.MinPeptides, "minpeptides", "", 1, "minimum number of peptides for a protein to be quantified")
 
 	}
 
@@ -43,6 +43,13 @@ func init() {
 		reportCmd.Flags().BoolVarP(&m.Report.Decoys, "decoys", "", false, "add decoy observations to reports")
 		reportCmd.Flags().BoolVarP(&m.Report.MSstats, "msstats", "", false, "create an output compatible with MSstats")
 		reportCmd.Flags().BoolVarP(&m.Report.MZID, "mzid", "", false, "create a mzID output")
+		reportCmd.Flags().StringVarP(&m.Report.MzIDFile, "mzidfile", "", "", "path of the mzID output, written to the workspace as report.mzid when empty")
+		reportCmd.Flags().BoolVarP(&m.Report.MzTab, "mztab", "", false, "create a mzTab output")
+		reportCmd.Flags().StringVarP(&m.Report.SQLite, "sqlite", "", "", "write the evidences to a SQLite database, workspaces are appended to an existing database")
+		reportCmd.Flags().BoolVarP(&m.Report.Parquet, "parquet", "", false, "write the reports also in the Parquet format")
+		reportCmd.Flags().BoolVarP(&m.Report.Long, "long", "", false, "write the channel intensities of the Parquet reports in the long format")
+		reportCmd.Flags().StringVarP(&m.Report.Design, "design", "", "", "experimental design used to write the MSstats or MSstatsTMT input file")
+		reportCmd.Flags().StringVarP(&m.Report.Template, "template", "", "", "YAML template with a custom layout for the reports, written next to the standard ones")
 		reportCmd.Flags().BoolVarP(&m.Report.IonMob, "ionmobility", "", false, "forces the printing of the ion mobility column")
 	}
 
@@ -4,11 +4,17 @@ package aba
 import (
 	"bufio"
 	"errors"
+	"fmt"
+	"math"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"philosopher/lib/met"
 	"philosopher/lib/msg"
+	"philosopher/lib/qua"
+	"philosopher/lib/rep"
+	"philosopher/lib/sys"
 )
 
 // DataSetLabelNames maps all custom names to each TMT tags
@@ -21,8 +27,15 @@ type DataSetLabelNames struct {
 // TODO update error methos on the abacus function
 func Run(m met.Data, args []string) {
 
-	if !m.Abacus.Peptide && !m.Abacus.Protein {
-		msg.Custom(errors.New("you need to specify a peptide or protein combined file for the Abacus analysis"), "fatal")
+	if !m.Abacus.Peptide && !m.Abacus.Protein && !m.Abacus.Gene && !m.Abacus.Site && !m.Abacus.MSstats {
+		msg.Custom(errors.New("you need to specify a peptide, protein, gene or site combined file for the Abacus analysis"), "fatal")
+	}
+
+	// match-between-runs aligns the retention times before the transfers
+	if m.Abacus.MBR {
+		qua.RunMatchBetweenRuns(m.Abacus, m.Temp, args)
+	} else if m.Abacus.Align {
+		qua.RunRetentionTimeAlignment(m.Abacus, m.Temp, args)
 	}
 
 	if m.Abacus.Peptide {
@@ -32,6 +45,18 @@ func Run(m met.Data, args []string) {
 	if m.Abacus.Protein {
 		proteinLevelAbacus(m, args)
 	}
+
+	if m.Abacus.Gene {
+		geneLevelAbacus(m, args)
+	}
+
+	if m.Abacus.Site {
+		siteLevelAbacus(m, args)
+	}
+
+	if m.Abacus.MSstats {
+		msstatsAbacus(m, args)
+	}
 }
 
 // addCustomNames adds to the label structures user-defined names to be used on the TMT labels
@@ -57,3 +82,80 @@ func getLabelNames(annot string) map[string]string {
 
 	return labels
 }
+
+// imputation creates the imputation parameters from the abacus options
+func imputation(a met.Abacus) qua.Imputation {
+	return qua.Imputation{
+		Method: a.Impute,
+		Width:  a.ImputeWidth,
+		Shift:  a.ImputeShift,
+		K:      a.ImputeK,
+	}
+}
+
+// imputeColumns imputes a block of columns from the combined rows and returns the block mask
+func imputeColumns(p qua.Imputation, rows int, get func(int) []float64, set func(int, []float64)) [][]bool {
+
+	var matrix = make([][]float64, rows)
+	for i := range matrix {
+		matrix[i] = get(i)
+	}
+
+	imputed, mask := p.Impute(matrix)
+
+	for i := range imputed {
+		set(i, imputed[i])
+	}
+
+	return mask
+}
+
+// appendMask adds the columns of a block mask to the report mask
+func appendMask(mask, block [][]bool) [][]bool {
+
+	if mask == nil {
+		mask = make([][]bool, len(block))
+	}
+
+	for i := range block {
+		mask[i] = append(mask[i], block[i]...)
+	}
+
+	return mask
+}
+
+// formatQuant prints a quantification value, the missing values kept by the NA imputation are printed as NA
+func formatQuant(format string, v float64) string {
+
+	if math.IsNaN(v) {
+		na := "NA"
+		if strings.HasPrefix(format, "\t") {
+			na = "\t" + na
+		}
+		if strings.HasSuffix(format, "\t") {
+			na += "\t"
+		}
+		return na
+	}
+
+	return fmt.Sprintf(format, v)
+}
+
+// quantField registers a quantification column, the missing values kept by the NA imputation are printed as NA
+func quantField(t *rep.Table, name, verb string, value func(n int) float64) *rep.Field {
+	return t.Decimal(name, verb, value).Printer(func(v interface{}) string { return formatQuant(verb, v.(float64)) })
+}
+
+// saveCombinedTable writes a combined table to the session and copies it to the work directory
+func saveCombinedTable(session string, t *rep.Table) *rep.Table {
+
+	output := fmt.Sprintf("%s%s%s.tsv", session, string(filepath.Separator), t.Name)
+	if e := t.WriteTSV(output); e != nil {
+		msg.WriteToFile(e, "fatal")
+	}
+
+	// copy to work directory
+	sys.CopyFile(output, filepath.Base(output))
+
+	return t
+}
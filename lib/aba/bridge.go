@@ -0,0 +1,285 @@
+package aba
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"philosopher/lib/iso"
+	"philosopher/lib/msg"
+	"philosopher/lib/rep"
+)
+
+// NormIRS is the internal reference scaling of the plexes based on their reference channels
+const NormIRS = "irs"
+
+// referenceTags mark the reference channels on the third column of the annotation files
+var referenceTags = map[string]struct{}{"reference": {}, "ref": {}, "bridge": {}}
+
+// getReferenceChannels reads the reference channels from an annotation file, a reference channel has one of the
+// reference tags after the channel and sample names
+func getReferenceChannels(annot string) []string {
+
+	var references []string
+
+	file, e := os.Open(annot)
+	if e != nil {
+		msg.ReadFile(errors.New("cannot open annotation file"), "error")
+		return references
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 2 {
+			if _, ok := referenceTags[strings.ToLower(fields[2])]; ok {
+				references = append(references, fields[0])
+			}
+		}
+	}
+
+	if e = scanner.Err(); e != nil {
+		msg.ReadFile(e, "error")
+	}
+
+	return references
+}
+
+// referenceIntensity returns the mean intensity of the reference channels, it is zero when one of them is missing
+func referenceIntensity(l iso.Labels, references []string) float64 {
+
+	var sum float64
+	var n int
+	for _, i := range l.Channels {
+		for _, j := range references {
+			if i.Name == j {
+				if i.Intensity <= 0 {
+					return 0
+				}
+				sum += i.Intensity
+				n++
+			}
+		}
+	}
+
+	if n == 0 {
+		return 0
+	}
+
+	return sum / float64(n)
+}
+
+// logRatios returns the log2 ratios of all channels to the reference, missing values are NaN
+func logRatios(l iso.Labels, references []string) []float64 {
+
+	var ratios = make([]float64, len(l.Channels))
+
+	ref := referenceIntensity(l, references)
+	for i := range l.Channels {
+		if ref > 0 && l.Channels[i].Intensity > 0 {
+			ratios[i] = math.Log2(l.Channels[i].Intensity / ref)
+		} else {
+			ratios[i] = math.NaN()
+		}
+	}
+
+	return ratios
+}
+
+// hasReferences checks if any data set declares a reference channel
+func hasReferences(references map[string][]string) bool {
+	for _, i := range references {
+		if len(i) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// isReference checks if the channel is one of the plex references
+func isReference(channel string, references []string) bool {
+	for _, i := range references {
+		if i == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// irsNormalization applies the internal reference scaling, for each protein the channels of every plex are scaled
+// so that the plex reference matches the geometric mean of the references from all plexes
+func irsNormalization(combined rep.CombinedProteinEvidenceList, namesList []string, references map[string][]string) rep.CombinedProteinEvidenceList {
+
+	for _, i := range namesList {
+		if len(references[i]) == 0 {
+			msg.Custom(fmt.Errorf("the data set %s has no reference channel for the IRS normalization", i), "fatal")
+		}
+	}
+
+	for i := range combined {
+		for _, level := range []map[string]iso.Labels{combined[i].TotalLabels, combined[i].UniqueLabels, combined[i].URazorLabels} {
+
+			var logSum float64
+			var refs = make(map[string]float64)
+			for _, j := range namesList {
+				if ref := referenceIntensity(level[j], references[j]); ref > 0 {
+					refs[j] = ref
+					logSum += math.Log(ref)
+				}
+			}
+
+			if len(refs) == 0 {
+				continue
+			}
+
+			geoMean := math.Exp(logSum / float64(len(refs)))
+
+			for j, ref := range refs {
+				labels := level[j].Copy()
+				for k := range labels.Channels {
+					labels.Channels[k].Intensity *= geoMean / ref
+				}
+				level[j] = labels
+			}
+		}
+	}
+
+	return combined
+}
+
+// channelLabel returns the sample name of a channel, or the channel name when it was not annotated
+func channelLabel(c iso.Channel) string {
+	if len(c.CustomName) > 0 {
+		return c.CustomName
+	}
+	return c.Name
+}
+
+// savePSMRatios creates the PSM report with the log2 ratios of every channel to the plex reference
+func savePSMRatios(session string, datasets map[string]rep.Evidence, namesList []string, references map[string][]string) *rep.Table {
+
+	var channels []string
+	for _, i := range namesList {
+		for _, j := range datasets[i].PSM {
+			if j.Labels != nil && len(j.Labels.Channels) > len(channels) {
+				channels = j.Labels.Names()
+			}
+		}
+	}
+
+	// a row per quantified target PSM of the data sets with a reference
+	type psmRatio struct {
+		name      string
+		psm       rep.PSMEvidence
+		reference float64
+		ratios    []float64
+	}
+
+	var rows []psmRatio
+	for _, i := range namesList {
+
+		if len(references[i]) == 0 {
+			continue
+		}
+
+		for _, j := range datasets[i].PSM {
+
+			if j.IsDecoy || j.Labels == nil {
+				continue
+			}
+
+			ref := referenceIntensity(*j.Labels, references[i])
+			if ref <= 0 {
+				continue
+			}
+
+			rows = append(rows, psmRatio{i, j, ref, logRatios(*j.Labels, references[i])})
+		}
+	}
+
+	t := rep.NewTable("combined_psm_ratio", len(rows))
+
+	t.Text("Data Set", func(n int) string { return rows[n].name })
+	t.Text("Spectrum", func(n int) string { return rows[n].psm.Spectrum })
+	t.Text("Peptide", func(n int) string { return rows[n].psm.Peptide })
+	t.Text("Protein", func(n int) string { return rows[n].psm.Protein })
+	t.Decimal("Reference Intensity", "%.4f", func(n int) float64 { return rows[n].reference })
+
+	for k, i := range channels {
+		k := k
+		quantField(t, fmt.Sprintf("%s Log2 Ratio", i), "%.4f", func(n int) float64 {
+			if k < len(rows[n].ratios) {
+				return rows[n].ratios[k]
+			}
+			return math.NaN()
+		})
+	}
+
+	return saveCombinedTable(session, t)
+}
+
+// saveProteinRatios creates the combined protein ratio matrix, with the log2 ratios of every sample channel to the
+// reference of its plex
+func saveProteinRatios(session string, combined rep.CombinedProteinEvidenceList, namesList []string, references map[string][]string, uniqueOnly bool) *rep.Table {
+
+	var level = func(p rep.CombinedProteinEvidence) map[string]iso.Labels { return p.URazorLabels }
+	if uniqueOnly {
+		level = func(p rep.CombinedProteinEvidence) map[string]iso.Labels { return p.UniqueLabels }
+	}
+
+	// the sample channels of each plex, taken from the first quantified protein
+	var channels = make(map[string][]iso.Channel)
+	for _, i := range namesList {
+		for _, j := range combined {
+			if l, ok := level(j)[i]; ok && len(l.Channels) > 0 {
+				for _, k := range l.Channels {
+					if !isReference(k.Name, references[i]) {
+						channels[i] = append(channels[i], k)
+					}
+				}
+				break
+			}
+		}
+	}
+
+	var rows rep.CombinedProteinEvidenceList
+	for _, i := range combined {
+		if len(i.TotalSpc) > 0 {
+			rows = append(rows, i)
+		}
+	}
+
+	t := rep.NewTable("combined_protein_ratio", len(rows))
+
+	t.Text("Protein", func(n int) string { return rows[n].ProteinName })
+	t.Text("Protein ID", func(n int) string { return rows[n].ProteinID })
+	t.Text("Gene", func(n int) string { return rows[n].GeneNames })
+
+	for _, i := range namesList {
+
+		if len(references[i]) == 0 {
+			continue
+		}
+
+		for _, j := range channels[i] {
+			i, j := i, j
+			quantField(t, fmt.Sprintf("%s %s Log2 Ratio", i, channelLabel(j)), "%.4f", func(n int) float64 {
+				labels := level(rows[n])[i]
+				ratios := logRatios(labels, references[i])
+				var v = math.NaN()
+				for l := range labels.Channels {
+					if labels.Channels[l].Name == j.Name {
+						v = ratios[l]
+					}
+				}
+				return v
+			})
+		}
+	}
+
+	return saveCombinedTable(session, t)
+}
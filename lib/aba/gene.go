@@ -0,0 +1,232 @@
+// Package aba (Abacus), gene level
+package aba
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"philosopher/lib/met"
+	"philosopher/lib/msg"
+	"philosopher/lib/rep"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Create gene combined report
+func geneLevelAbacus(m met.Data, args []string) {
+
+	var names []string
+	var datasets = make(map[string]rep.Evidence)
+
+	// recover all files
+	logrus.Info("Restoring gene results")
+
+	for _, i := range args {
+
+		var e rep.Evidence
+		rep.RestoreGeneWithPath(&e.Genes, i)
+
+		if len(e.Genes) == 0 {
+			msg.Custom(fmt.Errorf("no gene results found in %s, run the filter with the --gene option", i), "fatal")
+		}
+
+		// collect project names
+		prjName := i
+		if strings.Contains(prjName, string(filepath.Separator)) {
+			prjName = strings.Replace(filepath.Base(prjName), string(filepath.Separator), "", -1)
+		}
+
+		datasets[prjName] = e
+		names = append(names, prjName)
+	}
+
+	sort.Strings(names)
+
+	logrus.Info("Processing gene evidences")
+	evidences := collectGeneEvidences(datasets, m.Abacus.Tag)
+
+	saveParquetTable(m.Abacus, saveGeneAbacusResult(m.Temp, evidences, names, m.Abacus.Full))
+}
+
+// collectGeneEvidences creates a unique gene list with the counts and intensities from each data set
+func collectGeneEvidences(datasets map[string]rep.Evidence, decoyTag string) rep.CombinedGeneEvidenceList {
+
+	var geneMap = make(map[string]*rep.CombinedGeneEvidence)
+
+	for k, v := range datasets {
+		for _, i := range v.Genes {
+
+			if i.IsDecoy || strings.HasPrefix(i.GeneName, decoyTag) {
+				continue
+			}
+
+			ce, ok := geneMap[i.GeneName]
+			if !ok {
+				ce = &rep.CombinedGeneEvidence{
+					GeneName:        i.GeneName,
+					Organism:        i.Organism,
+					Description:     i.Description,
+					Proteins:        make(map[string]struct{}),
+					TotalSpc:        make(map[string]int),
+					UniqueSpc:       make(map[string]int),
+					UrazorSpc:       make(map[string]int),
+					TotalPeptides:   make(map[string]map[string]bool),
+					UniquePeptides:  make(map[string]map[string]bool),
+					UrazorPeptides:  make(map[string]map[string]bool),
+					TotalIntensity:  make(map[string]float64),
+					UniqueIntensity: make(map[string]float64),
+					UrazorIntensity: make(map[string]float64),
+				}
+				geneMap[i.GeneName] = ce
+			}
+
+			if i.Probability > ce.Probability {
+				ce.Probability = i.Probability
+			}
+
+			if i.TopPepProb > ce.TopPepProb {
+				ce.TopPepProb = i.TopPepProb
+			}
+
+			for j := range i.Proteins {
+				ce.Proteins[j] = struct{}{}
+			}
+
+			ce.TotalSpc[k] = i.TotalSpC
+			ce.UniqueSpc[k] = i.UniqueSpC
+			ce.UrazorSpc[k] = i.URazorSpC
+
+			ce.TotalPeptides[k] = make(map[string]bool)
+			for j := range i.TotalPeptides {
+				ce.TotalPeptides[k][j] = false
+			}
+
+			ce.UniquePeptides[k] = make(map[string]bool)
+			for j := range i.UniquePeptides {
+				ce.UniquePeptides[k][j] = false
+			}
+
+			ce.UrazorPeptides[k] = make(map[string]bool)
+			for j := range i.URazorPeptides {
+				ce.UrazorPeptides[k][j] = false
+			}
+
+			ce.TotalIntensity[k] = i.TotalIntensity
+			ce.UniqueIntensity[k] = i.UniqueIntensity
+			ce.UrazorIntensity[k] = i.URazorIntensity
+		}
+	}
+
+	var list rep.CombinedGeneEvidenceList
+	for _, v := range geneMap {
+		list = append(list, *v)
+	}
+
+	sort.Sort(list)
+
+	return list
+}
+
+// saveGeneAbacusResult creates a single gene report using 1 or more philosopher result files
+func saveGeneAbacusResult(session string, evidences rep.CombinedGeneEvidenceList, namesList []string, full bool) *rep.Table {
+
+	// sum the counts of all data sets for each gene
+	var totalPeptides = make([]int, len(evidences))
+	var uniquePeptides = make([]int, len(evidences))
+	var razorPeptides = make([]int, len(evidences))
+	var summTotalSpC = make([]int, len(evidences))
+	var summUniqueSpC = make([]int, len(evidences))
+	var summURazorSpC = make([]int, len(evidences))
+
+	for n, i := range evidences {
+
+		var peptides = make(map[string]struct{})
+		var unique = make(map[string]struct{})
+		var razor = make(map[string]struct{})
+		for _, j := range namesList {
+
+			summTotalSpC[n] += i.TotalSpc[j]
+			summUniqueSpC[n] += i.UniqueSpc[j]
+			summURazorSpC[n] += i.UrazorSpc[j]
+
+			for k := range i.TotalPeptides[j] {
+				peptides[k] = struct{}{}
+			}
+
+			for k := range i.UniquePeptides[j] {
+				unique[k] = struct{}{}
+			}
+
+			for k := range i.UrazorPeptides[j] {
+				razor[k] = struct{}{}
+			}
+		}
+
+		totalPeptides[n] = len(peptides)
+		uniquePeptides[n] = len(unique)
+		razorPeptides[n] = len(razor)
+	}
+
+	t := rep.NewTable("combined_gene", len(evidences))
+
+	t.Text("Gene", func(n int) string { return evidences[n].GeneName })
+	t.Text("Organism", func(n int) string { return evidences[n].Organism })
+	t.Text("Description", func(n int) string { return evidences[n].Description })
+	t.Decimal("Gene Probability", "%.4f", func(n int) float64 { return evidences[n].Probability })
+	t.Decimal("Top Peptide Probability", "%.4f", func(n int) float64 { return evidences[n].TopPepProb })
+	t.Integer("Combined Total Peptides", func(n int) int { return totalPeptides[n] })
+	t.Integer("Combined Unique Peptides", func(n int) int { return uniquePeptides[n] })
+	t.Integer("Combined Razor Peptides", func(n int) int { return razorPeptides[n] })
+	t.Integer("Combined Spectral Count", func(n int) int { return summURazorSpC[n] })
+	t.Integer("Combined Unique Spectral Count", func(n int) int { return summUniqueSpC[n] })
+	t.Integer("Combined Total Spectral Count", func(n int) int { return summTotalSpC[n] })
+
+	// Add Unique+Razor SPC
+	for _, i := range namesList {
+		i := i
+		t.Integer(fmt.Sprintf("%s Spectral Count", i), func(n int) int { return evidences[n].UrazorSpc[i] })
+	}
+
+	// Add Unique SPC
+	for _, i := range namesList {
+		i := i
+		t.Integer(fmt.Sprintf("%s Unique Spectral Count", i), func(n int) int { return evidences[n].UniqueSpc[i] }).Hide(!full)
+	}
+
+	// Add Total SPC
+	for _, i := range namesList {
+		i := i
+		t.Integer(fmt.Sprintf("%s Total Spectral Count", i), func(n int) int { return evidences[n].TotalSpc[i] }).Hide(!full)
+	}
+
+	// Add Unique+Razor Intensity
+	for _, i := range namesList {
+		i := i
+		t.Decimal(fmt.Sprintf("%s Intensity", i), "%6.f", func(n int) float64 { return evidences[n].UrazorIntensity[i] })
+	}
+
+	// Add Unique Intensity
+	for _, i := range namesList {
+		i := i
+		t.Decimal(fmt.Sprintf("%s Unique Intensity", i), "%6.f", func(n int) float64 { return evidences[n].UniqueIntensity[i] }).Hide(!full)
+	}
+
+	// Add Total Intensity
+	for _, i := range namesList {
+		i := i
+		t.Decimal(fmt.Sprintf("%s Total Intensity", i), "%6.f", func(n int) float64 { return evidences[n].TotalIntensity[i] }).Hide(!full)
+	}
+
+	t.Text("Proteins", func(n int) string {
+		var proteins []string
+		for j := range evidences[n].Proteins {
+			proteins = append(proteins, j)
+		}
+		sort.Strings(proteins)
+		return strings.Join(proteins, ", ")
+	})
+
+	return saveCombinedTable(session, t)
+}
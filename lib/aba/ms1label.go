@@ -0,0 +1,58 @@
+package aba
+
+import (
+	"fmt"
+	"math"
+
+	"philosopher/lib/rep"
+)
+
+// ms1LabelReference returns the MS1 labels with the largest channel set
+func ms1LabelReference(labels []rep.MS1Labels) *rep.MS1Labels {
+
+	var ref *rep.MS1Labels
+	for i := range labels {
+		if len(labels[i].Channels) > 1 && (ref == nil || len(labels[i].Channels) > len(ref.Channels)) {
+			ref = &labels[i]
+		}
+	}
+
+	return ref
+}
+
+// ms1RatioFields registers the partner ratios of a data set, missing ratios are printed as NA
+func ms1RatioFields(t *rep.Table, name string, ref *rep.MS1Labels, labels func(n int) map[string]rep.MS1Labels) {
+
+	// ratio returns the labels of a row when the partner ratio was measured
+	ratio := func(n, c int) (rep.MS1Labels, bool) {
+		l, ok := labels(n)[name]
+		return l, ok && c < len(l.Ratios) && l.Ratios[c] > 0
+	}
+
+	for c, i := range ref.Channels[1:] {
+
+		c := c
+		quantField(t, fmt.Sprintf("%s %s/%s Ratio", name, i, ref.Channels[0]), "%.4f", func(n int) float64 {
+			if l, ok := ratio(n, c); ok {
+				return l.Ratios[c]
+			}
+			return math.NaN()
+		})
+
+		t.Decimal(fmt.Sprintf("%s %s/%s Variability [%%]", name, i, ref.Channels[0]), "%.2f", func(n int) float64 {
+			if l, ok := ratio(n, c); ok && c < len(l.Variability) {
+				return l.Variability[c]
+			}
+			return 0
+		})
+	}
+
+	if ref.Scheme == rep.LabelPSILAC {
+		quantField(t, fmt.Sprintf("%s Heavy Fraction", name), "%.4f", func(n int) float64 {
+			if l, ok := labels(n)[name]; ok && l.HeavyFraction > 0 {
+				return l.HeavyFraction
+			}
+			return math.NaN()
+		})
+	}
+}
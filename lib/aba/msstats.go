@@ -0,0 +1,21 @@
+package aba
+
+import (
+	"philosopher/lib/met"
+	"philosopher/lib/rep"
+)
+
+// msstatsAbacus writes the MSstats or MSstatsTMT input of all data sets following the experimental design
+func msstatsAbacus(m met.Data, args []string) {
+
+	design := rep.ReadDesign(m.Abacus.Design, m.Abacus.Labels)
+	export := rep.NewMSstatsExport(design)
+
+	for _, i := range args {
+		var psm rep.PSMEvidenceList
+		rep.RestorePSMWithPath(&psm, i)
+		export.Add(psm, false)
+	}
+
+	export.Write(design.ExportName())
+}
@@ -0,0 +1,22 @@
+package aba
+
+import (
+	"fmt"
+
+	"philosopher/lib/met"
+	"philosopher/lib/msg"
+	"philosopher/lib/rep"
+)
+
+// saveParquetTable writes a combined table as a Parquet file in the working directory when requested, the channel
+// abundances are written in the long format when requested
+func saveParquetTable(a met.Abacus, t *rep.Table) {
+
+	if !a.Parquet {
+		return
+	}
+
+	if e := t.WriteParquet(t.Name+".parquet", a.Long); e != nil {
+		msg.WriteFile(fmt.Errorf("cannot create the Parquet table %s.parquet, %s", t.Name, e), "fatal")
+	}
+}
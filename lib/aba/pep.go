@@ -4,7 +4,6 @@ package aba
 import (
 	"errors"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -17,6 +16,7 @@ import (
 	"philosopher/lib/id"
 	"philosopher/lib/met"
 	"philosopher/lib/msg"
+	"philosopher/lib/qua"
 	"philosopher/lib/rep"
 	"philosopher/lib/sys"
 
@@ -98,7 +98,12 @@ func peptideLevelAbacus(m met.Data, args []string) {
 
 	os.Chdir(local)
 
-	savePeptideAbacusResult(m.Temp, evidences, datasets, names, m.Abacus.Unique, false, labelList)
+	if len(m.Abacus.Impute) > 0 {
+		logrus.Info("Imputing missing values")
+		evidences = imputePeptideIntensities(m.Temp, evidences, names, imputation(m.Abacus))
+	}
+
+	saveParquetTable(m.Abacus, savePeptideAbacusResult(m.Temp, evidences, datasets, names, m.Abacus.Unique, false, labelList))
 
 }
 
@@ -139,6 +144,7 @@ func collectPeptideDatafromExperiments(datasets map[string]rep.PSMEvidenceList,
 			var e rep.CombinedPeptideEvidence
 			e.Spc = make(map[string]int)
 			e.Intensity = make(map[string]float64)
+			e.MS1Labels = make(map[string]rep.MS1Labels)
 			e.AssignedMassDiffs = make(map[string]uint8)
 			e.ChargeStates = make(map[uint8]uint8)
 
@@ -167,6 +173,7 @@ func SummarizeAttributes(evidences rep.CombinedPeptideEvidenceList, datasets map
 
 		SpcMap := make(map[string]int)
 		IntMap := make(map[string]float64)
+		MS1Map := make(map[string]rep.MS1Labels)
 		ModsMap := make(map[string][]string)
 
 		protIDMap := make(map[string]string)
@@ -185,6 +192,9 @@ func SummarizeAttributes(evidences rep.CombinedPeptideEvidenceList, datasets map
 
 			SpcMap[j.Sequence] = j.Spc
 			IntMap[j.Sequence] = j.Intensity
+			if j.MS1Labels != nil {
+				MS1Map[j.Sequence] = *j.MS1Labels
+			}
 
 			protIDMap[j.Sequence] = j.ProteinID
 			protMap[j.Sequence] = j.Protein
@@ -211,6 +221,10 @@ func SummarizeAttributes(evidences rep.CombinedPeptideEvidenceList, datasets map
 			if ok {
 				evidences[i].Intensity[k] = it
 			}
+			l, ok := MS1Map[evidences[i].Sequence]
+			if ok {
+				evidences[i].MS1Labels[k] = l
+			}
 			m, ok := ModsMap[evidences[i].Sequence]
 			if ok {
 				for _, l := range m {
@@ -253,77 +267,93 @@ func SummarizeAttributes(evidences rep.CombinedPeptideEvidenceList, datasets map
 	return evidences
 }
 
-// savePeptideAbacusResult creates a single report using 1 or more philosopher result files
-func savePeptideAbacusResult(session string, evidences rep.CombinedPeptideEvidenceList, datasets map[string]rep.PSMEvidenceList, namesList []string, uniqueOnly, hasTMT bool, labelsList []DataSetLabelNames) {
+// imputePeptideIntensities imputes the peptide intensities and writes the imputed cells to the mask file
+func imputePeptideIntensities(session string, evidences rep.CombinedPeptideEvidenceList, namesList []string, p qua.Imputation) rep.CombinedPeptideEvidenceList {
+
+	sort.Sort(evidences)
 
-	// create result file
-	output := fmt.Sprintf("%s%scombined_peptide.tsv", session, string(filepath.Separator))
+	mask := imputeColumns(p, len(evidences),
+		func(i int) []float64 {
+			var v = make([]float64, len(namesList))
+			for j, k := range namesList {
+				v[j] = evidences[i].Intensity[k]
+			}
+			return v
+		},
+		func(i int, v []float64) {
+			for j, k := range namesList {
+				evidences[i].Intensity[k] = v[j]
+			}
+		})
 
-	// create result file
-	file, e := os.Create(output)
-	if e != nil {
-		msg.WriteFile(e, "error")
+	var keys []string
+	for _, i := range evidences {
+		keys = append(keys, fmt.Sprintf("%s\t%s", i.Sequence, i.ProteinID))
 	}
-	defer file.Close()
-
-	line := "Sequence\tCharge States\tProbability\tAssigned Modifications\tGene\tProtein\tProtein ID\tProtein Description\t"
 
+	var columns []string
 	for _, i := range namesList {
-		line += fmt.Sprintf("%s Spectral Count\t", i)
-		line += fmt.Sprintf("%s Intensity\t", i)
+		columns = append(columns, fmt.Sprintf("%s Intensity", i))
 	}
 
-	line += "\n"
-	_, e = io.WriteString(file, line)
-	if e != nil {
-		msg.WriteToFile(e, "fatal")
-	}
+	qua.WriteImputationMask(session, "combined_peptide_mask.tsv", []string{"Sequence", "Protein ID"}, keys, columns, mask)
+
+	return evidences
+}
+
+// savePeptideAbacusResult creates a single report using 1 or more philosopher result files
+func savePeptideAbacusResult(session string, evidences rep.CombinedPeptideEvidenceList, datasets map[string]rep.PSMEvidenceList, namesList []string, uniqueOnly, hasTMT bool, labelsList []DataSetLabelNames) *rep.Table {
 
 	// organize by group number
 	sort.Sort(evidences)
 
-	for _, i := range evidences {
-
-		var line string
+	t := rep.NewTable("combined_peptide", len(evidences))
 
-		line += fmt.Sprintf("%s\t", i.Sequence)
+	t.Text("Sequence", func(n int) string { return evidences[n].Sequence })
 
+	t.Text("Charge States", func(n int) string {
 		var c []string
-		for j := range i.ChargeStates {
+		for j := range evidences[n].ChargeStates {
 			c = append(c, strconv.Itoa(int(j)))
 		}
-		line += fmt.Sprintf("%s\t", strings.Join(c, ","))
+		return strings.Join(c, ",")
+	})
 
-		line += fmt.Sprintf("%f\t", i.BestPSM)
+	t.Decimal("Probability", "%f", func(n int) float64 { return evidences[n].BestPSM })
 
+	t.Text("Assigned Modifications", func(n int) string {
 		var m []string
-		for j := range i.AssignedMassDiffs {
+		for j := range evidences[n].AssignedMassDiffs {
 			m = append(m, j)
 		}
 		sort.Strings(m)
-		line += fmt.Sprintf("%v\t", strings.Join(m, ","))
-
-		line += fmt.Sprintf("%s\t", i.Gene)
+		return strings.Join(m, ",")
+	})
 
-		line += fmt.Sprintf("%s\t", i.Protein)
+	t.Text("Gene", func(n int) string { return evidences[n].Gene })
+	t.Text("Protein", func(n int) string { return evidences[n].Protein })
+	t.Text("Protein ID", func(n int) string { return evidences[n].ProteinID })
+	t.Text("Protein Description", func(n int) string { return evidences[n].ProteinDescription })
 
-		line += fmt.Sprintf("%s\t", i.ProteinID)
-
-		line += fmt.Sprintf("%s\t", i.ProteinDescription)
+	for _, i := range namesList {
+		i := i
+		t.Integer(fmt.Sprintf("%s Spectral Count", i), func(n int) int { return evidences[n].Spc[i] })
+		quantField(t, fmt.Sprintf("%s Intensity", i), "%.4f", func(n int) float64 { return evidences[n].Intensity[i] })
+	}
 
-		for _, j := range namesList {
-			line += fmt.Sprintf("%d\t%.4f\t", i.Spc[j], i.Intensity[j])
+	// MS1 labeled partner ratios
+	var ms1Labels []rep.MS1Labels
+	for _, i := range evidences {
+		for _, j := range i.MS1Labels {
+			ms1Labels = append(ms1Labels, j)
 		}
+	}
 
-		line += "\n"
-		_, e = io.WriteString(file, line)
-		if e != nil {
-			msg.WriteToFile(e, "fatal")
+	if ms1Ref := ms1LabelReference(ms1Labels); ms1Ref != nil {
+		for _, i := range namesList {
+			ms1RatioFields(t, i, ms1Ref, func(n int) map[string]rep.MS1Labels { return evidences[n].MS1Labels })
 		}
-
 	}
 
-	// copy to work directory
-	sys.CopyFile(output, filepath.Base(output))
-
+	return saveCombinedTable(session, t)
 }
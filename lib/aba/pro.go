@@ -19,12 +19,41 @@ import (
 	"philosopher/lib/fil"
 	"philosopher/lib/id"
 	"philosopher/lib/met"
+	"philosopher/lib/qua"
 	"philosopher/lib/rep"
 	"philosopher/lib/sys"
 
 	"github.com/sirupsen/logrus"
 )
 
+// abundanceChannels returns the channel names reported on the combined protein report, taken from the first labeled protein
+func abundanceChannels(combined rep.CombinedProteinEvidenceList) []string {
+
+	for _, i := range combined {
+		for _, j := range i.URazorLabels {
+			if len(j.Channels) > 0 {
+				return j.Names()
+			}
+		}
+		for _, j := range i.UniqueLabels {
+			if len(j.Channels) > 0 {
+				return j.Names()
+			}
+		}
+	}
+
+	return nil
+}
+
+// channelIntensities returns the intensities of the reported channels, missing channels are zero
+func channelIntensities(l iso.Labels, channels int) []float64 {
+
+	var v = make([]float64, channels)
+	copy(v, l.Intensities())
+
+	return v
+}
+
 // Create protein combined report
 func proteinLevelAbacus(m met.Data, args []string) {
 
@@ -34,6 +63,7 @@ func proteinLevelAbacus(m met.Data, args []string) {
 	var datasets = make(map[string]rep.Evidence)
 
 	var labelList []DataSetLabelNames
+	var references = make(map[string][]string)
 
 	// restore database
 	database = dat.Base{}
@@ -56,6 +86,12 @@ func proteinLevelAbacus(m met.Data, args []string) {
 		labels.LabelName = make(map[string]string)
 
 		// collect interact full file names
+		// collect project names
+		prjName := i
+		if strings.Contains(prjName, string(filepath.Separator)) {
+			prjName = strings.Replace(filepath.Base(prjName), string(filepath.Separator), "", -1)
+		}
+
 		files, _ := ioutil.ReadDir(i)
 		for _, f := range files {
 			if strings.Contains(f.Name(), "annotation") {
@@ -65,13 +101,12 @@ func proteinLevelAbacus(m met.Data, args []string) {
 				if len(m.Quantify.Annot) > 0 {
 					labels.LabelName = getLabelNames(annot)
 				}
-			}
-		}
 
-		// collect project names
-		prjName := i
-		if strings.Contains(prjName, string(filepath.Separator)) {
-			prjName = strings.Replace(filepath.Base(prjName), string(filepath.Separator), "", -1)
+				// the bridge channels are declared on the annotation files
+				if m.Abacus.Labels {
+					references[prjName] = getReferenceChannels(annot)
+				}
+			}
 		}
 
 		labelList = append(labelList, labels)
@@ -120,15 +155,39 @@ func proteinLevelAbacus(m met.Data, args []string) {
 	logrus.Info("Processing intensities")
 	evidences = sumProteinIntensities(evidences, datasets)
 
+	logrus.Info("Calculating MaxLFQ intensities")
+	evidences = getProteinMaxLFQIntensities(evidences, datasets, names)
+
+	if len(m.Abacus.Norm) > 0 && m.Abacus.Norm != NormIRS {
+		logrus.Info("Normalizing intensities")
+		evidences = normalizeProteinIntensities(m.Temp, evidences, names, m.Abacus.Norm, m.Abacus.NormRef)
+	}
+
 	// collect TMT labels
 	if m.Abacus.Labels {
 		evidences = getProteinLabelIntensities(evidences, datasets, m.Abacus.Tag)
+
+		if m.Abacus.Norm == NormIRS {
+			logrus.Info("Applying the internal reference scaling")
+			evidences = irsNormalization(evidences, names, references)
+		}
+
+		if hasReferences(references) {
+			logrus.Info("Calculating the reference channel ratios")
+			saveParquetTable(m.Abacus, savePSMRatios(m.Temp, datasets, names, references))
+			saveParquetTable(m.Abacus, saveProteinRatios(m.Temp, evidences, names, references, m.Abacus.Unique))
+		}
+	}
+
+	if len(m.Abacus.Impute) > 0 {
+		logrus.Info("Imputing missing values")
+		evidences = imputeProteinIntensities(m.Temp, evidences, names, imputation(m.Abacus), m.Abacus.Labels, m.Abacus.Unique, m.Abacus.Full)
 	}
 
 	if m.Abacus.Labels {
-		saveProteinAbacusResult(m.Temp, evidences, datasets, names, m.Abacus.Unique, true, m.Abacus.Full, labelList)
+		saveParquetTable(m.Abacus, saveProteinAbacusResult(m.Temp, evidences, datasets, names, m.Abacus.Unique, true, m.Abacus.Full, labelList))
 	} else {
-		saveProteinAbacusResult(m.Temp, evidences, datasets, names, m.Abacus.Unique, false, m.Abacus.Full, labelList)
+		saveParquetTable(m.Abacus, saveProteinAbacusResult(m.Temp, evidences, datasets, names, m.Abacus.Unique, false, m.Abacus.Full, labelList))
 	}
 
 	if m.Abacus.Reprint {
@@ -188,10 +247,15 @@ func processProteinCombinedFile(a met.Abacus, database dat.Base) rep.CombinedPro
 				ce.TotalIntensity = make(map[string]float64)
 				ce.UniqueIntensity = make(map[string]float64)
 				ce.UrazorIntensity = make(map[string]float64)
+				ce.MaxLFQIntensity = make(map[string]float64)
+				ce.IBAQ = make(map[string]float64)
+				ce.RIBAQ = make(map[string]float64)
+				ce.CopyNumber = make(map[string]float64)
 
 				ce.TotalLabels = make(map[string]iso.Labels)
 				ce.UniqueLabels = make(map[string]iso.Labels)
 				ce.URazorLabels = make(map[string]iso.Labels)
+				ce.MS1Labels = make(map[string]rep.MS1Labels)
 
 				ce.SupportingSpectra = make(map[string]string)
 				ce.ProteinName = j.ProteinName
@@ -340,6 +404,12 @@ func sumProteinIntensities(combined rep.CombinedProteinEvidenceList, datasets ma
 					i.TotalIntensity[k] = v.Proteins[j].TotalIntensity
 					i.UniqueIntensity[k] = v.Proteins[j].UniqueIntensity
 					i.UrazorIntensity[k] = v.Proteins[j].URazorIntensity
+					i.IBAQ[k] = v.Proteins[j].IBAQ
+					i.RIBAQ[k] = v.Proteins[j].RIBAQ
+					i.CopyNumber[k] = v.Proteins[j].CopyNumber
+					if v.Proteins[j].MS1Labels != nil {
+						i.MS1Labels[k] = *v.Proteins[j].MS1Labels
+					}
 					break
 				}
 			}
@@ -350,8 +420,206 @@ func sumProteinIntensities(combined rep.CombinedProteinEvidenceList, datasets ma
 	return combined
 }
 
+// getProteinMaxLFQIntensities calculates the MaxLFQ intensities using the razor ions from each data set
+func getProteinMaxLFQIntensities(combined rep.CombinedProteinEvidenceList, datasets map[string]rep.Evidence, namesList []string) rep.CombinedProteinEvidenceList {
+
+	var ionMaps = make(map[string]map[id.IonFormType]float64)
+	var proteinMaps = make(map[string]map[string]int)
+	for k, v := range datasets {
+
+		ionMaps[k] = make(map[id.IonFormType]float64)
+		for _, i := range v.Ions {
+			ionMaps[k][i.IonForm()] = i.Intensity
+		}
+
+		proteinMaps[k] = make(map[string]int)
+		for i := range v.Proteins {
+			proteinMaps[k][v.Proteins[i].ProteinID] = i
+		}
+	}
+
+	for i := range combined {
+
+		var intensities = make(map[id.IonFormType]map[string]float64)
+
+		for k, v := range datasets {
+
+			j, ok := proteinMaps[k][combined[i].ProteinID]
+			if !ok {
+				continue
+			}
+
+			for ion, evi := range v.Proteins[j].TotalPeptideIons {
+				if !evi.IsURazor || ionMaps[k][ion] <= 0 {
+					continue
+				}
+				if _, ok := intensities[ion]; !ok {
+					intensities[ion] = make(map[string]float64)
+				}
+				intensities[ion][k] = ionMaps[k][ion]
+			}
+		}
+
+		combined[i].MaxLFQIntensity = qua.MaxLFQ(intensities, namesList)
+	}
+
+	return combined
+}
+
+// normalizeProteinIntensities normalizes the total, unique and razor intensities across the data sets,
+// the razor intensity distributions are written to the summary
+func normalizeProteinIntensities(session string, combined rep.CombinedProteinEvidenceList, namesList []string, method, referenceFile string) rep.CombinedProteinEvidenceList {
+
+	references := qua.ReadNormalizationReferences(referenceFile)
+
+	var reference = make([]bool, len(combined))
+	for i := range combined {
+		for _, j := range []string{combined[i].ProteinName, combined[i].ProteinID, combined[i].EntryName, combined[i].GeneNames} {
+			if _, ok := references[j]; ok && len(j) > 0 {
+				reference[i] = true
+			}
+		}
+	}
+
+	var before, after [][]float64
+	for _, level := range []func(*rep.CombinedProteinEvidence) map[string]float64{
+		func(p *rep.CombinedProteinEvidence) map[string]float64 { return p.UrazorIntensity },
+		func(p *rep.CombinedProteinEvidence) map[string]float64 { return p.UniqueIntensity },
+		func(p *rep.CombinedProteinEvidence) map[string]float64 { return p.TotalIntensity },
+	} {
+
+		var matrix = make([][]float64, len(combined))
+		for i := range combined {
+			matrix[i] = make([]float64, len(namesList))
+			for j, k := range namesList {
+				matrix[i][j] = level(&combined[i])[k]
+			}
+		}
+
+		normalized := qua.NormalizeSamples(matrix, method, reference)
+
+		for i := range combined {
+			for j, k := range namesList {
+				level(&combined[i])[k] = normalized[i][j]
+			}
+		}
+
+		if before == nil {
+			before, after = matrix, normalized
+		}
+	}
+
+	qua.WriteNormalizationSummary(session, "combined_normalization_summary.tsv", namesList, before, after)
+
+	return combined
+}
+
+// imputeProteinIntensities imputes the intensity and label blocks of the combined protein report, the imputed
+// cells of the reported columns are written to the mask file
+func imputeProteinIntensities(session string, combined rep.CombinedProteinEvidenceList, namesList []string, p qua.Imputation, hasTMT, uniqueOnly, full bool) rep.CombinedProteinEvidenceList {
+
+	sort.Sort(combined)
+
+	var rows []int
+	var keys []string
+	for i := range combined {
+		if len(combined[i].TotalSpc) > 0 {
+			rows = append(rows, i)
+			keys = append(keys, fmt.Sprintf("%s\t%s", combined[i].ProteinName, combined[i].ProteinID))
+		}
+	}
+
+	var intensityBlock = func(level func(*rep.CombinedProteinEvidence) map[string]float64) [][]bool {
+		return imputeColumns(p, len(rows),
+			func(i int) []float64 {
+				var v = make([]float64, len(namesList))
+				for j, k := range namesList {
+					v[j] = level(&combined[rows[i]])[k]
+				}
+				return v
+			},
+			func(i int, v []float64) {
+				for j, k := range namesList {
+					level(&combined[rows[i]])[k] = v[j]
+				}
+			})
+	}
+
+	channels := abundanceChannels(combined)
+
+	var labelBlock = func(level func(*rep.CombinedProteinEvidence) map[string]iso.Labels) [][]bool {
+		return imputeColumns(p, len(rows),
+			func(i int) []float64 {
+				var v []float64
+				for _, k := range namesList {
+					v = append(v, channelIntensities(level(&combined[rows[i]])[k], len(channels))...)
+				}
+				return v
+			},
+			func(i int, v []float64) {
+				for j, k := range namesList {
+					labels := level(&combined[rows[i]])[k]
+					if len(labels.Channels) == 0 {
+						continue
+					}
+					labels = labels.Copy()
+					labels.SetIntensities(v[j*len(channels) : (j+1)*len(channels)])
+					level(&combined[rows[i]])[k] = labels
+				}
+			})
+	}
+
+	var mask [][]bool
+	var columns []string
+
+	mask = appendMask(mask, intensityBlock(func(e *rep.CombinedProteinEvidence) map[string]float64 { return e.UrazorIntensity }))
+	for _, i := range namesList {
+		columns = append(columns, fmt.Sprintf("%s Intensity", i))
+	}
+
+	unique := intensityBlock(func(e *rep.CombinedProteinEvidence) map[string]float64 { return e.UniqueIntensity })
+	total := intensityBlock(func(e *rep.CombinedProteinEvidence) map[string]float64 { return e.TotalIntensity })
+	if full {
+		mask = appendMask(mask, unique)
+		for _, i := range namesList {
+			columns = append(columns, fmt.Sprintf("%s Unique Intensity", i))
+		}
+
+		mask = appendMask(mask, total)
+		for _, i := range namesList {
+			columns = append(columns, fmt.Sprintf("%s Total Intensity", i))
+		}
+	}
+
+	mask = appendMask(mask, intensityBlock(func(e *rep.CombinedProteinEvidence) map[string]float64 { return e.MaxLFQIntensity }))
+	for _, i := range namesList {
+		columns = append(columns, fmt.Sprintf("%s MaxLFQ Intensity", i))
+	}
+
+	if hasTMT {
+		uniqueLabels := labelBlock(func(e *rep.CombinedProteinEvidence) map[string]iso.Labels { return e.UniqueLabels })
+		razorLabels := labelBlock(func(e *rep.CombinedProteinEvidence) map[string]iso.Labels { return e.URazorLabels })
+
+		if uniqueOnly {
+			mask = appendMask(mask, uniqueLabels)
+		} else {
+			mask = appendMask(mask, razorLabels)
+		}
+
+		for _, i := range namesList {
+			for _, j := range channels {
+				columns = append(columns, fmt.Sprintf("%s %s Abundance", i, j))
+			}
+		}
+	}
+
+	qua.WriteImputationMask(session, "combined_protein_mask.tsv", []string{"Protein", "Protein ID"}, keys, columns, mask)
+
+	return combined
+}
+
 // saveProteinAbacusResult creates a single report using 1 or more philosopher result files
-func saveProteinAbacusResult(session string, evidences rep.CombinedProteinEvidenceList, datasets map[string]rep.Evidence, namesList []string, uniqueOnly, hasTMT, full bool, labelsList []DataSetLabelNames) {
+func saveProteinAbacusResult(session string, evidences rep.CombinedProteinEvidenceList, datasets map[string]rep.Evidence, namesList []string, uniqueOnly, hasTMT, full bool, labelsList []DataSetLabelNames) *rep.Table {
 
 	var summTotalSpC = make(map[string]int)
 	var summUniqueSpC = make(map[string]int)
@@ -392,211 +660,150 @@ func saveProteinAbacusResult(session string, evidences rep.CombinedProteinEviden
 		razorPeptides[i.ProteinID] = uti.RemoveDuplicateStrings(razorPeptides[i.ProteinID])
 	}
 
-	// create result file
-	output := fmt.Sprintf("%s%scombined_protein.tsv", session, string(filepath.Separator))
-
-	// create result file
-	file, e := os.Create(output)
-	if e != nil {
-		msg.WriteFile(e, "error")
+	// the proteins without spectral counts are left out
+	var rows rep.CombinedProteinEvidenceList
+	for _, i := range evidences {
+		if len(i.TotalSpc) > 0 {
+			rows = append(rows, i)
+		}
 	}
-	defer file.Close()
 
-	header := "Protein\tProtein ID\tEntry Name\tGene\tProtein Length\tOrganism\tProtein Existence\tDescription\tProtein Probability\tTop Peptide Probability\tCombined Total Peptides\tCombined Spectral Count\tCombined Unique Spectral Count\tCombined Total Spectral Count"
+	t := rep.NewTable("combined_protein", len(rows))
+
+	t.Text("Protein", func(n int) string { return rows[n].ProteinName })
+	t.Text("Protein ID", func(n int) string { return rows[n].ProteinID })
+	t.Text("Entry Name", func(n int) string { return rows[n].EntryName })
+	t.Text("Gene", func(n int) string { return rows[n].GeneNames })
+	t.Integer("Protein Length", func(n int) int { return rows[n].Length })
+	t.Text("Organism", func(n int) string { return rows[n].Organism })
+	t.Text("Protein Existence", func(n int) string { return rows[n].ProteinExistence })
+	t.Text("Description", func(n int) string { return rows[n].Description })
+	t.Decimal("Protein Probability", "%.4f", func(n int) float64 { return rows[n].ProteinProbability })
+	t.Decimal("Top Peptide Probability", "%.4f", func(n int) float64 { return rows[n].TopPepProb })
+	t.Integer("Combined Total Peptides", func(n int) int { return len(totalPeptides[rows[n].ProteinID]) })
+	t.Integer("Combined Spectral Count", func(n int) int { return summURazorSpC[rows[n].ProteinID] })
+	t.Integer("Combined Unique Spectral Count", func(n int) int { return summUniqueSpC[rows[n].ProteinID] })
+	t.Integer("Combined Total Spectral Count", func(n int) int { return summTotalSpC[rows[n].ProteinID] })
 
 	// Add Unique+Razor SPC
 	for _, i := range namesList {
-		header += fmt.Sprintf("\t%s Spectral Count", i)
+		i := i
+		t.Integer(fmt.Sprintf("%s Spectral Count", i), func(n int) int { return rows[n].UrazorSpc[i] })
 	}
 
 	// Add Unique SPC
-	if full {
-		for _, i := range namesList {
-			header += fmt.Sprintf("\t%s Unique Spectral Count", i)
-		}
+	for _, i := range namesList {
+		i := i
+		t.Integer(fmt.Sprintf("%s Unique Spectral Count", i), func(n int) int { return rows[n].UniqueSpc[i] }).Hide(!full)
 	}
 
 	// Add Total SPC
-	if full {
-		for _, i := range namesList {
-			header += fmt.Sprintf("\t%s Total Spectral Count", i)
-		}
+	for _, i := range namesList {
+		i := i
+		t.Integer(fmt.Sprintf("%s Total Spectral Count", i), func(n int) int { return rows[n].TotalSpc[i] }).Hide(!full)
 	}
 
 	// Add Unique+Razor Intensity
 	for _, i := range namesList {
-		header += fmt.Sprintf("\t%s Intensity", i)
+		i := i
+		quantField(t, fmt.Sprintf("%s Intensity", i), "%6.f", func(n int) float64 { return rows[n].UrazorIntensity[i] })
 	}
 
 	// Add Unique Intensity
-	if full {
-		for _, i := range namesList {
-			header += fmt.Sprintf("\t%s Unique Intensity", i)
-		}
+	for _, i := range namesList {
+		i := i
+		quantField(t, fmt.Sprintf("%s Unique Intensity", i), "%6.f", func(n int) float64 { return rows[n].UniqueIntensity[i] }).Hide(!full)
 	}
 
 	// Add Total Intensity
-	if full {
-		for _, i := range namesList {
-			header += fmt.Sprintf("\t%s Total Intensity", i)
-		}
+	for _, i := range namesList {
+		i := i
+		quantField(t, fmt.Sprintf("%s Total Intensity", i), "%6.f", func(n int) float64 { return rows[n].TotalIntensity[i] }).Hide(!full)
 	}
 
-	if hasTMT {
-		for _, i := range namesList {
-			header += fmt.Sprintf("\t%s 126 Abundance", i)
-			header += fmt.Sprintf("\t%s 127N Abundance", i)
-			header += fmt.Sprintf("\t%s 127C Abundance", i)
-			header += fmt.Sprintf("\t%s 128N Abundance", i)
-			header += fmt.Sprintf("\t%s 128C Abundance", i)
-			header += fmt.Sprintf("\t%s 129N Abundance", i)
-			header += fmt.Sprintf("\t%s 129C Abundance", i)
-			header += fmt.Sprintf("\t%s 130N Abundance", i)
-			header += fmt.Sprintf("\t%s 130C Abundance", i)
-			header += fmt.Sprintf("\t%s 131N Abundance", i)
-
-			for _, j := range labelsList {
-				if j.Name == i {
-					for k, v := range j.LabelName {
-						before := fmt.Sprintf("%s %s Abundance", i, k)
-						after := fmt.Sprintf("%s Abundance", v)
-						header = strings.Replace(header, before, after, -1)
-					}
-				}
+	// Add MaxLFQ Intensity when the label-free intensities were quantified
+	var hasMaxLFQ bool
+	for _, i := range evidences {
+		for _, j := range i.MaxLFQIntensity {
+			if j > 0 {
+				hasMaxLFQ = true
 			}
 		}
-
 	}
 
-	header += "\tIndistinguishable Proteins"
-
-	header += "\n"
-	_, e = io.WriteString(file, header)
-	if e != nil {
-		msg.WriteToFile(e, "fatal")
+	for _, i := range namesList {
+		i := i
+		quantField(t, fmt.Sprintf("%s MaxLFQ Intensity", i), "%6.f", func(n int) float64 { return rows[n].MaxLFQIntensity[i] }).Hide(!hasMaxLFQ)
 	}
 
+	// Add absolute quantification when available
+	var hasIBAQ bool
 	for _, i := range evidences {
-
-		if len(i.TotalSpc) > 0 {
-
-			var line string
-
-			line += fmt.Sprintf("%s\t", i.ProteinName)
-
-			line += fmt.Sprintf("%s\t", i.ProteinID)
-
-			line += fmt.Sprintf("%s\t", i.EntryName)
-
-			line += fmt.Sprintf("%s\t", i.GeneNames)
-
-			line += fmt.Sprintf("%d\t", i.Length)
-
-			line += fmt.Sprintf("%s\t", i.Organism)
-
-			line += fmt.Sprintf("%s\t", i.ProteinExistence)
-
-			line += fmt.Sprintf("%s\t", i.Description)
-
-			line += fmt.Sprintf("%.4f\t", i.ProteinProbability)
-
-			line += fmt.Sprintf("%.4f\t", i.TopPepProb)
-
-			line += fmt.Sprintf("%d\t", len(totalPeptides[i.ProteinID]))
-
-			line += fmt.Sprintf("%d\t", summURazorSpC[i.ProteinID])
-
-			line += fmt.Sprintf("%d\t", summUniqueSpC[i.ProteinID])
-
-			line += fmt.Sprintf("%d\t", summTotalSpC[i.ProteinID])
-
-			// Add Unique+Razor SPC
-			for _, j := range namesList {
-				line += fmt.Sprintf("%d\t", i.UrazorSpc[j])
+		for _, j := range i.IBAQ {
+			if j > 0 {
+				hasIBAQ = true
 			}
+		}
+	}
 
-			// Add Unique SPC
-			if full {
-				for _, j := range namesList {
-					line += fmt.Sprintf("%d\t", i.UniqueSpc[j])
-				}
-			}
+	for _, i := range namesList {
+		i := i
+		t.Decimal(fmt.Sprintf("%s iBAQ", i), "%.4f", func(n int) float64 { return rows[n].IBAQ[i] }).Hide(!hasIBAQ)
+	}
+	for _, i := range namesList {
+		i := i
+		t.Decimal(fmt.Sprintf("%s riBAQ", i), "%.8f", func(n int) float64 { return rows[n].RIBAQ[i] }).Hide(!hasIBAQ)
+	}
+	for _, i := range namesList {
+		i := i
+		t.Decimal(fmt.Sprintf("%s Copy Number", i), "%.0f", func(n int) float64 { return rows[n].CopyNumber[i] }).Hide(!hasIBAQ)
+	}
 
-			// Add Total SPC
-			if full {
-				for _, j := range namesList {
-					line += fmt.Sprintf("%d\t", i.TotalSpc[j])
-				}
-			}
+	if hasTMT {
 
-			// Add Unique+Razor Int
-			for _, j := range namesList {
-				line += fmt.Sprintf("%6.f\t", i.UrazorIntensity[j])
-			}
+		channels := abundanceChannels(evidences)
 
-			// Add Unique Int
-			if full {
-				for _, j := range namesList {
-					line += fmt.Sprintf("%6.f\t", i.UniqueIntensity[j])
-				}
-			}
+		var level = func(p rep.CombinedProteinEvidence) map[string]iso.Labels { return p.URazorLabels }
+		if uniqueOnly {
+			level = func(p rep.CombinedProteinEvidence) map[string]iso.Labels { return p.UniqueLabels }
+		}
 
-			// Add Total Int
-			if full {
-				for _, j := range namesList {
-					line += fmt.Sprintf("%6.f\t", i.TotalIntensity[j])
-				}
-			}
+		for _, i := range namesList {
+			for c, j := range channels {
 
-			if hasTMT {
-				if uniqueOnly {
-					for _, j := range namesList {
-						line += fmt.Sprintf("%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t",
-							i.UniqueLabels[j].Channel1.Intensity,
-							i.UniqueLabels[j].Channel2.Intensity,
-							i.UniqueLabels[j].Channel3.Intensity,
-							i.UniqueLabels[j].Channel4.Intensity,
-							i.UniqueLabels[j].Channel5.Intensity,
-							i.UniqueLabels[j].Channel6.Intensity,
-							i.UniqueLabels[j].Channel7.Intensity,
-							i.UniqueLabels[j].Channel8.Intensity,
-							i.UniqueLabels[j].Channel9.Intensity,
-							i.UniqueLabels[j].Channel10.Intensity,
-						)
-					}
-				} else {
-					for _, j := range namesList {
-						line += fmt.Sprintf("%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t",
-							i.URazorLabels[j].Channel1.Intensity,
-							i.URazorLabels[j].Channel2.Intensity,
-							i.URazorLabels[j].Channel3.Intensity,
-							i.URazorLabels[j].Channel4.Intensity,
-							i.URazorLabels[j].Channel5.Intensity,
-							i.URazorLabels[j].Channel6.Intensity,
-							i.URazorLabels[j].Channel7.Intensity,
-							i.URazorLabels[j].Channel8.Intensity,
-							i.URazorLabels[j].Channel9.Intensity,
-							i.URazorLabels[j].Channel10.Intensity,
-						)
+				// the annotated channels are named after their samples
+				name := fmt.Sprintf("%s %s Abundance", i, j)
+				for _, k := range labelsList {
+					if v, ok := k.LabelName[j]; ok && k.Name == i {
+						name = fmt.Sprintf("%s Abundance", v)
 					}
 				}
-			}
-
-			ip := strings.Join(i.IndiProtein, ", ")
-			line += fmt.Sprintf("%s\t", ip)
 
-			line += "\n"
-			_, e := io.WriteString(file, line)
-			if e != nil {
-				msg.WriteToFile(e, "fatal")
+				i, c := i, c
+				quantField(t, name, "%.4f", func(n int) float64 {
+					return channelIntensities(level(rows[n])[i], len(channels))[c]
+				}).Channel(strings.TrimSuffix(name, " Abundance"))
 			}
+		}
+	}
 
+	// MS1 labeled partner ratios
+	var ms1Labels []rep.MS1Labels
+	for _, i := range evidences {
+		for _, j := range i.MS1Labels {
+			ms1Labels = append(ms1Labels, j)
 		}
 	}
 
-	// copy to work directory
-	sys.CopyFile(output, filepath.Base(output))
+	if ms1Ref := ms1LabelReference(ms1Labels); ms1Ref != nil {
+		for _, i := range namesList {
+			ms1RatioFields(t, i, ms1Ref, func(n int) map[string]rep.MS1Labels { return rows[n].MS1Labels })
+		}
+	}
+
+	t.Text("Indistinguishable Proteins", func(n int) string { return strings.Join(rows[n].IndiProtein, ", ") })
 
+	return saveCombinedTable(session, t)
 }
 
 // saveReprintSpCResults creates a single Spectral Count report using 1 or more philosopher result files using the Reprint format
@@ -701,7 +908,7 @@ func saveReprintIntResults(session string, evidences rep.CombinedProteinEvidence
 		line += fmt.Sprintf("%s\t%s\t", i.ProteinID, i.GeneNames)
 
 		for _, j := range namesList {
-			line += fmt.Sprintf("%f\t", i.UrazorIntensity[j])
+			line += formatQuant("%f\t", i.UrazorIntensity[j])
 		}
 
 		line += "\n"
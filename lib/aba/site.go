@@ -0,0 +1,127 @@
+// Package aba (Abacus), site level
+package aba
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"philosopher/lib/iso"
+	"philosopher/lib/met"
+	"philosopher/lib/rep"
+
+	"github.com/sirupsen/logrus"
+)
+
+// siteLevelAbacus combines the modified sites from all data sets
+func siteLevelAbacus(m met.Data, args []string) {
+
+	var names []string
+	var datasets = make(map[string]map[string]rep.SiteEvidence)
+	var combined = make(map[string]rep.SiteEvidence)
+
+	logrus.Info("Restoring site results")
+
+	for _, i := range args {
+
+		prjName := i
+		if strings.Contains(prjName, string(filepath.Separator)) {
+			prjName = strings.Replace(filepath.Base(prjName), string(filepath.Separator), "", -1)
+		}
+
+		var psm rep.PSMEvidenceList
+		rep.RestorePSMWithPath(&psm, i)
+
+		sites := make(map[string]rep.SiteEvidence)
+		for _, j := range rep.SiteLevel(psm, rep.SiteSequences(nil, i), false) {
+
+			sites[j.Key()] = j
+
+			// the combined site keeps the best localization across the data sets
+			if v, ok := combined[j.Key()]; !ok || j.Probability > v.Probability {
+				combined[j.Key()] = j
+			}
+		}
+
+		names = append(names, prjName)
+		datasets[prjName] = sites
+	}
+
+	sort.Strings(names)
+
+	var list rep.SiteEvidenceList
+	for _, v := range combined {
+		list = append(list, v)
+	}
+	sort.Sort(list)
+
+	saveParquetTable(m.Abacus, saveSiteAbacusResult(m.Temp, list, datasets, names, m.Abacus.Labels))
+}
+
+// siteChannels returns the channel names of a data set, custom names replace the channel names when available
+func siteChannels(sites map[string]rep.SiteEvidence) []iso.Channel {
+
+	for _, i := range sites {
+		if i.Labels != nil && len(i.Labels.Channels) > 0 {
+			return i.Labels.Channels
+		}
+	}
+
+	return nil
+}
+
+// saveSiteAbacusResult creates a single site report using 1 or more philosopher result files
+func saveSiteAbacusResult(session string, sites rep.SiteEvidenceList, datasets map[string]map[string]rep.SiteEvidence, namesList []string, hasTMT bool) *rep.Table {
+
+	t := rep.NewTable("combined_site", len(sites))
+
+	t.Text("Protein", func(n int) string { return sites[n].Protein })
+	t.Text("Protein ID", func(n int) string { return sites[n].ProteinID })
+	t.Text("Entry Name", func(n int) string { return sites[n].EntryName })
+	t.Text("Gene", func(n int) string { return sites[n].GeneName })
+	t.Integer("Position", func(n int) int { return sites[n].Position })
+	t.Text("Amino Acid", func(n int) string { return sites[n].AminoAcid })
+	t.Text("Modification", func(n int) string { return sites[n].Modification })
+	t.Text("Multiplicity", func(n int) string { return sites[n].MultiplicityName() })
+	t.Decimal("Localization Probability", "%.4f", func(n int) float64 { return sites[n].Probability })
+	t.Text("Class", func(n int) string { return sites[n].Class() })
+	t.Text("Sequence Window", func(n int) string { return sites[n].Window })
+
+	for _, i := range namesList {
+		i := i
+		t.Decimal(fmt.Sprintf("%s Localization Probability", i), "%.4f", func(n int) float64 { return datasets[i][sites[n].Key()].Probability })
+	}
+
+	for _, i := range namesList {
+		i := i
+		t.Integer(fmt.Sprintf("%s Spectral Count", i), func(n int) int { return datasets[i][sites[n].Key()].Spectra })
+	}
+
+	for _, i := range namesList {
+		i := i
+		t.Decimal(fmt.Sprintf("%s Intensity", i), "%.4f", func(n int) float64 { return datasets[i][sites[n].Key()].Intensity })
+	}
+
+	if hasTMT {
+		for _, i := range namesList {
+			for k, j := range siteChannels(datasets[i]) {
+
+				name := fmt.Sprintf("%s %s Abundance", i, j.Name)
+				if len(j.CustomName) > 0 {
+					name = fmt.Sprintf("%s Abundance", j.CustomName)
+				}
+
+				i, k := i, k
+				t.Decimal(name, "%.4f", func(n int) float64 {
+					if labels := datasets[i][sites[n].Key()].Labels; labels != nil && k < len(labels.Channels) {
+						return labels.Channels[k].Intensity
+					}
+					return 0
+				}).Channel(strings.TrimSuffix(name, " Abundance"))
+			}
+		}
+	}
+
+	return saveCombinedTable(session, t)
+}
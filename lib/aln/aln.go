@@ -0,0 +1,304 @@
+// Package aln (Alignment), retention time alignment between LC-MS/MS runs
+package aln
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"philosopher/lib/msg"
+	"philosopher/lib/sys"
+	"philosopher/lib/uti"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+)
+
+const (
+	// Loess is the local weighted regression method
+	Loess = "loess"
+	// Piecewise is the piecewise-linear method
+	Piecewise = "piecewise"
+
+	// minPoints is the minimum number of shared observations required for a fit
+	minPoints = 10
+	// gridSize is the number of points used to represent a LOESS curve
+	gridSize = 100
+	// loessSpan is the fraction of the observations used on each local regression
+	loessSpan = 0.3
+	// segments is the number of segments used by the piecewise-linear fit
+	segments = 10
+)
+
+// Model is a monotonic mapping between two retention time scales represented by a set of knots, the fitted
+// values are smoothed with an isotonic regression so the elution order is kept
+type Model struct {
+	Method string
+	X      []float64
+	Y      []float64
+}
+
+// Identity returns a model that does not change the retention times
+func Identity() Model {
+	return Model{Method: "identity"}
+}
+
+// Fit creates a non-linear mapping from x to y using the given method
+func Fit(x, y []float64, method string) (Model, error) {
+
+	if len(x) != len(y) {
+		return Identity(), errors.New("the alignment vectors have different sizes")
+	}
+
+	if len(x) < minPoints {
+		return Identity(), fmt.Errorf("not enough shared observations for the alignment, found %d and %d are required", len(x), minPoints)
+	}
+
+	xs, ys := sortPairs(x, y)
+
+	switch strings.ToLower(method) {
+	case Loess, "":
+		return fitLoess(xs, ys), nil
+	case Piecewise:
+		return fitPiecewise(xs, ys), nil
+	default:
+		return Identity(), fmt.Errorf("unknown alignment method: %s", method)
+	}
+}
+
+// Predict maps a retention time using linear interpolation between the knots, values outside the
+// fitted range are extrapolated using the closest segment
+func (m Model) Predict(x float64) float64 {
+
+	if len(m.X) == 0 {
+		return x
+	}
+
+	if len(m.X) == 1 {
+		return x + (m.Y[0] - m.X[0])
+	}
+
+	i := sort.SearchFloat64s(m.X, x)
+	if i == 0 {
+		i = 1
+	} else if i >= len(m.X) {
+		i = len(m.X) - 1
+	}
+
+	dx := m.X[i] - m.X[i-1]
+	if dx == 0 {
+		return m.Y[i]
+	}
+
+	return m.Y[i-1] + ((x - m.X[i-1]) * (m.Y[i] - m.Y[i-1]) / dx)
+}
+
+// Residuals returns the difference between the observed and the predicted values
+func (m Model) Residuals(x, y []float64) []float64 {
+
+	var r = make([]float64, len(x))
+	for i := range x {
+		r[i] = y[i] - m.Predict(x[i])
+	}
+
+	return r
+}
+
+// sortPairs returns copies of both vectors sorted by x
+func sortPairs(x, y []float64) ([]float64, []float64) {
+
+	idx := make([]int, len(x))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Slice(idx, func(i, j int) bool { return x[idx[i]] < x[idx[j]] })
+
+	xs := make([]float64, len(x))
+	ys := make([]float64, len(y))
+	for i, j := range idx {
+		xs[i] = x[j]
+		ys[i] = y[j]
+	}
+
+	return xs, ys
+}
+
+// fitLoess evaluates a local linear regression with tricube weights over an evenly spaced grid
+func fitLoess(x, y []float64) Model {
+
+	var m = Model{Method: Loess}
+
+	n := len(x)
+	k := int(math.Ceil(loessSpan * float64(n)))
+	if k < minPoints {
+		k = minPoints
+	}
+	if k > n {
+		k = n
+	}
+
+	step := (x[n-1] - x[0]) / float64(gridSize-1)
+
+	for g := 0; g < gridSize; g++ {
+
+		gx := x[0] + (float64(g) * step)
+
+		// expand a window with the k nearest neighbours
+		lo := sort.SearchFloat64s(x, gx)
+		hi := lo
+		for hi-lo < k {
+			if lo == 0 {
+				hi++
+			} else if hi == n {
+				lo--
+			} else if gx-x[lo-1] <= x[hi]-gx {
+				lo--
+			} else {
+				hi++
+			}
+		}
+
+		maxDist := math.Max(math.Abs(gx-x[lo]), math.Abs(x[hi-1]-gx))
+		if maxDist == 0 {
+			maxDist = 1
+		}
+
+		var sw, swx, swy, swxx, swxy float64
+		for i := lo; i < hi; i++ {
+			d := math.Abs(x[i]-gx) / maxDist
+			w := math.Pow(1-math.Pow(d, 3), 3)
+			sw += w
+			swx += w * x[i]
+			swy += w * y[i]
+			swxx += w * x[i] * x[i]
+			swxy += w * x[i] * y[i]
+		}
+
+		if sw == 0 {
+			continue
+		}
+
+		var gy float64
+		den := (sw * swxx) - (swx * swx)
+		if math.Abs(den) < 1e-12 {
+			gy = swy / sw
+		} else {
+			slope := ((sw * swxy) - (swx * swy)) / den
+			intercept := (swy - (slope * swx)) / sw
+			gy = intercept + (slope * gx)
+		}
+
+		if len(m.X) > 0 && gx <= m.X[len(m.X)-1] {
+			continue
+		}
+
+		m.X = append(m.X, gx)
+		m.Y = append(m.Y, gy)
+	}
+
+	m.Y = isotonic(m.Y)
+
+	return m
+}
+
+// fitPiecewise creates one knot per segment using the median of the observations that fall in it
+func fitPiecewise(x, y []float64) Model {
+
+	var m = Model{Method: Piecewise}
+
+	n := len(x)
+	size := int(math.Ceil(float64(n) / float64(segments)))
+
+	for i := 0; i < n; i += size {
+
+		end := i + size
+		if end > n {
+			end = n
+		}
+
+		kx := uti.Median(x[i:end])
+		ky := uti.Median(y[i:end])
+
+		if len(m.X) > 0 && kx <= m.X[len(m.X)-1] {
+			continue
+		}
+
+		m.X = append(m.X, kx)
+		m.Y = append(m.Y, ky)
+	}
+
+	m.Y = isotonic(m.Y)
+
+	return m
+}
+
+// isotonic returns the closest non-decreasing sequence using the pool adjacent violators algorithm
+func isotonic(y []float64) []float64 {
+
+	// each block keeps the mean and the number of values pooled together
+	var means []float64
+	var sizes []int
+
+	for _, v := range y {
+
+		means = append(means, v)
+		sizes = append(sizes, 1)
+
+		for n := len(means) - 1; n > 0 && means[n-1] > means[n]; n-- {
+			total := sizes[n-1] + sizes[n]
+			means[n-1] = ((means[n-1] * float64(sizes[n-1])) + (means[n] * float64(sizes[n]))) / float64(total)
+			sizes[n-1] = total
+			means = means[:n]
+			sizes = sizes[:n]
+		}
+	}
+
+	var fitted = make([]float64, 0, len(y))
+	for i := range means {
+		for j := 0; j < sizes[i]; j++ {
+			fitted = append(fitted, means[i])
+		}
+	}
+
+	return fitted
+}
+
+// PlotResiduals creates a PNG image with the alignment residuals of a run
+func PlotResiduals(session, name string, x, residuals []float64) {
+
+	path := fmt.Sprintf("%s%s%s_rt_alignment.png", session, string(filepath.Separator), name)
+
+	p, e := plot.New()
+	if e != nil {
+		msg.Plotter(e, "fatal")
+	}
+
+	p.Title.Text = "Retention time alignment " + name
+	p.X.Label.Text = "Retention time (min)"
+	p.Y.Label.Text = "Residual (min)"
+
+	pts := make(plotter.XYs, len(x))
+	for i := range x {
+		pts[i].X = x[i]
+		pts[i].Y = residuals[i]
+	}
+
+	s, e := plotter.NewScatter(pts)
+	if e != nil {
+		msg.Plotter(e, "fatal")
+	}
+	s.GlyphStyle.Radius = vg.Points(1)
+
+	p.Add(s, plotter.NewGrid())
+
+	if e := p.Save(8*vg.Inch, 6*vg.Inch, path); e != nil {
+		msg.Plotter(e, "fatal")
+	}
+
+	// copy to work directory
+	sys.CopyFile(path, filepath.Base(path))
+}
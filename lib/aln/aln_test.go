@@ -0,0 +1,61 @@
+package aln_test
+
+import (
+	"math"
+	"philosopher/lib/aln"
+	"testing"
+)
+
+func TestFit(t *testing.T) {
+
+	var x, y []float64
+	for i := 0; i < 200; i++ {
+		rt := float64(i) * 0.5
+		x = append(x, rt)
+		y = append(y, rt+2+(0.5*math.Sin(rt/20)))
+	}
+
+	for _, method := range []string{aln.Loess, aln.Piecewise} {
+
+		m, e := aln.Fit(x, y, method)
+		if e != nil {
+			t.Fatalf("Fit returned an error for %s: %s", method, e)
+		}
+
+		for _, i := range []float64{10, 45, 80} {
+			want := i + 2 + (0.5 * math.Sin(i/20))
+			if got := m.Predict(i); math.Abs(got-want) > 0.1 {
+				t.Errorf("Prediction with %s is incorrect, got %f, want %f", method, got, want)
+			}
+		}
+	}
+
+	// a local dip in the retention times cannot reverse the elution order
+	var dip []float64
+	for _, i := range x {
+		dip = append(dip, i+(15*math.Sin(i/10)))
+	}
+
+	for _, method := range []string{aln.Loess, aln.Piecewise} {
+
+		m, e := aln.Fit(x, dip, method)
+		if e != nil {
+			t.Fatalf("Fit returned an error for %s: %s", method, e)
+		}
+
+		for i := 1; i < len(m.Y); i++ {
+			if m.Y[i] < m.Y[i-1] {
+				t.Errorf("Mapping with %s is not monotonic, got %f after %f", method, m.Y[i], m.Y[i-1])
+				break
+			}
+		}
+	}
+
+	if _, e := aln.Fit(x[:5], y[:5], aln.Loess); e == nil {
+		t.Errorf("Fit should fail with less than the minimum number of points")
+	}
+
+	if got := aln.Identity().Predict(12.5); got != 12.5 {
+		t.Errorf("Identity prediction is incorrect, got %f, want %f", got, 12.5)
+	}
+}
@@ -72,3 +72,70 @@ func New(name string) AminoAcid {
 
 	return aa
 }
+
+// averageResidueMasses maps the one letter amino acid codes to their average residue masses
+var averageResidueMasses = map[byte]float64{
+	'A': 71.0779,
+	'R': 156.18568,
+	'N': 114.10264,
+	'D': 115.0874,
+	'C': 103.1429,
+	'E': 129.11398,
+	'Q': 128.12922,
+	'G': 57.05132,
+	'H': 137.13928,
+	'I': 113.15764,
+	'L': 113.15764,
+	'K': 128.17228,
+	'M': 131.19606,
+	'F': 147.17386,
+	'P': 97.11518,
+	'S': 87.0773,
+	'T': 101.10388,
+	'W': 186.2099,
+	'Y': 163.17326,
+	'V': 99.13106,
+}
+
+// averageWaterMass is the average mass of a water molecule
+const averageWaterMass = 18.01528
+
+// AverageMass returns the average mass of a peptide or protein sequence
+func AverageMass(sequence string) float64 {
+
+	var mass = averageWaterMass
+	for i := 0; i < len(sequence); i++ {
+		mass += averageResidueMasses[sequence[i]]
+	}
+
+	return mass
+}
+
+// monoIsotopicResidueMasses maps the one letter amino acid codes to their monoisotopic residue masses
+var monoIsotopicResidueMasses = map[byte]float64{
+	'A': 71.037113805,
+	'R': 156.101111050,
+	'N': 114.042927470,
+	'D': 115.026943065,
+	'C': 103.009184505,
+	'E': 129.042593135,
+	'Q': 128.058577540,
+	'G': 57.021463735,
+	'H': 137.058911875,
+	'I': 113.084064015,
+	'L': 113.084064015,
+	'K': 128.094963050,
+	'M': 131.040484645,
+	'F': 147.068413945,
+	'P': 97.052763875,
+	'S': 87.032028435,
+	'T': 101.047678505,
+	'W': 186.079312980,
+	'Y': 163.063328575,
+	'V': 99.068413945,
+}
+
+// MonoIsotopicResidueMass returns the monoisotopic residue mass of an amino acid code, or zero when unknown
+func MonoIsotopicResidueMass(code byte) float64 {
+	return monoIsotopicResidueMasses[code]
+}
@@ -145,3 +145,46 @@ func TestEnzymes(t *testing.T) {
 		t.Errorf("Enzyme is incorrect, got %s, want %s", e.Name, "glu_c")
 	}
 }
+
+func TestEnzyme_Digest(t *testing.T) {
+
+	var e Enzyme
+	e.Synth("trypsin")
+
+	got := e.Digest("MAKPEPTIDERGGGGGGKSAMPLEK", 6, 30)
+	want := []string{"MAKPEPTIDER", "GGGGGGK", "SAMPLEK"}
+
+	if len(got) != len(want) {
+		t.Fatalf("Digestion is incorrect, got %v, want %v", got, want)
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Digestion is incorrect, got %s, want %s", got[i], want[i])
+		}
+	}
+
+	e = Enzyme{}
+	e.Synth("lys_n")
+
+	got = e.Digest("MAPEPTIDEKGGGGGGKSAMPLEK", 6, 30)
+	want = []string{"MAPEPTIDE", "KGGGGGG", "KSAMPLE"}
+
+	if len(got) != len(want) {
+		t.Fatalf("Lys-N digestion is incorrect, got %v, want %v", got, want)
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Lys-N digestion is incorrect, got %s, want %s", got[i], want[i])
+		}
+	}
+
+	if !SupportedEnzyme("Lys_N") || SupportedEnzyme("pepsin") {
+		t.Error("the supported enzymes are incorrect")
+	}
+
+	if m := AverageMass("GG"); m < 132.11 || m > 132.12 {
+		t.Errorf("Average mass is incorrect, got %f, want %f", m, 132.117)
+	}
+}
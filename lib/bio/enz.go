@@ -12,6 +12,20 @@ type Enzyme struct {
 	Name    string
 	Pattern string
 	Join    string
+	NTerm   bool // cleaves before the pattern residues instead of after them
+}
+
+// enzymes are the enzymes known by Synth
+var enzymes = []string{"trypsin", "lys_c", "lys_n", "chymotrypsin", "glu_c"}
+
+// SupportedEnzyme tells if the enzyme can be used for the in-silico digestion
+func SupportedEnzyme(t string) bool {
+	for _, i := range enzymes {
+		if strings.EqualFold(i, t) {
+			return true
+		}
+	}
+	return false
 }
 
 // Synth is an enzyme builder
@@ -29,6 +43,7 @@ func (e *Enzyme) Synth(t string) {
 		e.Name = "lys_n"
 		e.Pattern = "K"
 		e.Join = "K"
+		e.NTerm = true
 	} else if strings.EqualFold(strings.ToLower(t), "chymotrypsin") {
 		e.Name = "chymotrypsin"
 		e.Pattern = "FWYL[^P]"
@@ -42,3 +57,59 @@ func (e *Enzyme) Synth(t string) {
 	}
 
 }
+
+// Digest cleaves a protein sequence without missed cleavages, and returns the peptides inside the length range. The
+// restricting residue follows the cleavage site, after the pattern residue or before it for N-terminal enzymes
+func (e Enzyme) Digest(sequence string, minLength, maxLength int) []string {
+
+	var peptides []string
+
+	cut := e.Pattern
+	var noCut string
+	if i := strings.Index(e.Pattern, "[^"); i > -1 {
+		cut = e.Pattern[:i]
+		noCut = strings.TrimSuffix(e.Pattern[i+2:], "]")
+	}
+
+	var start int
+	for i := 0; i < len(sequence); i++ {
+
+		if !strings.ContainsRune(cut, rune(sequence[i])) {
+			continue
+		}
+
+		// the cleavage site is the position between the two residues
+		site := i + 1
+		if e.NTerm {
+			site = i
+		}
+
+		if site == 0 || site == len(sequence) {
+			continue
+		}
+
+		if !e.NTerm && strings.ContainsRune(noCut, rune(sequence[site])) {
+			continue
+		}
+
+		if e.NTerm && strings.ContainsRune(noCut, rune(sequence[site-1])) {
+			continue
+		}
+
+		peptides = append(peptides, sequence[start:site])
+		start = site
+	}
+
+	if start < len(sequence) {
+		peptides = append(peptides, sequence[start:])
+	}
+
+	var filtered []string
+	for _, i := range peptides {
+		if len(i) >= minLength && len(i) <= maxLength {
+			filtered = append(filtered, i)
+		}
+	}
+
+	return filtered
+}
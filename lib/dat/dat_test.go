@@ -3,6 +3,7 @@ package dat_test
 import (
 	. "philosopher/lib/dat"
 	"philosopher/lib/sys"
+	"reflect"
 	"testing"
 )
 
@@ -112,3 +113,55 @@ func TestBase_Deploy(t *testing.T) {
 		})
 	}
 }
+
+func TestPeptideIndex_Map(t *testing.T) {
+
+	records := []Record{
+		{PartHeader: "sp|P00001|PROT1_HUMAN", Sequence: "MPEPTLDEKAAPEPTIDER"},
+		{PartHeader: "sp|P00002|PROT2_HUMAN", Sequence: "GGGPEPTIDEKR"},
+		{PartHeader: "rev_sp|P00001|PROT1_HUMAN", Sequence: "REDITPEPAAKEDLTPEPM", IsDecoy: true},
+	}
+
+	tests := []struct {
+		name    string
+		il      bool
+		clip    bool
+		peptide string
+		want    []PeptideMatch
+	}{
+		{
+			name:    "Testing exact matches",
+			peptide: "PEPTIDEK",
+			want: []PeptideMatch{
+				{Protein: "sp|P00002|PROT2_HUMAN", Start: 4, End: 11, PrevAA: 'G', NextAA: 'R'},
+			},
+		},
+		{
+			name:    "Testing I/L equivalence",
+			il:      true,
+			peptide: "PEPTIDEK",
+			want: []PeptideMatch{
+				{Protein: "sp|P00001|PROT1_HUMAN", Start: 2, End: 9, PrevAA: 'M', NextAA: 'A'},
+				{Protein: "sp|P00002|PROT2_HUMAN", Start: 4, End: 11, PrevAA: 'G', NextAA: 'R'},
+			},
+		},
+		{
+			name:    "Testing N-terminal methionine clipping",
+			il:      true,
+			clip:    true,
+			peptide: "PEPTLDEK",
+			want: []PeptideMatch{
+				{Protein: "sp|P00001|PROT1_HUMAN", Start: 2, End: 9, PrevAA: '-', NextAA: 'A'},
+				{Protein: "sp|P00002|PROT2_HUMAN", Start: 4, End: 11, PrevAA: 'G', NextAA: 'R'},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idx := NewPeptideIndex(records, tt.il, tt.clip)
+			if got := idx.Map(tt.peptide); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Map() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
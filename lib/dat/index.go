@@ -0,0 +1,113 @@
+package dat
+
+import (
+	"bytes"
+	"index/suffixarray"
+	"sort"
+	"strings"
+)
+
+// PeptideIndex is a suffix array built over all database protein sequences
+type PeptideIndex struct {
+	ILEquivalence bool
+	ClipNMet      bool
+	index         *suffixarray.Index
+	offsets       []int
+	headers       []string
+	sequences     []string
+	decoys        []bool
+}
+
+// PeptideMatch represents a peptide occurrence in a database protein
+type PeptideMatch struct {
+	Protein string
+	Start   int
+	End     int
+	PrevAA  byte
+	NextAA  byte
+	IsDecoy bool
+}
+
+// indexSeparator is used between sequences, it is not part of the amino acid alphabet
+const indexSeparator = '$'
+
+// NewPeptideIndex creates the suffix array index for the given records
+func NewPeptideIndex(records []Record, ilEquivalence, clipNMet bool) *PeptideIndex {
+
+	var idx = &PeptideIndex{
+		ILEquivalence: ilEquivalence,
+		ClipNMet:      clipNMet,
+		offsets:       make([]int, 0, len(records)),
+		headers:       make([]string, 0, len(records)),
+		sequences:     make([]string, 0, len(records)),
+		decoys:        make([]bool, 0, len(records)),
+	}
+
+	var buf bytes.Buffer
+	for _, i := range records {
+		idx.offsets = append(idx.offsets, buf.Len())
+		idx.headers = append(idx.headers, i.PartHeader)
+		idx.sequences = append(idx.sequences, i.Sequence)
+		idx.decoys = append(idx.decoys, i.IsDecoy)
+
+		buf.WriteString(idx.normalize(i.Sequence))
+		buf.WriteByte(indexSeparator)
+	}
+
+	idx.index = suffixarray.New(buf.Bytes())
+
+	return idx
+}
+
+// normalize converts a sequence to the indexed alphabet
+func (idx *PeptideIndex) normalize(seq string) string {
+	if idx.ILEquivalence {
+		return strings.Replace(seq, "L", "I", -1)
+	}
+	return seq
+}
+
+// Map returns all database proteins containing the given peptide sequence
+func (idx *PeptideIndex) Map(peptide string) []PeptideMatch {
+
+	var matches []PeptideMatch
+
+	if len(peptide) == 0 {
+		return matches
+	}
+
+	positions := idx.index.Lookup([]byte(idx.normalize(peptide)), -1)
+	sort.Ints(positions)
+
+	for _, pos := range positions {
+
+		// find the record that holds the position
+		r := sort.Search(len(idx.offsets), func(i int) bool { return idx.offsets[i] > pos }) - 1
+		seq := idx.sequences[r]
+
+		start := pos - idx.offsets[r]
+		end := start + len(peptide)
+
+		var m = PeptideMatch{
+			Protein: idx.headers[r],
+			Start:   start + 1,
+			End:     end,
+			PrevAA:  '-',
+			NextAA:  '-',
+			IsDecoy: idx.decoys[r],
+		}
+
+		// a peptide following the initiator methionine is a protein N-terminal peptide when clipping is allowed
+		if start > 0 && !(idx.ClipNMet && start == 1 && seq[0] == 'M') {
+			m.PrevAA = seq[start-1]
+		}
+
+		if end < len(seq) {
+			m.NextAA = seq[end]
+		}
+
+		matches = append(matches, m)
+	}
+
+	return matches
+}
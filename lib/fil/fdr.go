@@ -10,6 +10,7 @@ import (
 	"philosopher/lib/cla"
 	"philosopher/lib/id"
 	"philosopher/lib/msg"
+	"philosopher/lib/rep"
 	"philosopher/lib/uti"
 
 	"github.com/sirupsen/logrus"
@@ -205,6 +206,101 @@ func PickedFDR(p id.ProtXML) id.ProtXML {
 	return p
 }
 
+// GeneFDRFilter applies the picked FDR algorithm at the gene level, each target gene competes with
+// its decoy counterpart and only the best scoring one is kept before the FDR estimation
+func GeneFDRFilter(genes rep.GeneEvidenceList, targetFDR float64, decoyTag string) rep.GeneEvidenceList {
+
+	var targets uint
+	var decoys uint
+	var calcFDR float64
+	var minProb float64 = 10
+	var scoreMap = make(map[string]float64)
+
+	for _, i := range genes {
+		scoreMap[i.GeneName] = i.Probability
+	}
+
+	// picked, paired observations keep only the best scoring one, ties keep both
+	var list rep.GeneEvidenceList
+	for _, i := range genes {
+
+		var pair string
+		if i.IsDecoy {
+			pair = strings.Replace(i.GeneName, decoyTag, "", 1)
+		} else {
+			pair = fmt.Sprintf("%s%s", decoyTag, i.GeneName)
+		}
+
+		v, ok := scoreMap[pair]
+		if ok && v > i.Probability {
+			continue
+		}
+
+		if i.IsDecoy {
+			decoys++
+		} else {
+			targets++
+		}
+		list = append(list, i)
+	}
+
+	sort.Sort(list)
+
+	var fdrMap = make(map[float64]float64)
+	for j := len(list) - 1; j >= 0; j-- {
+		_, ok := fdrMap[list[j].Probability]
+		if !ok {
+			fdrMap[list[j].Probability] = float64(decoys) / float64(targets)
+		}
+		if list[j].IsDecoy {
+			decoys--
+		} else {
+			targets--
+		}
+	}
+
+	var keys []float64
+	for k := range fdrMap {
+		keys = append(keys, k)
+	}
+
+	sort.Sort(sort.Reverse(sort.Float64Slice(keys)))
+
+	var probList = make(map[float64]uint8)
+	for i := range keys {
+		if uti.ToFixed(fdrMap[keys[i]], 4) <= targetFDR {
+			probList[keys[i]] = 0
+			minProb = keys[i]
+			calcFDR = uti.ToFixed(fdrMap[keys[i]], 4)
+		}
+	}
+
+	cleanlist := make(rep.GeneEvidenceList, 0)
+	decoys = 0
+	targets = 0
+
+	for i := range list {
+		_, ok := probList[list[i].Probability]
+		if ok {
+			cleanlist = append(cleanlist, list[i])
+			if list[i].IsDecoy {
+				decoys++
+			} else {
+				targets++
+			}
+		}
+	}
+
+	msg := fmt.Sprintf("Converged to %.2f %% FDR with %d Genes", calcFDR*100, targets)
+	logrus.WithFields(logrus.Fields{
+		"decoy":     decoys,
+		"total":     (targets + decoys),
+		"threshold": minProb,
+	}).Info(msg)
+
+	return cleanlist
+}
+
 // RazorCandidateMap is a list of razor candidates
 type RazorCandidateMap map[string]RazorCandidate
 
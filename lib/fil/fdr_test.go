@@ -1,5 +1,11 @@
 package fil
 
+import (
+	"testing"
+
+	"philosopher/lib/rep"
+)
+
 // func TestPepXMLFDRFilter(t *testing.T) {
 
 // 	tes.SetupTestEnv()
@@ -113,3 +119,26 @@ package fil
 
 // 	//tes.ShutDowTestEnv()
 // }
+
+func TestGeneFDRFilter(t *testing.T) {
+
+	genes := rep.GeneEvidenceList{
+		{GeneName: "ALB", Probability: 0.99},
+		{GeneName: "rev_ALB", Probability: 0.20, IsDecoy: true},
+		{GeneName: "APOA1", Probability: 0.95},
+		{GeneName: "rev_GAPDH", Probability: 0.90, IsDecoy: true},
+		{GeneName: "GAPDH", Probability: 0.50},
+	}
+
+	got := GeneFDRFilter(genes, 0.01, "rev_")
+
+	if len(got) != 2 {
+		t.Errorf("GeneFDRFilter() got = %v genes, want %v", len(got), 2)
+	}
+
+	for _, i := range got {
+		if i.IsDecoy || i.GeneName == "GAPDH" {
+			t.Errorf("GeneFDRFilter() kept %v", i.GeneName)
+		}
+	}
+}
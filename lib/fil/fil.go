@@ -10,10 +10,12 @@ import (
 	"sync"
 
 	"philosopher/lib/cla"
+	"philosopher/lib/dat"
 	"philosopher/lib/id"
 	"philosopher/lib/inf"
 	"philosopher/lib/met"
 	"philosopher/lib/mod"
+	"philosopher/lib/msg"
 	"philosopher/lib/rep"
 	"philosopher/lib/sys"
 
@@ -62,7 +64,27 @@ func Run(f met.Data) met.Data {
 		f.Filter.TwoD = true
 	}
 
-	pepid, searchEngine := id.ReadPepXMLInput(f.Filter.Pex, f.Filter.Tag, f.Temp, f.Filter.Model)
+	var pepid id.PepIDListPtrs
+	var searchEngine string
+	if len(f.Filter.Mzid) > 0 {
+		pepid, searchEngine = id.ReadMzIdentMLInput(f.Filter.Mzid, f.Filter.Tag)
+	} else {
+		pepid, searchEngine = id.ReadPepXMLInput(f.Filter.Pex, f.Filter.Tag, f.Temp, f.Filter.Model)
+	}
+
+	var pepIndex *dat.PeptideIndex
+	if f.Filter.Remap {
+		logrus.Info("Re-mapping peptides to the database")
+
+		var dtb dat.Base
+		dtb.Restore()
+		if len(dtb.Records) < 1 {
+			msg.Custom(errors.New("database annotation not found, cannot re-map the peptides"), "fatal")
+		}
+
+		pepIndex = dat.NewPeptideIndex(dtb.Records, f.Filter.ILEq, f.Filter.ClipNMet)
+		remapPeptides(pepid, pepIndex, f.Filter.Tag)
+	}
 
 	f.SearchEngine = searchEngine
 
@@ -174,6 +196,9 @@ func Run(f met.Data) met.Data {
 
 	logrus.Info("Assigning protein identifications to layers")
 	e.UpdateLayerswithDatabase(f.Filter.Tag)
+	if pepIndex != nil {
+		e.UpdatePeptidePositions(pepIndex)
+	}
 	// evaluate modifications in data set
 	if f.Filter.Mapmods {
 		//e.UpdateIonModCount()
@@ -247,6 +272,12 @@ func Run(f met.Data) met.Data {
 	e = e.SyncPSMToPeptides(f.Filter.Tag)
 	e = e.SyncPSMToPeptideIons(f.Filter.Tag)
 
+	if f.Filter.Gene {
+		logrus.Info("Processing gene inference")
+		e.AssembleGeneReport(f.Filter.Tag)
+		e.Genes = GeneFDRFilter(e.Genes, f.Filter.GeneFDR, f.Filter.Tag)
+	}
+
 	var countPSM, countPep, countIon, coutProtein int
 	for _, i := range e.PSM {
 		if !i.IsDecoy {
@@ -0,0 +1,94 @@
+package fil
+
+import (
+	"sort"
+	"strings"
+
+	"philosopher/lib/dat"
+	"philosopher/lib/id"
+	"philosopher/lib/sys"
+
+	"github.com/sirupsen/logrus"
+)
+
+// remapPeptides replaces the search engine protein mappings by the ones found on the workspace database
+func remapPeptides(p id.PepIDListPtrs, idx *dat.PeptideIndex, decoyTag string) {
+
+	var cache = make(map[string][]dat.PeptideMatch)
+	var unmapped = make(map[string]struct{})
+
+	for _, i := range p {
+
+		matches, ok := cache[i.Peptide]
+		if !ok {
+			matches = idx.Map(i.Peptide)
+			cache[i.Peptide] = matches
+		}
+
+		if len(matches) == 0 {
+			unmapped[i.Peptide] = struct{}{}
+			continue
+		}
+
+		var proteins []string
+		var found bool
+		var hasTarget bool
+		for _, j := range matches {
+			proteins = append(proteins, j.Protein)
+			if j.Protein == i.Protein {
+				found = true
+			}
+			if !j.IsDecoy && !strings.HasPrefix(j.Protein, decoyTag) {
+				hasTarget = true
+			}
+		}
+		sort.Strings(proteins)
+
+		// the reference protein must be on the database, decoys are promoted to targets when possible
+		if !found || (hasTarget && strings.HasPrefix(i.Protein, decoyTag)) {
+			i.Protein = pickReferenceProtein(proteins, decoyTag)
+		}
+
+		i.AlternativeProteins = make(map[string]int)
+		for _, j := range proteins {
+			if j != i.Protein {
+				i.AlternativeProteins[j]++
+			}
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"peptides": len(cache),
+		"unmapped": len(unmapped),
+	}).Info("Peptides re-mapped to the database")
+
+	// the serialized pepXML is used by the two-dimensional filter, and must carry the same mappings
+	var pepxml id.PepXML4Serialiazation
+	sys.Restore(&pepxml, sys.PepxmlBin(), false)
+	pepxml.PeptideIdentification = p
+	pepxml.Serialize()
+}
+
+// pickReferenceProtein selects the reference protein from a sorted list, giving
+// preference to targets and to SwissProt entries
+func pickReferenceProtein(proteins []string, decoyTag string) string {
+
+	var target string
+	for _, i := range proteins {
+		if strings.HasPrefix(i, decoyTag) {
+			continue
+		}
+		if strings.HasPrefix(i, "sp|") {
+			return i
+		}
+		if len(target) == 0 {
+			target = i
+		}
+	}
+
+	if len(target) > 0 {
+		return target
+	}
+
+	return proteins[0]
+}
@@ -0,0 +1,601 @@
+package id
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"philosopher/lib/bio"
+	"philosopher/lib/mod"
+	"philosopher/lib/msg"
+	"philosopher/lib/psi"
+	"philosopher/lib/spc"
+	"philosopher/lib/uti"
+
+	"github.com/sirupsen/logrus"
+)
+
+// mzIdentML score accessions, the expectation values are listed by preference
+var (
+	mzidProbabilityScores = []string{"MS:1002357"}
+	mzidPEPScores         = []string{"MS:1001493"}
+	mzidExpectScores      = []string{"MS:1001192", "MS:1001172", "MS:1002053", "MS:1002052", "MS:1001330", "MS:1001328", "MS:1002257"}
+	mzidPhredScores       = []string{"MS:1001950", "MS:1001171"}
+	mzidXcorrScores       = []string{"MS:1001155", "MS:1002252"}
+	mzidDeltaCNScores     = []string{"MS:1001156", "MS:1002253"}
+	mzidHyperScores       = []string{"MS:1001331"}
+)
+
+var (
+	mzidNativeScan = regexp.MustCompile(`scan=(\d+)`)
+	mzidIndex      = regexp.MustCompile(`index=(\d+)`)
+	mzidTitle      = regexp.MustCompile(`^(\S+?)\.(\d+)\.(\d+)\.\d+`)
+)
+
+// ReadMzIdentMLInput reads one or more mzIdentML files and organize the data into PSM list
+func ReadMzIdentMLInput(input, decoyTag string) (PepIDListPtrs, string) {
+
+	var files []string
+	if strings.HasSuffix(strings.ToLower(input), ".mzid") {
+		files = append(files, input)
+	} else {
+		files = uti.IOReadDir(input, ".mzid")
+	}
+
+	if len(files) == 0 {
+		msg.NoParametersFound(errors.New("missing mzIdentML files"), "fatal")
+	}
+
+	sort.Strings(files)
+
+	var pepXML PepXML4Serialiazation
+	pepXML.DecoyTag = decoyTag
+	pepXML.PeptideIdentification = make(PepIDListPtrs, 0)
+	pepXML.Modifications.Index = make(map[string]mod.Modification)
+
+	var searchEngine string
+	for idx, i := range files {
+
+		var p PepXML
+		p.DecoyTag = decoyTag
+		p.ReadMzIdentML(i)
+
+		if idx == 0 {
+			searchEngine = p.SearchEngine
+			pepXML.SearchParameters = p.SearchParameters
+			pepXML.Database = p.Database
+		}
+
+		for j := range p.PeptideIdentification {
+			pepXML.PeptideIdentification = append(pepXML.PeptideIdentification, &p.PeptideIdentification[j])
+		}
+
+		for _, j := range p.Modifications.Index {
+			if _, ok := pepXML.Modifications.Index[j.Index]; !ok {
+				pepXML.Modifications.Index[j.Index] = j
+			}
+		}
+	}
+
+	// promoting Spectra that matches to both decoys and targets to TRUE hits
+	pepXML.PromoteProteinIDs()
+
+	// serialize all identifications
+	sort.Sort(pepXML.PeptideIdentification)
+	pepXML.Serialize()
+
+	return pepXML.PeptideIdentification, searchEngine
+}
+
+// ReadMzIdentML converts the top ranked SpectrumIdentificationItems of an mzIdentML file into peptide
+// identifications, the scores are translated into probabilities that keep the search engine ranking
+func (p *PepXML) ReadMzIdentML(f string) {
+
+	var mzid psi.MzIdentML
+	logrus.Info("Parsing ", f)
+	mzid.Parse(f)
+
+	p.FileName = path.Base(f)
+	p.Modifications.Index = make(map[string]mod.Modification)
+
+	if len(mzid.DataCollection.Inputs.SearchDatabase) > 0 {
+		p.Database = mzid.DataCollection.Inputs.SearchDatabase[0].Location
+	}
+
+	if len(mzid.DataCollection.Inputs.SpectraData) > 0 {
+		p.SpectraFile = mzid.DataCollection.Inputs.SpectraData[0].Location
+	}
+
+	var spectra = make(map[string]string)
+	for _, i := range mzid.DataCollection.Inputs.SpectraData {
+		spectra[i.ID] = mzidSourceName(i.Location)
+	}
+
+	var proteins = make(map[string]string)
+	for _, i := range mzid.SequenceCollection.DBSequence {
+		proteins[i.ID] = i.Accession
+	}
+
+	var peptides = make(map[string]psi.Peptide)
+	for _, i := range mzid.SequenceCollection.Peptide {
+		peptides[i.ID] = i
+	}
+
+	var evidences = make(map[string]psi.PeptideEvidence)
+	for _, i := range mzid.SequenceCollection.PeptideEvidence {
+		evidences[i.ID] = i
+	}
+
+	for _, i := range mzid.AnalysisProtocolCollection.SpectrumIdentificationProtocol {
+		if len(p.SearchEngine) == 0 {
+			p.SearchEngine = mzidSoftwareName(mzid.AnalysisSoftwareList, i.AnalysisSoftwareRef)
+		}
+		p.mapSearchModifications(i.ModificationParams)
+		p.SearchParameters = append(p.SearchParameters, mzidSearchParameters(i, p.Database)...)
+	}
+
+	var missingScores int
+	for _, i := range mzid.DataCollection.AnalysisData.SpectrumIdentificationList {
+		for _, j := range i.SpectrumIdentificationResult {
+
+			sii, ok := mzidTopItem(j.SpectrumIdentificationItem)
+			if !ok {
+				continue
+			}
+
+			peptide, ok := peptides[sii.PeptideRef]
+			if !ok {
+				continue
+			}
+
+			var psm PeptideIdentification
+			psm.AlternativeProteins = make(map[string]int)
+			psm.Index = uint32(len(p.PeptideIdentification))
+			psm.SpectrumFile = p.FileName
+			psm.HitRank = sii.Rank
+			psm.AssumedCharge = sii.ChargeState
+			psm.Peptide = peptide.PeptideSequence.Value
+
+			charge := float64(sii.ChargeState)
+			psm.PrecursorNeutralMass = (sii.ExperimentalMassToCharge - bio.Proton) * charge
+			psm.UncalibratedPrecursorNeutralMass = psm.PrecursorNeutralMass
+			psm.CalcNeutralPepMass = (sii.CalculatedMassToCharge - bio.Proton) * charge
+			psm.Massdiff = uti.ToFixed(psm.PrecursorNeutralMass-psm.CalcNeutralPepMass, 4)
+
+			psm.Spectrum = mzidSpectrumName(j, spectra[j.SpectraDataRef], sii.ChargeState)
+			psm.RetentionTime = mzidRetentionTime(j.CVParam)
+
+			psm.mapProteinsFromMzIdentML(sii.PeptideEvidenceRef, evidences, proteins, p.DecoyTag)
+
+			if !psm.mapScoresFromMzIdentML(sii.CVParam) {
+				missingScores++
+			}
+
+			psm.mapModsFromMzIdentML(peptide.Modification, p.Modifications)
+
+			p.PeptideIdentification = append(p.PeptideIdentification, psm)
+		}
+	}
+
+	if missingScores > 0 {
+		msg.Custom(fmt.Errorf("%d identifications from %s have no supported score", missingScores, p.FileName), "warning")
+	}
+
+	if len(p.PeptideIdentification) == 0 {
+		msg.NoPSMFound(errors.New(f), "warning")
+	}
+}
+
+// mzidTopItem returns the best ranked identification of a spectrum
+func mzidTopItem(items []psi.SpectrumIdentificationItem) (psi.SpectrumIdentificationItem, bool) {
+
+	var top psi.SpectrumIdentificationItem
+	var found bool
+
+	for _, i := range items {
+		if !found || (i.Rank > 0 && i.Rank < top.Rank) {
+			top = i
+			found = true
+		}
+	}
+
+	return top, found
+}
+
+// mzidSourceName removes the folders and the extensions from a spectra file location
+func mzidSourceName(location string) string {
+
+	name := filepath.Base(strings.Replace(location, "\\", "/", -1))
+	name = strings.TrimSuffix(name, ".gz")
+
+	if ext := filepath.Ext(name); len(ext) > 0 {
+		name = strings.TrimSuffix(name, ext)
+	}
+
+	return name
+}
+
+// mzidSpectrumName creates the spectrum name from the native scan number, the spectrum title written by the
+// trans-proteomic pipeline, or the spectrum index when the other two are missing
+func mzidSpectrumName(sir psi.SpectrumIdentificationResult, source string, charge uint8) string {
+
+	var title string
+	for _, i := range sir.CVParam {
+		if i.Accession == "MS:1000796" {
+			title = i.Value
+		}
+	}
+
+	var scan int
+	if m := mzidNativeScan.FindStringSubmatch(sir.SpectrumID); m != nil {
+		scan, _ = strconv.Atoi(m[1])
+	} else if m := mzidTitle.FindStringSubmatch(title); m != nil {
+		scan, _ = strconv.Atoi(m[2])
+		if len(source) == 0 {
+			source = m[1]
+		}
+	} else if m := mzidIndex.FindStringSubmatch(sir.SpectrumID); m != nil {
+		scan, _ = strconv.Atoi(m[1])
+		scan++
+	}
+
+	return fmt.Sprintf("%s.%05d.%05d.%d", source, scan, scan, charge)
+}
+
+// mzidRetentionTime reads the scan start time in seconds
+func mzidRetentionTime(params []psi.CVParam) float64 {
+
+	for _, i := range params {
+		if i.Accession == "MS:1000016" || i.Accession == "MS:1000894" {
+			rt, _ := strconv.ParseFloat(i.Value, 64)
+			if i.UnitAccession == "UO:0000031" || strings.EqualFold(i.UnitName, "minute") {
+				rt *= 60
+			}
+			return rt
+		}
+	}
+
+	return 0
+}
+
+// mzidSoftwareName finds the name of the software used for the search
+func mzidSoftwareName(list psi.AnalysisSoftwareList, ref string) string {
+
+	for _, i := range list.AnalysisSoftware {
+		if i.ID != ref {
+			continue
+		}
+		if i.SoftwareName.CVParam != nil && len(i.SoftwareName.CVParam.Name) > 0 {
+			return i.SoftwareName.CVParam.Name
+		}
+		if i.SoftwareName.UserParam != nil && len(i.SoftwareName.UserParam.Name) > 0 {
+			return i.SoftwareName.UserParam.Name
+		}
+		return i.Name
+	}
+
+	return ""
+}
+
+// mzidSearchParameters translates the search protocol into the parameter names used by the reports
+func mzidSearchParameters(sip psi.SpectrumIdentificationProtocol, database string) []spc.Parameter {
+
+	var params []spc.Parameter
+	var add = func(name, value string) {
+		if len(value) > 0 {
+			params = append(params, spc.Parameter{Name: name, Value: value})
+		}
+	}
+
+	add("database_name", database)
+
+	if sip.ParentTolerance != nil {
+		for _, i := range sip.ParentTolerance.CVParam {
+			if i.Accession == "MS:1001412" {
+				add("precursor_true_tolerance", i.Value)
+				add("precursor_true_units", i.UnitName)
+			}
+		}
+	}
+
+	if sip.FragmentTolerance != nil {
+		for _, i := range sip.FragmentTolerance.CVParam {
+			if i.Accession == "MS:1001412" {
+				add("fragment_mass_tolerance", i.Value)
+				add("fragment_mass_units", i.UnitName)
+			}
+		}
+	}
+
+	if sip.Enzymes != nil {
+		for _, i := range sip.Enzymes.Enzyme {
+
+			name := i.Name
+			if i.EnzymeName != nil && len(i.EnzymeName.CVParam) > 0 {
+				name = i.EnzymeName.CVParam[0].Name
+			}
+
+			add("search_enzyme_name", name)
+			add("allowed_missed_cleavage", strconv.Itoa(i.MissedCleavages))
+		}
+	}
+
+	if sip.AdditionalSearchParams != nil {
+		for _, i := range sip.AdditionalSearchParams.UserParam {
+			add(i.Name, i.Value)
+		}
+	}
+
+	return params
+}
+
+// mapSearchModifications adds the searched modifications to the modification index, the terminal
+// modifications are recognized from the specificity rules
+func (p *PepXML) mapSearchModifications(mp *psi.ModificationParams) {
+
+	if mp == nil {
+		return
+	}
+
+	for _, i := range mp.SearchModification {
+
+		var site string
+		for _, j := range i.SpecificityRules {
+			for _, k := range j.CVParam {
+				switch k.Accession {
+				case "MS:1001189", "MS:1002057":
+					site = "N-term"
+				case "MS:1001190", "MS:1002058":
+					site = "C-term"
+				}
+			}
+		}
+
+		var name, unimod string
+		for _, j := range i.CVParam {
+			if strings.HasPrefix(j.Accession, "UNIMOD:") {
+				unimod = j.Accession
+				name = j.Name
+			}
+		}
+
+		for _, j := range strings.Fields(i.Residues) {
+
+			aa := j
+			if j == "." {
+				aa = site
+			}
+
+			if len(aa) == 0 {
+				continue
+			}
+
+			key := fmt.Sprintf("%s#%.4f", aa, i.MassDelta)
+			if _, ok := p.Modifications.Index[key]; !ok {
+				p.Modifications.Index[key] = mod.Modification{
+					Index:     key,
+					ID:        unimod,
+					Name:      name,
+					Type:      mod.Assigned,
+					MassDiff:  uti.ToFixed(i.MassDelta, 4),
+					Variable:  !(i.FixedMod == "true" || i.FixedMod == "1"),
+					AminoAcid: aa,
+				}
+			}
+		}
+	}
+}
+
+// mapProteinsFromMzIdentML assigns the protein and the alternative proteins from the peptide evidences,
+// decoy evidences without the decoy tag get it so the target-decoy filters can recognize them
+func (p *PeptideIdentification) mapProteinsFromMzIdentML(refs []psi.PeptideEvidenceRef, evidences map[string]psi.PeptideEvidence, proteins map[string]string, decoyTag string) {
+
+	var targets, decoys []string
+	for _, i := range refs {
+
+		pe, ok := evidences[i.PeptideEvidenceRef]
+		if !ok {
+			continue
+		}
+
+		accession := proteins[pe.DBSequenceRef]
+		if len(accession) == 0 {
+			accession = pe.DBSequenceRef
+		}
+
+		if pe.IsDecoy == "true" || pe.IsDecoy == "1" || strings.HasPrefix(accession, decoyTag) {
+			if !strings.HasPrefix(accession, decoyTag) {
+				accession = decoyTag + accession
+			}
+			decoys = append(decoys, accession)
+		} else {
+			targets = append(targets, accession)
+		}
+	}
+
+	for _, i := range append(targets, decoys...) {
+		if len(p.Protein) == 0 {
+			p.Protein = i
+		} else if i != p.Protein {
+			p.AlternativeProteins[i]++
+		}
+	}
+}
+
+// mapScoresFromMzIdentML reads the search engine scores, the probability comes from the PSM-level
+// probability or posterior error probability when they are given, otherwise from the expectation value
+func (p *PeptideIdentification) mapScoresFromMzIdentML(params []psi.CVParam) bool {
+
+	var scores = make(map[string]float64)
+	for _, i := range params {
+		if v, e := strconv.ParseFloat(i.Value, 64); e == nil {
+			scores[i.Accession] = v
+		}
+	}
+
+	var first = func(accessions []string) (float64, bool) {
+		for _, i := range accessions {
+			if v, ok := scores[i]; ok {
+				return v, true
+			}
+		}
+		return 0, false
+	}
+
+	if v, ok := first(mzidXcorrScores); ok {
+		p.Xcorr = v
+	}
+
+	if v, ok := first(mzidDeltaCNScores); ok {
+		p.DeltaCN = v
+	}
+
+	if v, ok := first(mzidHyperScores); ok {
+		p.Hyperscore = v
+	}
+
+	expect, hasExpect := first(mzidExpectScores)
+	if !hasExpect {
+		if v, ok := first(mzidPhredScores); ok {
+			expect = math.Pow(10, -v/10)
+			hasExpect = true
+		}
+	}
+
+	if hasExpect {
+		p.Expectation = expect
+	}
+
+	if v, ok := first(mzidProbabilityScores); ok {
+		p.Probability = v
+	} else if v, ok := first(mzidPEPScores); ok {
+		p.Probability = 1 - v
+	} else if hasExpect {
+		p.Probability = 1 / (1 + expect)
+	} else {
+		return false
+	}
+
+	return true
+}
+
+// mapModsFromMzIdentML adds the peptide modifications following the keys used by the pepXML modifications, the
+// location 0 is the N-terminus and the location after the last residue is the C-terminus
+func (p *PeptideIdentification) mapModsFromMzIdentML(mods []psi.Modification, index mod.Modifications) {
+
+	pModificationsIndex := make(map[string]mod.Modification)
+
+	for _, i := range mods {
+
+		position, e := strconv.Atoi(i.Location)
+		if e != nil {
+			continue
+		}
+
+		var aa string
+		switch {
+		case position == 0:
+			aa = "N-term"
+		case position > len(p.Peptide):
+			aa = "C-term"
+		default:
+			aa = string(p.Peptide[position-1])
+		}
+
+		m := mod.Modification{
+			Type:      mod.Assigned,
+			AminoAcid: aa,
+			MassDiff:  uti.ToFixed(i.MonoIsotopicMassDelta, 4),
+			Variable:  true,
+		}
+
+		for _, j := range i.CVParam {
+			if strings.HasPrefix(j.Accession, "UNIMOD:") {
+				m.ID = j.Accession
+				m.Name = j.Name
+			}
+		}
+
+		// the fixed state and the missing annotations come from the searched modifications
+		if v, ok := index.Index[fmt.Sprintf("%s#%.4f", aa, m.MassDiff)]; ok {
+			m.Variable = v.Variable
+			if len(m.ID) == 0 {
+				m.ID = v.ID
+				m.Name = v.Name
+			}
+		} else {
+			key := fmt.Sprintf("%s#%.4f", aa, m.MassDiff)
+			index.Index[key] = mod.Modification{Index: key, ID: m.ID, Name: m.Name, Type: mod.Assigned, MassDiff: m.MassDiff, Variable: true, AminoAcid: aa}
+		}
+
+		if aa == "N-term" || aa == "C-term" {
+			m.Index = fmt.Sprintf("%s#%.4f", aa, m.MassDiff)
+		} else {
+			m.Position = position
+			m.Index = fmt.Sprintf("%s#%d#%.4f", aa, position, m.MassDiff)
+		}
+
+		pModificationsIndex[m.Index] = m
+	}
+
+	p.ModifiedPeptide = modifiedPeptide(p.Peptide, pModificationsIndex)
+
+	key := fmt.Sprintf("%.4f", p.Massdiff)
+	if _, ok := pModificationsIndex[key]; !ok {
+		pModificationsIndex[key] = mod.Modification{
+			Index:    key,
+			Name:     "Unknown",
+			Type:     mod.Observed,
+			MassDiff: p.Massdiff,
+		}
+	}
+
+	p.Modifications = mod.Modifications{Index: pModificationsIndex}.ToSlice()
+}
+
+// modifiedPeptide writes the modified sequence with the nominal masses of the modified residues and termini, in
+// the same notation used by the pepXML files
+func modifiedPeptide(sequence string, mods map[string]mod.Modification) string {
+
+	if len(mods) == 0 {
+		return ""
+	}
+
+	var residues = make(map[int]float64)
+	var nTerm, cTerm float64
+	for _, i := range mods {
+		switch i.AminoAcid {
+		case "N-term":
+			nTerm += i.MassDiff
+		case "C-term":
+			cTerm += i.MassDiff
+		default:
+			residues[i.Position] += i.MassDiff
+		}
+	}
+
+	var b strings.Builder
+
+	if nTerm != 0 {
+		fmt.Fprintf(&b, "n[%.0f]", 1.007825+nTerm)
+	}
+
+	for i := 0; i < len(sequence); i++ {
+		b.WriteByte(sequence[i])
+		if v, ok := residues[i+1]; ok {
+			fmt.Fprintf(&b, "[%.0f]", bio.MonoIsotopicResidueMass(sequence[i])+v)
+		}
+	}
+
+	if cTerm != 0 {
+		fmt.Fprintf(&b, "c[%.0f]", 17.002740+cTerm)
+	}
+
+	return b.String()
+}
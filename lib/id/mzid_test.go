@@ -0,0 +1,179 @@
+package id
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"testing"
+
+	"philosopher/lib/mod"
+)
+
+const mzidTestFile = `<?xml version="1.0" encoding="UTF-8"?>
+<MzIdentML id="test" version="1.1.0" xmlns="http://psidev.info/psi/pi/mzIdentML/1.1">
+  <AnalysisSoftwareList>
+    <AnalysisSoftware id="ID_software" name="MS-GF+">
+      <SoftwareName><cvParam accession="MS:1002048" cvRef="PSI-MS" name="MS-GF+"/></SoftwareName>
+    </AnalysisSoftware>
+  </AnalysisSoftwareList>
+  <SequenceCollection>
+    <DBSequence id="DBSeq1" accession="sp|P12345|PROT_HUMAN" searchDatabase_ref="SearchDB_1"/>
+    <DBSequence id="DBSeq2" accession="sp|Q67890|OTHER_HUMAN" searchDatabase_ref="SearchDB_1"/>
+    <DBSequence id="DBSeq3" accession="XXX_sp|P12345|PROT_HUMAN" searchDatabase_ref="SearchDB_1"/>
+    <Peptide id="Pep1">
+      <PeptideSequence>PEMTIDEK</PeptideSequence>
+      <Modification location="0" monoisotopicMassDelta="229.162932">
+        <cvParam accession="UNIMOD:737" cvRef="UNIMOD" name="TMT6plex"/>
+      </Modification>
+      <Modification location="3" monoisotopicMassDelta="15.994915" residues="M">
+        <cvParam accession="UNIMOD:35" cvRef="UNIMOD" name="Oxidation"/>
+      </Modification>
+    </Peptide>
+    <Peptide id="Pep2">
+      <PeptideSequence>KEDITPEP</PeptideSequence>
+    </Peptide>
+    <PeptideEvidence id="PepEv1" peptide_ref="Pep1" dBSequence_ref="DBSeq1" isDecoy="false"/>
+    <PeptideEvidence id="PepEv2" peptide_ref="Pep1" dBSequence_ref="DBSeq2" isDecoy="false"/>
+    <PeptideEvidence id="PepEv3" peptide_ref="Pep2" dBSequence_ref="DBSeq3" isDecoy="true"/>
+  </SequenceCollection>
+  <AnalysisProtocolCollection>
+    <SpectrumIdentificationProtocol id="SearchProtocol_1" analysisSoftware_ref="ID_software">
+      <ModificationParams>
+        <SearchModification fixedMod="false" massDelta="15.994915" residues="M">
+          <cvParam accession="UNIMOD:35" cvRef="UNIMOD" name="Oxidation"/>
+        </SearchModification>
+        <SearchModification fixedMod="true" massDelta="229.162932" residues=".">
+          <SpecificityRules><cvParam accession="MS:1001189" cvRef="PSI-MS" name="modification specificity peptide N-term"/></SpecificityRules>
+          <cvParam accession="UNIMOD:737" cvRef="UNIMOD" name="TMT6plex"/>
+        </SearchModification>
+      </ModificationParams>
+      <Enzymes>
+        <Enzyme id="Tryp" missedCleavages="2">
+          <EnzymeName><cvParam accession="MS:1001251" cvRef="PSI-MS" name="Trypsin"/></EnzymeName>
+        </Enzyme>
+      </Enzymes>
+      <ParentTolerance>
+        <cvParam accession="MS:1001412" cvRef="PSI-MS" name="search tolerance plus value" value="20" unitName="parts per million"/>
+      </ParentTolerance>
+    </SpectrumIdentificationProtocol>
+  </AnalysisProtocolCollection>
+  <DataCollection>
+    <Inputs>
+      <SearchDatabase id="SearchDB_1" location="C:\databases\human.fasta"/>
+      <SpectraData id="SID_1" location="C:\data\run_01.mzML"/>
+    </Inputs>
+    <AnalysisData>
+      <SpectrumIdentificationList id="SI_LIST_1">
+        <SpectrumIdentificationResult id="SIR_1" spectrumID="controllerType=0 controllerNumber=1 scan=1234" spectraData_ref="SID_1">
+          <SpectrumIdentificationItem id="SII_1_2" rank="2" chargeState="2" experimentalMassToCharge="589.76" calculatedMassToCharge="589.75" peptide_ref="Pep2" passThreshold="true">
+            <PeptideEvidenceRef peptideEvidence_ref="PepEv3"/>
+            <cvParam accession="MS:1002053" cvRef="PSI-MS" name="MS-GF:EValue" value="5.0"/>
+          </SpectrumIdentificationItem>
+          <SpectrumIdentificationItem id="SII_1_1" rank="1" chargeState="2" experimentalMassToCharge="704.3544" calculatedMassToCharge="704.3540" peptide_ref="Pep1" passThreshold="true">
+            <PeptideEvidenceRef peptideEvidence_ref="PepEv1"/>
+            <PeptideEvidenceRef peptideEvidence_ref="PepEv2"/>
+            <cvParam accession="MS:1002053" cvRef="PSI-MS" name="MS-GF:EValue" value="1.0E-4"/>
+          </SpectrumIdentificationItem>
+          <cvParam accession="MS:1000016" cvRef="PSI-MS" name="scan start time" value="20.5" unitAccession="UO:0000031" unitName="minute"/>
+        </SpectrumIdentificationResult>
+        <SpectrumIdentificationResult id="SIR_2" spectrumID="index=41" spectraData_ref="SID_1">
+          <SpectrumIdentificationItem id="SII_2_1" rank="1" chargeState="3" experimentalMassToCharge="306.17" calculatedMassToCharge="306.17" peptide_ref="Pep2" passThreshold="true">
+            <PeptideEvidenceRef peptideEvidence_ref="PepEv3"/>
+            <cvParam accession="MS:1001950" cvRef="PSI-MS" name="PEAKS:peptideScore" value="30"/>
+          </SpectrumIdentificationItem>
+        </SpectrumIdentificationResult>
+      </SpectrumIdentificationList>
+    </AnalysisData>
+  </DataCollection>
+</MzIdentML>
+`
+
+func TestPepXML_ReadMzIdentML(t *testing.T) {
+
+	f, e := ioutil.TempFile("", "*.mzid")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.Remove(f.Name())
+
+	if _, e := f.WriteString(mzidTestFile); e != nil {
+		t.Fatal(e)
+	}
+	f.Close()
+
+	var p PepXML
+	p.DecoyTag = "rev_"
+	p.ReadMzIdentML(f.Name())
+
+	if p.SearchEngine != "MS-GF+" {
+		t.Errorf("search engine is incorrect, got %s, want MS-GF+", p.SearchEngine)
+	}
+
+	if len(p.PeptideIdentification) != 2 {
+		t.Fatalf("expected 2 identifications, got %d", len(p.PeptideIdentification))
+	}
+
+	target := p.PeptideIdentification[0]
+
+	if target.Spectrum != "run_01.01234.01234.2" {
+		t.Errorf("spectrum name is incorrect, got %s", target.Spectrum)
+	}
+
+	if target.Peptide != "PEMTIDEK" || target.HitRank != 1 {
+		t.Errorf("the top ranked identification was not selected, got %s rank %d", target.Peptide, target.HitRank)
+	}
+
+	if target.Protein != "sp|P12345|PROT_HUMAN" || target.AlternativeProteins["sp|Q67890|OTHER_HUMAN"] != 1 {
+		t.Errorf("protein evidences are incorrect, got %s and %v", target.Protein, target.AlternativeProteins)
+	}
+
+	if target.RetentionTime != 1230 {
+		t.Errorf("retention time is incorrect, got %f, want 1230", target.RetentionTime)
+	}
+
+	if target.Expectation != 1.0e-4 || math.Abs(target.Probability-(1/(1+1.0e-4))) > 1e-12 {
+		t.Errorf("scores are incorrect, got expectation %g and probability %g", target.Expectation, target.Probability)
+	}
+
+	if target.ModifiedPeptide != "n[230]PEM[147]TIDEK" {
+		t.Errorf("modified peptide is incorrect, got %s", target.ModifiedPeptide)
+	}
+
+	var assigned = make(map[string]mod.Modification)
+	for _, i := range target.Modifications.IndexSlice {
+		if i.Type == mod.Assigned {
+			assigned[i.Index] = i
+		}
+	}
+
+	if m, ok := assigned["M#3#15.9949"]; !ok || m.ID != "UNIMOD:35" || !m.Variable {
+		t.Errorf("oxidation is incorrect, got %v", assigned)
+	}
+
+	if m, ok := assigned["N-term#229.1629"]; !ok || m.Variable || m.AminoAcid != "N-term" {
+		t.Errorf("N-terminal TMT is incorrect, got %v", assigned)
+	}
+
+	decoy := p.PeptideIdentification[1]
+
+	if decoy.Protein != "rev_XXX_sp|P12345|PROT_HUMAN" {
+		t.Errorf("decoy protein must carry the decoy tag, got %s", decoy.Protein)
+	}
+
+	if decoy.Spectrum != "run_01.00042.00042.3" {
+		t.Errorf("spectrum index is incorrect, got %s", decoy.Spectrum)
+	}
+
+	if math.Abs(decoy.Expectation-1.0e-3) > 1e-12 {
+		t.Errorf("PEAKS score conversion is incorrect, got %g", decoy.Expectation)
+	}
+
+	var params = make(map[string]string)
+	for _, i := range p.SearchParameters {
+		params[i.Name] = i.Value
+	}
+
+	if params["search_enzyme_name"] != "Trypsin" || params["allowed_missed_cleavage"] != "2" || params["precursor_true_tolerance"] != "20" {
+		t.Errorf("search parameters are incorrect, got %v", params)
+	}
+}
@@ -0,0 +1,254 @@
+package iso
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"philosopher/lib/msg"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// maxSolverSweeps limits the coordinate descent iterations of the non-negative solve
+	maxSolverSweeps = 500
+	// solverTolerance is the relative intensity change that stops the non-negative solve
+	solverTolerance = 1e-10
+)
+
+// Impurities are the isotopic impurity percentages of each channel on the NeighbourOffsets, as given
+// by the lot-specific product data sheet
+type Impurities map[string][4]float64
+
+// ReadImpurities reads the reagent purity table, YAML files map each channel to its four percentages, the other
+// files have one channel per line followed by the -2, -1, +1 and +2 percentages separated by commas or tabs
+func ReadImpurities(path string) Impurities {
+
+	var impurities = make(Impurities)
+
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".yaml" || ext == ".yml" {
+
+		b, e := ioutil.ReadFile(path)
+		if e != nil {
+			msg.ReadFile(errors.New("cannot open the reagent purity table"), "fatal")
+		}
+
+		var table map[string][]float64
+		e = yaml.Unmarshal(b, &table)
+		if e != nil {
+			msg.Custom(fmt.Errorf("malformed reagent purity table: %s", e), "fatal")
+		}
+
+		for k, v := range table {
+			impurities[k] = impurityRow(k, v)
+		}
+
+	} else {
+		impurities = readImpurityTable(path)
+	}
+
+	if len(impurities) == 0 {
+		msg.Custom(errors.New("the reagent purity table has no channels"), "fatal")
+	}
+
+	return impurities
+}
+
+// readImpurityTable reads the delimited purity table, the header line is optional
+func readImpurityTable(path string) Impurities {
+
+	var impurities = make(Impurities)
+
+	file, e := os.Open(path)
+	if e != nil {
+		msg.ReadFile(errors.New("cannot open the reagent purity table"), "fatal")
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == '\t' || r == ';' })
+
+		var values []float64
+		var header bool
+		for _, i := range fields[1:] {
+			v, e := strconv.ParseFloat(strings.TrimSpace(i), 64)
+			if e != nil {
+				header = true
+				break
+			}
+			values = append(values, v)
+		}
+
+		// the header line names the offsets
+		if header || isOffsetHeader(values) {
+			continue
+		}
+
+		name := strings.TrimSpace(fields[0])
+		impurities[name] = impurityRow(name, values)
+	}
+
+	if e = scanner.Err(); e != nil {
+		msg.ReadFile(e, "fatal")
+	}
+
+	return impurities
+}
+
+// isOffsetHeader checks if the values are the neighbour offsets of a header line
+func isOffsetHeader(values []float64) bool {
+
+	if len(values) != len(NeighbourOffsets) {
+		return false
+	}
+
+	for i := range values {
+		if values[i] != float64(NeighbourOffsets[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// impurityRow validates the percentages of a channel
+func impurityRow(name string, values []float64) [4]float64 {
+
+	var row [4]float64
+
+	if len(values) != len(NeighbourOffsets) {
+		msg.Custom(fmt.Errorf("the channel %s needs %d impurity percentages", name, len(NeighbourOffsets)), "fatal")
+	}
+
+	var sum float64
+	for i := range values {
+		if values[i] < 0 {
+			msg.Custom(fmt.Errorf("the channel %s has a negative impurity percentage", name), "fatal")
+		}
+		row[i] = values[i]
+		sum += values[i]
+	}
+
+	if sum >= 100 {
+		msg.Custom(fmt.Errorf("the channel %s impurities add up to 100%% or more", name), "fatal")
+	}
+
+	return row
+}
+
+// ImpurityMatrix creates the correction matrix, each column has the fraction of a channel signal observed on every
+// channel; the impurities falling outside the reagent channels are only removed from the channel itself
+func (r Reagent) ImpurityMatrix(impurities Impurities) [][]float64 {
+
+	var index = make(map[string]int)
+	var matrix = make([][]float64, len(r.Channels))
+	for i := range r.Channels {
+		index[r.Channels[i].Name] = i
+		matrix[i] = make([]float64, len(r.Channels))
+		matrix[i][i] = 1
+	}
+
+	for k := range impurities {
+		if _, ok := index[k]; !ok {
+			msg.Custom(fmt.Errorf("the channel %s from the purity table is not part of the reagent", k), "warning")
+		}
+	}
+
+	for i, c := range r.Channels {
+
+		p, ok := impurities[c.Name]
+		if !ok {
+			continue
+		}
+
+		for j := range p {
+			matrix[i][i] -= p[j] / 100
+			if n, ok := index[c.Neighbours[j]]; ok {
+				matrix[n][i] += p[j] / 100
+			}
+		}
+	}
+
+	return matrix
+}
+
+// SolveImpurities solves the observed intensities against the impurity matrix, the solution is constrained to
+// non-negative intensities using a projected coordinate descent on the least squares problem
+func SolveImpurities(matrix [][]float64, observed []float64) []float64 {
+
+	n := len(observed)
+	var x = make([]float64, n)
+
+	if n == 0 || len(matrix) != n {
+		copy(x, observed)
+		return x
+	}
+
+	// normal equations
+	var ata = make([][]float64, n)
+	var atb = make([]float64, n)
+	var top float64
+	for i := 0; i < n; i++ {
+		ata[i] = make([]float64, n)
+		for j := 0; j < n; j++ {
+			for k := 0; k < n; k++ {
+				ata[i][j] += matrix[k][i] * matrix[k][j]
+			}
+		}
+		for k := 0; k < n; k++ {
+			atb[i] += matrix[k][i] * observed[k]
+		}
+		x[i] = math.Max(observed[i], 0)
+		top = math.Max(top, x[i])
+	}
+
+	if top == 0 {
+		return x
+	}
+
+	for s := 0; s < maxSolverSweeps; s++ {
+
+		var change float64
+		for i := 0; i < n; i++ {
+
+			if ata[i][i] == 0 {
+				continue
+			}
+
+			var g = -atb[i]
+			for j := 0; j < n; j++ {
+				g += ata[i][j] * x[j]
+			}
+
+			v := math.Max(0, x[i]-(g/ata[i][i]))
+			change = math.Max(change, math.Abs(v-x[i]))
+			x[i] = v
+		}
+
+		if change <= solverTolerance*top {
+			break
+		}
+	}
+
+	return x
+}
+
+// CorrectImpurities replaces the channel intensities by the impurity corrected ones
+func (l *Labels) CorrectImpurities(matrix [][]float64) {
+	l.SetIntensities(SolveImpurities(matrix, l.Intensities()))
+}
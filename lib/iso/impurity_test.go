@@ -0,0 +1,57 @@
+package iso
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSolveImpurities(t *testing.T) {
+
+	r := NewReagent("test", []string{"126", "127N", "127C", "128N", "128C"}, []float64{126.127726, 127.124761, 127.131081, 128.128116, 128.134436})
+
+	matrix := r.ImpurityMatrix(Impurities{
+		"126":  {0, 0, 8, 0.5},
+		"127C": {0, 1, 6, 0},
+		"128C": {0.2, 2, 5, 0},
+	})
+
+	// the +1 impurity of 126 goes to 127C and the +2 to 128C
+	if math.Abs(matrix[2][0]-0.08) > 1e-9 || math.Abs(matrix[4][0]-0.005) > 1e-9 || math.Abs(matrix[0][0]-0.915) > 1e-9 {
+		t.Errorf("unexpected impurity matrix column for 126: %v %v %v", matrix[0][0], matrix[2][0], matrix[4][0])
+	}
+
+	tests := []struct {
+		name string
+		true []float64
+	}{
+		{"all channels", []float64{1000, 2000, 500, 800, 1200}},
+		{"empty channels", []float64{5000, 0, 0, 0, 300}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			var observed = make([]float64, len(tt.true))
+			for i := range matrix {
+				for j := range matrix[i] {
+					observed[i] += matrix[i][j] * tt.true[j]
+				}
+			}
+
+			got := SolveImpurities(matrix, observed)
+			for i := range got {
+				if got[i] < 0 || math.Abs(got[i]-tt.true[i]) > 1e-3 {
+					t.Errorf("channel %d = %v, want %v", i, got[i], tt.true[i])
+				}
+			}
+		})
+	}
+
+	// noise on an empty channel cannot become a negative intensity
+	got := SolveImpurities(matrix, []float64{5000, 0, 0, 0, 0})
+	for i := range got {
+		if got[i] < 0 {
+			t.Errorf("channel %d has a negative intensity: %v", i, got[i])
+		}
+	}
+}
@@ -1,5 +1,28 @@
 package iso
 
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"philosopher/lib/msg"
+)
+
+const (
+	// isotopeSpacing is the mass difference between the 13C and the 12C isotopes
+	isotopeSpacing = 1.0033548
+	// neighbourTolerance is the maximum m/z difference for a channel to be an isotopic neighbour
+	neighbourTolerance = 0.01
+)
+
+// NeighbourOffsets are the isotopic offsets, in Da, of the reagent channel neighbours
+var NeighbourOffsets = [4]int{-2, -1, 1, 2}
+
 // Labels main struct
 type Labels struct {
 	Spectrum      string
@@ -8,169 +31,224 @@ type Labels struct {
 	RetentionTime float64
 	ChargeState   int
 	IsUsed        bool
-	Channel1      Channel1
-	Channel2      Channel2
-	Channel3      Channel3
-	Channel4      Channel4
-	Channel5      Channel5
-	Channel6      Channel6
-	Channel7      Channel7
-	Channel8      Channel8
-	Channel9      Channel9
-	Channel10     Channel10
-	Channel11     Channel11
-	Channel12     Channel12
-	Channel13     Channel13
-	Channel14     Channel14
-	Channel15     Channel15
-	Channel16     Channel16
-	Channel17     Channel17
-	Channel18     Channel18
+	SignalToNoise float64
+	Channels      []Channel
 }
 
-// LabeledSpectra is a list of spectra lables
-type LabeledSpectra map[string]Labels
-
-// Channel1 TMT
-type Channel1 struct {
+// Channel is a reporter ion from an isobaric labeling reagent
+type Channel struct {
 	Name       string
 	CustomName string
 	Mz         float64
 	Intensity  float64
 }
 
-// Channel2 TMT
-type Channel2 struct {
-	Name       string
-	CustomName string
-	Mz         float64
-	Intensity  float64
-}
+// LabeledSpectra is a list of spectra lables
+type LabeledSpectra map[string]Labels
 
-// Channel3 TMT
-type Channel3 struct {
-	Name       string
-	CustomName string
-	Mz         float64
-	Intensity  float64
+// Reagent is an isobaric labeling reagent defined by its reporter ions
+type Reagent struct {
+	Name     string
+	Channels []ReagentChannel
 }
 
-// Channel4 TMT
-type Channel4 struct {
+// ReagentChannel is a reporter ion definition, the neighbours are the channels receiving its isotopic
+// impurities on each of the NeighbourOffsets, an empty name means that there is no neighbour
+type ReagentChannel struct {
 	Name       string
-	CustomName string
 	Mz         float64
-	Intensity  float64
+	Neighbours [4]string
 }
 
-// Channel5 TMT
-type Channel5 struct {
-	Name       string
-	CustomName string
-	Mz         float64
-	Intensity  float64
-}
+// NewReagent creates a reagent from the channel names and m/z values, the neighbours are assigned by mass
+func NewReagent(name string, names []string, mz []float64) Reagent {
 
-// Channel6 TMT
-type Channel6 struct {
-	Name       string
-	CustomName string
-	Mz         float64
-	Intensity  float64
-}
+	var r = Reagent{Name: name}
 
-// Channel7 TMT
-type Channel7 struct {
-	Name       string
-	CustomName string
-	Mz         float64
-	Intensity  float64
+	for i := range names {
+		r.Channels = append(r.Channels, ReagentChannel{Name: names[i], Mz: mz[i]})
+	}
+
+	for i := range r.Channels {
+		for j, k := range NeighbourOffsets {
+			r.Channels[i].Neighbours[j] = r.closestChannel(r.Channels[i].Mz + (float64(k) * isotopeSpacing))
+		}
+	}
+
+	return r
 }
 
-// Channel8 TMT
-type Channel8 struct {
-	Name       string
-	CustomName string
-	Mz         float64
-	Intensity  float64
+// closestChannel returns the name of the channel closest to the m/z inside the neighbour tolerance
+func (r Reagent) closestChannel(mz float64) string {
+
+	var name string
+	var best = neighbourTolerance
+
+	for _, i := range r.Channels {
+		if d := math.Abs(i.Mz - mz); d <= best {
+			name = i.Name
+			best = d
+		}
+	}
+
+	return name
 }
 
-// Channel9 TMT
-type Channel9 struct {
-	Name       string
-	CustomName string
-	Mz         float64
-	Intensity  float64
+// ReadReagent reads a reagent definition file, each line has the channel name, the reporter ion m/z and optionally the
+// neighbours at -2, -1, +1 and +2 Da; missing neighbours are marked with a dash, and are assigned by mass when the
+// columns are not given
+func ReadReagent(path string) Reagent {
+
+	file, e := os.Open(path)
+	if e != nil {
+		msg.ReadFile(errors.New("cannot open the reagent definition file"), "fatal")
+	}
+	defer file.Close()
+
+	var names []string
+	var mz []float64
+	var neighbours [][]string
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 && len(fields) != 2+len(NeighbourOffsets) {
+			msg.Custom(fmt.Errorf("malformed reagent definition: %s", line), "fatal")
+		}
+
+		v, e := strconv.ParseFloat(fields[1], 64)
+		if e != nil {
+			msg.Custom(fmt.Errorf("malformed reporter ion m/z: %s", fields[1]), "fatal")
+		}
+
+		names = append(names, fields[0])
+		mz = append(mz, v)
+		neighbours = append(neighbours, fields[2:])
+	}
+
+	if e = scanner.Err(); e != nil {
+		msg.ReadFile(e, "fatal")
+	}
+
+	if len(names) == 0 {
+		msg.Custom(errors.New("the reagent definition file has no channels"), "fatal")
+	}
+
+	r := NewReagent(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)), names, mz)
+
+	for i := range r.Channels {
+		if len(neighbours[i]) == 0 {
+			continue
+		}
+		for j, k := range neighbours[i] {
+			if k == "-" {
+				k = ""
+			}
+			r.Channels[i].Neighbours[j] = k
+		}
+	}
+
+	return r
 }
 
-// Channel10 TMT
-type Channel10 struct {
-	Name       string
-	CustomName string
-	Mz         float64
-	Intensity  float64
+// Names returns the channel names in channel order
+func (r Reagent) Names() []string {
+
+	var names []string
+	for _, i := range r.Channels {
+		names = append(names, i.Name)
+	}
+
+	return names
 }
 
-// Channel11 TMT
-type Channel11 struct {
-	Name       string
-	CustomName string
-	Mz         float64
-	Intensity  float64
+// Labels creates an empty set of labels with the reagent channels
+func (r Reagent) Labels() Labels {
+
+	var l Labels
+	for _, i := range r.Channels {
+		l.Channels = append(l.Channels, Channel{Name: i.Name, Mz: i.Mz})
+	}
+
+	return l
 }
 
-// Channel12 TMT
-type Channel12 struct {
-	Name       string
-	CustomName string
-	Mz         float64
-	Intensity  float64
+// Copy returns a copy of the labels that does not share the channels
+func (l Labels) Copy() Labels {
+
+	c := l
+	c.Channels = make([]Channel, len(l.Channels))
+	copy(c.Channels, l.Channels)
+
+	return c
 }
 
-// Channel13 TMT
-type Channel13 struct {
-	Name       string
-	CustomName string
-	Mz         float64
-	Intensity  float64
+// Names returns the channel names in channel order
+func (l Labels) Names() []string {
+
+	var names []string
+	for _, i := range l.Channels {
+		names = append(names, i.Name)
+	}
+
+	return names
 }
 
-// Channel14 TMT
-type Channel14 struct {
-	Name       string
-	CustomName string
-	Mz         float64
-	Intensity  float64
+// Intensities returns the channel intensities in channel order
+func (l Labels) Intensities() []float64 {
+
+	var v = make([]float64, len(l.Channels))
+	for i := range l.Channels {
+		v[i] = l.Channels[i].Intensity
+	}
+
+	return v
 }
 
-// Channel15 TMT
-type Channel15 struct {
-	Name       string
-	CustomName string
-	Mz         float64
-	Intensity  float64
+// SetIntensities replaces the channel intensities using the channel order
+func (l *Labels) SetIntensities(v []float64) {
+	for i := range l.Channels {
+		if i < len(v) {
+			l.Channels[i].Intensity = v[i]
+		}
+	}
 }
 
-// Channel16 TMT
-type Channel16 struct {
-	Name       string
-	CustomName string
-	Mz         float64
-	Intensity  float64
+// Sum returns the summed intensity of all channels
+func (l Labels) Sum() float64 {
+
+	var sum float64
+	for _, i := range l.Channels {
+		sum += i.Intensity
+	}
+
+	return sum
 }
 
-// Channel17 TMT
-type Channel17 struct {
-	Name       string
-	CustomName string
-	Mz         float64
-	Intensity  float64
+// Add sums the intensities of other labels, the channel definitions are taken from them
+func (l *Labels) Add(o Labels) {
+
+	if len(l.Channels) < len(o.Channels) {
+		l.Channels = append(l.Channels, make([]Channel, len(o.Channels)-len(l.Channels))...)
+	}
+
+	for i := range o.Channels {
+		l.Channels[i].Name = o.Channels[i].Name
+		l.Channels[i].CustomName = o.Channels[i].CustomName
+		l.Channels[i].Mz = o.Channels[i].Mz
+		l.Channels[i].Intensity += o.Channels[i].Intensity
+	}
 }
 
-// Channel18 TMT
-type Channel18 struct {
-	Name       string
-	CustomName string
-	Mz         float64
-	Intensity  float64
+// Clear sets all channel intensities to zero
+func (l *Labels) Clear() {
+	for i := range l.Channels {
+		l.Channels[i].Intensity = 0
+	}
 }
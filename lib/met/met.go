@@ -340,6 +340,7 @@ type PTMProphet struct {
 // Filter options and parameters
 type Filter struct {
 	Pex       string  `yaml:"pepxml"`
+	Mzid      string  `yaml:"mzid"`
 	Pox       string  `yaml:"protxml"`
 	Tag       string  `yaml:"tag"`
 	Mods      string  `yaml:"mods"`
@@ -348,6 +349,7 @@ type Filter struct {
 	PepFDR    float64 `yaml:"peptideFDR"`
 	IonFDR    float64 `yaml:"ionFDR"`
 	PtFDR     float64 `yaml:"proteinFDR"`
+	GeneFDR   float64 `yaml:"geneFDR"`
 	ProtProb  float64 `yaml:"proteinProbability"`
 	PepProb   float64 `yaml:"peptideProbability"`
 	Weight    float64 `yaml:"peptideWeight"`
@@ -357,48 +359,90 @@ type Filter struct {
 	Seq       bool    `yaml:"sequential"`
 	TwoD      bool    `yaml:"two-dimensional"`
 	Mapmods   bool    `yaml:"mapMods"`
+	Gene      bool    `yaml:"gene"`
+	Remap     bool    `yaml:"remap"`
+	ILEq      bool    `yaml:"ilEquivalence"`
+	ClipNMet  bool    `yaml:"clipNMet"`
 	Inference bool
 }
 
 // Quantify options and parameters
 type Quantify struct {
-	Pex        string  `yaml:"pepxml"`
-	Tag        string  `yaml:"tag"`
-	Format     string  `yaml:"format"`
-	Dir        string  `yaml:"dir"`
-	Brand      string  `yaml:"brand"`
-	Plex       string  `yaml:"plex"`
-	ChanNorm   string  `yaml:"chanNorm"`
-	Annot      string  `yaml:"annotation"`
-	Level      int     `yaml:"level"`
-	RTWin      float64 `yaml:"retentionTimeWindow"`
-	PTWin      float64 `yaml:"peakTimeWindow"`
-	Tol        float64 `yaml:"tolerance"`
-	Purity     float64 `yaml:"purity"`
-	MinProb    float64 `yaml:"minprob"`
-	RemoveLow  float64 `yaml:"removeLow"`
-	Isolated   bool    `yaml:"isolated"`
-	IntNorm    bool    `yaml:"intNorm"`
-	Unique     bool    `yaml:"uniqueOnly"`
-	BestPSM    bool    `yaml:"bestPSM"`
-	Raw        bool    `yaml:"raw"`
-	Faims      bool    `yaml:"faims"`
-	LabelNames map[string]string
+	Pex         string  `yaml:"pepxml"`
+	Tag         string  `yaml:"tag"`
+	Format      string  `yaml:"format"`
+	Dir         string  `yaml:"dir"`
+	Brand       string  `yaml:"brand"`
+	Plex        string  `yaml:"plex"`
+	Reagent     string  `yaml:"reagentDefinition"`
+	Impurity    string  `yaml:"impurityTable"`
+	ChanNorm    string  `yaml:"chanNorm"`
+	Annot       string  `yaml:"annotation"`
+	Level       int     `yaml:"level"`
+	RTWin       float64 `yaml:"retentionTimeWindow"`
+	PTWin       float64 `yaml:"peakTimeWindow"`
+	Tol         float64 `yaml:"tolerance"`
+	Purity      float64 `yaml:"purity"`
+	MinProb     float64 `yaml:"minprob"`
+	RemoveLow   float64 `yaml:"removeLow"`
+	MinSNR      float64 `yaml:"minSignalToNoise"`
+	SNR         bool    `yaml:"signalToNoise"`
+	Isolated    bool    `yaml:"isolated"`
+	IntNorm     bool    `yaml:"intNorm"`
+	Unique      bool    `yaml:"uniqueOnly"`
+	BestPSM     bool    `yaml:"bestPSM"`
+	Raw         bool    `yaml:"raw"`
+	Faims       bool    `yaml:"faims"`
+	Align       bool    `yaml:"align"`
+	AlignMethod string  `yaml:"alignmentMethod"`
+	Feature     bool    `yaml:"featureDetection"`
+	Area        bool    `yaml:"peakArea"`
+	Rollup      string  `yaml:"rollup"`
+	RollupTopN  int     `yaml:"rollupTopN"`
+	RollupPep   string  `yaml:"rollupPeptides"`
+	MinPeptides int     `yaml:"minPeptides"`
+	IBAQ        bool    `yaml:"ibaq"`
+	Enzyme      string  `yaml:"enzyme"`
+	Ploidy      float64 `yaml:"ploidy"`
+	GenomeSize  float64 `yaml:"genomeSize"`
+	Label       string  `yaml:"ms1Labeling"`
+	LabelNames  map[string]string
+	// ImpurityChannels and ImpurityMatrix record the isotopic impurity correction applied to the reporter ions
+	ImpurityChannels []string
+	ImpurityMatrix   [][]float64
 }
 
 // Abacus options ad parameters
 type Abacus struct {
-	Tag      string  `yaml:"tag"`
-	ProtProb float64 `yaml:"proteinProbability"`
-	PepProb  float64 `yaml:"peptideProbability"`
-	Peptide  bool    `yaml:"peptide"`
-	Protein  bool    `yaml:"protein"`
-	Razor    bool    `yaml:"razor"`
-	Picked   bool    `yaml:"picked"`
-	Labels   bool    `yaml:"labels"`
-	Unique   bool    `yaml:"uniqueOnly"`
-	Reprint  bool    `yaml:"reprint"`
-	Full     bool    `yaml:"full"`
+	Tag         string  `yaml:"tag"`
+	ProtProb    float64 `yaml:"proteinProbability"`
+	PepProb     float64 `yaml:"peptideProbability"`
+	Peptide     bool    `yaml:"peptide"`
+	Protein     bool    `yaml:"protein"`
+	Gene        bool    `yaml:"gene"`
+	MBR         bool    `yaml:"matchBetweenRuns"`
+	MBRFDR      float64 `yaml:"mbrFDR"`
+	MBRRTWin    float64 `yaml:"mbrRetentionTimeWindow"`
+	MBRIMWin    float64 `yaml:"mbrIonMobilityWindow"`
+	Align       bool    `yaml:"align"`
+	AlignMethod string  `yaml:"alignmentMethod"`
+	Norm        string  `yaml:"normalization"`
+	NormRef     string  `yaml:"normalizationReferences"`
+	Impute      string  `yaml:"imputation"`
+	ImputeWidth float64 `yaml:"imputationWidth"`
+	ImputeShift float64 `yaml:"imputationShift"`
+	ImputeK     int     `yaml:"imputationNeighbours"`
+	Razor       bool    `yaml:"razor"`
+	Picked      bool    `yaml:"picked"`
+	Site        bool    `yaml:"site"`
+	MSstats     bool    `yaml:"msstats"`
+	Design      string  `yaml:"design"`
+	Labels      bool    `yaml:"labels"`
+	Unique      bool    `yaml:"uniqueOnly"`
+	Reprint     bool    `yaml:"reprint"`
+	Full        bool    `yaml:"full"`
+	Parquet     bool    `yaml:"parquet"`
+	Long        bool    `yaml:"parquetLong"`
 }
 
 // BioQuant options and parameters
@@ -409,10 +453,17 @@ type BioQuant struct {
 
 // Report options and parameters
 type Report struct {
-	Decoys  bool `yaml:"withDecoys"`
-	MSstats bool `yaml:"msstats"`
-	MZID    bool `yaml:"mzID"`
-	IonMob  bool `yaml:"ionmobility"`
+	Decoys   bool   `yaml:"withDecoys"`
+	MSstats  bool   `yaml:"msstats"`
+	MZID     bool   `yaml:"mzID"`
+	MzIDFile string `yaml:"mzIDFile"`
+	MzTab    bool   `yaml:"mzTab"`
+	SQLite   string `yaml:"sqlite"`
+	Parquet  bool   `yaml:"parquet"`
+	Long     bool   `yaml:"parquetLong"`
+	Template string `yaml:"template"`
+	Design   string `yaml:"design"`
+	IonMob   bool   `yaml:"ionmobility"`
 }
 
 // TMTIntegrator options and parameters
@@ -566,3 +617,32 @@ func (d PeptideProphet) ToCmdString() {
 	logrus.Info(cmd)
 
 }
+
+// RollupName describes the peptide to protein rollup strategy used for the quantification, the default strategy of
+// the quantification has no name
+func (d Quantify) RollupName(defaultMethod string) string {
+
+	if len(d.Rollup) == 0 {
+		return ""
+	}
+
+	if d.Rollup == defaultMethod && (d.Rollup != "topN" || d.RollupTopN == 0 || d.RollupTopN == 3) &&
+		(len(d.RollupPep) == 0 || d.RollupPep == "all") && d.MinPeptides <= 1 {
+		return ""
+	}
+
+	name := d.Rollup
+	if d.Rollup == "topN" {
+		name = fmt.Sprintf("top%d", d.RollupTopN)
+	}
+
+	if len(d.RollupPep) > 0 && d.RollupPep != "all" {
+		name += ", " + d.RollupPep
+	}
+
+	if d.MinPeptides > 1 {
+		name += fmt.Sprintf(", min %d peptides", d.MinPeptides)
+	}
+
+	return name
+}
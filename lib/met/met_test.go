@@ -50,3 +50,24 @@ func TestMetaData(t *testing.T) {
 	}
 
 }
+
+func TestRollupName(t *testing.T) {
+
+	tests := []struct {
+		quantify      met.Quantify
+		defaultMethod string
+		want          string
+	}{
+		{met.Quantify{Rollup: "topN", RollupTopN: 3, RollupPep: "all"}, "topN", ""},
+		{met.Quantify{Rollup: "sum", RollupTopN: 3, RollupPep: "all"}, "sum", ""},
+		{met.Quantify{Rollup: "topN", RollupTopN: 5, RollupPep: "all"}, "topN", "top5"},
+		{met.Quantify{Rollup: "sum", RollupTopN: 3, RollupPep: "all"}, "topN", "sum"},
+		{met.Quantify{Rollup: "median", RollupPep: "unique", MinPeptides: 2}, "sum", "median, unique, min 2 peptides"},
+	}
+
+	for _, i := range tests {
+		if got := i.quantify.RollupName(i.defaultMethod); got != i.want {
+			t.Errorf("RollupName(%s) = %q, want %q", i.defaultMethod, got, i.want)
+		}
+	}
+}
@@ -41,6 +41,7 @@ type Spectrum struct {
 	Mz                  Mz
 	Intensity           Intensity
 	IonMobility         IonMobility
+	Noise               Noise
 }
 
 // Precursor struct
@@ -80,6 +81,23 @@ type IonMobility struct {
 	Compression   string
 }
 
+// Noise struct holds the Orbitrap noise data, either sampled along the scan or given for each centroid
+type Noise struct {
+	Mz           NoiseArray
+	Intensity    NoiseArray
+	Baseline     NoiseArray
+	PeakNoise    NoiseArray
+	PeakBaseline NoiseArray
+}
+
+// NoiseArray struct
+type NoiseArray struct {
+	Stream        []byte
+	DecodedStream []float64
+	Precision     string
+	Compression   string
+}
+
 func (a Spectra) Len() int           { return len(a) }
 func (a Spectra) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a Spectra) Less(i, j int) bool { return a[i].Index < a[j].Index }
@@ -350,7 +368,17 @@ func processSpectrum(mzSpec psi.Spectrum) Spectrum {
 		}
 	}
 
-	if mzSpec.BinaryDataArrayList.Count == 3 {
+	// the noise arrays are identified by their terms, any other third array has the ion mobility
+	var hasNoise bool
+	for _, i := range mzSpec.BinaryDataArrayList.BinaryDataArray[2:] {
+		if a := noiseArray(&spec.Noise, i.CVParam); a != nil {
+			a.Stream = i.Binary.Value
+			a.Precision, a.Compression = binaryFormat(i.CVParam)
+			hasNoise = true
+		}
+	}
+
+	if mzSpec.BinaryDataArrayList.Count == 3 && !hasNoise {
 		spec.IonMobility.Stream = mzSpec.BinaryDataArrayList.BinaryDataArray[2].Binary.Value
 		for _, j := range mzSpec.BinaryDataArrayList.BinaryDataArray[2].CVParam {
 			if string(j.Accession) == "MS:1000523" {
@@ -386,6 +414,112 @@ func (s *Spectrum) Decode() {
 		s.IonMobility.Stream = nil
 	}
 
+	for _, i := range []*NoiseArray{&s.Noise.Mz, &s.Noise.Intensity, &s.Noise.Baseline, &s.Noise.PeakNoise, &s.Noise.PeakBaseline} {
+		if len(i.Stream) > 0 {
+			i.DecodedStream = readEncoded(i.Stream, i.Precision, i.Compression)
+			i.Stream = nil
+		}
+	}
+
+}
+
+// noiseArray returns the noise array described by the binary array terms, or nil for other arrays; the sampled
+// noise arrays have their own terms, the centroid noise arrays are non-standard arrays named after their content
+func noiseArray(n *Noise, cv []psi.CVParam) *NoiseArray {
+
+	for _, j := range cv {
+		switch j.Accession {
+		case "MS:1002743":
+			return &n.Mz
+		case "MS:1002744":
+			return &n.Intensity
+		case "MS:1002745":
+			return &n.Baseline
+		case "MS:1000786":
+			if strings.Contains(strings.ToLower(j.Value), "baseline") {
+				return &n.PeakBaseline
+			} else if strings.Contains(strings.ToLower(j.Value), "noise") {
+				return &n.PeakNoise
+			}
+		}
+	}
+
+	return nil
+}
+
+// binaryFormat returns the precision and compression of a binary array
+func binaryFormat(cv []psi.CVParam) (string, string) {
+
+	var precision, compression string
+	for _, j := range cv {
+		if j.Accession == "MS:1000523" {
+			precision = "64"
+		} else if j.Accession == "MS:1000521" {
+			precision = "32"
+		}
+
+		if j.Accession == "MS:1000574" {
+			compression = "1"
+		} else if j.Accession == "MS:1000576" {
+			compression = "0"
+		}
+	}
+
+	return precision, compression
+}
+
+// HasNoise checks if the spectrum carries noise data
+func (s Spectrum) HasNoise() bool {
+	return len(s.Noise.PeakNoise.DecodedStream) == len(s.Mz.DecodedStream) && len(s.Mz.DecodedStream) > 0 ||
+		len(s.Noise.Mz.DecodedStream) > 0 && len(s.Noise.Mz.DecodedStream) == len(s.Noise.Intensity.DecodedStream)
+}
+
+// SignalToNoise returns the signal to noise ratio of a peak, the baseline is removed from the signal and from the
+// noise when it is available; the sampled noise is interpolated at the peak m/z. It is zero without noise data
+func (s Spectrum) SignalToNoise(peak int) float64 {
+
+	var noise, baseline float64
+
+	if len(s.Noise.PeakNoise.DecodedStream) == len(s.Mz.DecodedStream) && peak < len(s.Mz.DecodedStream) {
+		noise = s.Noise.PeakNoise.DecodedStream[peak]
+		if len(s.Noise.PeakBaseline.DecodedStream) == len(s.Mz.DecodedStream) {
+			baseline = s.Noise.PeakBaseline.DecodedStream[peak]
+		}
+	} else if len(s.Noise.Mz.DecodedStream) > 0 && len(s.Noise.Mz.DecodedStream) == len(s.Noise.Intensity.DecodedStream) {
+		mz := s.Mz.DecodedStream[peak]
+		noise = interpolate(s.Noise.Mz.DecodedStream, s.Noise.Intensity.DecodedStream, mz)
+		if len(s.Noise.Baseline.DecodedStream) == len(s.Noise.Mz.DecodedStream) {
+			baseline = interpolate(s.Noise.Mz.DecodedStream, s.Noise.Baseline.DecodedStream, mz)
+		}
+	}
+
+	if noise-baseline <= 0 {
+		if noise <= 0 {
+			return 0
+		}
+		baseline = 0
+	}
+
+	return math.Max(0, s.Intensity.DecodedStream[peak]-baseline) / (noise - baseline)
+}
+
+// interpolate returns the linear interpolation of the sampled values at x, the samples are sorted by x
+func interpolate(xs, ys []float64, x float64) float64 {
+
+	if x <= xs[0] {
+		return ys[0]
+	}
+
+	for i := 1; i < len(xs); i++ {
+		if x <= xs[i] {
+			if xs[i] == xs[i-1] {
+				return ys[i]
+			}
+			return ys[i-1] + (ys[i]-ys[i-1])*(x-xs[i-1])/(xs[i]-xs[i-1])
+		}
+	}
+
+	return ys[len(ys)-1]
 }
 
 // readEncoded transforms the binary data into float64 values
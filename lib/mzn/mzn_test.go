@@ -108,3 +108,36 @@ func TestMS2Spectra(t *testing.T) {
 		t.Errorf("Spectrum number is incorrect, got %f, want %f", spec.Precursor.IsolationWindowLowerOffset, 0.34999999404)
 	}
 }
+
+func TestSpectrum_SignalToNoise(t *testing.T) {
+
+	var s mzn.Spectrum
+	s.Mz.DecodedStream = []float64{126.1277, 127.1248, 128.1344}
+	s.Intensity.DecodedStream = []float64{12000, 600, 30000}
+
+	if s.HasNoise() {
+		t.Errorf("spectrum without noise data reported as having noise")
+	}
+
+	// sampled noise is interpolated at the peak m/z
+	s.Noise.Mz.DecodedStream = []float64{120, 130}
+	s.Noise.Intensity.DecodedStream = []float64{1000, 2000}
+	s.Noise.Baseline.DecodedStream = []float64{0, 0}
+
+	if !s.HasNoise() {
+		t.Errorf("sampled noise data not detected")
+	}
+
+	want := 12000 / (1000 + 1000*(126.1277-120)/10)
+	if got := s.SignalToNoise(0); got < want-1e-6 || got > want+1e-6 {
+		t.Errorf("SignalToNoise() = %v, want %v", got, want)
+	}
+
+	// centroid noise takes precedence and the baseline is removed
+	s.Noise.PeakNoise.DecodedStream = []float64{1100, 300, 1500}
+	s.Noise.PeakBaseline.DecodedStream = []float64{100, 100, 500}
+
+	if got := s.SignalToNoise(2); got != 29.5 {
+		t.Errorf("SignalToNoise() = %v, want %v", got, 29.5)
+	}
+}
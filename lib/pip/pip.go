@@ -552,7 +552,7 @@ func FreeQuant(meta met.Data, p Directives, dir string, data []string) met.Data
 		meta.Quantify.Pex = fmt.Sprintf("%s%sinteract.pep.xml", dsAbs, string(filepath.Separator))
 		meta.Quantify.Tag = "rev_"
 
-		qua.RunLabelFreeQuantification(meta.Quantify)
+		qua.RunLabelFreeQuantification(meta.Quantify, meta.Temp)
 
 		meta.Serialize()
 
@@ -595,7 +595,7 @@ func LabelQuant(meta met.Data, p Directives, dir string, data []string) met.Data
 		meta.Quantify.Pex = fmt.Sprintf("%s%sinteract.pep.xml", dsAbs, string(filepath.Separator))
 		meta.Quantify.Tag = "rev_"
 
-		meta.Quantify = qua.RunIsobaricLabelQuantification(meta.Quantify, meta.Filter.Mapmods)
+		meta.Quantify = qua.RunIsobaricLabelQuantification(meta.Quantify, meta.Filter.Mapmods, meta.Temp)
 
 		meta.Serialize()
 
@@ -14,11 +14,11 @@ type MzIdentML struct {
 	Xmlns                      string                     `xml:"xmlns,attr"`
 	XmlnsXsi                   string                     `xml:"xmlns:xsi,attr"`
 	XsiSchemaLocation          string                     `xml:"xsi:schemaLocation,attr"`
-	CvList                     CvList                     `xml:"cvList"`
+	CvList                     IdentCvList                `xml:"cvList"`
 	AnalysisSoftwareList       AnalysisSoftwareList       `xml:"AnalysisSoftwareList"`
-	Provider                   Provider                   `xml:"Provider"`
-	AuditCollection            AuditCollection            `xml:"AuditCollection"`
-	AnalysisSampleCollection   AnalysisSampleCollection   `xml:"AnalysisSampleCollection"`
+	Provider                   *Provider                  `xml:"Provider,omitempty"`
+	AuditCollection            *AuditCollection           `xml:"AuditCollection,omitempty"`
+	AnalysisSampleCollection   *AnalysisSampleCollection  `xml:"AnalysisSampleCollection,omitempty"`
 	SequenceCollection         SequenceCollection         `xml:"SequenceCollection"`
 	AnalysisCollection         AnalysisCollection         `xml:"AnalysisCollection"`
 	AnalysisProtocolCollection AnalysisProtocolCollection `xml:"AnalysisProtocolCollection"`
@@ -26,6 +26,23 @@ type MzIdentML struct {
 	BibliographicReference     []BibliographicReference   `xml:"BibliographicReference"`
 }
 
+// IdentCvList is the list of controlled vocabularies used in the file, unlike
+// mzML the list has no counter and the address is written as uri
+type IdentCvList struct {
+	XMLName xml.Name  `xml:"cvList"`
+	CV      []IdentCV `xml:"cv"`
+}
+
+// IdentCV is a source controlled vocabulary from which cvParams will be
+// obtained
+type IdentCV struct {
+	XMLName  xml.Name `xml:"cv"`
+	ID       string   `xml:"id,attr"`
+	FullName string   `xml:"fullName,attr"`
+	Version  string   `xml:"version,attr,omitempty"`
+	URI      string   `xml:"uri,attr"`
+}
+
 // AnalysisSoftwareList is the software packages used to perform the analyses
 type AnalysisSoftwareList struct {
 	XMLName          xml.Name           `xml:"AnalysisSoftwareList"`
@@ -34,14 +51,14 @@ type AnalysisSoftwareList struct {
 
 // AnalysisSoftware is the software used for performing the analysis
 type AnalysisSoftware struct {
-	XMLName        xml.Name       `xml:"AnalysisSoftware"`
-	ID             string         `xml:"id,attr,omitempty"`
-	Name           string         `xml:"name,attr,omitempty"`
-	URI            string         `xml:"uri,attr,omitempty"`
-	Version        string         `xml:"version,attr,omitempty"`
-	ContactRole    ContactRole    `xml:"ContactRole"`
-	SoftwareName   SoftwareName   `xml:"SoftwareName"`
-	Customizations Customizations `xml:"Customizations"`
+	XMLName        xml.Name        `xml:"AnalysisSoftware"`
+	ID             string          `xml:"id,attr,omitempty"`
+	Name           string          `xml:"name,attr,omitempty"`
+	URI            string          `xml:"uri,attr,omitempty"`
+	Version        string          `xml:"version,attr,omitempty"`
+	ContactRole    *ContactRole    `xml:"ContactRole,omitempty"`
+	SoftwareName   SoftwareName    `xml:"SoftwareName"`
+	Customizations *Customizations `xml:"Customizations,omitempty"`
 }
 
 // ContactRole is the Contact that provided the document instance
@@ -60,9 +77,9 @@ type Role struct {
 // SoftwareName is the name of the analysis software package, sourced from a CV
 // if available
 type SoftwareName struct {
-	XMLName   xml.Name  `xml:"SoftwareName"`
-	CVParam   CVParam   `xml:"cvParam"`
-	UserParam UserParam `xml:"userParam"`
+	XMLName   xml.Name   `xml:"SoftwareName"`
+	CVParam   *CVParam   `xml:"cvParam,omitempty"`
+	UserParam *UserParam `xml:"userParam,omitempty"`
 }
 
 // Customizations is Any customizations to the software, such as alternative
@@ -75,11 +92,11 @@ type Customizations struct {
 // Provider is the Provider of the mzIdentML record in terms of the contact and
 // software
 type Provider struct {
-	XMLName             xml.Name    `xml:"Provider"`
-	AnalysisSoftwareRef string      `xml:"analysisSoftware_ref,attr,omitempty"`
-	ID                  string      `xml:"id,attr,omitempty"`
-	Name                string      `xml:"name,attr,omitempty"`
-	ContactRole         ContactRole `xml:"ContactRole"`
+	XMLName             xml.Name     `xml:"Provider"`
+	AnalysisSoftwareRef string       `xml:"analysisSoftware_ref,attr,omitempty"`
+	ID                  string       `xml:"id,attr,omitempty"`
+	Name                string       `xml:"name,attr,omitempty"`
+	ContactRole         *ContactRole `xml:"ContactRole,omitempty"`
 }
 
 // AuditCollection is the complete set of Contacts (people and organisations)
@@ -120,7 +137,7 @@ type Organization struct {
 	Name      string      `xml:"name,attr,omitempty"`
 	CVParam   []CVParam   `xml:"cvParam"`
 	UserParam []UserParam `xml:"userParam"`
-	Parent    Parent      `xml:"Parent"`
+	Parent    *Parent     `xml:"Parent,omitempty"`
 }
 
 // Parent is the containing organization (the university or business which a lab
@@ -166,7 +183,7 @@ type DBSequence struct {
 	Length            string      `xml:"length,attr,omitempty"`
 	Name              string      `xml:"name,attr,omitempty"`
 	SearchDatabaseRef string      `xml:"searchDatabase_ref,attr,omitempty"`
-	Seq               Seq         `xml:"Seq"`
+	Seq               *Seq        `xml:"Seq,omitempty"`
 	CVParam           []CVParam   `xml:"cvParam"`
 	UserParam         []UserParam `xml:"userParam"`
 }
@@ -257,7 +274,7 @@ type PeptideEvidence struct {
 type AnalysisCollection struct {
 	XMLName                xml.Name                 `xml:"AnalysisCollection"`
 	SpectrumIdentification []SpectrumIdentification `xml:"SpectrumIdentification"`
-	ProteinDetection       ProteinDetection         `xml:"ProteinDetection"`
+	ProteinDetection       *ProteinDetection        `xml:"ProteinDetection,omitempty"`
 }
 
 // SpectrumIdentification is an analysis which tries to identify peptides in
@@ -309,44 +326,44 @@ type InputSpectrumIdentifications struct {
 type AnalysisProtocolCollection struct {
 	XMLName                        xml.Name                         `xml:"AnalysisProtocolCollection"`
 	SpectrumIdentificationProtocol []SpectrumIdentificationProtocol `xml:"SpectrumIdentificationProtocol"`
-	ProteinDetectionProtocol       ProteinDetectionProtocol         `xml:"ProteinDetectionProtocol"`
+	ProteinDetectionProtocol       *ProteinDetectionProtocol        `xml:"ProteinDetectionProtocol,omitempty"`
 }
 
 // SpectrumIdentificationProtocol is the parameters and settings of a
 // SpectrumIdentification analysis
 type SpectrumIdentificationProtocol struct {
-	XMLName                xml.Name               `xml:"SpectrumIdentificationProtocol"`
-	AnalysisSoftwareRef    string                 `xml:"analysisSoftware_ref,attr,omitempty"`
-	ID                     string                 `xml:"id,attr,omitempty"`
-	Name                   string                 `xml:"name,attr,omitempty"`
-	SearchType             SearchType             `xml:"SearchType"`
-	AdditionalSearchParams AdditionalSearchParams `xml:"AdditionalSearchParams"`
-	ModificationParams     ModificationParams     `xml:"ModificationParams"`
-	Enzymes                Enzymes                `xml:"Enzymes"`
-	MassTable              []MassTable            `xml:"MassTable"`
-	FragmentTolerance      FragmentTolerance      `xml:"FragmentTolerance"`
-	ParentTolerance        ParentTolerance        `xml:"ParentTolerance"`
-	Threshold              Threshold              `xml:"Threshold"`
-	DatabaseFilters        DatabaseFilters        `xml:"DatabaseFilters"`
-	DatabaseTranslation    DatabaseTranslation    `xml:"DatabaseTranslation"`
+	XMLName                xml.Name                `xml:"SpectrumIdentificationProtocol"`
+	AnalysisSoftwareRef    string                  `xml:"analysisSoftware_ref,attr,omitempty"`
+	ID                     string                  `xml:"id,attr,omitempty"`
+	Name                   string                  `xml:"name,attr,omitempty"`
+	SearchType             SearchType              `xml:"SearchType"`
+	AdditionalSearchParams *AdditionalSearchParams `xml:"AdditionalSearchParams,omitempty"`
+	ModificationParams     *ModificationParams     `xml:"ModificationParams,omitempty"`
+	Enzymes                *Enzymes                `xml:"Enzymes,omitempty"`
+	MassTable              []MassTable             `xml:"MassTable"`
+	FragmentTolerance      *FragmentTolerance      `xml:"FragmentTolerance,omitempty"`
+	ParentTolerance        *ParentTolerance        `xml:"ParentTolerance,omitempty"`
+	Threshold              Threshold               `xml:"Threshold"`
+	DatabaseFilters        *DatabaseFilters        `xml:"DatabaseFilters,omitempty"`
+	DatabaseTranslation    *DatabaseTranslation    `xml:"DatabaseTranslation,omitempty"`
 }
 
 // ProteinDetectionProtocol is the parameters and settings of a
 // ProteinDetection process
 type ProteinDetectionProtocol struct {
-	XMLName             xml.Name       `xml:"ProteinDetectionProtocol"`
-	AnalysisSoftwareRef string         `xml:"analysisSoftware_ref,attr,omitempty"`
-	ID                  string         `xml:"id,attr,omitempty"`
-	Name                string         `xml:"name,attr,omitempty"`
-	AnalysisParams      AnalysisParams `xml:"AnalysisParams"`
-	Threshold           Threshold      `xml:"Threshold"`
+	XMLName             xml.Name        `xml:"ProteinDetectionProtocol"`
+	AnalysisSoftwareRef string          `xml:"analysisSoftware_ref,attr,omitempty"`
+	ID                  string          `xml:"id,attr,omitempty"`
+	Name                string          `xml:"name,attr,omitempty"`
+	AnalysisParams      *AnalysisParams `xml:"AnalysisParams,omitempty"`
+	Threshold           Threshold       `xml:"Threshold"`
 }
 
 // AnalysisParams is the parameters and settings for the protein detection given
 // as CV terms
 type AnalysisParams struct {
 	XMLName   xml.Name    `xml:"AnalysisParams"`
-	CVParam   CVParam     `xml:"cvParam"`
+	CVParam   []CVParam   `xml:"cvParam"`
 	UserParam []UserParam `xml:"userParam"`
 }
 
@@ -406,16 +423,16 @@ type Enzymes struct {
 // giving a regular expression or a CV term if a "standard" enzyme cleavage has
 // been performed
 type Enzyme struct {
-	XMLName         xml.Name   `xml:"Enzyme"`
-	CTermGain       string     `xml:"cTermGain,attr,omitempty"`
-	ID              string     `xml:"id,attr,omitempty"`
-	MinDistance     int        `xml:"minDistance,attr,omitempty"`
-	MissedCleavages int        `xml:"missedCleavages,attr,omitempty"`
-	NTermGain       string     `xml:"nTermGain,attr,omitempty"`
-	Name            string     `xml:"name,attr,omitempty"`
-	SemiSpecific    bool       `xml:"semiSpecific,attr,omitempty"`
-	SiteRegexp      SiteRegexp `xml:"SiteRegexp"`
-	EnzymeName      EnzymeName `xml:"EnzymeName"`
+	XMLName         xml.Name    `xml:"Enzyme"`
+	CTermGain       string      `xml:"cTermGain,attr,omitempty"`
+	ID              string      `xml:"id,attr,omitempty"`
+	MinDistance     int         `xml:"minDistance,attr,omitempty"`
+	MissedCleavages int         `xml:"missedCleavages,attr,omitempty"`
+	NTermGain       string      `xml:"nTermGain,attr,omitempty"`
+	Name            string      `xml:"name,attr,omitempty"`
+	SemiSpecific    bool        `xml:"semiSpecific,attr,omitempty"`
+	SiteRegexp      *SiteRegexp `xml:"SiteRegexp,omitempty"`
+	EnzymeName      *EnzymeName `xml:"EnzymeName,omitempty"`
 }
 
 // SiteRegexp is the Regular expression for specifying the enzyme cleavage site
@@ -559,18 +576,18 @@ type Inputs struct {
 // set of amino acid sequence entries, nucleotide databases (e.g. 6 frame
 // translated) or annotated spectra libraries
 type SearchDatabase struct {
-	XMLName                     xml.Name                    `xml:"SearchDatabase"`
-	ID                          string                      `xml:"id,attr,omitempty"`
-	Location                    string                      `xml:"location,attr,omitempty"`
-	Name                        string                      `xml:"name,attr,omitempty"`
-	NumDatabaseSequences        int                         `xml:"numDatabaseSequences,attr,omitempty"`
-	NumResidues                 string                      `xml:"numResidues,attr,omitempty"`
-	ReleaseDate                 string                      `xml:"releaseDate,attr,omitempty"`
-	Version                     string                      `xml:"version,attr,omitempty"`
-	ExternalFormatDocumentation ExternalFormatDocumentation `xml:"ExternalFormatDocumentation"`
-	FileFormat                  FileFormat                  `xml:"FileFormat"`
-	DatabaseName                DatabaseName                `xml:"DatabaseName"`
-	CVParam                     []CVParam                   `xml:"cvParam"`
+	XMLName                     xml.Name                     `xml:"SearchDatabase"`
+	ID                          string                       `xml:"id,attr,omitempty"`
+	Location                    string                       `xml:"location,attr,omitempty"`
+	Name                        string                       `xml:"name,attr,omitempty"`
+	NumDatabaseSequences        int                          `xml:"numDatabaseSequences,attr,omitempty"`
+	NumResidues                 string                       `xml:"numResidues,attr,omitempty"`
+	ReleaseDate                 string                       `xml:"releaseDate,attr,omitempty"`
+	Version                     string                       `xml:"version,attr,omitempty"`
+	ExternalFormatDocumentation *ExternalFormatDocumentation `xml:"ExternalFormatDocumentation,omitempty"`
+	FileFormat                  *FileFormat                  `xml:"FileFormat,omitempty"`
+	DatabaseName                DatabaseName                 `xml:"DatabaseName"`
+	CVParam                     []CVParam                    `xml:"cvParam"`
 }
 
 // ExternalFormatDocumentation is a URI to access documentation and tools to
@@ -592,20 +609,20 @@ type FileFormat struct {
 // exactly to one of the release databases listed in the CV, otherwise a
 // userParam should be used
 type DatabaseName struct {
-	XMLName   xml.Name  `xml:"DatabaseName"`
-	CVParam   CVParam   `xml:"cvParam"`
-	UserParam UserParam `xml:"userParam"`
+	XMLName   xml.Name   `xml:"DatabaseName"`
+	CVParam   *CVParam   `xml:"cvParam,omitempty"`
+	UserParam *UserParam `xml:"userParam,omitempty"`
 }
 
 // SpectraData should be used
 type SpectraData struct {
-	XMLName                     xml.Name                    `xml:"SpectraData"`
-	ID                          string                      `xml:"id,attr,omitempty"`
-	Location                    string                      `xml:"location,attr,omitempty"`
-	Name                        string                      `xml:"name,attr,omitempty"`
-	ExternalFormatDocumentation ExternalFormatDocumentation `xml:"ExternalFormatDocumentation"`
-	FileFormat                  FileFormat                  `xml:"FileFormat"`
-	SpectrumIDFormat            SpectrumIDFormat            `xml:"SpectrumIDFormat"`
+	XMLName                     xml.Name                     `xml:"SpectraData"`
+	ID                          string                       `xml:"id,attr,omitempty"`
+	Location                    string                       `xml:"location,attr,omitempty"`
+	Name                        string                       `xml:"name,attr,omitempty"`
+	ExternalFormatDocumentation *ExternalFormatDocumentation `xml:"ExternalFormatDocumentation,omitempty"`
+	FileFormat                  *FileFormat                  `xml:"FileFormat,omitempty"`
+	SpectrumIDFormat            SpectrumIDFormat             `xml:"SpectrumIDFormat"`
 }
 
 // SpectrumIDFormat is the format of the spectrum identifier within the source
@@ -620,7 +637,7 @@ type SpectrumIDFormat struct {
 type AnalysisData struct {
 	XMLName                    xml.Name                     `xml:"AnalysisData"`
 	SpectrumIdentificationList []SpectrumIdentificationList `xml:"SpectrumIdentificationList"`
-	ProteinDetectionList       ProteinDetectionList         `xml:"ProteinDetectionList"`
+	ProteinDetectionList       *ProteinDetectionList        `xml:"ProteinDetectionList,omitempty"`
 }
 
 // SpectrumIdentificationList is the set of all search results from
@@ -630,7 +647,7 @@ type SpectrumIdentificationList struct {
 	ID                           string                         `xml:"id,attr,omitempty"`
 	Name                         string                         `xml:"name,attr,omitempty"`
 	NumSequencesSearched         float64                        `xml:"numSequencesSearched,attr,omitempty"`
-	FragmentationTable           FragmentationTable             `xml:"FragmentationTable"`
+	FragmentationTable           *FragmentationTable            `xml:"FragmentationTable,omitempty"`
 	SpectrumIdentificationResult []SpectrumIdentificationResult `xml:"SpectrumIdentificationResult"`
 	CVParam                      []CVParam                      `xml:"cvParam"`
 	UserParam                    []UserParam                    `xml:"userParam"`
@@ -664,6 +681,8 @@ type SpectrumIdentificationResult struct {
 	SpectraDataRef             string                       `xml:"spectraData_ref,attr,omitempty"`
 	SpectrumID                 string                       `xml:"spectrumID,attr,omitempty"`
 	SpectrumIdentificationItem []SpectrumIdentificationItem `xml:"SpectrumIdentificationItem"`
+	CVParam                    []CVParam                    `xml:"cvParam"`
+	UserParam                  []UserParam                  `xml:"userParam"`
 }
 
 // SpectrumIdentificationItem is an identification of a single (poly)peptide,
@@ -685,7 +704,7 @@ type SpectrumIdentificationItem struct {
 	Rank                     uint8                `xml:"rank,attr,omitempty"`
 	SampleRef                string               `xml:"sample_ref,attr,omitempty"`
 	PeptideEvidenceRef       []PeptideEvidenceRef `xml:"PeptideEvidenceRef"`
-	Fragmentation            Fragmentation        `xml:"Fragmentation"`
+	Fragmentation            *Fragmentation       `xml:"Fragmentation,omitempty"`
 	CVParam                  []CVParam            `xml:"cvParam"`
 	UserParam                []UserParam          `xml:"userParam"`
 }
@@ -755,7 +774,7 @@ type ProteinDetectionHypothesis struct {
 	DBSquenceRef      string              `xml:"dBSequence_ref,attr,omitempty"`
 	ID                string              `xml:"id,attr,omitempty"`
 	Name              string              `xml:"name,attr,omitempty"`
-	PassThreshold     string              `xml:"PassThreshold,attr"`
+	PassThreshold     string              `xml:"passThreshold,attr"`
 	PeptideHypothesis []PeptideHypothesis `xml:"PeptideHypothesis"`
 	CVParam           []CVParam           `xml:"cvParam"`
 	UserParam         []UserParam         `xml:"userParam"`
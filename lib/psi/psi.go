@@ -3,15 +3,12 @@ package psi
 import (
 	"bytes"
 	"encoding/xml"
-	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 
 	"philosopher/lib/msg"
 
-	"philosopher/lib/sys"
-
 	"github.com/rogpeppe/go-charset/charset"
 	"github.com/sirupsen/logrus"
 
@@ -26,14 +23,14 @@ type DataFormat interface {
 
 // SourceFile is a file from which this instance was created
 type SourceFile struct {
-	XMLName                     xml.Name                    `xml:"SourceFile"`
-	ID                          string                      `xml:"id,attr,omitempty"`
-	Location                    string                      `xml:"location,attr,omitempty"`
-	Name                        string                      `xml:"name,attr,omitempty"`
-	ExternalFormatDocumentation ExternalFormatDocumentation `xml:"ExternalFormatDocumentation"`
-	FileFormat                  FileFormat                  `xml:"FileFormat"`
-	CVParam                     []CVParam                   `xml:"cvParam"`
-	UserParam                   []UserParam                 `xml:"userParam"`
+	XMLName                     xml.Name                     `xml:"SourceFile"`
+	ID                          string                       `xml:"id,attr,omitempty"`
+	Location                    string                       `xml:"location,attr,omitempty"`
+	Name                        string                       `xml:"name,attr,omitempty"`
+	ExternalFormatDocumentation *ExternalFormatDocumentation `xml:"ExternalFormatDocumentation,omitempty"`
+	FileFormat                  *FileFormat                  `xml:"FileFormat,omitempty"`
+	CVParam                     []CVParam                    `xml:"cvParam"`
+	UserParam                   []UserParam                  `xml:"userParam"`
 }
 
 // CvList is the container for one or more controlled vocabulary definitions
@@ -75,7 +72,7 @@ type UserParam struct {
 	Type          string   `xml:"type,attr,omitempty"`
 	UnitAccession string   `xml:"unitAccession,attr,omitempty"`
 	UnitCvRef     string   `xml:"unitCvRef,attr,omitempty"`
-	UnitName      string   `xml:"UnitName,attr,omitempty"`
+	UnitName      string   `xml:"unitName,attr,omitempty"`
 	Value         string   `xml:"value,attr,omitempty"`
 }
 
@@ -141,10 +138,8 @@ func (p *MzIdentML) Parse(f string) {
 
 }
 
-// Parse is the main function for parsing pepxml data
-func (p *MzIdentML) Write() {
-
-	output := fmt.Sprintf("%s%sreport.mzid", sys.MetaDir(), string(filepath.Separator))
+// Write encodes the mzIdentML document to the given file
+func (p *MzIdentML) Write(output string) {
 
 	file, e := os.Create(output)
 	if e != nil {
@@ -158,10 +153,6 @@ func (p *MzIdentML) Write() {
 	enc.Indent("", "   ")
 
 	if e := enc.Encode(p); e != nil {
-		msg.DecodeMsgPck(e, "trace")
+		msg.WriteFile(e, "fatal")
 	}
-
-	// copy to work directory
-	sys.CopyFile(output, filepath.Base(output))
-
 }
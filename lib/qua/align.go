@@ -0,0 +1,192 @@
+package qua
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"philosopher/lib/aln"
+	"philosopher/lib/id"
+	"philosopher/lib/met"
+	"philosopher/lib/msg"
+	"philosopher/lib/rep"
+	"philosopher/lib/uti"
+
+	"github.com/sirupsen/logrus"
+)
+
+// alignMinProbability is the minimum PSM probability for an ion to be used as an alignment anchor
+const alignMinProbability = 0.95
+
+// runAlignment maps the retention times of a run to and from the reference run
+type runAlignment struct {
+	ToReference   aln.Model
+	FromReference aln.Model
+}
+
+// RunRetentionTimeAlignment aligns the retention times from all runs of an abacus set to a common reference
+func RunRetentionTimeAlignment(a met.Abacus, session string, args []string) {
+
+	logrus.Info("Aligning retention times")
+
+	var datasets = make(map[string]*rep.Evidence)
+	var runs = make(map[string]map[id.IonFormType]float64)
+
+	for _, i := range args {
+
+		var e rep.Evidence
+		e.RestoreGranularWithPath(i)
+		datasets[i] = &e
+
+		for k, v := range collectAnchors(e.PSM) {
+			runs[alignmentRunName(i, k)] = v
+		}
+	}
+
+	alignments := alignRuns(runs, a.AlignMethod, session)
+
+	for k, v := range datasets {
+		setAlignedRetentionTimes(v, alignments, func(source string) string { return alignmentRunName(k, source) })
+		v.SerializeGranularWithPath(k)
+	}
+}
+
+// alignEvidence aligns the retention times from all runs of a workspace to a common reference
+func alignEvidence(evi rep.Evidence, method, session string) rep.Evidence {
+
+	logrus.Info("Aligning retention times")
+
+	alignments := alignRuns(collectAnchors(evi.PSM), method, session)
+
+	setAlignedRetentionTimes(&evi, alignments, func(source string) string { return source })
+
+	return evi
+}
+
+// alignmentRunName identifies a run from an abacus data set
+func alignmentRunName(dataset, source string) string {
+	return fmt.Sprintf("%s_%s", filepath.Base(dataset), source)
+}
+
+// collectAnchors returns the retention time in minutes of the confident target ions from each run
+func collectAnchors(psms rep.PSMEvidenceList) map[string]map[id.IonFormType]float64 {
+
+	var runs = make(map[string]map[id.IonFormType]float64)
+	var best = make(map[string]map[id.IonFormType]float64)
+
+	for _, i := range psms {
+
+		if i.IsDecoy || i.Probability < alignMinProbability {
+			continue
+		}
+
+		source := strings.Split(i.Spectrum, ".")[0]
+		if _, ok := runs[source]; !ok {
+			runs[source] = make(map[id.IonFormType]float64)
+			best[source] = make(map[id.IonFormType]float64)
+		}
+
+		if i.Probability > best[source][i.IonForm()] {
+			best[source][i.IonForm()] = i.Probability
+			runs[source][i.IonForm()] = i.RetentionTime / 60
+		}
+	}
+
+	return runs
+}
+
+// alignRuns fits a non-linear mapping between each run and the run with the highest number of anchors,
+// and writes the residual plots for each run
+func alignRuns(runs map[string]map[id.IonFormType]float64, method, session string) map[string]runAlignment {
+
+	var alignments = make(map[string]runAlignment)
+
+	var names []string
+	for k := range runs {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	if len(names) == 0 {
+		return alignments
+	}
+
+	var ref = names[0]
+	for _, i := range names {
+		if len(runs[i]) > len(runs[ref]) {
+			ref = i
+		}
+	}
+
+	logrus.Info("Using ", ref, " as the retention time reference")
+
+	for _, i := range names {
+
+		if i == ref {
+			alignments[i] = runAlignment{aln.Identity(), aln.Identity()}
+			continue
+		}
+
+		var x, y []float64
+		for k, v := range runs[i] {
+			if o, ok := runs[ref][k]; ok {
+				x = append(x, v)
+				y = append(y, o)
+			}
+		}
+
+		toRef, e := aln.Fit(x, y, method)
+		if e != nil {
+			msg.Custom(fmt.Errorf("cannot align %s: %s", i, e), "warning")
+			alignments[i] = runAlignment{aln.Identity(), aln.Identity()}
+			continue
+		}
+
+		fromRef, _ := aln.Fit(y, x, method)
+
+		residuals := toRef.Residuals(x, y)
+
+		var abs = make([]float64, len(residuals))
+		for j := range residuals {
+			abs[j] = math.Abs(residuals[j])
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"anchors":  len(x),
+			"residual": fmt.Sprintf("%.3f", uti.Median(abs)),
+		}).Info("Aligned ", i)
+
+		aln.PlotResiduals(session, i, x, residuals)
+
+		alignments[i] = runAlignment{toRef, fromRef}
+	}
+
+	return alignments
+}
+
+// setAlignedRetentionTimes maps the PSM retention times to the reference run, ions receive the median of their PSMs
+func setAlignedRetentionTimes(evi *rep.Evidence, alignments map[string]runAlignment, runName func(string) string) {
+
+	var ionRT = make(map[id.IonFormType][]float64)
+
+	for i := range evi.PSM {
+
+		source := strings.Split(evi.PSM[i].Spectrum, ".")[0]
+
+		a, ok := alignments[runName(source)]
+		if !ok {
+			a = runAlignment{aln.Identity(), aln.Identity()}
+		}
+
+		evi.PSM[i].AlignedRetentionTime = a.ToReference.Predict(evi.PSM[i].RetentionTime/60) * 60
+		ionRT[evi.PSM[i].IonForm()] = append(ionRT[evi.PSM[i].IonForm()], evi.PSM[i].AlignedRetentionTime)
+	}
+
+	for i := range evi.Ions {
+		if v, ok := ionRT[evi.Ions[i].IonForm()]; ok {
+			evi.Ions[i].AlignedRetentionTime = uti.Median(v)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package qua
+
+import (
+	"math"
+	"sort"
+
+	"philosopher/lib/bio"
+	"philosopher/lib/mzn"
+)
+
+const (
+	// isotopeSpacing is the mass difference between the 13C and the 12C isotopes
+	isotopeSpacing = 1.0033548
+	// featureIsotopes is the number of isotopic peaks traced for each precursor
+	featureIsotopes = 3
+	// featureMinPoints is the minimum number of scans inside the peak boundaries
+	featureMinPoints = 3
+	// featureBoundary is the fraction of the apex used as the peak boundary
+	featureBoundary = 0.05
+	// featureMinCorrelation is the minimum correlation between the observed and the theoretical isotope envelopes
+	featureMinCorrelation = 0.7
+)
+
+// feature is a precursor isotope envelope traced across the MS1 scans
+type feature struct {
+	Intensity          float64
+	Area               float64
+	FWHM               float64
+	IsotopeCorrelation float64
+	ApexRetentionTime  float64
+}
+
+// detectFeature traces the isotopic envelope of a precursor, detects the peak boundaries around the apex closest
+// to the identification, and integrates the smoothed chromatogram; retention times are in minutes. Features whose
+// isotope envelope does not match the theoretical one are rejected
+func detectFeature(ms1 mzn.Spectra, mass float64, charge int, rt, rtWin, ptWin, ppmPrecision float64, cv string) (feature, bool) {
+
+	var f feature
+
+	if charge < 1 {
+		return f, false
+	}
+
+	var times []float64
+	var traces = make([][]float64, featureIsotopes)
+
+	for _, s := range ms1 {
+
+		if s.ScanStartTime < rt-rtWin || s.ScanStartTime > rt+rtWin {
+			continue
+		}
+
+		if len(cv) > 0 && s.CompensationVoltage != cv {
+			continue
+		}
+
+		times = append(times, s.ScanStartTime)
+
+		for k := 0; k < featureIsotopes; k++ {
+			mz := (mass + (float64(k) * isotopeSpacing) + (float64(charge) * bio.Proton)) / float64(charge)
+			traces[k] = append(traces[k], maxPeak(s, mz, ppmPrecision))
+		}
+	}
+
+	if len(times) < featureMinPoints {
+		return f, false
+	}
+
+	smoothed := smoothTrace(traces[0])
+
+	apex, left, right, ok := peakBoundaries(times, smoothed, rt, ptWin)
+	if !ok {
+		return f, false
+	}
+
+	f.Intensity = smoothed[apex]
+	f.ApexRetentionTime = times[apex]
+	f.Area = integrate(times[left:right+1], smoothed[left:right+1])
+	f.FWHM = fullWidthHalfMaximum(times, smoothed, apex, left, right)
+
+	// the observed envelope is the summed intensity of each isotope inside the peak boundaries
+	var observed = make([]float64, featureIsotopes)
+	for k := range traces {
+		for i := left; i <= right; i++ {
+			observed[k] += traces[k][i]
+		}
+	}
+
+	f.IsotopeCorrelation = pearson(observed, theoreticalIsotopes(mass, featureIsotopes))
+	if f.IsotopeCorrelation < featureMinCorrelation {
+		return f, false
+	}
+
+	return f, true
+}
+
+// maxPeak returns the most intense peak inside the tolerance window
+func maxPeak(s mzn.Spectrum, mz, ppmPrecision float64) float64 {
+
+	lowi := sort.Search(len(s.Mz.DecodedStream), func(i int) bool { return s.Mz.DecodedStream[i] >= mz-ppmPrecision*mz })
+	highi := sort.Search(len(s.Mz.DecodedStream), func(i int) bool { return s.Mz.DecodedStream[i] >= mz+ppmPrecision*mz })
+
+	var maxI float64
+	for _, i := range s.Intensity.DecodedStream[lowi:highi] {
+		if i > maxI {
+			maxI = i
+		}
+	}
+
+	return maxI
+}
+
+// smoothTrace applies a 5-point weighted moving average
+func smoothTrace(trace []float64) []float64 {
+
+	var weights = []float64{1, 2, 3, 2, 1}
+	var smoothed = make([]float64, len(trace))
+
+	for i := range trace {
+		var sum, norm float64
+		for j, w := range weights {
+			k := i + j - 2
+			if k < 0 || k >= len(trace) {
+				continue
+			}
+			sum += w * trace[k]
+			norm += w
+		}
+		smoothed[i] = sum / norm
+	}
+
+	return smoothed
+}
+
+// peakBoundaries finds the apex inside the peak window, and expands the peak until the intensity drops
+// below the boundary fraction or starts rising again
+func peakBoundaries(times, trace []float64, rt, ptWin float64) (int, int, int, bool) {
+
+	var apex = -1
+	for i := range trace {
+		if times[i] > rt-ptWin && times[i] < rt+ptWin {
+			if apex == -1 || trace[i] > trace[apex] {
+				apex = i
+			}
+		}
+	}
+
+	if apex == -1 || trace[apex] <= 0 {
+		return 0, 0, 0, false
+	}
+
+	limit := trace[apex] * featureBoundary
+
+	left := apex
+	for left > 0 && trace[left-1] > limit && trace[left-1] <= trace[left] {
+		left--
+	}
+
+	right := apex
+	for right < len(trace)-1 && trace[right+1] > limit && trace[right+1] <= trace[right] {
+		right++
+	}
+
+	if right-left+1 < featureMinPoints {
+		return 0, 0, 0, false
+	}
+
+	return apex, left, right, true
+}
+
+// integrate calculates the area under the trace using the trapezoidal rule
+func integrate(times, trace []float64) float64 {
+
+	var area float64
+	for i := 1; i < len(trace); i++ {
+		area += (times[i] - times[i-1]) * (trace[i] + trace[i-1]) / 2
+	}
+
+	return area
+}
+
+// fullWidthHalfMaximum interpolates the peak width at half of the apex intensity
+func fullWidthHalfMaximum(times, trace []float64, apex, left, right int) float64 {
+
+	half := trace[apex] / 2
+
+	start := times[left]
+	for i := apex; i > left; i-- {
+		if trace[i-1] <= half {
+			start = interpolateTime(times[i-1], times[i], trace[i-1], trace[i], half)
+			break
+		}
+	}
+
+	end := times[right]
+	for i := apex; i < right; i++ {
+		if trace[i+1] <= half {
+			end = interpolateTime(times[i], times[i+1], trace[i], trace[i+1], half)
+			break
+		}
+	}
+
+	return end - start
+}
+
+func interpolateTime(t1, t2, i1, i2, target float64) float64 {
+	if i2 == i1 {
+		return t1
+	}
+	return t1 + ((target - i1) * (t2 - t1) / (i2 - i1))
+}
+
+// theoreticalIsotopes approximates the isotopic distribution of a peptide using a Poisson model with averagine composition
+func theoreticalIsotopes(mass float64, n int) []float64 {
+
+	lambda := (0.000594 * mass) - 0.03091
+	if lambda < 0 {
+		lambda = 0
+	}
+
+	var dist = make([]float64, n)
+	for k := 0; k < n; k++ {
+		dist[k] = math.Exp(-lambda) * math.Pow(lambda, float64(k)) / math.Gamma(float64(k+1))
+	}
+
+	return dist
+}
+
+// pearson calculates the Pearson correlation coefficient between two vectors
+func pearson(x, y []float64) float64 {
+
+	var mx, my float64
+	for i := range x {
+		mx += x[i]
+		my += y[i]
+	}
+	mx /= float64(len(x))
+	my /= float64(len(y))
+
+	var sxy, sxx, syy float64
+	for i := range x {
+		sxy += (x[i] - mx) * (y[i] - my)
+		sxx += (x[i] - mx) * (x[i] - mx)
+		syy += (y[i] - my) * (y[i] - my)
+	}
+
+	if sxx == 0 || syy == 0 {
+		return 0
+	}
+
+	return sxy / math.Sqrt(sxx*syy)
+}
@@ -0,0 +1,76 @@
+package qua
+
+import (
+	"math"
+	"testing"
+
+	"philosopher/lib/bio"
+	"philosopher/lib/mzn"
+)
+
+func TestPeakShape(t *testing.T) {
+
+	// gaussian peak centered at 10 minutes with sigma = 0.1
+	var times, trace []float64
+	for i := 0; i <= 100; i++ {
+		rt := 9.5 + (float64(i) * 0.01)
+		times = append(times, rt)
+		trace = append(trace, 1000*math.Exp(-math.Pow(rt-10, 2)/(2*0.01)))
+	}
+
+	apex, left, right, ok := peakBoundaries(times, trace, 10.02, 0.2)
+	if !ok {
+		t.Fatalf("Peak boundaries were not found")
+	}
+
+	if math.Abs(times[apex]-10) > 0.001 {
+		t.Errorf("Apex retention time is incorrect, got %f, want %f", times[apex], 10.0)
+	}
+
+	area := integrate(times[left:right+1], trace[left:right+1])
+	want := 1000 * 0.1 * math.Sqrt(2*math.Pi)
+	if math.Abs(area-want)/want > 0.05 {
+		t.Errorf("Peak area is incorrect, got %f, want %f", area, want)
+	}
+
+	fwhm := fullWidthHalfMaximum(times, trace, apex, left, right)
+	if math.Abs(fwhm-(2.3548*0.1)) > 0.005 {
+		t.Errorf("FWHM is incorrect, got %f, want %f", fwhm, 2.3548*0.1)
+	}
+
+	dist := theoreticalIsotopes(1500, 3)
+	if r := pearson(dist, theoreticalIsotopes(1500, 3)); math.Abs(r-1) > 1e-9 {
+		t.Errorf("Isotope correlation is incorrect, got %f, want %f", r, 1.0)
+	}
+}
+
+func TestDetectFeature(t *testing.T) {
+
+	mass := 1500.0
+	charge := 2
+
+	// gaussian elution profile with the given isotope envelope
+	scans := func(envelope []float64) mzn.Spectra {
+		var ms1 mzn.Spectra
+		for i := 0; i <= 60; i++ {
+			rt := 9.7 + (float64(i) * 0.01)
+			s := mzn.Spectrum{Level: "1", ScanStartTime: rt}
+			for k, j := range envelope {
+				mz := (mass + (float64(k) * isotopeSpacing) + (float64(charge) * bio.Proton)) / float64(charge)
+				s.Mz.DecodedStream = append(s.Mz.DecodedStream, mz)
+				s.Intensity.DecodedStream = append(s.Intensity.DecodedStream, j*1000*math.Exp(-math.Pow(rt-10, 2)/(2*0.01)))
+			}
+			ms1 = append(ms1, s)
+		}
+		return ms1
+	}
+
+	f, ok := detectFeature(scans(theoreticalIsotopes(mass, featureIsotopes)), mass, charge, 10, 0.3, 0.2, 10e-6, "")
+	if !ok {
+		t.Fatalf("Feature was not detected, isotope correlation %f", f.IsotopeCorrelation)
+	}
+
+	if _, ok := detectFeature(scans([]float64{0.2, 0.5, 1}), mass, charge, 10, 0.3, 0.2, 10e-6, ""); ok {
+		t.Errorf("Feature with a mismatched isotope envelope must be rejected")
+	}
+}
@@ -0,0 +1,113 @@
+package qua
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"philosopher/lib/bio"
+	"philosopher/lib/id"
+	"philosopher/lib/met"
+	"philosopher/lib/msg"
+	"philosopher/lib/rep"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// avogadro is the number of molecules in one mol
+	avogadro = 6.02214076e23
+	// basePairMass is the average mass of a DNA base pair in Daltons
+	basePairMass = 615.8771
+	// observableMinLength is the shortest observable tryptic peptide
+	observableMinLength = 6
+	// observableMaxLength is the longest observable tryptic peptide
+	observableMaxLength = 30
+)
+
+// histoneRegex identifies the core and linker histones by their description
+var histoneRegex = regexp.MustCompile(`(?i)histone\s+h(1|2a|2b|3|4)`)
+
+// ValidateIBAQ checks the digestion enzyme before the quantification starts
+func ValidateIBAQ(p met.Quantify) {
+	if p.IBAQ && !bio.SupportedEnzyme(p.Enzyme) {
+		msg.Custom(fmt.Errorf("the enzyme %s is not supported by the iBAQ calculation, use trypsin, lys_c, lys_n, chymotrypsin or glu_c", p.Enzyme), "fatal")
+	}
+}
+
+// razorIonIntensities sums the intensities of the unique and razor peptide ions of each protein, the absolute
+// abundances do not depend on the rollup strategy
+func razorIonIntensities(evi rep.Evidence) []float64 {
+
+	var ions = make(map[id.IonFormType]float64)
+	for _, i := range evi.Ions {
+		ions[i.IonForm()] = i.Intensity
+	}
+
+	var intensities = make([]float64, len(evi.Proteins))
+	for i := range evi.Proteins {
+		for k, v := range evi.Proteins[i].TotalPeptideIons {
+			if v.IsURazor {
+				intensities[i] += ions[k]
+			}
+		}
+	}
+
+	return intensities
+}
+
+// calculateAbsoluteAbundances calculates the iBAQ, riBAQ and the proteomic ruler copy numbers using the sum of the
+// razor peptide ion intensities
+func calculateAbsoluteAbundances(evi rep.Evidence, enzyme string, ploidy, genomeSize float64) rep.Evidence {
+
+	logrus.Info("Calculating absolute abundances")
+
+	var enz bio.Enzyme
+	enz.Synth(enzyme)
+
+	var ibaqSum, histoneSum float64
+
+	intensities := razorIonIntensities(evi)
+
+	for i := range evi.Proteins {
+
+		evi.Proteins[i].IBAQ = 0
+		evi.Proteins[i].RIBAQ = 0
+		evi.Proteins[i].CopyNumber = 0
+
+		if evi.Proteins[i].IsDecoy || len(evi.Proteins[i].Sequence) == 0 {
+			continue
+		}
+
+		observable := len(enz.Digest(evi.Proteins[i].Sequence, observableMinLength, observableMaxLength))
+		if observable > 0 {
+			evi.Proteins[i].IBAQ = intensities[i] / float64(observable)
+			ibaqSum += evi.Proteins[i].IBAQ
+		}
+
+		if histoneRegex.MatchString(evi.Proteins[i].Description) {
+			histoneSum += intensities[i]
+		}
+	}
+
+	if histoneSum == 0 {
+		msg.Custom(errors.New("no histone intensities found, the proteomic ruler copy numbers will not be calculated"), "warning")
+	}
+
+	// the histone mass is assumed to be equal to the DNA mass of the cell
+	dnaMass := ploidy * genomeSize * basePairMass / avogadro
+
+	for i := range evi.Proteins {
+
+		if ibaqSum > 0 {
+			evi.Proteins[i].RIBAQ = evi.Proteins[i].IBAQ / ibaqSum
+		}
+
+		if histoneSum > 0 && !evi.Proteins[i].IsDecoy && len(evi.Proteins[i].Sequence) > 0 {
+			proteinMass := (intensities[i] / histoneSum) * dnaMass
+			evi.Proteins[i].CopyNumber = proteinMass * avogadro / bio.AverageMass(evi.Proteins[i].Sequence)
+		}
+	}
+
+	return evi
+}
@@ -0,0 +1,42 @@
+package qua
+
+import (
+	"math"
+	"testing"
+
+	"philosopher/lib/id"
+	"philosopher/lib/rep"
+)
+
+func TestAbsoluteAbundances(t *testing.T) {
+
+	ions := rep.IonEvidenceList{
+		{Sequence: "PEPTIDEK", ChargeState: 2, PeptideMass: 900.5, Intensity: 100, IsURazor: true},
+		{Sequence: "SAMPLEKR", ChargeState: 2, PeptideMass: 950.5, Intensity: 300, IsURazor: true},
+		{Sequence: "SHAREDAK", ChargeState: 3, PeptideMass: 800.5, Intensity: 1000},
+	}
+
+	var peptides = make(map[id.IonFormType]rep.IonEvidence)
+	for _, i := range ions {
+		peptides[i.IonForm()] = i
+	}
+
+	evi := rep.Evidence{
+		Ions: ions,
+		Proteins: rep.ProteinEvidenceList{
+			// the rollup keeps the most intense ion only, the iBAQ must still use every razor ion
+			{Sequence: "MAPEPTIDEKGGSAMPLEKR", URazorIntensity: 300, TotalPeptideIons: peptides},
+		},
+	}
+
+	evi = calculateAbsoluteAbundances(evi, "trypsin", 2, 3.2e9)
+
+	// MAPEPTIDEK and GGSAMPLEK are observable, R is too short
+	if v := evi.Proteins[0].IBAQ; math.Abs(v-200) > 1e-9 {
+		t.Errorf("iBAQ is incorrect, got %f, want %f", v, 200.0)
+	}
+
+	if v := evi.Proteins[0].RIBAQ; math.Abs(v-1) > 1e-9 {
+		t.Errorf("riBAQ is incorrect, got %f, want %f", v, 1.0)
+	}
+}
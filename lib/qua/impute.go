@@ -0,0 +1,264 @@
+package qua
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"philosopher/lib/msg"
+	"philosopher/lib/sys"
+	"philosopher/lib/uti"
+)
+
+const (
+	// ImputeDownshift draws missing values from a normal distribution shifted below the observed intensities
+	ImputeDownshift = "downshift"
+	// ImputeMinProb draws missing values from a normal distribution centered on a low quantile of each sample
+	ImputeMinProb = "minprob"
+	// ImputeKNN averages the intensities of the most similar rows
+	ImputeKNN = "knn"
+	// ImputeNA keeps the missing values and reports them as NA
+	ImputeNA = "na"
+
+	// imputeSeed keeps the random imputations reproducible
+	imputeSeed = 1
+	// minProbQuantile is the quantile of the observed intensities used as the minimum-probability center
+	minProbQuantile = 0.01
+)
+
+// Imputation defines how missing values are replaced in a feature by sample matrix
+type Imputation struct {
+	Method string
+	Width  float64
+	Shift  float64
+	K      int
+}
+
+// ValidateImputation checks the imputation parameters before the combined reports are created
+func ValidateImputation(method string, width, shift float64, k int) {
+
+	switch method {
+	case "", ImputeNA:
+	case ImputeDownshift, ImputeMinProb:
+		if width <= 0 {
+			msg.Custom(errors.New("the imputation width must be positive"), "fatal")
+		}
+		if method == ImputeDownshift && shift < 0 {
+			msg.Custom(errors.New("the imputation downshift cannot be negative"), "fatal")
+		}
+	case ImputeKNN:
+		if k < 1 {
+			msg.Custom(errors.New("the KNN imputation needs at least one neighbour"), "fatal")
+		}
+	default:
+		msg.Custom(fmt.Errorf("unknown imputation method: %s", method), "fatal")
+	}
+}
+
+// Impute replaces the missing values of a feature by sample matrix, zeros are missing values; the mask marks the
+// imputed cells, and the NA method replaces them by NaN. Samples without any observed value are not imputed
+func (p Imputation) Impute(matrix [][]float64) ([][]float64, [][]bool) {
+
+	var imputed = make([][]float64, len(matrix))
+	var mask = make([][]bool, len(matrix))
+	for i := range matrix {
+		imputed[i] = make([]float64, len(matrix[i]))
+		mask[i] = make([]bool, len(matrix[i]))
+		copy(imputed[i], matrix[i])
+	}
+
+	if len(matrix) == 0 || len(p.Method) == 0 {
+		return imputed, mask
+	}
+
+	columns := len(matrix[0])
+
+	// the distributions are estimated on the log2 scale
+	var observed = make([][]float64, columns)
+	for j := 0; j < columns; j++ {
+		for i := range matrix {
+			if matrix[i][j] > 0 {
+				observed[j] = append(observed[j], math.Log2(matrix[i][j]))
+			}
+		}
+		sort.Float64s(observed[j])
+	}
+
+	var r = rand.New(rand.NewSource(imputeSeed))
+	var rowSD = medianRowDeviation(matrix)
+
+	for j := 0; j < columns; j++ {
+
+		if len(observed[j]) == 0 {
+			continue
+		}
+
+		mean, sd := meanDeviation(observed[j])
+
+		for i := range matrix {
+
+			if matrix[i][j] > 0 {
+				continue
+			}
+
+			mask[i][j] = true
+
+			switch p.Method {
+			case ImputeNA:
+				imputed[i][j] = math.NaN()
+			case ImputeDownshift:
+				imputed[i][j] = math.Pow(2, mean-(p.Shift*sd)+(r.NormFloat64()*p.Width*sd))
+			case ImputeMinProb:
+				deviation := rowSD
+				if deviation == 0 {
+					deviation = sd
+				}
+				imputed[i][j] = math.Pow(2, quantile(observed[j], minProbQuantile)+(r.NormFloat64()*p.Width*deviation))
+			case ImputeKNN:
+				imputed[i][j] = nearestNeighbours(matrix, i, j, p.K, observed[j][0])
+			}
+		}
+	}
+
+	return imputed, mask
+}
+
+// meanDeviation returns the mean and the sample standard deviation of the values
+func meanDeviation(values []float64) (float64, float64) {
+
+	var mean float64
+	for _, i := range values {
+		mean += i
+	}
+	mean /= float64(len(values))
+
+	if len(values) < 2 {
+		return mean, 0
+	}
+
+	var ss float64
+	for _, i := range values {
+		ss += (i - mean) * (i - mean)
+	}
+
+	return mean, math.Sqrt(ss / float64(len(values)-1))
+}
+
+// medianRowDeviation returns the median standard deviation of the log2 intensities of the rows with at least two values
+func medianRowDeviation(matrix [][]float64) float64 {
+
+	var deviations []float64
+	for i := range matrix {
+
+		var values []float64
+		for _, v := range matrix[i] {
+			if v > 0 {
+				values = append(values, math.Log2(v))
+			}
+		}
+
+		if len(values) > 1 {
+			_, sd := meanDeviation(values)
+			deviations = append(deviations, sd)
+		}
+	}
+
+	if len(deviations) == 0 {
+		return 0
+	}
+
+	return uti.Median(deviations)
+}
+
+// nearestNeighbours averages the log2 intensities of the k closest rows that were observed on the sample, the distance
+// is the root mean square difference over the shared samples; the fallback is used when no neighbour is found
+func nearestNeighbours(matrix [][]float64, row, column, k int, fallback float64) float64 {
+
+	type neighbour struct {
+		distance float64
+		value    float64
+	}
+
+	var neighbours []neighbour
+	for i := range matrix {
+
+		if i == row || matrix[i][column] <= 0 {
+			continue
+		}
+
+		var sum float64
+		var shared int
+		for j := range matrix[i] {
+			if j == column || matrix[row][j] <= 0 || matrix[i][j] <= 0 {
+				continue
+			}
+			d := math.Log2(matrix[row][j]) - math.Log2(matrix[i][j])
+			sum += d * d
+			shared++
+		}
+
+		if shared == 0 {
+			continue
+		}
+
+		neighbours = append(neighbours, neighbour{math.Sqrt(sum / float64(shared)), math.Log2(matrix[i][column])})
+	}
+
+	if len(neighbours) == 0 {
+		return math.Pow(2, fallback)
+	}
+
+	sort.SliceStable(neighbours, func(a, b int) bool { return neighbours[a].distance < neighbours[b].distance })
+
+	var sum float64
+	var n int
+	for n < k && n < len(neighbours) {
+		sum += neighbours[n].value
+		n++
+	}
+
+	return math.Pow(2, sum/float64(n))
+}
+
+// WriteImputationMask writes the imputed cells of a combined report, 1 marks an imputed or missing value
+func WriteImputationMask(session, name string, keys, rows, columns []string, mask [][]bool) {
+
+	output := fmt.Sprintf("%s%s%s", session, string(filepath.Separator), name)
+
+	file, e := os.Create(output)
+	if e != nil {
+		msg.WriteFile(errors.New("cannot create the imputation mask"), "error")
+	}
+	defer file.Close()
+
+	_, e = io.WriteString(file, strings.Join(append(keys, columns...), "\t")+"\n")
+	if e != nil {
+		msg.WriteToFile(e, "fatal")
+	}
+
+	for i := range rows {
+
+		line := rows[i]
+		for _, j := range mask[i] {
+			if j {
+				line += "\t1"
+			} else {
+				line += "\t0"
+			}
+		}
+
+		_, e = io.WriteString(file, line+"\n")
+		if e != nil {
+			msg.WriteToFile(e, "fatal")
+		}
+	}
+
+	// copy to work directory
+	sys.CopyFile(output, filepath.Base(output))
+}
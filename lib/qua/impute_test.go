@@ -0,0 +1,55 @@
+package qua
+
+import (
+	"math"
+	"testing"
+)
+
+func TestImputation_Impute(t *testing.T) {
+
+	var matrix = [][]float64{
+		{1000, 1100, 0},
+		{2000, 2100, 2050},
+		{4000, 0, 4100},
+		{8000, 8200, 7900},
+	}
+
+	for _, method := range []string{ImputeDownshift, ImputeMinProb, ImputeKNN, ImputeNA} {
+
+		p := Imputation{Method: method, Width: 0.3, Shift: 1.8, K: 1}
+		imputed, mask := p.Impute(matrix)
+
+		if !mask[0][2] || !mask[2][1] || mask[0][0] {
+			t.Errorf("%s imputation mask is incorrect, got %v", method, mask)
+		}
+
+		if imputed[1][1] != 2100 {
+			t.Errorf("%s imputation changed an observed value, got %f", method, imputed[1][1])
+		}
+
+		if matrix[0][2] != 0 {
+			t.Errorf("%s imputation modified the input matrix", method)
+		}
+
+		switch method {
+		case ImputeNA:
+			if !math.IsNaN(imputed[0][2]) {
+				t.Errorf("NA imputation must keep the missing value, got %f", imputed[0][2])
+			}
+		case ImputeKNN:
+			// the closest row to the third one is the last one, observed at 8200 on the missing sample
+			if math.Abs(imputed[2][1]-8200) > 1e-6 || math.Abs(imputed[0][2]-2050) > 1e-6 {
+				t.Errorf("KNN imputation is incorrect, got %f and %f", imputed[2][1], imputed[0][2])
+			}
+		case ImputeDownshift:
+			if imputed[0][2] <= 0 || imputed[0][2] >= 2050 {
+				t.Errorf("downshift imputation must be below the observed intensities, got %f", imputed[0][2])
+			}
+		case ImputeMinProb:
+			// centered on the lowest observed intensity of the sample
+			if imputed[0][2] < 1000 || imputed[0][2] > 4100 {
+				t.Errorf("minprob imputation is incorrect, got %f", imputed[0][2])
+			}
+		}
+	}
+}
@@ -32,7 +32,7 @@ func NewLFQ() LFQ {
 	return self
 }
 
-func peakIntensity(evi rep.Evidence, dir, format string, rTWin, pTWin, tol float64, isIso, isRaw, isFaims bool) rep.Evidence {
+func peakIntensity(evi rep.Evidence, dir, format string, rTWin, pTWin, tol float64, isIso, isRaw, isFaims, isFeature, useArea bool) rep.Evidence {
 
 	logrus.Info("Indexing PSM information")
 
@@ -48,6 +48,7 @@ func peakIntensity(evi rep.Evidence, dir, format string, rTWin, pTWin, tol float
 	var retentionTime = make(map[id.SpectrumType]float64)
 	var intensity = make(map[id.SpectrumType]float64)
 	var instensityCV = make(map[id.SpectrumType]float64)
+	var features = make(map[id.SpectrumType]feature)
 
 	var charges = make(map[id.SpectrumType]int)
 
@@ -125,36 +126,44 @@ func peakIntensity(evi rep.Evidence, dir, format string, rTWin, pTWin, tol float
 		}
 
 		v, ok := spectra[s]
-		if ok {
-			for _, j := range v {
-
-				measuredFaims, measured, retrieved := xic(mz.Spectra, minRT[j], maxRT[j], ppmPrecision[j], mzMap[j.Str()])
-
-				if retrieved {
+		if ok && isFeature {
 
-					var timeW = retentionTime[j] / 60
-					var topI = 0.0
-					var topCVI = 0.0
-					var ms2CompensationVoltage = compVoltageMap[j]
+			var ms1 mzn.Spectra
+			for i := range mz.Spectra {
+				if mz.Spectra[i].Level == "1" {
+					ms1 = append(ms1, mz.Spectra[i])
+				}
+			}
 
-					for k, v := range measured {
+			for _, j := range v {
 
-						if k > (timeW-pTWin) && k < (timeW+pTWin) {
-							if v > topI {
-								topI = v
-							}
-						}
+				var cv string
+				if isFaims {
+					cv = compVoltageMap[j]
+				}
 
-						if isFaims {
-							v1, ok := measuredFaims[ms2CompensationVoltage]
-							if ok {
-								if v1 > topCVI {
-									topCVI = v1
-								}
-							}
-						}
+				// the feature window is wider than the peak window, so the peak boundaries can be found
+				psm := psmMap[j]
+				f, detected := detectFeature(ms1, psm.CalcNeutralPepMass, charges[j], retentionTime[j]/60, rTWin+(2*pTWin), pTWin, ppmPrecision[j], cv)
+
+				if detected {
+					features[j] = f
+					intensity[j] = f.Intensity
+					instensityCV[j] = f.Intensity
+					if useArea {
+						intensity[j] = f.Area
+						instensityCV[j] = f.Area
 					}
+				} else if topI, topCVI, retrieved := xicApex(mz.Spectra, minRT[j], maxRT[j], ppmPrecision[j], mzMap[j.Str()], retentionTime[j]/60, pTWin, compVoltageMap[j], isFaims); retrieved {
+					// the precursors without a feature keep the XIC apex
+					intensity[j] = topI
+					instensityCV[j] = topCVI
+				}
+			}
 
+		} else if ok {
+			for _, j := range v {
+				if topI, topCVI, retrieved := xicApex(mz.Spectra, minRT[j], maxRT[j], ppmPrecision[j], mzMap[j.Str()], retentionTime[j]/60, pTWin, compVoltageMap[j], isFaims); retrieved {
 					intensity[j] = topI
 					instensityCV[j] = topCVI
 				}
@@ -178,11 +187,52 @@ func peakIntensity(evi rep.Evidence, dir, format string, rTWin, pTWin, tol float
 			evi.PSM[i].Purity = v.Purity
 		}
 
+		f, ok := features[evi.PSM[i].SpectrumFileName()]
+		if ok {
+			evi.PSM[i].Area = f.Area
+			evi.PSM[i].FWHM = f.FWHM * 60
+			evi.PSM[i].IsotopeCorrelation = f.IsotopeCorrelation
+			evi.PSM[i].ApexRetentionTime = f.ApexRetentionTime * 60
+		}
+
 	}
 
 	return evi
 }
 
+// xicApex returns the most intense point of the ion chromatogram inside the peak window, and the most intense
+// point on the compensation voltage of the identification; retention times are in minutes
+func xicApex(spectra mzn.Spectra, minRT, maxRT, ppmPrecision, mz, rt, pTWin float64, cv string, isFaims bool) (float64, float64, bool) {
+
+	measuredFaims, measured, retrieved := xic(spectra, minRT, maxRT, ppmPrecision, mz)
+	if !retrieved {
+		return 0, 0, false
+	}
+
+	var topI = 0.0
+	var topCVI = 0.0
+
+	for k, v := range measured {
+
+		if k > (rt-pTWin) && k < (rt+pTWin) {
+			if v > topI {
+				topI = v
+			}
+		}
+
+		if isFaims {
+			v1, ok := measuredFaims[cv]
+			if ok {
+				if v1 > topCVI {
+					topCVI = v1
+				}
+			}
+		}
+	}
+
+	return topI, topCVI, true
+}
+
 // xic extract ion chomatograms
 func xic(mz mzn.Spectra, minRT, maxRT, ppmPrecision, mzValue float64) (map[string]float64, map[float64]float64, bool) {
 
@@ -221,7 +271,7 @@ func xic(mz mzn.Spectra, minRT, maxRT, ppmPrecision, mzValue float64) (map[strin
 	return ms1CompensationVoltage, list, false
 }
 
-func calculateIntensities(e rep.Evidence) rep.Evidence {
+func calculateIntensities(e rep.Evidence, r rollup) rep.Evidence {
 
 	logrus.Info("Assigning intensities to data layers")
 
@@ -231,6 +281,7 @@ func calculateIntensities(e rep.Evidence) rep.Evidence {
 
 	var peptideIntMap = make(map[string]float64)
 	var ionIntMap = make(map[id.IonFormType]float64)
+	var ionPSMMap = make(map[id.IonFormType]rep.PSMEvidence)
 
 	for _, i := range e.PSM {
 
@@ -247,13 +298,23 @@ func calculateIntensities(e rep.Evidence) rep.Evidence {
 		if ok {
 			if i.Intensity > ionV {
 				ionIntMap[i.IonForm()] = i.Intensity
+				ionPSMMap[i.IonForm()] = i
 			}
 		} else {
 			ionIntMap[i.IonForm()] = i.Intensity
+			ionPSMMap[i.IonForm()] = i
 		}
 
 	}
 
+	// ions transferred by match-between-runs have no supporting PSMs
+	for _, i := range e.Ions {
+		if i.IsTransferred {
+			ionIntMap[i.IonForm()] = i.Intensity
+			peptideIntMap[i.Sequence] += i.Intensity
+		}
+	}
+
 	for i := range e.Peptides {
 		v, ok := peptideIntMap[e.Peptides[i].Sequence]
 		if ok {
@@ -266,61 +327,62 @@ func calculateIntensities(e rep.Evidence) rep.Evidence {
 		if ok {
 			e.Ions[i].Intensity = v
 		}
+
+		// the peak shape comes from the PSM that defines the ion intensity
+		psm, ok := ionPSMMap[e.Ions[i].IonForm()]
+		if ok {
+			e.Ions[i].Area = psm.Area
+			e.Ions[i].FWHM = psm.FWHM
+			e.Ions[i].IsotopeCorrelation = psm.IsotopeCorrelation
+			e.Ions[i].ApexRetentionTime = psm.ApexRetentionTime
+		}
 	}
 
-	// protein intensities : top 3 most intense ions
+	// protein intensities : rollup of the peptide ions
 	for i := range e.Proteins {
 
-		var totalInt []float64
-		var uniqueInt []float64
-		var razorInt []float64
-
+		var rows []rollupRow
 		for _, k := range e.Proteins[i].TotalPeptideIons {
 			v, ok := ionIntMap[k.IonForm()]
 			if ok {
+				rows = append(rows, rollupRow{Sequence: k.Sequence, IsUnique: k.IsUnique, IsURazor: k.IsURazor, Values: []float64{v}})
+			}
+		}
 
-				totalInt = append(totalInt, v)
-
-				if k.IsUnique {
-					uniqueInt = append(uniqueInt, v)
-				}
+		total, unique, razor := r.proteinLevels(rows, 1)
 
-				if k.IsURazor {
-					razorInt = append(razorInt, v)
-				}
+		e.Proteins[i].TotalIntensity = total[0]
+		e.Proteins[i].UniqueIntensity = unique[0]
+		e.Proteins[i].URazorIntensity = razor[0]
+	}
 
-			}
-		}
+	// gene intensities : top 3 most intense ions
+	for i := range e.Genes {
+		e.Genes[i].TotalIntensity = sumTopIonIntensities(e.Genes[i].TotalPeptideIons, ionIntMap, 3)
+		e.Genes[i].UniqueIntensity = sumTopIonIntensities(e.Genes[i].UniquePeptideIons, ionIntMap, 3)
+		e.Genes[i].URazorIntensity = sumTopIonIntensities(e.Genes[i].URazorPeptideIons, ionIntMap, 3)
+	}
 
-		sort.Float64s(totalInt)
-		sort.Float64s(uniqueInt)
-		sort.Float64s(razorInt)
+	return e
+}
 
-		if len(totalInt) >= 3 {
-			e.Proteins[i].TotalIntensity = (totalInt[len(totalInt)-1] + totalInt[len(totalInt)-2] + totalInt[len(totalInt)-3])
-		} else if len(totalInt) == 2 {
-			e.Proteins[i].TotalIntensity = (totalInt[len(totalInt)-1] + totalInt[len(totalInt)-2])
-		} else if len(totalInt) == 1 {
-			e.Proteins[i].TotalIntensity = (totalInt[len(totalInt)-1])
-		}
+// sumTopIonIntensities sums the n most intense ions from the given list
+func sumTopIonIntensities(ions map[id.IonFormType]uint8, ionIntMap map[id.IonFormType]float64, n int) float64 {
 
-		if len(uniqueInt) >= 3 {
-			e.Proteins[i].UniqueIntensity = (uniqueInt[len(uniqueInt)-1] + uniqueInt[len(uniqueInt)-2] + uniqueInt[len(uniqueInt)-3])
-		} else if len(uniqueInt) == 2 {
-			e.Proteins[i].UniqueIntensity = (uniqueInt[len(uniqueInt)-1] + uniqueInt[len(uniqueInt)-2])
-		} else if len(uniqueInt) == 1 {
-			e.Proteins[i].UniqueIntensity = (uniqueInt[len(uniqueInt)-1])
+	var intensities []float64
+	for k := range ions {
+		v, ok := ionIntMap[k]
+		if ok {
+			intensities = append(intensities, v)
 		}
+	}
 
-		if len(razorInt) >= 3 {
-			e.Proteins[i].URazorIntensity = (razorInt[len(razorInt)-1] + razorInt[len(razorInt)-2] + razorInt[len(razorInt)-3])
-		} else if len(razorInt) == 2 {
-			e.Proteins[i].URazorIntensity = (razorInt[len(razorInt)-1] + razorInt[len(razorInt)-2])
-		} else if len(razorInt) == 1 {
-			e.Proteins[i].URazorIntensity = (razorInt[len(razorInt)-1])
-		}
+	sort.Sort(sort.Reverse(sort.Float64Slice(intensities)))
 
+	var sum float64
+	for i := 0; i < n && i < len(intensities); i++ {
+		sum += intensities[i]
 	}
 
-	return e
+	return sum
 }
@@ -0,0 +1,205 @@
+package qua
+
+import (
+	"math"
+	"sort"
+
+	"philosopher/lib/id"
+	"philosopher/lib/uti"
+)
+
+// maxLFQMinRatios is the minimum number of shared peptide ions for a pairwise sample ratio
+const maxLFQMinRatios = 2
+
+// MaxLFQ calculates the protein intensity profile across samples from the peptide ion intensities using pairwise median
+// ratios and a least-squares solution; the profile is scaled to the summed peptide intensities of each connected group of samples
+func MaxLFQ(intensities map[id.IonFormType]map[string]float64, samples []string) map[string]float64 {
+
+	var lfq = make(map[string]float64)
+
+	n := len(samples)
+	if n == 0 {
+		return lfq
+	}
+
+	// pairwise median log ratios
+	var ratios = make([][]float64, n)
+	var hasRatio = make([][]bool, n)
+	for i := range ratios {
+		ratios[i] = make([]float64, n)
+		hasRatio[i] = make([]bool, n)
+	}
+
+	for a := 0; a < n; a++ {
+		for b := a + 1; b < n; b++ {
+
+			var logRatios []float64
+			for _, v := range intensities {
+				ia, okA := v[samples[a]]
+				ib, okB := v[samples[b]]
+				if okA && okB && ia > 0 && ib > 0 {
+					logRatios = append(logRatios, math.Log(ia/ib))
+				}
+			}
+
+			if len(logRatios) >= maxLFQMinRatios {
+				ratios[a][b] = uti.Median(logRatios)
+				hasRatio[a][b] = true
+				hasRatio[b][a] = true
+			}
+		}
+	}
+
+	var totals = make([]float64, n)
+	for _, v := range intensities {
+		for i, s := range samples {
+			totals[i] += v[s]
+		}
+	}
+
+	for _, component := range connectedSamples(hasRatio) {
+
+		var total float64
+		for _, i := range component {
+			total += totals[i]
+		}
+
+		if total == 0 {
+			continue
+		}
+
+		profile := solveProfile(component, ratios, hasRatio)
+
+		var sum float64
+		for _, i := range profile {
+			sum += math.Exp(i)
+		}
+
+		for i, j := range component {
+			lfq[samples[j]] = total * math.Exp(profile[i]) / sum
+		}
+	}
+
+	return lfq
+}
+
+// connectedSamples groups the samples linked by at least one ratio
+func connectedSamples(hasRatio [][]bool) [][]int {
+
+	var components [][]int
+	var visited = make([]bool, len(hasRatio))
+
+	for i := range hasRatio {
+
+		if visited[i] {
+			continue
+		}
+
+		var component []int
+		var queue = []int{i}
+		visited[i] = true
+
+		for len(queue) > 0 {
+			a := queue[0]
+			queue = queue[1:]
+			component = append(component, a)
+
+			for b := range hasRatio[a] {
+				if hasRatio[a][b] && !visited[b] {
+					visited[b] = true
+					queue = append(queue, b)
+				}
+			}
+		}
+
+		sort.Ints(component)
+		components = append(components, component)
+	}
+
+	return components
+}
+
+// solveProfile finds the log intensities that best explain the pairwise ratios of a group of samples,
+// the solution is centered at zero
+func solveProfile(component []int, ratios [][]float64, hasRatio [][]bool) []float64 {
+
+	n := len(component)
+
+	var a = make([][]float64, n)
+	var b = make([]float64, n)
+	for i := range a {
+		a[i] = make([]float64, n)
+		for j := range a[i] {
+			a[i][j] = 1
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+
+			x, y := component[i], component[j]
+			if !hasRatio[x][y] {
+				continue
+			}
+
+			r := ratios[x][y]
+			if x > y {
+				r = -ratios[y][x]
+			}
+
+			a[i][i]++
+			a[j][j]++
+			a[i][j]--
+			a[j][i]--
+			b[i] += r
+			b[j] -= r
+		}
+	}
+
+	return gaussianElimination(a, b)
+}
+
+// gaussianElimination solves the linear system a*x = b using partial pivoting
+func gaussianElimination(a [][]float64, b []float64) []float64 {
+
+	n := len(b)
+
+	for col := 0; col < n; col++ {
+
+		pivot := col
+		for i := col + 1; i < n; i++ {
+			if math.Abs(a[i][col]) > math.Abs(a[pivot][col]) {
+				pivot = i
+			}
+		}
+
+		a[col], a[pivot] = a[pivot], a[col]
+		b[col], b[pivot] = b[pivot], b[col]
+
+		if a[col][col] == 0 {
+			continue
+		}
+
+		for i := col + 1; i < n; i++ {
+			f := a[i][col] / a[col][col]
+			for j := col; j < n; j++ {
+				a[i][j] -= f * a[col][j]
+			}
+			b[i] -= f * b[col]
+		}
+	}
+
+	var x = make([]float64, n)
+	for i := n - 1; i >= 0; i-- {
+		if a[i][i] == 0 {
+			continue
+		}
+		sum := b[i]
+		for j := i + 1; j < n; j++ {
+			sum -= a[i][j] * x[j]
+		}
+		x[i] = sum / a[i][i]
+	}
+
+	return x
+}
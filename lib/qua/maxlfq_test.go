@@ -0,0 +1,42 @@
+package qua
+
+import (
+	"math"
+	"testing"
+
+	"philosopher/lib/id"
+)
+
+func TestMaxLFQ(t *testing.T) {
+
+	// sample B has twice the amount of sample A, and sample C four times; one ion is missing in sample C
+	var intensities = map[id.IonFormType]map[string]float64{
+		{Peptide: "PEPTIDEA", AssumedCharge: 2}: {"A": 100, "B": 200, "C": 400},
+		{Peptide: "PEPTIDEB", AssumedCharge: 2}: {"A": 1000, "B": 2000, "C": 4000},
+		{Peptide: "PEPTIDEC", AssumedCharge: 3}: {"A": 50, "B": 100},
+	}
+
+	lfq := MaxLFQ(intensities, []string{"A", "B", "C"})
+
+	if r := lfq["B"] / lfq["A"]; math.Abs(r-2) > 1e-6 {
+		t.Errorf("MaxLFQ ratio B/A is incorrect, got %f, want %f", r, 2.0)
+	}
+
+	if r := lfq["C"] / lfq["A"]; math.Abs(r-4) > 1e-6 {
+		t.Errorf("MaxLFQ ratio C/A is incorrect, got %f, want %f", r, 4.0)
+	}
+
+	var total float64
+	for _, v := range lfq {
+		total += v
+	}
+
+	if math.Abs(total-7850) > 1e-6 {
+		t.Errorf("MaxLFQ total intensity is incorrect, got %f, want %f", total, 7850.0)
+	}
+
+	single := MaxLFQ(intensities, []string{"C"})
+	if single["C"] != 4400 {
+		t.Errorf("MaxLFQ single sample intensity is incorrect, got %f, want %f", single["C"], 4400.0)
+	}
+}
@@ -0,0 +1,499 @@
+package qua
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"philosopher/lib/bio"
+	"philosopher/lib/id"
+	"philosopher/lib/met"
+	"philosopher/lib/msg"
+	"philosopher/lib/mzn"
+	"philosopher/lib/rep"
+	"philosopher/lib/sys"
+	"philosopher/lib/uti"
+
+	"github.com/sirupsen/logrus"
+)
+
+// mbrDecoyMassShift is the mass added to the transferred ions to create the decoy transfers
+const mbrDecoyMassShift = 7.0
+
+// mbrRun represents a single LC-MS/MS run from a data set
+type mbrRun struct {
+	DataSet string
+	Source  string
+	Dir     string
+	Tol     float64
+	Ions    map[id.IonFormType]mbrObservation
+	Align   runAlignment
+}
+
+// mbrObservation is the identification of an ion in a run
+type mbrObservation struct {
+	RetentionTime float64
+	IonMobility   float64
+	Probability   float64
+}
+
+// mbrCandidate is an ion identified in at least one run that can be transferred to other data sets
+type mbrCandidate struct {
+	Ion           rep.IonEvidence
+	RetentionTime float64
+	IonMobility   float64
+	MZ            float64
+	DataSets      map[string]struct{}
+}
+
+// mbrTransfer is an ion traced on a run where it was not identified
+type mbrTransfer struct {
+	DataSet   string
+	Ion       id.IonFormType
+	Intensity float64
+	Score     float64
+	IsDecoy   bool
+}
+
+// RunMatchBetweenRuns transfers identifications between the data sets from an abacus set
+func RunMatchBetweenRuns(a met.Abacus, session string, args []string) {
+
+	logrus.Info("Running match-between-runs")
+
+	var runs []*mbrRun
+	var datasets = make(map[string]*rep.Evidence)
+	var params = make(map[string]met.Quantify)
+	var candidates = make(map[id.IonFormType]*mbrCandidate)
+
+	for _, i := range args {
+
+		var m met.Data
+		m.Restore(fmt.Sprintf("%s%s%s", i, string(filepath.Separator), sys.Meta()))
+
+		if len(m.Quantify.Dir) == 0 {
+			msg.Custom(fmt.Errorf("no label-free quantification found in %s, run freequant before the match-between-runs", i), "fatal")
+		}
+
+		var e rep.Evidence
+		e.RestoreGranularWithPath(i)
+		params[i] = m.Quantify
+		removeTransfers(&e, params[i])
+		datasets[i] = &e
+
+		// freequant is executed inside the workspace, relative paths must be resolved from there
+		dir := m.Quantify.Dir
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(i, dir)
+		}
+
+		var sources = make(map[string]*mbrRun)
+		for _, j := range e.PSM {
+
+			if j.IsDecoy {
+				continue
+			}
+
+			source := strings.Split(j.Spectrum, ".")[0]
+			r, ok := sources[source]
+			if !ok {
+				r = &mbrRun{DataSet: i, Source: source, Dir: dir, Tol: m.Quantify.Tol, Ions: make(map[id.IonFormType]mbrObservation)}
+				sources[source] = r
+				runs = append(runs, r)
+			}
+
+			obs, ok := r.Ions[j.IonForm()]
+			if !ok || j.Probability > obs.Probability {
+				r.Ions[j.IonForm()] = mbrObservation{j.RetentionTime / 60, j.IonMobility, j.Probability}
+			}
+		}
+	}
+
+	sort.Slice(runs, func(i, j int) bool {
+		if runs[i].DataSet == runs[j].DataSet {
+			return runs[i].Source < runs[j].Source
+		}
+		return runs[i].DataSet < runs[j].DataSet
+	})
+
+	if len(runs) < 2 {
+		msg.Custom(errors.New("match-between-runs needs at least two runs"), "fatal")
+	}
+
+	alignRetentionTimes(runs, a.AlignMethod, session)
+
+	for k, v := range datasets {
+		dataset := k
+		setAlignedRetentionTimes(v, alignmentsByName(runs), func(source string) string { return alignmentRunName(dataset, source) })
+	}
+
+	// collect the transfer candidates in the reference retention time space
+	var refRT = make(map[id.IonFormType][]float64)
+	var refIM = make(map[id.IonFormType][]float64)
+	for _, r := range runs {
+		for k, v := range r.Ions {
+			refRT[k] = append(refRT[k], r.Align.ToReference.Predict(v.RetentionTime))
+			if v.IonMobility > 0 {
+				refIM[k] = append(refIM[k], v.IonMobility)
+			}
+		}
+	}
+
+	for k, v := range datasets {
+		for _, i := range v.Ions {
+
+			if i.IsDecoy {
+				continue
+			}
+
+			c, ok := candidates[i.IonForm()]
+			if !ok {
+				c = &mbrCandidate{
+					Ion:           i,
+					RetentionTime: uti.Median(refRT[i.IonForm()]),
+					IonMobility:   uti.Median(refIM[i.IonForm()]),
+					MZ:            (i.PeptideMass + (float64(i.ChargeState) * bio.Proton)) / float64(i.ChargeState),
+					DataSets:      make(map[string]struct{}),
+				}
+				candidates[i.IonForm()] = c
+			} else if i.Probability > c.Ion.Probability {
+				c.Ion = i
+			}
+
+			c.DataSets[k] = struct{}{}
+		}
+	}
+
+	var transfers []mbrTransfer
+	for _, r := range runs {
+		transfers = append(transfers, traceTransfers(r, candidates, a.MBRRTWin, a.MBRIMWin)...)
+	}
+
+	accepted := mbrFDRFilter(transfers, a.MBRFDR)
+
+	for k, v := range datasets {
+
+		// data sets with several runs keep the most intense transfer, as the ion intensities do
+		var best = make(map[id.IonFormType]float64)
+		for _, i := range accepted[k] {
+			if i.Intensity > best[i.Ion] {
+				best[i.Ion] = i.Intensity
+			}
+		}
+
+		var ions []rep.IonEvidence
+		for ionForm, intensity := range best {
+			ion := candidates[ionForm].Ion
+			ion.Intensity = intensity
+			ion.AlignedRetentionTime = candidates[ionForm].RetentionTime * 60
+			ions = append(ions, ion)
+		}
+
+		applyTransfers(v, ions, params[k])
+
+		logrus.WithFields(logrus.Fields{
+			"ions": len(ions),
+		}).Info("Transferred identifications to ", filepath.Base(k))
+
+		v.SerializeGranularWithPath(k)
+	}
+}
+
+// alignRetentionTimes maps the retention times of each run to the run with the highest number of confident ions
+func alignRetentionTimes(runs []*mbrRun, method, session string) {
+
+	var anchors = make(map[string]map[id.IonFormType]float64)
+	for _, r := range runs {
+		name := alignmentRunName(r.DataSet, r.Source)
+		anchors[name] = make(map[id.IonFormType]float64)
+		for k, v := range r.Ions {
+			if v.Probability >= alignMinProbability {
+				anchors[name][k] = v.RetentionTime
+			}
+		}
+	}
+
+	alignments := alignRuns(anchors, method, session)
+
+	for _, r := range runs {
+		r.Align = alignments[alignmentRunName(r.DataSet, r.Source)]
+	}
+}
+
+// alignmentsByName indexes the run alignments by the run name
+func alignmentsByName(runs []*mbrRun) map[string]runAlignment {
+
+	var alignments = make(map[string]runAlignment)
+	for _, r := range runs {
+		alignments[alignmentRunName(r.DataSet, r.Source)] = r.Align
+	}
+
+	return alignments
+}
+
+// traceTransfers looks for the ions from other data sets, and their decoy counterparts on a run
+func traceTransfers(r *mbrRun, candidates map[id.IonFormType]*mbrCandidate, rtWin, imWin float64) []mbrTransfer {
+
+	var transfers []mbrTransfer
+
+	var missing []id.IonFormType
+	for k, v := range candidates {
+		if _, ok := v.DataSets[r.DataSet]; !ok {
+			missing = append(missing, k)
+		}
+	}
+
+	if len(missing) == 0 {
+		return transfers
+	}
+
+	logrus.Info("Tracing ", len(missing), " ions on ", r.Source)
+
+	var mz mzn.MsData
+	mz.Read(fmt.Sprintf("%s%s%s.mzML", r.Dir, string(filepath.Separator), r.Source))
+
+	var ms1 mzn.Spectra
+	for i := range mz.Spectra {
+		if mz.Spectra[i].Level == "1" {
+			mz.Spectra[i].Decode()
+			ms1 = append(ms1, mz.Spectra[i])
+		}
+	}
+	mz.Spectra = nil
+
+	ppmPrecision := r.Tol / math.Pow(10, 6)
+
+	for _, k := range missing {
+
+		c := candidates[k]
+		predicted := r.Align.FromReference.Predict(c.RetentionTime)
+
+		target := traceIon(ms1, predicted-rtWin, predicted+rtWin, ppmPrecision, c.MZ, c.IonMobility, imWin)
+		if intensity, score, ok := scoreTrace(target, predicted, rtWin); ok {
+			transfers = append(transfers, mbrTransfer{r.DataSet, k, intensity, score, false})
+		}
+
+		decoyMZ := c.MZ + (mbrDecoyMassShift / float64(k.AssumedCharge))
+		decoy := traceIon(ms1, predicted-rtWin, predicted+rtWin, ppmPrecision, decoyMZ, c.IonMobility, imWin)
+		if intensity, score, ok := scoreTrace(decoy, predicted, rtWin); ok {
+			transfers = append(transfers, mbrTransfer{r.DataSet, k, intensity, score, true})
+		}
+	}
+
+	return transfers
+}
+
+// traceIon extracts the ion chromatogram for a given m/z, and optionally ion mobility, window
+func traceIon(spectra mzn.Spectra, minRT, maxRT, ppmPrecision, mzValue, im, imWin float64) map[float64]float64 {
+
+	var list = make(map[float64]float64)
+
+	for j := range spectra {
+
+		if spectra[j].ScanStartTime < minRT || spectra[j].ScanStartTime > maxRT {
+			continue
+		}
+
+		stream := spectra[j].Mz.DecodedStream
+		lowi := sort.Search(len(stream), func(i int) bool { return stream[i] >= mzValue-ppmPrecision*mzValue })
+		highi := sort.Search(len(stream), func(i int) bool { return stream[i] >= mzValue+ppmPrecision*mzValue })
+
+		mobility := spectra[j].IonMobility.DecodedStream
+		useMobility := im > 0 && imWin > 0 && len(mobility) == len(stream)
+
+		var maxI = 0.0
+		for k := lowi; k < highi; k++ {
+			if useMobility && math.Abs(mobility[k]-im) > imWin {
+				continue
+			}
+			if spectra[j].Intensity.DecodedStream[k] > maxI {
+				maxI = spectra[j].Intensity.DecodedStream[k]
+			}
+		}
+
+		if maxI > 0 {
+			list[spectra[j].ScanStartTime] = maxI
+		}
+	}
+
+	return list
+}
+
+// scoreTrace scores a traced ion using the apex intensity and the distance to the predicted retention time
+func scoreTrace(trace map[float64]float64, predicted, rtWin float64) (float64, float64, bool) {
+
+	if len(trace) < 5 {
+		return 0, 0, false
+	}
+
+	var apexRT, apexI float64
+	for k, v := range trace {
+		if v > apexI {
+			apexRT = k
+			apexI = v
+		}
+	}
+
+	score := math.Log10(apexI) * (1 - (math.Abs(apexRT-predicted) / rtWin))
+
+	return apexI, score, true
+}
+
+// mbrFDRFilter estimates the transfer FDR using the decoy transfers, and returns the accepted transfers for each data set
+func mbrFDRFilter(transfers []mbrTransfer, targetFDR float64) map[string][]mbrTransfer {
+
+	var accepted = make(map[string][]mbrTransfer)
+
+	sort.Slice(transfers, func(i, j int) bool { return transfers[i].Score > transfers[j].Score })
+
+	// q-values are the minimum FDR from each position to the end of the list
+	var fdr = make([]float64, len(transfers))
+	var targets, decoys float64
+	for i := range transfers {
+		if transfers[i].IsDecoy {
+			decoys++
+		} else {
+			targets++
+		}
+		fdr[i] = decoys / math.Max(targets, 1)
+	}
+
+	for i := len(fdr) - 2; i >= 0; i-- {
+		if fdr[i+1] < fdr[i] {
+			fdr[i] = fdr[i+1]
+		}
+	}
+
+	var count int
+	var calcFDR float64
+	for i := range transfers {
+		if !transfers[i].IsDecoy && fdr[i] <= targetFDR {
+			accepted[transfers[i].DataSet] = append(accepted[transfers[i].DataSet], transfers[i])
+			calcFDR = fdr[i]
+			count++
+		}
+	}
+
+	logrus.Info(fmt.Sprintf("Converged to %.2f %% FDR with %d transfers", calcFDR*100, count))
+
+	return accepted
+}
+
+// removeTransfers clears the match-between-runs ions from a previous execution
+func removeTransfers(e *rep.Evidence, p met.Quantify) {
+
+	var transferred = make(map[id.IonFormType]struct{})
+	var ions rep.IonEvidenceList
+	for _, i := range e.Ions {
+		if i.IsTransferred {
+			transferred[i.IonForm()] = struct{}{}
+		} else {
+			ions = append(ions, i)
+		}
+	}
+
+	if len(transferred) == 0 {
+		return
+	}
+
+	e.Ions = ions
+
+	var peptides rep.PeptideEvidenceList
+	for _, i := range e.Peptides {
+		if i.IsTransferred && i.Spc == 0 {
+			continue
+		}
+		i.IsTransferred = false
+		peptides = append(peptides, i)
+	}
+	e.Peptides = peptides
+
+	for i := range e.Proteins {
+		for k := range e.Proteins[i].TotalPeptideIons {
+			if _, ok := transferred[k]; ok {
+				delete(e.Proteins[i].TotalPeptideIons, k)
+			}
+		}
+	}
+
+	for i := range e.Genes {
+		for k := range transferred {
+			delete(e.Genes[i].TotalPeptideIons, k)
+			delete(e.Genes[i].UniquePeptideIons, k)
+			delete(e.Genes[i].URazorPeptideIons, k)
+		}
+	}
+
+	*e = quantifyLayers(*e, p)
+}
+
+// applyTransfers adds the transferred ions to the data set layers and updates the intensities
+func applyTransfers(e *rep.Evidence, ions []rep.IonEvidence, p met.Quantify) {
+
+	if len(ions) == 0 {
+		return
+	}
+
+	var peptides = make(map[string]int)
+	for i := range e.Peptides {
+		peptides[e.Peptides[i].Sequence] = i
+	}
+
+	for _, i := range ions {
+
+		i.IsTransferred = true
+		i.Spectra = make(map[id.SpectrumType]int)
+		e.Ions = append(e.Ions, i)
+
+		if idx, ok := peptides[i.Sequence]; ok {
+			e.Peptides[idx].IsTransferred = true
+		} else {
+			e.Peptides = append(e.Peptides, rep.PeptideEvidence{
+				Sequence:           i.Sequence,
+				Protein:            i.Protein,
+				ProteinID:          i.ProteinID,
+				GeneName:           i.GeneName,
+				EntryName:          i.EntryName,
+				ProteinDescription: i.ProteinDescription,
+				Probability:        i.Probability,
+				PrevAA:             i.PrevAA,
+				NextAA:             i.NextAA,
+				IsUnique:           i.IsUnique,
+				IsURazor:           i.IsURazor,
+				IsTransferred:      true,
+				ChargeState:        map[uint8]uint8{i.ChargeState: 1},
+				Spectra:            make(map[id.SpectrumType]uint8),
+				MappedProteins:     i.MappedProteins,
+				MappedGenes:        i.MappedGenes,
+				Modifications:      i.Modifications,
+			})
+			peptides[i.Sequence] = len(e.Peptides) - 1
+		}
+
+		for j := range e.Proteins {
+			_, mapped := i.MappedProteins[e.Proteins[j].PartHeader]
+			if e.Proteins[j].PartHeader == i.Protein || mapped {
+				ion := i
+				ion.IsURazor = i.IsURazor && e.Proteins[j].PartHeader == i.Protein
+				e.Proteins[j].TotalPeptideIons[i.IonForm()] = ion
+			}
+		}
+
+		for j := range e.Genes {
+			_, mapped := i.MappedGenes[e.Genes[j].GeneName]
+			if e.Genes[j].GeneName == i.GeneName || mapped {
+				e.Genes[j].TotalPeptideIons[i.IonForm()]++
+				if i.IsUnique {
+					e.Genes[j].UniquePeptideIons[i.IonForm()]++
+				}
+				if e.Genes[j].GeneName == i.GeneName {
+					e.Genes[j].URazorPeptideIons[i.IonForm()]++
+				}
+			}
+		}
+	}
+
+	*e = quantifyLayers(*e, p)
+}
@@ -0,0 +1,271 @@
+package qua
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"philosopher/lib/msg"
+	"philosopher/lib/sys"
+	"philosopher/lib/uti"
+)
+
+const (
+	// NormSum scales all samples to the same total intensity
+	NormSum = "sum"
+	// NormMedian centers the log2 medians of all samples
+	NormMedian = "median"
+	// NormQuantile forces all samples to the same intensity distribution
+	NormQuantile = "quantile"
+	// NormSpikeIn scales all samples to the same total intensity of the reference proteins
+	NormSpikeIn = "spikein"
+)
+
+// ValidateNormalization checks the normalization parameters before the quantification starts
+func ValidateNormalization(method, references string) {
+
+	switch method {
+	case "", NormSum, NormMedian, NormQuantile:
+	case NormSpikeIn:
+		if len(references) == 0 {
+			msg.Custom(errors.New("the spike-in normalization needs a reference protein list"), "fatal")
+		}
+	default:
+		msg.Custom(fmt.Errorf("unknown normalization method: %s", method), "fatal")
+	}
+}
+
+// ReadNormalizationReferences reads the spike-in or housekeeping proteins, one identifier per line
+func ReadNormalizationReferences(path string) map[string]struct{} {
+
+	var references = make(map[string]struct{})
+
+	if len(path) == 0 {
+		return references
+	}
+
+	file, e := os.Open(path)
+	if e != nil {
+		msg.ReadFile(errors.New("cannot open the normalization reference list"), "fatal")
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) > 0 && !strings.HasPrefix(line, "#") {
+			references[line] = struct{}{}
+		}
+	}
+
+	if e = scanner.Err(); e != nil {
+		msg.ReadFile(e, "fatal")
+	}
+
+	return references
+}
+
+// NormalizeSamples normalizes the columns of a feature by sample matrix, zeros are missing values and are kept;
+// the reference rows are only used by the spike-in normalization
+func NormalizeSamples(matrix [][]float64, method string, reference []bool) [][]float64 {
+
+	var normalized = make([][]float64, len(matrix))
+	for i := range matrix {
+		normalized[i] = make([]float64, len(matrix[i]))
+		copy(normalized[i], matrix[i])
+	}
+
+	if len(matrix) == 0 || len(method) == 0 {
+		return normalized
+	}
+
+	columns := len(matrix[0])
+
+	if method == NormQuantile {
+		return quantileNormalization(normalized, columns)
+	}
+
+	// the other methods find a scaling factor for each sample
+	var stats = make([]float64, columns)
+	var valid = make([]bool, columns)
+	for j := 0; j < columns; j++ {
+
+		var values []float64
+		for i := range matrix {
+			if matrix[i][j] <= 0 {
+				continue
+			}
+			if method == NormSpikeIn && (len(reference) == 0 || !reference[i]) {
+				continue
+			}
+			values = append(values, matrix[i][j])
+		}
+
+		if len(values) == 0 {
+			continue
+		}
+		valid[j] = true
+
+		switch method {
+		case NormSum, NormSpikeIn:
+			for _, v := range values {
+				stats[j] += v
+			}
+			stats[j] = math.Log2(stats[j])
+		case NormMedian:
+			stats[j] = uti.Median(log2Values(values))
+		}
+	}
+
+	var target float64
+	var observed int
+	for j := range stats {
+		if valid[j] {
+			target += stats[j]
+			observed++
+		}
+	}
+
+	if observed == 0 {
+		msg.Custom(errors.New("no intensities available for the normalization"), "warning")
+		return normalized
+	}
+	target /= float64(observed)
+
+	for j := 0; j < columns; j++ {
+
+		if !valid[j] {
+			continue
+		}
+
+		factor := math.Pow(2, target-stats[j])
+		for i := range normalized {
+			normalized[i][j] *= factor
+		}
+	}
+
+	return normalized
+}
+
+// quantileNormalization replaces each value by the average intensity of the same quantile across all samples;
+// samples with missing values are interpolated to the common distribution
+func quantileNormalization(matrix [][]float64, columns int) [][]float64 {
+
+	var sorted = make([][]float64, columns)
+	for j := 0; j < columns; j++ {
+		for i := range matrix {
+			if matrix[i][j] > 0 {
+				sorted[j] = append(sorted[j], matrix[i][j])
+			}
+		}
+		sort.Float64s(sorted[j])
+	}
+
+	for j := 0; j < columns; j++ {
+
+		n := len(sorted[j])
+		if n == 0 {
+			continue
+		}
+
+		for i := range matrix {
+
+			if matrix[i][j] <= 0 {
+				continue
+			}
+
+			// average rank for ties
+			lo := sort.SearchFloat64s(sorted[j], matrix[i][j])
+			hi := sort.Search(n, func(k int) bool { return sorted[j][k] > matrix[i][j] }) - 1
+			q := 0.0
+			if n > 1 {
+				q = (float64(lo+hi) / 2) / float64(n-1)
+			}
+
+			var sum float64
+			var count int
+			for k := range sorted {
+				if len(sorted[k]) > 0 {
+					sum += quantile(sorted[k], q)
+					count++
+				}
+			}
+
+			matrix[i][j] = sum / float64(count)
+		}
+	}
+
+	return matrix
+}
+
+// quantile interpolates the value at the given fraction of a sorted list
+func quantile(sorted []float64, q float64) float64 {
+
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+
+	pos := q * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+
+	return sorted[lo] + ((pos - float64(lo)) * (sorted[hi] - sorted[lo]))
+}
+
+// WriteNormalizationSummary writes the intensity distribution of each sample before and after the normalization
+func WriteNormalizationSummary(session, name string, samples []string, before, after [][]float64) {
+
+	output := fmt.Sprintf("%s%s%s", session, string(filepath.Separator), name)
+
+	file, e := os.Create(output)
+	if e != nil {
+		msg.WriteFile(errors.New("cannot create the normalization summary"), "error")
+	}
+	defer file.Close()
+
+	_, e = io.WriteString(file, "Sample\tStage\tValues\tSum\tMinimum\tFirst Quartile\tMedian\tThird Quartile\tMaximum\n")
+	if e != nil {
+		msg.WriteToFile(e, "fatal")
+	}
+
+	for j, s := range samples {
+		for _, stage := range []string{"before", "after"} {
+
+			matrix := before
+			if stage == "after" {
+				matrix = after
+			}
+
+			var values []float64
+			var sum float64
+			for i := range matrix {
+				if matrix[i][j] > 0 {
+					values = append(values, matrix[i][j])
+					sum += matrix[i][j]
+				}
+			}
+			sort.Float64s(values)
+
+			line := fmt.Sprintf("%s\t%s\t%d\t%.0f", s, stage, len(values), sum)
+			if len(values) > 0 {
+				line += fmt.Sprintf("\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\n", values[0], quantile(values, 0.25), quantile(values, 0.5), quantile(values, 0.75), values[len(values)-1])
+			} else {
+				line += "\t0\t0\t0\t0\t0\n"
+			}
+
+			_, e = io.WriteString(file, line)
+			if e != nil {
+				msg.WriteToFile(e, "fatal")
+			}
+		}
+	}
+
+	// copy to work directory
+	sys.CopyFile(output, filepath.Base(output))
+}
@@ -0,0 +1,44 @@
+package qua
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNormalizeSamples(t *testing.T) {
+
+	// the second sample was loaded with twice the amount, the last row is a missing value
+	var matrix = [][]float64{
+		{100, 200},
+		{400, 800},
+		{1000, 2000},
+		{50, 0},
+	}
+
+	median := NormalizeSamples(matrix[:3], NormMedian, nil)
+	for i := 0; i < 3; i++ {
+		if math.Abs(median[i][0]-median[i][1]) > 1e-6 {
+			t.Errorf("median normalization row %d is incorrect, got %f and %f", i, median[i][0], median[i][1])
+		}
+	}
+
+	if matrix[0][1] != 200 {
+		t.Errorf("the input matrix must not be modified, got %f", matrix[0][1])
+	}
+
+	spike := NormalizeSamples(matrix, NormSpikeIn, []bool{false, false, true, false})
+	if r := spike[2][1] / spike[2][0]; math.Abs(r-1) > 1e-6 {
+		t.Errorf("spike-in normalization ratio is incorrect, got %f, want %f", r, 1.0)
+	}
+
+	if spike[3][1] != 0 {
+		t.Errorf("missing values must be kept, got %f", spike[3][1])
+	}
+
+	quantile := NormalizeSamples([][]float64{{1, 4}, {2, 6}, {3, 8}}, NormQuantile, nil)
+	for i, want := range []float64{2.5, 4, 5.5} {
+		if quantile[i][0] != want || quantile[i][1] != want {
+			t.Errorf("quantile normalization row %d is incorrect, got %v, want %f", i, quantile[i], want)
+		}
+	}
+}
@@ -0,0 +1,460 @@
+package qua
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"philosopher/lib/iso"
+	"philosopher/lib/mod"
+	"philosopher/lib/msg"
+	"philosopher/lib/rep"
+	"philosopher/lib/sys"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+	"gonum.org/v1/plot/vg"
+)
+
+const (
+	// tagMassTolerance is the tolerance in Daltons used to match a modification to an isobaric tag
+	tagMassTolerance = 0.01
+	// purityBins is the number of bins of the precursor purity distribution
+	purityBins = 10
+)
+
+// tagMasses are the monoisotopic masses of the TMT and iTRAQ tags
+var tagMasses = []float64{
+	224.152478, // TMT0
+	225.155833, // TMT2
+	229.162932, // TMT 6 to 11 plex
+	295.189592, // TMTpro zero
+	304.207146, // TMTpro
+	144.102063, // iTRAQ 4plex
+	304.205360, // iTRAQ 8plex
+}
+
+// labelingEfficiency counts the tagged peptide N-termini and lysines of a set of PSMs
+type labelingEfficiency struct {
+	PSMs            int
+	NTermini        int
+	LabeledNTermini int
+	Lysines         int
+	LabeledLysines  int
+	FullyLabeled    int
+}
+
+// isTag checks if the modification carries an isobaric tag
+func isTag(m mod.Modification) bool {
+
+	if m.Type != mod.Assigned {
+		return false
+	}
+
+	for _, i := range tagMasses {
+		if math.Abs(m.MassDiff-i) <= tagMassTolerance {
+			return true
+		}
+	}
+
+	return false
+}
+
+// add counts the tagged sites of a PSM, the tags must be searched as variable modifications for the efficiency to
+// be meaningful
+func (l *labelingEfficiency) add(psm rep.PSMEvidence) {
+
+	var nTerm bool
+	var lysines int
+	for _, i := range psm.Modifications.IndexSlice {
+		if !isTag(i) {
+			continue
+		}
+		if i.AminoAcid == "N-term" || i.AminoAcid == "n" {
+			nTerm = true
+		} else if i.AminoAcid == "K" {
+			lysines++
+		}
+	}
+
+	sites := strings.Count(psm.Peptide, "K")
+	if lysines > sites {
+		lysines = sites
+	}
+
+	l.PSMs++
+	l.NTermini++
+	l.Lysines += sites
+	l.LabeledLysines += lysines
+
+	if nTerm {
+		l.LabeledNTermini++
+	}
+
+	if nTerm && lysines == sites {
+		l.FullyLabeled++
+	}
+}
+
+// ratio returns the fraction, or zero when there are no sites
+func ratio(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+// isobaricQC reports the labeling efficiency, the reporter ion distributions, the missing channel rates, the
+// precursor purity distribution and the total protein normalization factors of the isobaric quantification
+func isobaricQC(session string, evi rep.Evidence, reagent iso.Reagent, factors []float64) {
+
+	var runs = make(map[string]*labelingEfficiency)
+	var total labelingEfficiency
+	var purity []float64
+	var channels = make([][]float64, len(reagent.Channels))
+	var missing = make([]int, len(reagent.Channels))
+	var quantified int
+	var customNames = make([]string, len(reagent.Channels))
+
+	for _, i := range evi.PSM {
+
+		if i.IsDecoy {
+			continue
+		}
+
+		run := strings.Split(i.Spectrum, ".")[0]
+		if _, ok := runs[run]; !ok {
+			runs[run] = &labelingEfficiency{}
+		}
+		runs[run].add(i)
+		total.add(i)
+
+		purity = append(purity, i.Purity)
+
+		if i.Labels == nil || i.Labels.Sum() <= 0 {
+			continue
+		}
+
+		quantified++
+		for j := range reagent.Channels {
+			var v float64
+			if j < len(i.Labels.Channels) {
+				v = i.Labels.Channels[j].Intensity
+				customNames[j] = i.Labels.Channels[j].CustomName
+			}
+			if v > 0 {
+				channels[j] = append(channels[j], math.Log2(v))
+			} else {
+				missing[j]++
+			}
+		}
+	}
+
+	if total.PSMs == 0 {
+		msg.Custom(errors.New("there are no target PSMs for the isobaric quantification QC"), "warning")
+		return
+	}
+
+	var runList []string
+	for i := range runs {
+		runList = append(runList, i)
+	}
+	sort.Strings(runList)
+
+	saveLabelingEfficiency(session, runList, runs, total)
+	saveChannelQC(session, reagent, customNames, channels, missing, quantified, factors)
+	savePurityDistribution(session, purity)
+
+	plotLabelingEfficiency(session, runList, runs)
+	plotChannelIntensities(session, reagent.Names(), channels)
+	plotPurityDistribution(session, purity)
+}
+
+// saveLabelingEfficiency writes the labeling efficiency of each run and of the whole experiment
+func saveLabelingEfficiency(session string, runList []string, runs map[string]*labelingEfficiency, total labelingEfficiency) {
+
+	output := fmt.Sprintf("%s%sisobaric_labeling_efficiency.tsv", session, string(filepath.Separator))
+
+	file, e := os.Create(output)
+	if e != nil {
+		msg.WriteFile(errors.New("cannot create the labeling efficiency report"), "fatal")
+	}
+	defer file.Close()
+
+	_, e = io.WriteString(file, "Run\tPSMs\tN-termini\tLabeled N-termini\tN-terminal Efficiency\tLysines\tLabeled Lysines\tLysine Efficiency\tFully Labeled PSMs\tFully Labeled Fraction\n")
+	if e != nil {
+		msg.WriteToFile(e, "fatal")
+	}
+
+	var line = func(name string, l labelingEfficiency) string {
+		return fmt.Sprintf("%s\t%d\t%d\t%d\t%.4f\t%d\t%d\t%.4f\t%d\t%.4f\n",
+			name,
+			l.PSMs,
+			l.NTermini,
+			l.LabeledNTermini,
+			ratio(l.LabeledNTermini, l.NTermini),
+			l.Lysines,
+			l.LabeledLysines,
+			ratio(l.LabeledLysines, l.Lysines),
+			l.FullyLabeled,
+			ratio(l.FullyLabeled, l.PSMs),
+		)
+	}
+
+	for _, i := range runList {
+		_, e = io.WriteString(file, line(i, *runs[i]))
+		if e != nil {
+			msg.WriteToFile(e, "fatal")
+		}
+	}
+
+	_, e = io.WriteString(file, line("All", total))
+	if e != nil {
+		msg.WriteToFile(e, "fatal")
+	}
+
+	// copy to work directory
+	sys.CopyFile(output, filepath.Base(output))
+}
+
+// saveChannelQC writes the log2 reporter ion distribution, the missing rate and the normalization factor of each channel
+func saveChannelQC(session string, reagent iso.Reagent, customNames []string, channels [][]float64, missing []int, quantified int, factors []float64) {
+
+	output := fmt.Sprintf("%s%sisobaric_channel_qc.tsv", session, string(filepath.Separator))
+
+	file, e := os.Create(output)
+	if e != nil {
+		msg.WriteFile(errors.New("cannot create the channel QC report"), "fatal")
+	}
+	defer file.Close()
+
+	_, e = io.WriteString(file, "Channel\tSample\tQuantified PSMs\tMissing PSMs\tMissing Rate\tMinimum Log2 Intensity\tFirst Quartile Log2 Intensity\tMedian Log2 Intensity\tThird Quartile Log2 Intensity\tMaximum Log2 Intensity\tNormalization Factor\n")
+	if e != nil {
+		msg.WriteToFile(e, "fatal")
+	}
+
+	for i, c := range reagent.Channels {
+
+		var factor float64
+		if i < len(factors) {
+			factor = factors[i]
+		}
+
+		q := quartiles(channels[i])
+
+		line := fmt.Sprintf("%s\t%s\t%d\t%d\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\n",
+			c.Name,
+			customNames[i],
+			len(channels[i]),
+			missing[i],
+			ratio(missing[i], quantified),
+			q[0],
+			q[1],
+			q[2],
+			q[3],
+			q[4],
+			factor,
+		)
+
+		_, e = io.WriteString(file, line)
+		if e != nil {
+			msg.WriteToFile(e, "fatal")
+		}
+	}
+
+	// copy to work directory
+	sys.CopyFile(output, filepath.Base(output))
+}
+
+// savePurityDistribution writes the number of PSMs on each precursor purity bin
+func savePurityDistribution(session string, purity []float64) {
+
+	output := fmt.Sprintf("%s%sisobaric_purity.tsv", session, string(filepath.Separator))
+
+	file, e := os.Create(output)
+	if e != nil {
+		msg.WriteFile(errors.New("cannot create the purity distribution report"), "fatal")
+	}
+	defer file.Close()
+
+	_, e = io.WriteString(file, "Lower Purity\tUpper Purity\tPSMs\n")
+	if e != nil {
+		msg.WriteToFile(e, "fatal")
+	}
+
+	for i, n := range purityHistogram(purity) {
+		line := fmt.Sprintf("%.2f\t%.2f\t%d\n", float64(i)/purityBins, float64(i+1)/purityBins, n)
+		_, e = io.WriteString(file, line)
+		if e != nil {
+			msg.WriteToFile(e, "fatal")
+		}
+	}
+
+	// copy to work directory
+	sys.CopyFile(output, filepath.Base(output))
+}
+
+// purityHistogram counts the purity values on equal bins between 0 and 1, the purity of 1 falls on the last bin
+func purityHistogram(purity []float64) []int {
+
+	var bins = make([]int, purityBins)
+	for _, i := range purity {
+		b := int(i * purityBins)
+		if b < 0 {
+			b = 0
+		} else if b >= purityBins {
+			b = purityBins - 1
+		}
+		bins[b]++
+	}
+
+	return bins
+}
+
+// quartiles returns the minimum, first quartile, median, third quartile and maximum of the values
+func quartiles(values []float64) [5]float64 {
+
+	var q [5]float64
+	if len(values) == 0 {
+		return q
+	}
+
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	var quantile = func(p float64) float64 {
+		pos := p * float64(len(sorted)-1)
+		lower := int(math.Floor(pos))
+		upper := int(math.Ceil(pos))
+		return sorted[lower] + ((pos - float64(lower)) * (sorted[upper] - sorted[lower]))
+	}
+
+	for i, p := range []float64{0, 0.25, 0.5, 0.75, 1} {
+		q[i] = quantile(p)
+	}
+
+	return q
+}
+
+// plotLabelingEfficiency creates an SVG image with the N-terminal and lysine efficiencies of each run
+func plotLabelingEfficiency(session string, runList []string, runs map[string]*labelingEfficiency) {
+
+	path := fmt.Sprintf("%s%sisobaric_labeling_efficiency.svg", session, string(filepath.Separator))
+
+	p, e := plot.New()
+	if e != nil {
+		msg.Plotter(e, "fatal")
+	}
+
+	p.Title.Text = "Labeling efficiency"
+	p.Y.Label.Text = "Labeled fraction"
+	p.Y.Min = 0
+	p.Y.Max = 1
+
+	var nTerm, lysine plotter.Values
+	for _, i := range runList {
+		nTerm = append(nTerm, ratio(runs[i].LabeledNTermini, runs[i].NTermini))
+		lysine = append(lysine, ratio(runs[i].LabeledLysines, runs[i].Lysines))
+	}
+
+	w := vg.Points(10)
+
+	nBars, e := plotter.NewBarChart(nTerm, w)
+	if e != nil {
+		msg.Plotter(e, "fatal")
+	}
+	nBars.Color = plotutil.Color(0)
+	nBars.Offset = -w / 2
+
+	kBars, e := plotter.NewBarChart(lysine, w)
+	if e != nil {
+		msg.Plotter(e, "fatal")
+	}
+	kBars.Color = plotutil.Color(1)
+	kBars.Offset = w / 2
+
+	p.Add(nBars, kBars, plotter.NewGrid())
+	p.Legend.Add("N-termini", nBars)
+	p.Legend.Add("Lysines", kBars)
+	p.Legend.Top = true
+	p.NominalX(runList...)
+
+	savePlot(p, path)
+}
+
+// plotChannelIntensities creates an SVG image with the log2 reporter ion distribution of each channel
+func plotChannelIntensities(session string, names []string, channels [][]float64) {
+
+	path := fmt.Sprintf("%s%sisobaric_channel_intensities.svg", session, string(filepath.Separator))
+
+	p, e := plot.New()
+	if e != nil {
+		msg.Plotter(e, "fatal")
+	}
+
+	p.Title.Text = "Reporter ion intensities"
+	p.Y.Label.Text = "Log2 intensity"
+
+	for i := range channels {
+		if len(channels[i]) == 0 {
+			continue
+		}
+		b, e := plotter.NewBoxPlot(vg.Points(12), float64(i), plotter.Values(channels[i]))
+		if e != nil {
+			msg.Plotter(e, "fatal")
+		}
+		p.Add(b)
+	}
+
+	p.Add(plotter.NewGrid())
+	p.NominalX(names...)
+
+	savePlot(p, path)
+}
+
+// plotPurityDistribution creates an SVG image with the precursor purity histogram
+func plotPurityDistribution(session string, purity []float64) {
+
+	path := fmt.Sprintf("%s%sisobaric_purity.svg", session, string(filepath.Separator))
+
+	p, e := plot.New()
+	if e != nil {
+		msg.Plotter(e, "fatal")
+	}
+
+	p.Title.Text = "Precursor purity"
+	p.X.Label.Text = "Purity"
+	p.Y.Label.Text = "PSMs"
+
+	var bins plotter.XYs
+	for i, n := range purityHistogram(purity) {
+		bins = append(bins, plotter.XY{X: (float64(i) + 0.5) / purityBins, Y: float64(n)})
+	}
+
+	h, e := plotter.NewHistogram(bins, purityBins)
+	if e != nil {
+		msg.Plotter(e, "fatal")
+	}
+
+	p.Add(h, plotter.NewGrid())
+
+	savePlot(p, path)
+}
+
+// savePlot writes the plot on the format given by the file extension and copies it to the work directory
+func savePlot(p *plot.Plot, path string) {
+
+	if e := p.Save(8*vg.Inch, 6*vg.Inch, path); e != nil {
+		msg.Plotter(e, "fatal")
+	}
+
+	// copy to work directory
+	sys.CopyFile(path, filepath.Base(path))
+}
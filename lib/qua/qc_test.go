@@ -0,0 +1,50 @@
+package qua
+
+import (
+	"math"
+	"testing"
+
+	"philosopher/lib/mod"
+	"philosopher/lib/rep"
+)
+
+func TestLabelingEfficiency(t *testing.T) {
+
+	var psms = []rep.PSMEvidence{
+		{Peptide: "PEPKTIDEK", Modifications: mod.ModificationsSlice{IndexSlice: []mod.Modification{
+			{AminoAcid: "N-term", MassDiff: 229.1629},
+			{AminoAcid: "K", MassDiff: 229.1629},
+			{AminoAcid: "K", MassDiff: 229.1629},
+		}}},
+		{Peptide: "PEPTIDEK", Modifications: mod.ModificationsSlice{IndexSlice: []mod.Modification{
+			{AminoAcid: "N-term", MassDiff: 229.1629},
+			{AminoAcid: "M", MassDiff: 15.9949},
+		}}},
+		{Peptide: "PEPTIDER"},
+	}
+
+	var l labelingEfficiency
+	for _, i := range psms {
+		l.add(i)
+	}
+
+	if l.PSMs != 3 || l.LabeledNTermini != 2 || l.Lysines != 3 || l.LabeledLysines != 2 || l.FullyLabeled != 1 {
+		t.Errorf("Labeling efficiency is incorrect, got %+v", l)
+	}
+}
+
+func TestQuartiles(t *testing.T) {
+
+	q := quartiles([]float64{5, 1, 3, 2, 4})
+	want := [5]float64{1, 2, 3, 4, 5}
+	for i := range q {
+		if math.Abs(q[i]-want[i]) > 1e-9 {
+			t.Errorf("Quartiles are incorrect, got %v, want %v", q, want)
+		}
+	}
+
+	bins := purityHistogram([]float64{0, 0.05, 0.55, 1})
+	if bins[0] != 2 || bins[5] != 1 || bins[9] != 1 {
+		t.Errorf("Purity histogram is incorrect, got %v", bins)
+	}
+}
@@ -35,7 +35,7 @@ func (p PairList) Less(i, j int) bool { return p[i].Value < p[j].Value }
 func (p PairList) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
 
 // RunLabelFreeQuantification is the top function for label free quantification
-func RunLabelFreeQuantification(p met.Quantify) {
+func RunLabelFreeQuantification(p met.Quantify, session string) {
 
 	// This parameter is hardcoded now because of the changes in the latest msconvert version 3.20.
 	p.Isolated = true
@@ -43,30 +43,55 @@ func RunLabelFreeQuantification(p met.Quantify) {
 	var evi rep.Evidence
 	evi.RestoreGranular()
 
-	evi = peakIntensity(evi, p.Dir, p.Format, p.RTWin, p.PTWin, p.Tol, p.Isolated, p.Raw, p.Faims)
+	evi = peakIntensity(evi, p.Dir, p.Format, p.RTWin, p.PTWin, p.Tol, p.Isolated, p.Raw, p.Faims, p.Feature, p.Area)
 
-	evi = calculateIntensities(evi)
+	evi = quantifyLayers(evi, p)
+
+	if len(p.Label) > 0 {
+		evi = quantifyMS1Labels(evi, p)
+	}
+
+	if p.Align {
+		evi = alignEvidence(evi, p.AlignMethod, session)
+	}
 
 	evi.SerializeGranular()
 
 }
 
+// quantifyLayers assigns the intensities to the data layers, and calculates the absolute abundances when requested
+func quantifyLayers(evi rep.Evidence, p met.Quantify) rep.Evidence {
+
+	evi = calculateIntensities(evi, newRollup(p, RollupTopN))
+
+	if p.IBAQ {
+		evi = calculateAbsoluteAbundances(evi, p.Enzyme, p.Ploidy, p.GenomeSize)
+	}
+
+	return evi
+}
+
 // RunIsobaricLabelQuantification is the top function for label quantification
-func RunIsobaricLabelQuantification(p met.Quantify, mods bool) met.Quantify {
+func RunIsobaricLabelQuantification(p met.Quantify, mods bool, session string) met.Quantify {
 
 	var psmMap = make(map[id.SpectrumType]rep.PSMEvidence)
 	var sourceMap = make(map[string][]rep.PSMEvidence)
 	var sourceList []string
 
-	if p.Brand == "" {
-		msg.NoParametersFound(errors.New("you need to specify a brand type (tmt or itraq)"), "fatal")
+	if p.Brand == "" && p.Reagent == "" {
+		msg.NoParametersFound(errors.New("you need to specify a brand type (tmt or itraq) or a reagent definition file"), "fatal")
 	}
 
 	var evi rep.Evidence
 	evi.RestoreGranular()
 
+	reagent := labelReagent(p.Brand, p.Plex, p.Reagent)
+	if len(reagent.Channels) == 0 {
+		msg.NoParametersFound(errors.New("the isobaric reagent has no channels, check the plex or the reagent definition"), "fatal")
+	}
+
 	// removed all calculated defined values from before
-	evi = cleanPreviousData(evi, p.Brand, p.Plex)
+	evi = cleanPreviousData(evi, reagent)
 
 	// collect all used source file names
 	for _, i := range evi.PSM {
@@ -83,12 +108,23 @@ func RunIsobaricLabelQuantification(p met.Quantify, mods bool) met.Quantify {
 		sort.Strings(sourceList)
 	}
 
+	// the impurity correction is recorded on the meta data for the reports
+	p.ImpurityChannels = nil
+	p.ImpurityMatrix = nil
+	if len(p.Impurity) > 0 {
+		p.ImpurityChannels = reagent.Names()
+		p.ImpurityMatrix = reagent.ImpurityMatrix(iso.ReadImpurities(p.Impurity))
+	}
+
 	// read the annotation file
 	p.LabelNames = make(map[string]string)
 	if len(p.Annot) > 0 {
 		p.LabelNames = uti.GetLabelNames(p.Annot)
 	}
 
+	// the sources with noise data are filtered by the signal to noise threshold
+	var noisySources = make(map[string]bool)
+
 	logrus.Info("Calculating intensities and ion interference")
 
 	for i := range sourceList {
@@ -115,15 +151,24 @@ func RunIsobaricLabelQuantification(p met.Quantify, mods bool) met.Quantify {
 
 		mappedPurity := calculateIonPurity(p.Dir, p.Format, mz, sourceMap[sourceList[i]])
 
+		noisySources[sourceList[i]] = hasNoiseData(mz)
+		if (p.SNR || p.MinSNR > 0) && !noisySources[sourceList[i]] {
+			msg.Custom(fmt.Errorf("no noise data found in %s, the reporter ion intensities are used", sourceList[i]), "warning")
+		}
+
 		var labels map[string]iso.Labels
 		if p.Level == 3 {
-			labels = prepareLabelStructureWithMS3(p.Dir, p.Format, p.Brand, p.Plex, p.Tol, mz)
+			labels = prepareLabelStructureWithMS3(p.Dir, p.Format, reagent, p.Tol, p.SNR, mz)
 
 		} else {
-			labels = prepareLabelStructureWithMS2(p.Dir, p.Format, p.Brand, p.Plex, p.Tol, mz)
+			labels = prepareLabelStructureWithMS2(p.Dir, p.Format, reagent, p.Tol, p.SNR, mz)
+		}
+
+		if p.ImpurityMatrix != nil {
+			labels = correctImpurities(labels, p.ImpurityMatrix)
 		}
 
-		labels = assignLabelNames(labels, p.LabelNames, p.Brand, p.Plex)
+		labels = assignLabelNames(labels, p.LabelNames)
 
 		mappedPSM := mapLabeledSpectra(labels, p.Purity, sourceMap[sourceList[i]])
 
@@ -158,7 +203,7 @@ func RunIsobaricLabelQuantification(p met.Quantify, mods bool) met.Quantify {
 
 	// classification and filtering based on quality filters
 	logrus.Info("Filtering spectra for label quantification")
-	spectrumMap, phosphoSpectrumMap := classification(evi, mods, p.BestPSM, p.RemoveLow, p.Purity, p.MinProb)
+	spectrumMap, phosphoSpectrumMap := classification(evi, mods, p.BestPSM, p.RemoveLow, p.MinSNR, p.Purity, p.MinProb, noisySources)
 
 	// assignment happens only for general PSMs
 	evi = assignUsage(evi, spectrumMap)
@@ -172,6 +217,12 @@ func RunIsobaricLabelQuantification(p met.Quantify, mods bool) met.Quantify {
 
 	evi = rollUpProteins(evi, spectrumMap, phosphoSpectrumMap)
 
+	evi = rollUpProteinLabels(evi, spectrumMap, newRollup(p, RollupSum), len(reagent.Channels))
+
+	// quality control of the labeling and of the reporter ions
+	logrus.Info("Creating the isobaric quantification QC reports")
+	isobaricQC(session, evi, reagent, totalProteinFactors(evi))
+
 	// normalize to the total protein levels
 	logrus.Info("Calculating normalized protein levels")
 	evi = NormToTotalProteins(evi)
@@ -218,249 +269,78 @@ func RunBioQuantification(c met.Data) {
 }
 
 // cleanPreviousData cleans previous label quantifications
-func cleanPreviousData(evi rep.Evidence, brand, plex string) rep.Evidence {
+func cleanPreviousData(evi rep.Evidence, reagent iso.Reagent) rep.Evidence {
 
 	for i := range evi.PSM {
-		if brand == "tmt" {
-			evi.PSM[i].Labels = &iso.Labels{}
-			*evi.PSM[i].Labels = tmt.New(plex)
-		} else if brand == "itraq" {
-			evi.PSM[i].Labels = &iso.Labels{}
-			*evi.PSM[i].Labels = trq.New(plex)
-		}
+		evi.PSM[i].Labels = &iso.Labels{}
+		*evi.PSM[i].Labels = reagent.Labels()
 	}
 
 	for i := range evi.Ions {
-		if brand == "tmt" {
-			evi.Ions[i].Labels = &iso.Labels{}
-			*evi.Ions[i].Labels = tmt.New(plex)
-		} else if brand == "itraq" {
-			evi.Ions[i].Labels = &iso.Labels{}
-			*evi.Ions[i].Labels = trq.New(plex)
-		}
+		evi.Ions[i].Labels = &iso.Labels{}
+		*evi.Ions[i].Labels = reagent.Labels()
 	}
 
 	for i := range evi.Proteins {
-		if brand == "tmt" {
-			evi.Proteins[i].TotalLabels = &iso.Labels{}
-			evi.Proteins[i].UniqueLabels = &iso.Labels{}
-			evi.Proteins[i].URazorLabels = &iso.Labels{}
-			*evi.Proteins[i].TotalLabels = tmt.New(plex)
-			*evi.Proteins[i].UniqueLabels = tmt.New(plex)
-			*evi.Proteins[i].URazorLabels = tmt.New(plex)
-		} else if brand == "itraq" {
-			evi.Proteins[i].TotalLabels = &iso.Labels{}
-			evi.Proteins[i].UniqueLabels = &iso.Labels{}
-			evi.Proteins[i].URazorLabels = &iso.Labels{}
-			*evi.Proteins[i].TotalLabels = trq.New(plex)
-			*evi.Proteins[i].UniqueLabels = trq.New(plex)
-			*evi.Proteins[i].URazorLabels = trq.New(plex)
-		}
+		evi.Proteins[i].TotalLabels = &iso.Labels{}
+		evi.Proteins[i].UniqueLabels = &iso.Labels{}
+		evi.Proteins[i].URazorLabels = &iso.Labels{}
+		*evi.Proteins[i].TotalLabels = reagent.Labels()
+		*evi.Proteins[i].UniqueLabels = reagent.Labels()
+		*evi.Proteins[i].URazorLabels = reagent.Labels()
 	}
 
 	return evi
 }
 
-// checks for custom names and assign the normal channel or the custom name to the CustomName
-func assignLabelNames(labels map[string]iso.Labels, labelNames map[string]string, brand, plex string) map[string]iso.Labels {
-
-	for k, v := range labels {
-		v2 := v
-
-		if brand == "tmt" {
-
-			if len(labelNames["126"]) < 1 {
-				v2.Channel1.CustomName = "126"
-			} else {
-				v2.Channel1.CustomName = labelNames["126"]
-			}
-
-			if len(labelNames["127N"]) < 1 {
-				v2.Channel2.CustomName = "127N"
-			} else {
-				v2.Channel2.CustomName = labelNames["127N"]
-			}
-
-			if len(labelNames["127C"]) < 1 {
-				v2.Channel3.CustomName = "127C"
-			} else {
-				v2.Channel3.CustomName = labelNames["127C"]
-			}
-
-			if len(labelNames["128N"]) < 1 {
-				v2.Channel4.CustomName = "128N"
-			} else {
-				v2.Channel4.CustomName = labelNames["128N"]
-			}
-
-			if len(labelNames["128C"]) < 1 {
-				v2.Channel5.CustomName = "128C"
-			} else {
-				v2.Channel5.CustomName = labelNames["128C"]
-			}
-
-			if len(labelNames["129N"]) < 1 {
-				v2.Channel6.CustomName = "129N"
-			} else {
-				v2.Channel6.CustomName = labelNames["129N"]
-			}
-
-			if len(labelNames["129C"]) < 1 {
-				v2.Channel7.CustomName = "129C"
-			} else {
-				v2.Channel7.CustomName = labelNames["129C"]
-			}
-
-			if len(labelNames["130N"]) < 1 {
-				v2.Channel8.CustomName = "130N"
-			} else {
-				v2.Channel8.CustomName = labelNames["130N"]
-			}
-
-			if len(labelNames["130C"]) < 1 {
-				v2.Channel9.CustomName = "130C"
-			} else {
-				v2.Channel9.CustomName = labelNames["130C"]
-			}
-
-			if len(labelNames["131N"]) < 1 {
-				v2.Channel10.CustomName = "131N"
-			} else {
-				v2.Channel10.CustomName = labelNames["131N"]
-			}
-
-			if len(labelNames["131C"]) < 1 {
-				v2.Channel11.CustomName = "131C"
-			} else {
-				v2.Channel11.CustomName = labelNames["131C"]
-			}
-
-			if len(labelNames["132N"]) < 1 {
-				v2.Channel12.CustomName = "132N"
-			} else {
-				v2.Channel12.CustomName = labelNames["132N"]
-			}
-
-			if len(labelNames["132C"]) < 1 {
-				v2.Channel13.CustomName = "132C"
-			} else {
-				v2.Channel13.CustomName = labelNames["132C"]
-			}
-
-			if len(labelNames["133N"]) < 1 {
-				v2.Channel14.CustomName = "133N"
-			} else {
-				v2.Channel14.CustomName = labelNames["133N"]
-			}
-
-			if len(labelNames["133C"]) < 1 {
-				v2.Channel15.CustomName = "133C"
-			} else {
-				v2.Channel15.CustomName = labelNames["133C"]
-			}
-
-			if len(labelNames["134N"]) < 1 {
-				v2.Channel16.CustomName = "134N"
-			} else {
-				v2.Channel16.CustomName = labelNames["134N"]
-			}
-
-			if len(labelNames["134C"]) < 1 {
-				v2.Channel17.CustomName = "134C"
-			} else {
-				v2.Channel17.CustomName = labelNames["134C"]
-			}
-
-			if len(labelNames["135N"]) < 1 {
-				v2.Channel18.CustomName = "135N"
-			} else {
-				v2.Channel18.CustomName = labelNames["135N"]
-			}
-
-		} else if brand == "itraq" && plex == "4" {
-
-			if len(labelNames["114"]) < 1 {
-				v2.Channel1.CustomName = "114"
-			} else {
-				v2.Channel1.CustomName = labelNames["114"]
-			}
-
-			if len(labelNames["115"]) < 1 {
-				v2.Channel2.CustomName = "115"
-			} else {
-				v2.Channel2.CustomName = labelNames["115"]
-			}
-
-			if len(labelNames["116"]) < 1 {
-				v2.Channel3.CustomName = "116"
-			} else {
-				v2.Channel3.CustomName = labelNames["116"]
-			}
+// hasNoiseData checks if any fragment spectrum has noise data
+func hasNoiseData(mz mzn.MsData) bool {
 
-			if len(labelNames["117"]) < 1 {
-				v2.Channel4.CustomName = "117"
-			} else {
-				v2.Channel4.CustomName = labelNames["117"]
-			}
+	for _, i := range mz.Spectra {
+		if i.Level != "1" && i.HasNoise() {
+			return true
+		}
+	}
 
-		} else if brand == "itraq" && plex == "8" {
-			if len(labelNames["113"]) < 1 {
-				v2.Channel1.CustomName = "113"
-			} else {
-				v2.Channel1.CustomName = labelNames["113"]
-			}
+	return false
+}
 
-			if len(labelNames["114"]) < 1 {
-				v2.Channel2.CustomName = "114"
-			} else {
-				v2.Channel2.CustomName = labelNames["114"]
-			}
+// labelReagent returns the reagent from the definition file, or the built-in reagent for the brand and plex
+func labelReagent(brand, plex, path string) iso.Reagent {
 
-			if len(labelNames["115"]) < 1 {
-				v2.Channel3.CustomName = "115"
-			} else {
-				v2.Channel3.CustomName = labelNames["115"]
-			}
+	if len(path) > 0 {
+		return iso.ReadReagent(path)
+	}
 
-			if len(labelNames["116"]) < 1 {
-				v2.Channel4.CustomName = "116"
-			} else {
-				v2.Channel4.CustomName = labelNames["116"]
-			}
+	if brand == "tmt" {
+		return tmt.NewReagent(plex)
+	} else if brand == "itraq" {
+		return trq.NewReagent(plex)
+	}
 
-			if len(labelNames["117"]) < 1 {
-				v2.Channel5.CustomName = "117"
-			} else {
-				v2.Channel5.CustomName = labelNames["117"]
-			}
+	return iso.Reagent{}
+}
 
-			if len(labelNames["118"]) < 1 {
-				v2.Channel6.CustomName = "118"
-			} else {
-				v2.Channel6.CustomName = labelNames["118"]
-			}
+// checks for custom names and assign the normal channel or the custom name to the CustomName
+func assignLabelNames(labels map[string]iso.Labels, labelNames map[string]string) map[string]iso.Labels {
 
-			if len(labelNames["119"]) < 1 {
-				v2.Channel7.CustomName = "119"
-			} else {
-				v2.Channel7.CustomName = labelNames["119"]
-			}
+	for k, v := range labels {
 
-			if len(labelNames["121"]) < 1 {
-				v2.Channel8.CustomName = "121"
+		for i := range v.Channels {
+			if len(labelNames[v.Channels[i].Name]) < 1 {
+				v.Channels[i].CustomName = v.Channels[i].Name
 			} else {
-				v2.Channel8.CustomName = labelNames["121"]
+				v.Channels[i].CustomName = labelNames[v.Channels[i].Name]
 			}
-
 		}
 
-		labels[k] = v2
+		labels[k] = v
 	}
 
 	return labels
 }
 
-func classification(evi rep.Evidence, mods, best bool, remove, purity, probability float64) (map[id.SpectrumType]iso.Labels, map[id.SpectrumType]iso.Labels) {
+func classification(evi rep.Evidence, mods, best bool, remove, minSNR, purity, probability float64, noisySources map[string]bool) (map[id.SpectrumType]iso.Labels, map[id.SpectrumType]iso.Labels) {
 
 	var spectrumMap = make(map[id.SpectrumType]iso.Labels)
 	var phosphoSpectrumMap = make(map[id.SpectrumType]iso.Labels)
@@ -472,7 +352,7 @@ func classification(evi rep.Evidence, mods, best bool, remove, purity, probabili
 	for _, i := range evi.PSM {
 		if i.Probability >= probability && i.Purity >= purity {
 
-			spectrumMap[i.SpectrumFileName()] = *i.Labels
+			spectrumMap[i.SpectrumFileName()] = i.Labels.Copy()
 			bestMap[i.SpectrumFileName()] = 0
 
 			if mods && i.PTM != nil {
@@ -480,31 +360,14 @@ func classification(evi rep.Evidence, mods, best bool, remove, purity, probabili
 				_, ok2 := i.PTM.LocalizedPTMSites["PTMProphet_STY79.96633"]
 				_, ok3 := i.PTM.LocalizedPTMSites["PTMProphet_STY79.966331"]
 				if ok1 || ok2 || ok3 {
-					phosphoSpectrumMap[i.SpectrumFileName()] = *i.Labels
+					phosphoSpectrumMap[i.SpectrumFileName()] = i.Labels.Copy()
 				}
 			}
 
 		}
 
 		if remove != 0 {
-			sum := i.Labels.Channel1.Intensity +
-				i.Labels.Channel2.Intensity +
-				i.Labels.Channel3.Intensity +
-				i.Labels.Channel4.Intensity +
-				i.Labels.Channel5.Intensity +
-				i.Labels.Channel6.Intensity +
-				i.Labels.Channel7.Intensity +
-				i.Labels.Channel8.Intensity +
-				i.Labels.Channel9.Intensity +
-				i.Labels.Channel10.Intensity +
-				i.Labels.Channel11.Intensity +
-				i.Labels.Channel12.Intensity +
-				i.Labels.Channel13.Intensity +
-				i.Labels.Channel14.Intensity +
-				i.Labels.Channel15.Intensity +
-				i.Labels.Channel16.Intensity +
-				i.Labels.Channel17.Intensity +
-				i.Labels.Channel18.Intensity
+			sum := i.Labels.Sum()
 			psmLabelSumList = append(psmLabelSumList, Pair{i.SpectrumFileName(), sum})
 
 			if sum > 0 {
@@ -536,24 +399,7 @@ func classification(evi rep.Evidence, mods, best bool, remove, purity, probabili
 				var bestPSM id.SpectrumType
 				var bestPSMInt float64
 				for _, i := range v {
-					tmtSum := i.Labels.Channel1.Intensity +
-						i.Labels.Channel2.Intensity +
-						i.Labels.Channel3.Intensity +
-						i.Labels.Channel4.Intensity +
-						i.Labels.Channel5.Intensity +
-						i.Labels.Channel6.Intensity +
-						i.Labels.Channel7.Intensity +
-						i.Labels.Channel8.Intensity +
-						i.Labels.Channel9.Intensity +
-						i.Labels.Channel10.Intensity +
-						i.Labels.Channel11.Intensity +
-						i.Labels.Channel12.Intensity +
-						i.Labels.Channel13.Intensity +
-						i.Labels.Channel14.Intensity +
-						i.Labels.Channel15.Intensity +
-						i.Labels.Channel16.Intensity +
-						i.Labels.Channel17.Intensity +
-						i.Labels.Channel18.Intensity
+					tmtSum := i.Labels.Sum()
 
 					if tmtSum > bestPSMInt {
 						bestPSM = i.SpectrumFileName()
@@ -584,6 +430,21 @@ func classification(evi rep.Evidence, mods, best bool, remove, purity, probabili
 		}
 	}
 
+	// 4th check: minimum signal to noise
+	// Ignore all PSMs with a mean reporter ion signal to noise under the threshold, the spectra without reporter peaks
+	// are removed as well. The files without noise data are kept
+	if minSNR > 0 {
+		for _, i := range evi.PSM {
+			if !noisySources[strings.Split(i.Spectrum, ".")[0]] {
+				continue
+			}
+			if i.Labels == nil || i.Labels.SignalToNoise < minSNR {
+				toDelete[i.SpectrumFileName()] = 0
+				toDeletePhospho[i.SpectrumFileName()] = 0
+			}
+		}
+	}
+
 	for k := range spectrumMap {
 		_, ok := bestMap[k]
 		if !ok {
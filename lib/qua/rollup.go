@@ -0,0 +1,411 @@
+package qua
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"philosopher/lib/id"
+	"philosopher/lib/iso"
+	"philosopher/lib/met"
+	"philosopher/lib/msg"
+	"philosopher/lib/rep"
+	"philosopher/lib/uti"
+)
+
+const (
+	// RollupTopN sums the N most intense peptide ions
+	RollupTopN = "topN"
+	// RollupSum sums all peptide ions
+	RollupSum = "sum"
+	// RollupMean averages the peptide ions
+	RollupMean = "mean"
+	// RollupMedian takes the median of the peptide ions
+	RollupMedian = "median"
+	// RollupMedianPolish fits Tukey's median polish on the log2 intensities
+	RollupMedianPolish = "medianpolish"
+	// RollupTukey takes Tukey's biweight median of the log2 intensities
+	RollupTukey = "tukey"
+
+	// medianPolishIterations is the maximum number of sweeps of the median polish
+	medianPolishIterations = 10
+	// tukeyConstant is the tuning constant of the biweight estimator
+	tukeyConstant = 5.0
+)
+
+// rollup defines how peptide ions are combined into protein intensities
+type rollup struct {
+	Method      string
+	TopN        int
+	Peptides    string
+	MinPeptides int
+}
+
+// rollupRow is a peptide ion with its intensities on each sample or channel
+type rollupRow struct {
+	Sequence string
+	IsUnique bool
+	IsURazor bool
+	Values   []float64
+}
+
+// newRollup creates the rollup strategy from the quantification parameters, the given method is used when none was chosen
+func newRollup(p met.Quantify, defaultMethod string) rollup {
+
+	var r = rollup{
+		Method:      p.Rollup,
+		TopN:        p.RollupTopN,
+		Peptides:    p.RollupPep,
+		MinPeptides: p.MinPeptides,
+	}
+
+	if len(r.Method) == 0 {
+		r.Method = defaultMethod
+	}
+
+	if r.TopN < 1 {
+		r.TopN = 3
+	}
+
+	if len(r.Peptides) == 0 {
+		r.Peptides = "all"
+	}
+
+	return r
+}
+
+// ValidateRollup checks the rollup parameters before the quantification starts
+func ValidateRollup(p met.Quantify) {
+
+	switch p.Rollup {
+	case "", RollupTopN, RollupSum, RollupMean, RollupMedian, RollupMedianPolish, RollupTukey:
+	default:
+		msg.Custom(fmt.Errorf("unknown rollup strategy: %s", p.Rollup), "fatal")
+	}
+
+	switch p.RollupPep {
+	case "", "all", "unique", "razor":
+	default:
+		msg.Custom(fmt.Errorf("unknown rollup peptide filter: %s", p.RollupPep), "fatal")
+	}
+
+	if p.Rollup == RollupTopN && p.RollupTopN < 1 {
+		msg.Custom(errors.New("the top-N rollup needs at least one peptide ion"), "fatal")
+	}
+}
+
+// proteinLevels summarizes the rows into the total, unique and razor protein levels
+func (r rollup) proteinLevels(rows []rollupRow, columns int) ([]float64, []float64, []float64) {
+
+	var total, unique, razor []rollupRow
+	for _, i := range rows {
+
+		if r.Peptides == "unique" && !i.IsUnique {
+			continue
+		}
+
+		if r.Peptides == "razor" && !i.IsURazor {
+			continue
+		}
+
+		total = append(total, i)
+
+		if i.IsUnique {
+			unique = append(unique, i)
+		}
+
+		if i.IsURazor {
+			razor = append(razor, i)
+		}
+	}
+
+	return r.summarize(total, columns), r.summarize(unique, columns), r.summarize(razor, columns)
+}
+
+// summarize combines the rows into a single intensity for each column, zeros are treated as missing values
+func (r rollup) summarize(rows []rollupRow, columns int) []float64 {
+
+	var result = make([]float64, columns)
+
+	var peptides = make(map[string]struct{})
+	for _, i := range rows {
+		peptides[i.Sequence] = struct{}{}
+	}
+
+	if len(rows) == 0 || len(peptides) < r.MinPeptides {
+		return result
+	}
+
+	switch r.Method {
+	case RollupTopN:
+
+		sorted := make([]rollupRow, len(rows))
+		copy(sorted, rows)
+		sort.SliceStable(sorted, func(i, j int) bool { return rowSum(sorted[i]) > rowSum(sorted[j]) })
+
+		for i := 0; i < r.TopN && i < len(sorted); i++ {
+			for j := range result {
+				result[j] += sorted[i].Values[j]
+			}
+		}
+
+	case RollupSum, RollupMean, RollupMedian, RollupTukey:
+
+		for j := range result {
+
+			var values []float64
+			for _, i := range rows {
+				if i.Values[j] > 0 {
+					values = append(values, i.Values[j])
+				}
+			}
+
+			if len(values) == 0 {
+				continue
+			}
+
+			switch r.Method {
+			case RollupSum:
+				for _, v := range values {
+					result[j] += v
+				}
+			case RollupMean:
+				for _, v := range values {
+					result[j] += v
+				}
+				result[j] /= float64(len(values))
+			case RollupMedian:
+				result[j] = uti.Median(values)
+			case RollupTukey:
+				result[j] = math.Pow(2, tukeyBiweight(log2Values(values)))
+			}
+		}
+
+	case RollupMedianPolish:
+
+		var matrix = make([][]float64, len(rows))
+		for i := range rows {
+			matrix[i] = make([]float64, columns)
+			for j := range rows[i].Values {
+				if rows[i].Values[j] > 0 {
+					matrix[i][j] = math.Log2(rows[i].Values[j])
+				} else {
+					matrix[i][j] = math.NaN()
+				}
+			}
+		}
+
+		for j, v := range medianPolish(matrix) {
+			if !math.IsNaN(v) {
+				result[j] = math.Pow(2, v)
+			}
+		}
+	}
+
+	return result
+}
+
+func rowSum(r rollupRow) float64 {
+	var sum float64
+	for _, i := range r.Values {
+		sum += i
+	}
+	return sum
+}
+
+func log2Values(values []float64) []float64 {
+	var logs = make([]float64, len(values))
+	for i := range values {
+		logs[i] = math.Log2(values[i])
+	}
+	return logs
+}
+
+// medianPolish fits overall, row and column effects using medians, and returns the overall plus column effects;
+// missing values are NaN, and columns without values are NaN
+func medianPolish(matrix [][]float64) []float64 {
+
+	rows := len(matrix)
+	if rows == 0 {
+		return nil
+	}
+	columns := len(matrix[0])
+
+	var residuals = make([][]float64, rows)
+	for i := range matrix {
+		residuals[i] = make([]float64, columns)
+		copy(residuals[i], matrix[i])
+	}
+
+	var overall float64
+	var colEffects = make([]float64, columns)
+	var rowEffects = make([]float64, rows)
+
+	for iter := 0; iter < medianPolishIterations; iter++ {
+
+		var change float64
+
+		// row sweep
+		for i := range residuals {
+			m := nanMedian(residuals[i])
+			if math.IsNaN(m) {
+				continue
+			}
+			for j := range residuals[i] {
+				residuals[i][j] -= m
+			}
+			rowEffects[i] += m
+			change += math.Abs(m)
+		}
+
+		m := nanMedian(colEffects)
+		for j := range colEffects {
+			colEffects[j] -= m
+		}
+		overall += m
+
+		// column sweep
+		for j := 0; j < columns; j++ {
+			var column = make([]float64, rows)
+			for i := range residuals {
+				column[i] = residuals[i][j]
+			}
+			m := nanMedian(column)
+			if math.IsNaN(m) {
+				continue
+			}
+			for i := range residuals {
+				residuals[i][j] -= m
+			}
+			colEffects[j] += m
+			change += math.Abs(m)
+		}
+
+		m = nanMedian(rowEffects)
+		for i := range rowEffects {
+			rowEffects[i] -= m
+		}
+		overall += m
+
+		if change < 1e-6 {
+			break
+		}
+	}
+
+	var result = make([]float64, columns)
+	for j := 0; j < columns; j++ {
+
+		var observed bool
+		for i := range matrix {
+			if !math.IsNaN(matrix[i][j]) {
+				observed = true
+				break
+			}
+		}
+
+		if observed {
+			result[j] = overall + colEffects[j]
+		} else {
+			result[j] = math.NaN()
+		}
+	}
+
+	return result
+}
+
+// nanMedian returns the median of the values that are not NaN
+func nanMedian(values []float64) float64 {
+
+	var clean []float64
+	for _, i := range values {
+		if !math.IsNaN(i) {
+			clean = append(clean, i)
+		}
+	}
+
+	if len(clean) == 0 {
+		return math.NaN()
+	}
+
+	return uti.Median(clean)
+}
+
+// tukeyBiweight calculates the one-step Tukey biweight location estimate
+func tukeyBiweight(values []float64) float64 {
+
+	m := uti.Median(values)
+
+	var deviations = make([]float64, len(values))
+	for i := range values {
+		deviations[i] = math.Abs(values[i] - m)
+	}
+	mad := uti.Median(deviations)
+
+	var sw, swx float64
+	for _, i := range values {
+		u := (i - m) / ((tukeyConstant * mad) + 1e-4)
+		if math.Abs(u) >= 1 {
+			continue
+		}
+		w := math.Pow(1-(u*u), 2)
+		sw += w
+		swx += w * i
+	}
+
+	if sw == 0 {
+		return m
+	}
+
+	return swx / sw
+}
+
+// labelRows creates the rollup rows from the peptide ions of a protein using the labeled spectra
+func labelRows(protein rep.ProteinEvidence, spectrumMap map[id.SpectrumType]iso.Labels, channels int) []rollupRow {
+
+	var rows []rollupRow
+
+	for _, k := range protein.TotalPeptideIons {
+
+		var row = rollupRow{
+			Sequence: k.Sequence,
+			IsUnique: k.IsUnique,
+			IsURazor: k.IsURazor,
+			Values:   make([]float64, channels),
+		}
+
+		var found bool
+		for l := range k.Spectra {
+			i, ok := spectrumMap[l]
+			if ok {
+				found = true
+				for j, v := range i.Intensities() {
+					if j < channels {
+						row.Values[j] += v
+					}
+				}
+			}
+		}
+
+		if found {
+			rows = append(rows, row)
+		}
+	}
+
+	return rows
+}
+
+// rollUpProteinLabels replaces the protein channel intensities using the chosen rollup strategy
+func rollUpProteinLabels(evi rep.Evidence, spectrumMap map[id.SpectrumType]iso.Labels, r rollup, channels int) rep.Evidence {
+
+	for j := range evi.Proteins {
+
+		total, unique, razor := r.proteinLevels(labelRows(evi.Proteins[j], spectrumMap, channels), channels)
+
+		evi.Proteins[j].TotalLabels.SetIntensities(total)
+		evi.Proteins[j].UniqueLabels.SetIntensities(unique)
+		evi.Proteins[j].URazorLabels.SetIntensities(razor)
+	}
+
+	return evi
+}
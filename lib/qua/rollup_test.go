@@ -0,0 +1,50 @@
+package qua
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRollupSummarize(t *testing.T) {
+
+	var rows = []rollupRow{
+		{Sequence: "PEPTIDEA", IsUnique: true, IsURazor: true, Values: []float64{100, 200}},
+		{Sequence: "PEPTIDEB", IsUnique: false, IsURazor: true, Values: []float64{400, 800}},
+		{Sequence: "PEPTIDEC", IsUnique: true, IsURazor: true, Values: []float64{1600, 3200}},
+		{Sequence: "PEPTIDED", IsUnique: false, IsURazor: false, Values: []float64{10, 0}},
+	}
+
+	var tests = []struct {
+		method string
+		want   []float64
+	}{
+		{RollupTopN, []float64{2100, 4200}},
+		{RollupSum, []float64{2110, 4200}},
+		{RollupMedian, []float64{250, 800}},
+		{RollupMedianPolish, []float64{400, 800}},
+	}
+
+	for _, tt := range tests {
+		r := rollup{Method: tt.method, TopN: 3, Peptides: "all", MinPeptides: 1}
+		got := r.summarize(rows[:3], 2)
+		if tt.method == RollupSum || tt.method == RollupMedian {
+			got = r.summarize(rows, 2)
+		}
+		for i := range got {
+			if math.Abs(got[i]-tt.want[i]) > 1e-6 {
+				t.Errorf("Rollup %s is incorrect, got %f, want %f", tt.method, got[i], tt.want[i])
+			}
+		}
+	}
+
+	r := rollup{Method: RollupSum, TopN: 3, Peptides: "unique", MinPeptides: 2}
+	total, unique, razor := r.proteinLevels(rows, 2)
+	if total[0] != 1700 || unique[0] != 1700 || razor[0] != 1700 {
+		t.Errorf("Rollup filter is incorrect, got %f %f %f, want %f", total[0], unique[0], razor[0], 1700.0)
+	}
+
+	r.MinPeptides = 3
+	if total, _, _ := r.proteinLevels(rows, 2); total[0] != 0 {
+		t.Errorf("Rollup minimum peptides is incorrect, got %f, want %f", total[0], 0.0)
+	}
+}
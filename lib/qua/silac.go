@@ -0,0 +1,425 @@
+package qua
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"philosopher/lib/id"
+	"philosopher/lib/met"
+	"philosopher/lib/mod"
+	"philosopher/lib/msg"
+	"philosopher/lib/mzn"
+	"philosopher/lib/rep"
+
+	"github.com/sirupsen/logrus"
+)
+
+// MS1 labeling schemes
+const (
+	LabelSILAC     = "silac"
+	LabelSILAC3    = "silac3"
+	LabelDimethyl  = "dimethyl"
+	LabelDimethyl3 = "dimethyl3"
+	LabelPSILAC    = rep.LabelPSILAC
+)
+
+// labelMassTolerance is the tolerance in Daltons used to match a modification to a label channel
+const labelMassTolerance = 0.005
+
+// labelChannel is the mass shift a channel adds to each lysine, arginine and peptide N-terminus
+type labelChannel struct {
+	Name  string
+	Lys   float64
+	Arg   float64
+	NTerm float64
+}
+
+// labelSchemes lists the channels of each scheme, the light channel comes first
+var labelSchemes = map[string][]labelChannel{
+	LabelSILAC: {
+		{Name: "L"},
+		{Name: "H", Lys: 8.014199, Arg: 10.008269},
+	},
+	LabelSILAC3: {
+		{Name: "L"},
+		{Name: "M", Lys: 4.025107, Arg: 6.020129},
+		{Name: "H", Lys: 8.014199, Arg: 10.008269},
+	},
+	LabelDimethyl: {
+		{Name: "L", Lys: 28.0313, NTerm: 28.0313},
+		{Name: "H", Lys: 36.0757, NTerm: 36.0757},
+	},
+	LabelDimethyl3: {
+		{Name: "L", Lys: 28.0313, NTerm: 28.0313},
+		{Name: "M", Lys: 32.0564, NTerm: 32.0564},
+		{Name: "H", Lys: 36.0757, NTerm: 36.0757},
+	},
+	LabelPSILAC: {
+		{Name: "L"},
+		{Name: "H", Lys: 8.014199, Arg: 10.008269},
+	},
+}
+
+// ValidateLabel checks that the MS1 labeling scheme is known
+func ValidateLabel(label string) {
+
+	if len(label) == 0 {
+		return
+	}
+
+	if _, ok := labelSchemes[label]; !ok {
+		msg.Custom(fmt.Errorf("unknown MS1 labeling scheme: %s", label), "fatal")
+	}
+}
+
+// labelSites counts the labeled sites of a peptide sequence
+type labelSites struct {
+	Lys   int
+	Arg   int
+	NTerm int
+}
+
+// newLabelSites counts the lysines and arginines, the N-terminus is only labeled by the dimethyl schemes
+func newLabelSites(sequence string, channels []labelChannel) labelSites {
+
+	var s labelSites
+	s.Lys = strings.Count(sequence, "K")
+	s.Arg = strings.Count(sequence, "R")
+
+	for _, i := range channels {
+		if i.NTerm != 0 {
+			s.NTerm = 1
+		}
+	}
+
+	return s
+}
+
+// delta returns the mass shift of the channel on the peptide
+func (c labelChannel) delta(s labelSites) float64 {
+	return (float64(s.Lys) * c.Lys) + (float64(s.Arg) * c.Arg) + (float64(s.NTerm) * c.NTerm)
+}
+
+// shift returns the mass shift of the channel on a single site
+func (c labelChannel) shift(site string) float64 {
+	switch site {
+	case "K":
+		return c.Lys
+	case "R":
+		return c.Arg
+	case "N-term":
+		return c.NTerm
+	}
+	return 0
+}
+
+// identifyChannel finds the channel carried by the identification from its lysine, arginine and N-terminal
+// modifications, a site without a label modification is light; it fails when the sites disagree
+func identifyChannel(mods []mod.Modification, channels []labelChannel, s labelSites) (int, bool) {
+
+	var matches = func(site string, mass float64) int {
+		var n int
+		for _, i := range mods {
+			if i.Type == mod.Assigned && i.AminoAcid == site && math.Abs(i.MassDiff-mass) <= labelMassTolerance {
+				n++
+			}
+		}
+		return n
+	}
+
+	// sites carrying any of the channel shifts, the remaining ones are unmodified
+	var labeled = make(map[string]int)
+	for _, i := range []string{"K", "R", "N-term"} {
+		var seen = make(map[float64]struct{})
+		for _, c := range channels {
+			mass := c.shift(i)
+			if _, ok := seen[mass]; ok || mass == 0 {
+				continue
+			}
+			seen[mass] = struct{}{}
+			labeled[i] += matches(i, mass)
+		}
+	}
+
+	var count = func(site string, mass float64, sites int) int {
+		if mass == 0 {
+			return sites - labeled[site]
+		}
+		return matches(site, mass)
+	}
+
+	for i, c := range channels {
+		if count("K", c.shift("K"), s.Lys) == s.Lys && count("R", c.shift("R"), s.Arg) == s.Arg && count("N-term", c.shift("N-term"), s.NTerm) == s.NTerm {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// partnerMasses returns the neutral mass of the peptide on every channel, starting from the identified one
+func partnerMasses(mass float64, identified int, channels []labelChannel, s labelSites) []float64 {
+
+	var masses = make([]float64, len(channels))
+
+	base := mass - channels[identified].delta(s)
+	for i := range channels {
+		masses[i] = base + channels[i].delta(s)
+	}
+
+	return masses
+}
+
+// quantifyMS1Labels detects the labeled partner features of every identification in the MS1 scans, and summarizes
+// the partner ratios on the ion, peptide and protein levels
+func quantifyMS1Labels(evi rep.Evidence, p met.Quantify) rep.Evidence {
+
+	logrus.Info("Quantifying the MS1 labeled partners")
+
+	channels := labelSchemes[p.Label]
+
+	var names []string
+	for _, i := range channels {
+		names = append(names, i.Name)
+	}
+
+	var sourceMap = make(map[string][]int)
+	for i := range evi.PSM {
+		source := strings.Split(evi.PSM[i].Spectrum, ".")[0]
+		sourceMap[source] = append(sourceMap[source], i)
+	}
+
+	var sourceList []string
+	for i := range sourceMap {
+		sourceList = append(sourceList, i)
+	}
+
+	sort.Strings(sourceList)
+
+	for _, s := range sourceList {
+
+		logrus.Info("Processing ", s)
+
+		var mz mzn.MsData
+		mz.Read(fmt.Sprintf("%s%s%s.mzML", p.Dir, string(filepath.Separator), s))
+
+		var ms1 mzn.Spectra
+		for i := range mz.Spectra {
+			if mz.Spectra[i].Level == "1" {
+				mz.Spectra[i].Decode()
+				ms1 = append(ms1, mz.Spectra[i])
+			}
+		}
+
+		for _, i := range sourceMap[s] {
+
+			psm := evi.PSM[i]
+
+			sites := newLabelSites(psm.Peptide, channels)
+			if sites.Lys+sites.Arg+sites.NTerm == 0 {
+				continue
+			}
+
+			identified, ok := identifyChannel(psm.Modifications.IndexSlice, channels, sites)
+			if !ok {
+				continue
+			}
+
+			var cv string
+			if p.Faims {
+				cv = psm.CompensationVoltage
+			}
+
+			var intensities = make([]float64, len(channels))
+			for j, mass := range partnerMasses(psm.CalcNeutralPepMass, identified, channels, sites) {
+				f, detected := detectFeature(ms1, mass, int(psm.AssumedCharge), psm.RetentionTime/60, p.RTWin+(2*p.PTWin), p.PTWin, p.Tol/math.Pow(10, 6), cv)
+				if detected {
+					intensities[j] = f.Intensity
+					if p.Area {
+						intensities[j] = f.Area
+					}
+				}
+			}
+
+			l := &rep.MS1Labels{
+				Scheme:      p.Label,
+				Channel:     channels[identified].Name,
+				Channels:    names,
+				Intensities: intensities,
+				Ratios:      labelRatios(intensities),
+			}
+			l.HeavyFraction = heavyFraction(l)
+			if hasRatio(l) {
+				l.Count = 1
+			}
+
+			evi.PSM[i].MS1Labels = l
+		}
+	}
+
+	evi = rollUpMS1Labels(evi, p.Label, names)
+
+	return evi
+}
+
+// labelRatios calculates the ratios of every channel to the light one
+func labelRatios(intensities []float64) []float64 {
+
+	var ratios = make([]float64, len(intensities)-1)
+	for i := range ratios {
+		if intensities[0] > 0 && intensities[i+1] > 0 {
+			ratios[i] = intensities[i+1] / intensities[0]
+		}
+	}
+
+	return ratios
+}
+
+// heavyFraction is the share of the heaviest channel, only reported for the pulsed SILAC scheme
+func heavyFraction(l *rep.MS1Labels) float64 {
+
+	if l.Scheme != LabelPSILAC || len(l.Ratios) == 0 {
+		return 0
+	}
+
+	r := l.Ratios[len(l.Ratios)-1]
+	if r <= 0 {
+		return 0
+	}
+
+	return r / (1 + r)
+}
+
+// hasRatio checks if any channel has a ratio to the light one
+func hasRatio(l *rep.MS1Labels) bool {
+	for _, i := range l.Ratios {
+		if i > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// summarizeMS1Labels combines the partner quantification of a group of evidences, the ratio is the geometric median
+// of the member ratios and the variability is the standard deviation of the natural log ratios in percent; the
+// intensities are either summed or taken from the most intense member
+func summarizeMS1Labels(scheme string, channels []string, members []*rep.MS1Labels, sumIntensities bool) *rep.MS1Labels {
+
+	if len(members) == 0 {
+		return nil
+	}
+
+	l := &rep.MS1Labels{
+		Scheme:      scheme,
+		Channels:    channels,
+		Intensities: make([]float64, len(channels)),
+		Ratios:      make([]float64, len(channels)-1),
+		Variability: make([]float64, len(channels)-1),
+	}
+
+	var top float64
+	for _, i := range members {
+
+		var total float64
+		for j := range l.Intensities {
+			if j < len(i.Intensities) {
+				total += i.Intensities[j]
+			}
+		}
+
+		if sumIntensities {
+			for j := range l.Intensities {
+				if j < len(i.Intensities) {
+					l.Intensities[j] += i.Intensities[j]
+				}
+			}
+		} else if total > top {
+			top = total
+			copy(l.Intensities, i.Intensities)
+		}
+
+		if hasRatio(i) {
+			l.Count++
+		}
+	}
+
+	for j := range l.Ratios {
+
+		var logs []float64
+		for _, i := range members {
+			if j < len(i.Ratios) && i.Ratios[j] > 0 {
+				logs = append(logs, math.Log(i.Ratios[j]))
+			}
+		}
+
+		if len(logs) == 0 {
+			continue
+		}
+
+		l.Ratios[j] = math.Exp(nanMedian(logs))
+
+		if len(logs) > 1 {
+			var mean, ss float64
+			for _, k := range logs {
+				mean += k
+			}
+			mean /= float64(len(logs))
+			for _, k := range logs {
+				ss += (k - mean) * (k - mean)
+			}
+			l.Variability[j] = 100 * math.Sqrt(ss/float64(len(logs)-1))
+		}
+	}
+
+	l.HeavyFraction = heavyFraction(l)
+
+	return l
+}
+
+// rollUpMS1Labels summarizes the PSM partners on the ions, the ions on the peptides, and the unique and razor ions
+// on the proteins
+func rollUpMS1Labels(evi rep.Evidence, scheme string, channels []string) rep.Evidence {
+
+	var ionPSMs = make(map[id.IonFormType][]*rep.MS1Labels)
+	for _, i := range evi.PSM {
+		if i.MS1Labels != nil {
+			ionPSMs[i.IonForm()] = append(ionPSMs[i.IonForm()], i.MS1Labels)
+		}
+	}
+
+	var ionMap = make(map[id.IonFormType]*rep.MS1Labels)
+	for k, v := range ionPSMs {
+		ionMap[k] = summarizeMS1Labels(scheme, channels, v, false)
+	}
+
+	var peptideIons = make(map[string][]*rep.MS1Labels)
+	for i := range evi.Ions {
+		if l, ok := ionMap[evi.Ions[i].IonForm()]; ok {
+			evi.Ions[i].MS1Labels = l
+			peptideIons[evi.Ions[i].Sequence] = append(peptideIons[evi.Ions[i].Sequence], l)
+		}
+	}
+
+	for i := range evi.Peptides {
+		if v, ok := peptideIons[evi.Peptides[i].Sequence]; ok {
+			evi.Peptides[i].MS1Labels = summarizeMS1Labels(scheme, channels, v, true)
+		}
+	}
+
+	for i := range evi.Proteins {
+
+		var members []*rep.MS1Labels
+		for _, v := range evi.Proteins[i].TotalPeptideIons {
+			if l, ok := ionMap[v.IonForm()]; ok && v.IsURazor {
+				members = append(members, l)
+			}
+		}
+
+		evi.Proteins[i].MS1Labels = summarizeMS1Labels(scheme, channels, members, true)
+	}
+
+	return evi
+}
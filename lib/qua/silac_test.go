@@ -0,0 +1,71 @@
+package qua
+
+import (
+	"math"
+	"testing"
+
+	"philosopher/lib/mod"
+	"philosopher/lib/rep"
+)
+
+func TestIdentifyChannel(t *testing.T) {
+
+	channels := labelSchemes[LabelSILAC3]
+	sites := newLabelSites("PEPKTIDEK", channels)
+
+	var tests = []struct {
+		mods []mod.Modification
+		want int
+		ok   bool
+	}{
+		{nil, 0, true},
+		{[]mod.Modification{{AminoAcid: "K", MassDiff: 4.0251}, {AminoAcid: "K", MassDiff: 4.0251}}, 1, true},
+		{[]mod.Modification{{AminoAcid: "K", MassDiff: 8.0142}, {AminoAcid: "K", MassDiff: 8.0142}, {AminoAcid: "M", MassDiff: 15.9949}}, 2, true},
+		{[]mod.Modification{{AminoAcid: "K", MassDiff: 8.0142}}, 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := identifyChannel(tt.mods, channels, sites)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Channel is incorrect, got %d %t, want %d %t", got, ok, tt.want, tt.ok)
+		}
+	}
+
+	masses := partnerMasses(1000+(2*8.014199), 2, channels, sites)
+	if math.Abs(masses[0]-1000) > 1e-6 || math.Abs(masses[1]-(1000+(2*4.025107))) > 1e-6 {
+		t.Errorf("Partner masses are incorrect, got %v", masses)
+	}
+
+	dimethyl := labelSchemes[LabelDimethyl]
+	sites = newLabelSites("PEPTIDER", dimethyl)
+	got, ok := identifyChannel([]mod.Modification{{AminoAcid: "N-term", MassDiff: 36.0757}}, dimethyl, sites)
+	if got != 1 || !ok {
+		t.Errorf("Dimethyl channel is incorrect, got %d %t, want %d %t", got, ok, 1, true)
+	}
+}
+
+func TestSummarizeMS1Labels(t *testing.T) {
+
+	var members = []*rep.MS1Labels{
+		{Intensities: []float64{100, 200}, Ratios: []float64{2}},
+		{Intensities: []float64{300, 2400}, Ratios: []float64{8}},
+	}
+
+	l := summarizeMS1Labels(LabelPSILAC, []string{"L", "H"}, members, true)
+
+	if math.Abs(l.Ratios[0]-4) > 1e-9 {
+		t.Errorf("Ratio is incorrect, got %f, want %f", l.Ratios[0], 4.0)
+	}
+
+	if math.Abs(l.Variability[0]-(100*math.Sqrt(2)*math.Log(2))) > 1e-9 {
+		t.Errorf("Variability is incorrect, got %f, want %f", l.Variability[0], 100*math.Sqrt(2)*math.Log(2))
+	}
+
+	if l.Intensities[0] != 400 || l.Intensities[1] != 2600 || l.Count != 2 {
+		t.Errorf("Intensities are incorrect, got %v %d", l.Intensities, l.Count)
+	}
+
+	if math.Abs(l.HeavyFraction-0.8) > 1e-9 {
+		t.Errorf("Heavy fraction is incorrect, got %f, want %f", l.HeavyFraction, 0.8)
+	}
+}
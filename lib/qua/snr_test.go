@@ -0,0 +1,32 @@
+package qua
+
+import (
+	"testing"
+
+	"philosopher/lib/iso"
+	"philosopher/lib/rep"
+)
+
+func TestClassificationSignalToNoise(t *testing.T) {
+
+	labels := func(snr float64) *iso.Labels {
+		return &iso.Labels{SignalToNoise: snr, Channels: []iso.Channel{{Name: "126", Intensity: 100}}}
+	}
+
+	evi := rep.Evidence{
+		PSM: rep.PSMEvidenceList{
+			{Spectrum: "noisy.00001.00001.2", Probability: 1, Purity: 1, Labels: labels(25)},
+			{Spectrum: "noisy.00002.00002.2", Probability: 1, Purity: 1, Labels: labels(5)},
+			{Spectrum: "noisy.00003.00003.2", Probability: 1, Purity: 1, Labels: labels(0)},
+			{Spectrum: "plain.00001.00001.2", Probability: 1, Purity: 1, Labels: labels(0)},
+		},
+	}
+
+	spectra, _ := classification(evi, false, false, 0, 10, 0, 0, map[string]bool{"noisy": true, "plain": false})
+
+	for n, want := range []bool{true, false, false, true} {
+		if _, ok := spectra[evi.PSM[n].SpectrumFileName()]; ok != want {
+			t.Errorf("%s kept = %t, want %t", evi.PSM[n].Spectrum, ok, want)
+		}
+	}
+}
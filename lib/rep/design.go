@@ -0,0 +1,196 @@
+package rep
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"philosopher/lib/msg"
+)
+
+// DesignRow is a run, or a channel of a run for isobaric data, of the experimental design
+type DesignRow struct {
+	Run            string
+	Fraction       int
+	Condition      string
+	BioReplicate   string
+	Mixture        string
+	TechRepMixture string
+	Channel        string
+}
+
+// Design is the experimental design of the MSstats exports, following the MSstats and MSstatsTMT annotation files
+type Design struct {
+	Rows     []DesignRow
+	Isobaric bool
+	index    map[string]int
+	runs     map[string]int
+}
+
+// runExtensions are removed from the run names so the design matches the spectrum names
+var runExtensions = []string{".raw", ".mzml", ".mzxml", ".mgf", ".d", ".wiff"}
+
+// designRun normalizes a run name to the spectrum file name used in the spectrum identifiers
+func designRun(run string) string {
+
+	run = filepath.Base(strings.TrimSpace(run))
+
+	for _, i := range runExtensions {
+		if strings.HasSuffix(strings.ToLower(run), i) {
+			return run[:len(run)-len(i)]
+		}
+	}
+
+	return run
+}
+
+// designKey indexes a design row by run and channel
+func designKey(run, channel string) string {
+	return designRun(run) + "\t" + channel
+}
+
+// ReadDesign reads a comma or tab separated experimental design, the isobaric designs need the Mixture,
+// TechRepMixture and Channel columns
+func ReadDesign(path string, isobaric bool) Design {
+
+	d, e := readDesign(path, isobaric)
+	if e != nil {
+		msg.Custom(e, "fatal")
+	}
+
+	return d
+}
+
+// readDesign parses the experimental design file
+func readDesign(path string, isobaric bool) (Design, error) {
+
+	var d = Design{Isobaric: isobaric, index: make(map[string]int), runs: make(map[string]int)}
+
+	file, e := os.Open(path)
+	if e != nil {
+		return d, errors.New("cannot open the experimental design file")
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	first, _ := reader.Peek(4096)
+
+	r := csv.NewReader(reader)
+	r.TrimLeadingSpace = true
+	r.Comment = '#'
+	if line := strings.SplitN(string(first), "\n", 2)[0]; strings.Contains(line, "\t") {
+		r.Comma = '\t'
+	}
+
+	records, e := r.ReadAll()
+	if e != nil {
+		return d, fmt.Errorf("malformed experimental design: %s", e)
+	}
+
+	if len(records) < 2 {
+		return d, errors.New("the experimental design has no runs")
+	}
+
+	var columns = make(map[string]int)
+	for i, j := range records[0] {
+		columns[strings.TrimSpace(j)] = i
+	}
+
+	required := []string{"Run", "Condition", "BioReplicate"}
+	if isobaric {
+		required = append(required, "Mixture", "TechRepMixture", "Channel")
+	}
+
+	var missing []string
+	for _, i := range required {
+		if _, ok := columns[i]; !ok {
+			missing = append(missing, i)
+		}
+	}
+	if len(missing) > 0 {
+		return d, fmt.Errorf("the experimental design is missing the columns %s", strings.Join(missing, ", "))
+	}
+
+	value := func(record []string, column string) string {
+		if i, ok := columns[column]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	for n, i := range records[1:] {
+
+		row := DesignRow{
+			Run:            value(i, "Run"),
+			Fraction:       1,
+			Condition:      value(i, "Condition"),
+			BioReplicate:   value(i, "BioReplicate"),
+			Mixture:        value(i, "Mixture"),
+			TechRepMixture: value(i, "TechRepMixture"),
+			Channel:        value(i, "Channel"),
+		}
+
+		if f := value(i, "Fraction"); len(f) > 0 {
+			if row.Fraction, e = strconv.Atoi(f); e != nil {
+				return d, fmt.Errorf("the fraction %q on line %d of the experimental design is not a number", f, n+2)
+			}
+		}
+
+		if len(row.Run) == 0 || len(row.Condition) == 0 || len(row.BioReplicate) == 0 || (isobaric && len(row.Channel) == 0) {
+			return d, fmt.Errorf("line %d of the experimental design has empty values", n+2)
+		}
+
+		if !isobaric {
+			row.Channel = ""
+		}
+
+		key := designKey(row.Run, row.Channel)
+		if _, ok := d.index[key]; ok {
+			return d, fmt.Errorf("the run %s %s is repeated in the experimental design", row.Run, row.Channel)
+		}
+
+		if _, ok := d.runs[designRun(row.Run)]; !ok {
+			d.runs[designRun(row.Run)] = len(d.Rows)
+		}
+
+		d.index[key] = len(d.Rows)
+		d.Rows = append(d.Rows, row)
+	}
+
+	return d, nil
+}
+
+// Lookup returns the design row of a run, and of a channel for isobaric designs
+func (d Design) Lookup(run, channel string) (DesignRow, bool) {
+
+	i, ok := d.index[designKey(run, channel)]
+	if !ok {
+		return DesignRow{}, false
+	}
+
+	return d.Rows[i], true
+}
+
+// LookupRun returns the first design row of a run
+func (d Design) LookupRun(run string) (DesignRow, bool) {
+
+	i, ok := d.runs[designRun(run)]
+	if !ok {
+		return DesignRow{}, false
+	}
+
+	return d.Rows[i], true
+}
+
+// ExportName is the file name of the MSstats export following the design
+func (d Design) ExportName() string {
+	if d.Isobaric {
+		return "msstats_tmt.csv"
+	}
+	return "msstats_lfq.csv"
+}
@@ -0,0 +1,237 @@
+package rep
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// expression computes a value from the columns of a report row, missing or non-numeric values give NaN
+type expression func(value func(column int) interface{}) float64
+
+// expressionFunctions are the functions available to the computed columns
+var expressionFunctions = map[string]func(float64) float64{
+	"abs":   math.Abs,
+	"log2":  math.Log2,
+	"log10": math.Log10,
+	"ln":    math.Log,
+	"sqrt":  math.Sqrt,
+}
+
+// expressionParser compiles arithmetic expressions where report columns are referenced as {Column Name}
+type expressionParser struct {
+	input   string
+	pos     int
+	columns map[string]int
+}
+
+// parseExpression compiles an expression against the columns of a report header
+func parseExpression(input string, columns map[string]int) (expression, error) {
+
+	p := expressionParser{input: input, columns: columns}
+
+	expr, e := p.sum()
+	if e != nil {
+		return nil, e
+	}
+
+	p.skip()
+	if p.pos < len(p.input) {
+		return nil, fmt.Errorf("unexpected %q at position %d of %q", p.input[p.pos:], p.pos, input)
+	}
+
+	return expr, nil
+}
+
+func (p *expressionParser) skip() {
+	for p.pos < len(p.input) && p.input[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// next consumes the given operator when it is the next character
+func (p *expressionParser) next(op byte) bool {
+
+	p.skip()
+	if p.pos < len(p.input) && p.input[p.pos] == op {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+func (p *expressionParser) sum() (expression, error) {
+
+	left, e := p.product()
+	if e != nil {
+		return nil, e
+	}
+
+	for {
+		var op byte
+		if p.next('+') {
+			op = '+'
+		} else if p.next('-') {
+			op = '-'
+		} else {
+			return left, nil
+		}
+
+		right, e := p.product()
+		if e != nil {
+			return nil, e
+		}
+
+		l := left
+		if op == '+' {
+			left = func(c func(int) interface{}) float64 { return l(c) + right(c) }
+		} else {
+			left = func(c func(int) interface{}) float64 { return l(c) - right(c) }
+		}
+	}
+}
+
+func (p *expressionParser) product() (expression, error) {
+
+	left, e := p.unary()
+	if e != nil {
+		return nil, e
+	}
+
+	for {
+		var op byte
+		if p.next('*') {
+			op = '*'
+		} else if p.next('/') {
+			op = '/'
+		} else {
+			return left, nil
+		}
+
+		right, e := p.unary()
+		if e != nil {
+			return nil, e
+		}
+
+		l := left
+		if op == '*' {
+			left = func(c func(int) interface{}) float64 { return l(c) * right(c) }
+		} else {
+			left = func(c func(int) interface{}) float64 { return l(c) / right(c) }
+		}
+	}
+}
+
+func (p *expressionParser) unary() (expression, error) {
+
+	if p.next('-') {
+		operand, e := p.unary()
+		if e != nil {
+			return nil, e
+		}
+		return func(c func(int) interface{}) float64 { return -operand(c) }, nil
+	}
+
+	return p.primary()
+}
+
+func (p *expressionParser) primary() (expression, error) {
+
+	p.skip()
+	if p.pos >= len(p.input) {
+		return nil, fmt.Errorf("unexpected end of %q", p.input)
+	}
+
+	switch ch := p.input[p.pos]; {
+	case ch == '(':
+		p.pos++
+		expr, e := p.sum()
+		if e != nil {
+			return nil, e
+		}
+		if !p.next(')') {
+			return nil, fmt.Errorf("missing closing parenthesis in %q", p.input)
+		}
+		return expr, nil
+
+	case ch == '{':
+		end := strings.IndexByte(p.input[p.pos:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("missing closing brace in %q", p.input)
+		}
+		name := p.input[p.pos+1 : p.pos+end]
+		p.pos += end + 1
+
+		index, ok := p.columns[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q in %q", name, p.input)
+		}
+
+		return func(c func(int) interface{}) float64 { return numeric(c(index)) }, nil
+
+	case unicode.IsLetter(rune(ch)):
+		start := p.pos
+		for p.pos < len(p.input) && (unicode.IsLetter(rune(p.input[p.pos])) || unicode.IsDigit(rune(p.input[p.pos]))) {
+			p.pos++
+		}
+		name := p.input[start:p.pos]
+
+		fn, ok := expressionFunctions[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown function %q in %q", name, p.input)
+		}
+
+		if !p.next('(') {
+			return nil, fmt.Errorf("the function %s needs an argument in %q", name, p.input)
+		}
+		arg, e := p.sum()
+		if e != nil {
+			return nil, e
+		}
+		if !p.next(')') {
+			return nil, fmt.Errorf("missing closing parenthesis in %q", p.input)
+		}
+
+		return func(c func(int) interface{}) float64 { return fn(arg(c)) }, nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.input) && strings.IndexByte("0123456789.eE", p.input[p.pos]) >= 0 {
+		if (p.input[p.pos] == 'e' || p.input[p.pos] == 'E') && p.pos+1 < len(p.input) && (p.input[p.pos+1] == '-' || p.input[p.pos+1] == '+') {
+			p.pos++
+		}
+		p.pos++
+	}
+
+	v, e := strconv.ParseFloat(p.input[start:p.pos], 64)
+	if e != nil || start == p.pos {
+		return nil, fmt.Errorf("unexpected %q at position %d of %q", p.input[start:], start, p.input)
+	}
+
+	return func(func(int) interface{}) float64 { return v }, nil
+}
+
+// numeric converts a report value to a number, text is parsed and booleans are one or zero
+func numeric(v interface{}) float64 {
+
+	switch x := v.(type) {
+	case float64:
+		return x
+	case int64:
+		return float64(x)
+	case bool:
+		if x {
+			return 1
+		}
+		return 0
+	case string:
+		if f, e := strconv.ParseFloat(x, 64); e == nil {
+			return f
+		}
+	}
+
+	return math.NaN()
+}
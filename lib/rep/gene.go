@@ -0,0 +1,231 @@
+package rep
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"philosopher/lib/dat"
+	"philosopher/lib/id"
+	"philosopher/lib/msg"
+)
+
+// AssembleGeneReport collapses proteins and isoforms into genes using the PSM to protein mappings.
+// Shared peptides are assigned as razor to the gene with the highest number of peptides.
+func (evi *Evidence) AssembleGeneReport(decoyTag string) {
+
+	type liteRecord struct {
+		ID          string
+		GeneNames   string
+		Organism    string
+		Description string
+		IsDecoy     bool
+	}
+	var recordMap = make(map[string]liteRecord)
+
+	{
+		var dtb dat.Base
+		dtb.Restore()
+		for _, j := range dtb.Records {
+			recordMap[j.PartHeader] = liteRecord{j.ID, j.GeneNames, j.Organism, strings.TrimSpace(j.Description), j.IsDecoy}
+		}
+	}
+
+	// geneKey returns the gene identifier for a protein, decoy genes carry the decoy tag
+	geneKey := func(protein string) (string, liteRecord, bool) {
+		rec, ok := recordMap[protein]
+		if !ok || len(rec.GeneNames) == 0 {
+			return "", rec, false
+		}
+		if rec.IsDecoy || strings.HasPrefix(protein, decoyTag) {
+			return decoyTag + rec.GeneNames, rec, true
+		}
+		return rec.GeneNames, rec, true
+	}
+
+	var pepGenes = make(map[string]map[string]struct{})
+	var genePeptides = make(map[string]map[string]struct{})
+	var genes = make(map[string]*GeneEvidence)
+
+	for _, i := range evi.PSM {
+
+		var proteins = []string{i.Protein}
+		for k := range i.MappedProteins {
+			proteins = append(proteins, k)
+		}
+
+		for _, p := range proteins {
+
+			// targets and decoys never share a gene
+			if i.IsDecoy != strings.HasPrefix(p, decoyTag) {
+				continue
+			}
+
+			g, rec, ok := geneKey(p)
+			if !ok {
+				continue
+			}
+
+			gene, ok := genes[g]
+			if !ok {
+				gene = &GeneEvidence{
+					GeneName:          g,
+					Organism:          rec.Organism,
+					Description:       rec.Description,
+					IsDecoy:           i.IsDecoy,
+					Proteins:          make(map[string]struct{}),
+					ProteinIDs:        make(map[string]struct{}),
+					SupportingSpectra: make(map[id.SpectrumType]int),
+					TotalPeptides:     make(map[string]int),
+					UniquePeptides:    make(map[string]int),
+					URazorPeptides:    make(map[string]int),
+					TotalPeptideIons:  make(map[id.IonFormType]uint8),
+					UniquePeptideIons: make(map[id.IonFormType]uint8),
+					URazorPeptideIons: make(map[id.IonFormType]uint8),
+				}
+				genes[g] = gene
+			}
+
+			gene.Proteins[p] = struct{}{}
+			if len(rec.ID) > 0 {
+				gene.ProteinIDs[rec.ID] = struct{}{}
+			}
+
+			if _, ok := pepGenes[i.Peptide]; !ok {
+				pepGenes[i.Peptide] = make(map[string]struct{})
+			}
+			pepGenes[i.Peptide][g] = struct{}{}
+
+			if _, ok := genePeptides[g]; !ok {
+				genePeptides[g] = make(map[string]struct{})
+			}
+			genePeptides[g][i.Peptide] = struct{}{}
+		}
+	}
+
+	// razor assignment, ties are broken by the gene name to keep the assignment stable
+	var razorGene = make(map[string]string)
+	for pep, gs := range pepGenes {
+		var best string
+		for g := range gs {
+			if len(best) == 0 || len(genePeptides[g]) > len(genePeptides[best]) || (len(genePeptides[g]) == len(genePeptides[best]) && g < best) {
+				best = g
+			}
+		}
+		razorGene[pep] = best
+	}
+
+	var bestProb = make(map[string]float64)
+	for _, i := range evi.PSM {
+		if i.Probability > bestProb[i.Peptide] {
+			bestProb[i.Peptide] = i.Probability
+		}
+	}
+
+	for _, i := range evi.PSM {
+
+		gs, ok := pepGenes[i.Peptide]
+		if !ok {
+			continue
+		}
+
+		for g := range gs {
+
+			gene := genes[g]
+
+			gene.TotalSpC++
+			gene.TotalPeptides[i.Peptide]++
+			gene.TotalPeptideIons[i.IonForm()]++
+			gene.SupportingSpectra[i.SpectrumFileName()]++
+
+			if i.Probability > gene.TopPepProb {
+				gene.TopPepProb = i.Probability
+			}
+
+			if len(gs) == 1 {
+				gene.UniqueSpC++
+				gene.UniquePeptides[i.Peptide]++
+				gene.UniquePeptideIons[i.IonForm()]++
+			}
+
+			if razorGene[i.Peptide] == g {
+				gene.URazorSpC++
+				gene.URazorPeptides[i.Peptide]++
+				gene.URazorPeptideIons[i.IonForm()]++
+			}
+		}
+	}
+
+	evi.Genes = make(GeneEvidenceList, 0, len(genes))
+	for _, v := range genes {
+
+		// the gene probability combines the best probability of each razor peptide
+		var absence = 1.0
+		for pep := range v.URazorPeptides {
+			absence *= (1 - bestProb[pep])
+		}
+		v.Probability = 1 - absence
+
+		evi.Genes = append(evi.Genes, *v)
+	}
+
+	sort.Sort(evi.Genes)
+}
+
+// MetaGeneReport creates the gene report
+func (eviGenes GeneEvidenceList) MetaGeneReport(workspace string, hasDecoys bool) *Table {
+
+	output := fmt.Sprintf("%s%sgene.tsv", workspace, string(filepath.Separator))
+
+	// building the printing set tat may or not contain decoys
+	var printSet []*GeneEvidence
+	for idx, i := range eviGenes {
+		if !hasDecoys {
+			if !i.IsDecoy {
+				printSet = append(printSet, &eviGenes[idx])
+			}
+		} else {
+			printSet = append(printSet, &eviGenes[idx])
+		}
+	}
+
+	t := NewTable("gene", len(printSet))
+
+	t.Text("Gene", func(n int) string { return printSet[n].GeneName })
+	t.Text("Organism", func(n int) string { return printSet[n].Organism })
+	t.Text("Description", func(n int) string { return printSet[n].Description })
+	t.Decimal("Gene Probability", "%.4f", func(n int) float64 { return printSet[n].Probability })
+	t.Decimal("Top Peptide Probability", "%.4f", func(n int) float64 { return printSet[n].TopPepProb })
+	t.Integer("Total Peptides", func(n int) int { return len(printSet[n].TotalPeptides) })
+	t.Integer("Unique Peptides", func(n int) int { return len(printSet[n].UniquePeptides) })
+	t.Integer("Razor Peptides", func(n int) int { return len(printSet[n].URazorPeptides) })
+	t.Integer("Total Spectral Count", func(n int) int { return printSet[n].TotalSpC })
+	t.Integer("Unique Spectral Count", func(n int) int { return printSet[n].UniqueSpC })
+	t.Integer("Razor Spectral Count", func(n int) int { return printSet[n].URazorSpC })
+	t.Decimal("Total Intensity", "%6.f", func(n int) float64 { return printSet[n].TotalIntensity })
+	t.Decimal("Unique Intensity", "%6.f", func(n int) float64 { return printSet[n].UniqueIntensity })
+	t.Decimal("Razor Intensity", "%6.f", func(n int) float64 { return printSet[n].URazorIntensity })
+	t.Text("Proteins", func(n int) string {
+		var proteins []string
+		for j := range printSet[n].Proteins {
+			proteins = append(proteins, j)
+		}
+		sort.Strings(proteins)
+		return strings.Join(proteins, ", ")
+	})
+	t.Text("Protein IDs", func(n int) string {
+		var proteinIDs []string
+		for j := range printSet[n].ProteinIDs {
+			proteinIDs = append(proteinIDs, j)
+		}
+		sort.Strings(proteinIDs)
+		return strings.Join(proteinIDs, ", ")
+	})
+
+	if e := t.WriteTSV(output); e != nil {
+		msg.WriteToFile(e, "fatal")
+	}
+
+	return t
+}
@@ -0,0 +1,50 @@
+package rep
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"philosopher/lib/msg"
+)
+
+// ImpurityReport creates the report with the reporter ion impurity correction matrix, each column has the fraction
+// of the channel signal that was observed on the channel of each row
+func ImpurityReport(workspace string, channels []string, matrix [][]float64) {
+
+	output := fmt.Sprintf("%s%simpurity_correction.tsv", workspace, string(filepath.Separator))
+
+	// create result file
+	file, e := os.Create(output)
+	bw := bufio.NewWriter(file)
+	if e != nil {
+		msg.WriteFile(errors.New("cannot create impurity correction report"), "error")
+	}
+	defer file.Close()
+	defer bw.Flush()
+
+	header := "Channel\t" + strings.Join(channels, "\t") + "\n"
+
+	_, e = io.WriteString(bw, header)
+	if e != nil {
+		msg.WriteToFile(e, "fatal")
+	}
+
+	for i := range matrix {
+
+		line := channels[i]
+		for _, j := range matrix[i] {
+			line += fmt.Sprintf("\t%.4f", j)
+		}
+		line += "\n"
+
+		_, e = io.WriteString(bw, line)
+		if e != nil {
+			msg.WriteToFile(e, "fatal")
+		}
+	}
+}
@@ -27,6 +27,11 @@ func (evi *Evidence) SerializeGranular() {
 		SerializeProteins(&evi.Proteins)
 	}()
 	wg.Wait()
+
+	// create Gene Bin, only when the gene inference was performed
+	if evi.Genes != nil {
+		SerializeGenes(&evi.Genes)
+	}
 }
 
 // SerializePSM creates an ev serial with Evidence data
@@ -49,6 +54,11 @@ func SerializeProteins(evi *ProteinEvidenceList) {
 	sys.Serialize(evi, sys.ProBin())
 }
 
+// SerializeGenes creates an ev serial with Evidence data
+func SerializeGenes(evi *GeneEvidenceList) {
+	sys.Serialize(evi, sys.GeneBin())
+}
+
 // RestoreGranular reads philosopher results files and restore the data sctructure
 func (evi *Evidence) RestoreGranular() {
 
@@ -63,6 +73,9 @@ func (evi *Evidence) RestoreGranular() {
 
 	// Protein
 	RestoreProtein(&evi.Proteins)
+
+	// Gene
+	RestoreGene(&evi.Genes)
 }
 
 // RestorePSM restores PSM data
@@ -85,6 +98,11 @@ func RestoreProtein(evi *ProteinEvidenceList) {
 	sys.Restore(evi, sys.ProBin(), false)
 }
 
+// RestoreGene restores Gene data, the gene layer is optional so missing files are ignored
+func RestoreGene(evi *GeneEvidenceList) {
+	sys.Restore(evi, sys.GeneBin(), true)
+}
+
 // RestoreGranularWithPath reads philosopher results files and restore the data sctructure
 func (evi *Evidence) RestoreGranularWithPath(p string) {
 
@@ -99,6 +117,9 @@ func (evi *Evidence) RestoreGranularWithPath(p string) {
 
 	// Protein
 	RestoreProteinWithPath(&evi.Proteins, p)
+
+	// Gene
+	RestoreGeneWithPath(&evi.Genes, p)
 }
 
 // RestorePSMWithPath restores PSM data
@@ -124,3 +145,26 @@ func RestoreProteinWithPath(evi *ProteinEvidenceList, p string) {
 	path := fmt.Sprintf("%s%s%s", p, string(filepath.Separator), sys.ProBin())
 	sys.Restore(evi, path, false)
 }
+
+// RestoreGeneWithPath restores Gene data
+func RestoreGeneWithPath(evi *GeneEvidenceList, p string) {
+	path := fmt.Sprintf("%s%s%s", p, string(filepath.Separator), sys.GeneBin())
+	sys.Restore(evi, path, true)
+}
+
+// SerializeGranularWithPath writes the evidence bins to the given workspace
+func (evi *Evidence) SerializeGranularWithPath(p string) {
+
+	sys.Serialize(&evi.PSM, fmt.Sprintf("%s%s%s", p, string(filepath.Separator), sys.PSMBin()))
+	sys.Serialize(&evi.Ions, fmt.Sprintf("%s%s%s", p, string(filepath.Separator), sys.IonBin()))
+	sys.Serialize(&evi.Peptides, fmt.Sprintf("%s%s%s", p, string(filepath.Separator), sys.PepBin()))
+
+	if evi.Proteins == nil {
+		evi.Proteins = make(ProteinEvidenceList, 0)
+	}
+	sys.Serialize(&evi.Proteins, fmt.Sprintf("%s%s%s", p, string(filepath.Separator), sys.ProBin()))
+
+	if evi.Genes != nil {
+		sys.Serialize(&evi.Genes, fmt.Sprintf("%s%s%s", p, string(filepath.Separator), sys.GeneBin()))
+	}
+}
@@ -0,0 +1,38 @@
+package rep
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIonReportAlignedRetention(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "ion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	evi := IonEvidenceList{
+		{Sequence: "PEPTIDEK", ChargeState: 2, Probability: 0.99, AlignedRetentionTime: 1234.5},
+		{Sequence: "SAMPLEK", ChargeState: 2, Probability: 0.98},
+	}
+
+	evi.MetaIonReport(dir, "rev_", false, false)
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "ion.tsv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if !strings.HasSuffix(lines[0], "\tAligned Retention") {
+		t.Errorf("the aligned retention must be reported, got %s", lines[0])
+	}
+	if !strings.HasSuffix(lines[1], "\t1234.5000") || !strings.HasSuffix(lines[2], "\t0.0000") {
+		t.Errorf("the aligned retention values are incorrect, got %s", strings.Join(lines[1:], "\n"))
+	}
+}
@@ -199,40 +199,23 @@ func (evi *Evidence) AssembleModificationReport() {
 }
 
 // ModificationReport ...
-func (evi *Evidence) ModificationReport(workspace string) {
+func (evi *Evidence) ModificationReport(workspace string) *Table {
 
 	// create result file
 	output := fmt.Sprintf("%s%smodifications.tsv", workspace, string(filepath.Separator))
 
-	// create result file
-	file, e := os.Create(output)
-	if e != nil {
-		msg.WriteFile(errors.New("could not create report files"), "error")
-	}
-	defer file.Close()
+	bins := evi.Modifications.MassBins
 
-	line := "Mass Bin\tPSMs with Assigned Modifications\tPSMs with Observed Modifications\n"
+	t := NewTable("modifications", len(bins))
+	t.Decimal("Mass Bin", "%.4f", func(n int) float64 { return bins[n].CorrectedMass })
+	t.Integer("PSMs with Assigned Modifications", func(n int) int { return len(bins[n].AssignedMods) })
+	t.Integer("PSMs with Observed Modifications", func(n int) int { return len(bins[n].ObservedMods) })
 
-	_, e = io.WriteString(file, line)
-	if e != nil {
-		msg.WriteToFile(e, "fatal")
+	if e := t.WriteTSV(output); e != nil {
+		msg.WriteFile(errors.New("could not create report files"), "error")
 	}
 
-	for _, i := range evi.Modifications.MassBins {
-
-		line = fmt.Sprintf("%.4f\t%d\t%d",
-			i.CorrectedMass,
-			len(i.AssignedMods),
-			len(i.ObservedMods),
-		)
-
-		line += "\n"
-		_, e = io.WriteString(file, line)
-		if e != nil {
-			msg.WriteToFile(e, "fatal")
-		}
-
-	}
+	return t
 }
 
 // PlotMassHist plots the delta mass histogram
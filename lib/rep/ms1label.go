@@ -0,0 +1,79 @@
+package rep
+
+import (
+	"fmt"
+)
+
+// LabelPSILAC is the pulsed SILAC scheme, the heavy fraction reports the newly synthesized share of the protein
+const LabelPSILAC = "psilac"
+
+// MS1Labels holds the MS1 labeled partner intensities, the first channel is the light one and the ratios are taken
+// to it; missing intensities and ratios are zero
+type MS1Labels struct {
+	Scheme        string
+	Channel       string // channel carrying the identification
+	Channels      []string
+	Intensities   []float64
+	Ratios        []float64
+	Variability   []float64 // coefficient of variation of the ratios, in percent
+	HeavyFraction float64
+	Count         int
+}
+
+// ms1LabelReference returns the labels with the largest channel set
+func ms1LabelReference(labels []*MS1Labels) *MS1Labels {
+
+	var ref *MS1Labels
+	for _, i := range labels {
+		if i != nil && len(i.Channels) > 0 && (ref == nil || len(i.Channels) > len(ref.Channels)) {
+			ref = i
+		}
+	}
+
+	return ref
+}
+
+// ms1LabelFields registers the labeled partner columns following the reference channels, the PSM level carries the
+// identified channel instead of the ratio summaries
+func (t *Table) ms1LabelFields(ref *MS1Labels, isPSM bool, labels func(n int) *MS1Labels) {
+
+	var label = func(n int) *MS1Labels {
+		if l := labels(n); l != nil {
+			return l
+		}
+		return &MS1Labels{}
+	}
+
+	if isPSM {
+		t.Text("Label Channel", func(n int) string { return label(n).Channel })
+	}
+
+	for c, i := range ref.Channels {
+		c := c
+		t.Decimal(fmt.Sprintf("%s Intensity", i), "%.4f", func(n int) float64 { return valueAt(label(n).Intensities, c) }).Channel(i)
+	}
+
+	for c, i := range ref.Channels[1:] {
+		c := c
+		t.Decimal(fmt.Sprintf("%s/%s Ratio", i, ref.Channels[0]), "%.4f", func(n int) float64 { return valueAt(label(n).Ratios, c) })
+		if !isPSM {
+			t.Decimal(fmt.Sprintf("%s/%s Variability [%%]", i, ref.Channels[0]), "%.2f", func(n int) float64 { return valueAt(label(n).Variability, c) })
+		}
+	}
+
+	if !isPSM {
+		t.Integer("Ratio Count", func(n int) int { return label(n).Count })
+	}
+
+	if ref.Scheme == LabelPSILAC {
+		t.Decimal("Heavy Fraction", "%.4f", func(n int) float64 { return label(n).HeavyFraction })
+	}
+}
+
+// valueAt returns the value on the index, or zero when the list is shorter
+func valueAt(values []float64, i int) float64 {
+	if i < len(values) {
+		return values[i]
+	}
+	return 0
+}
@@ -6,14 +6,18 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"philosopher/lib/bio"
+	"philosopher/lib/iso"
 	"philosopher/lib/msg"
+
+	"github.com/sirupsen/logrus"
 )
 
 // MetaMSstatsReport report all psms from study that passed the FDR filter
-func (evi Evidence) MetaMSstatsReport(workspace, brand string, channels int, hasDecoys bool) {
+func (evi Evidence) MetaMSstatsReport(workspace string, hasDecoys bool) {
 	if evi.PSM == nil {
 		RestorePSM(&evi.PSM)
 	}
@@ -42,19 +46,17 @@ func (evi Evidence) MetaMSstatsReport(workspace, brand string, channels int, has
 
 	header = "Spectrum.Name\tSpectrum.File\tPeptide.Sequence\tModified.Peptide.Sequence\tCharge\tCalculated.MZ\tPeptideProphet.Probability\tIntensity\tIs.Unique\tGene\tProtein.Accessions\tModifications"
 
-	if brand == "tmt" {
-		switch channels {
-		case 10:
-			header += "\tPurity\tChannel 126\tChannel 127N\tChannel 127C\tChannel 128N\tChannel 128C\tChannel 129N\tChannel 129C\tChannel 130N\tChannel 130C\tChannel 131N"
-		case 11:
-			header += "\tPurity\tChannel 126\tChannel 127N\tChannel 127C\tChannel 128N\tChannel 128C\tChannel 129N\tChannel 129C\tChannel 130N\tChannel 130C\tChannel 131N\tChannel 131C"
-		case 16:
-			header += "\tPurity\tChannel 126\tChannel 127N\tChannel 127C\tChannel 128N\tChannel 128C\tChannel 129N\tChannel 129C\tChannel 130N\tChannel 130C\tChannel 131N\tChannel 131C\tChannel 132N\tChannel 132C\tChannel 133N\tChannel 133C\tChannel 134N"
-		case 18:
-			header += "\tChannel 126\tChannel 127N\tChannel 127C\tChannel 128N\tChannel 128C\tChannel 129N\tChannel 129C\tChannel 130N\tChannel 130C\tChannel 131N\tChannel 131C\tChannel 132N\tChannel 132C\tChannel 133N\tChannel 133C\tChannel 134N\tChannel 134C\tChannel 135N"
-		default:
-			header += ""
-		}
+	// the channels are taken from the labeled evidences
+	var channels int
+	var labels []*iso.Labels
+	for _, i := range printSet {
+		labels = append(labels, i.Labels)
+	}
+	ref := referenceLabels(labels, false)
+
+	if ref != nil {
+		channels = len(ref.Channels)
+		header += "\tPurity" + channelHeader(ref, false)
 	}
 
 	header += "\n"
@@ -85,97 +87,8 @@ func (evi Evidence) MetaMSstatsReport(workspace, brand string, channels int, has
 			"",
 		)
 
-		if brand == "tmt" {
-			switch channels {
-			case 6:
-				line = fmt.Sprintf("%s\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f",
-					line,
-					i.Purity,
-					i.Labels.Channel1.Intensity,
-					i.Labels.Channel2.Intensity,
-					i.Labels.Channel5.Intensity,
-					i.Labels.Channel6.Intensity,
-					i.Labels.Channel9.Intensity,
-					i.Labels.Channel10.Intensity,
-				)
-			case 10:
-				line = fmt.Sprintf("%s\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f",
-					line,
-					i.Purity,
-					i.Labels.Channel1.Intensity,
-					i.Labels.Channel2.Intensity,
-					i.Labels.Channel3.Intensity,
-					i.Labels.Channel4.Intensity,
-					i.Labels.Channel5.Intensity,
-					i.Labels.Channel6.Intensity,
-					i.Labels.Channel7.Intensity,
-					i.Labels.Channel8.Intensity,
-					i.Labels.Channel9.Intensity,
-					i.Labels.Channel10.Intensity,
-				)
-			case 11:
-				line = fmt.Sprintf("%s\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f",
-					line,
-					i.Purity,
-					i.Labels.Channel1.Intensity,
-					i.Labels.Channel2.Intensity,
-					i.Labels.Channel3.Intensity,
-					i.Labels.Channel4.Intensity,
-					i.Labels.Channel5.Intensity,
-					i.Labels.Channel6.Intensity,
-					i.Labels.Channel7.Intensity,
-					i.Labels.Channel8.Intensity,
-					i.Labels.Channel9.Intensity,
-					i.Labels.Channel10.Intensity,
-					i.Labels.Channel11.Intensity,
-				)
-			case 16:
-				line = fmt.Sprintf("%s\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f",
-					line,
-					i.Purity,
-					i.Labels.Channel1.Intensity,
-					i.Labels.Channel2.Intensity,
-					i.Labels.Channel3.Intensity,
-					i.Labels.Channel4.Intensity,
-					i.Labels.Channel5.Intensity,
-					i.Labels.Channel6.Intensity,
-					i.Labels.Channel7.Intensity,
-					i.Labels.Channel8.Intensity,
-					i.Labels.Channel9.Intensity,
-					i.Labels.Channel10.Intensity,
-					i.Labels.Channel11.Intensity,
-					i.Labels.Channel12.Intensity,
-					i.Labels.Channel13.Intensity,
-					i.Labels.Channel14.Intensity,
-					i.Labels.Channel15.Intensity,
-					i.Labels.Channel16.Intensity,
-				)
-			case 18:
-				line = fmt.Sprintf("%s\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f",
-					line,
-					i.Purity,
-					i.Labels.Channel1.Intensity,
-					i.Labels.Channel2.Intensity,
-					i.Labels.Channel3.Intensity,
-					i.Labels.Channel4.Intensity,
-					i.Labels.Channel5.Intensity,
-					i.Labels.Channel6.Intensity,
-					i.Labels.Channel7.Intensity,
-					i.Labels.Channel8.Intensity,
-					i.Labels.Channel9.Intensity,
-					i.Labels.Channel10.Intensity,
-					i.Labels.Channel11.Intensity,
-					i.Labels.Channel12.Intensity,
-					i.Labels.Channel13.Intensity,
-					i.Labels.Channel14.Intensity,
-					i.Labels.Channel15.Intensity,
-					i.Labels.Channel16.Intensity,
-					i.Labels.Channel17.Intensity,
-					i.Labels.Channel18.Intensity,
-				)
-			default:
-				header += ""
-			}
+		if channels > 0 {
+			line = fmt.Sprintf("%s\t%.4f%s", line, i.Purity, channelValues(i.Labels, channels))
 		}
 
 		line += "\n"
@@ -186,3 +99,178 @@ func (evi Evidence) MetaMSstatsReport(workspace, brand string, channels int, has
 		}
 	}
 }
+
+// MSstatsExport collects the PSMs of one or more data sets in the MSstats or MSstatsTMT input formats, the runs and
+// channels are annotated with the experimental design
+type MSstatsExport struct {
+	design   Design
+	features map[string]*msstatsFeature
+	missing  map[string]bool
+}
+
+// msstatsFeature is a precursor of a run, or the best PSM of a mixture for isobaric designs
+type msstatsFeature struct {
+	run       string
+	protein   string
+	peptide   string
+	charge    uint8
+	intensity float64
+	labels    *iso.Labels
+}
+
+// NewMSstatsExport creates an empty export following the experimental design
+func NewMSstatsExport(design Design) *MSstatsExport {
+	return &MSstatsExport{
+		design:   design,
+		features: make(map[string]*msstatsFeature),
+		missing:  make(map[string]bool),
+	}
+}
+
+// Add collects the PSMs of a data set, the runs that are not in the design are skipped
+func (x *MSstatsExport) Add(psms PSMEvidenceList, hasDecoys bool) {
+
+	for _, i := range psms {
+
+		if i.IsDecoy && !hasDecoys {
+			continue
+		}
+
+		run := strings.Split(i.Spectrum, ".")[0]
+		row, ok := x.design.LookupRun(run)
+		if !ok {
+			if !x.missing[run] {
+				logrus.Warning("the run ", run, " is not in the experimental design and was left out of the MSstats export")
+				x.missing[run] = true
+			}
+			continue
+		}
+
+		peptide := i.ModifiedPeptide
+		if len(peptide) == 0 {
+			peptide = i.Peptide
+		}
+
+		f := &msstatsFeature{run: run, protein: i.Protein, peptide: peptide, charge: i.AssumedCharge, intensity: i.Intensity}
+
+		// the isobaric PSMs are summarized per mixture, the fractions are merged by keeping the most intense spectrum
+		var key string
+		if x.design.Isobaric {
+			if i.Labels == nil {
+				continue
+			}
+			f.labels = i.Labels
+			f.intensity = 0
+			for _, j := range i.Labels.Channels {
+				f.intensity += j.Intensity
+			}
+			key = fmt.Sprintf("%s\t%s\t%s\t%s_%d", row.Mixture, row.TechRepMixture, i.Protein, peptide, i.AssumedCharge)
+		} else {
+			key = fmt.Sprintf("%s\t%s\t%s\t%d", row.Run, i.Protein, peptide, i.AssumedCharge)
+		}
+
+		if v, ok := x.features[key]; !ok || f.intensity > v.intensity {
+			x.features[key] = f
+		}
+	}
+}
+
+// msstatsIntensity prints the missing intensities as NA
+func msstatsIntensity(v float64) string {
+	if v <= 0 {
+		return "NA"
+	}
+	return fmt.Sprintf("%.4f", v)
+}
+
+// Write prints the export sorted by protein, peptide and charge
+func (x *MSstatsExport) Write(output string) {
+
+	file, e := os.Create(output)
+	if e != nil {
+		msg.WriteFile(errors.New("cannot create MSstats report"), "fatal")
+	}
+	defer file.Close()
+
+	var keys []string
+	for k := range x.features {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var header string
+	if x.design.Isobaric {
+		header = "ProteinName,PeptideSequence,Charge,PSM,Mixture,TechRepMixture,Run,Channel,Condition,BioReplicate,Intensity\n"
+	} else {
+		header = "ProteinName,PeptideSequence,PrecursorCharge,FragmentIon,ProductCharge,IsotopeLabelType,Condition,BioReplicate,Run,Fraction,Intensity\n"
+	}
+
+	_, e = io.WriteString(file, header)
+	if e != nil {
+		msg.WriteToFile(errors.New("cannot write to MSstats report"), "fatal")
+	}
+
+	for _, k := range keys {
+
+		f := x.features[k]
+
+		var lines []string
+		if x.design.Isobaric {
+			for _, i := range f.labels.Channels {
+
+				row, ok := x.design.Lookup(f.run, i.Name)
+				if !ok {
+					row, ok = x.design.Lookup(f.run, i.CustomName)
+				}
+				if !ok {
+					continue
+				}
+
+				lines = append(lines, fmt.Sprintf("%s,%s,%d,%s_%d,%s,%s,%s,%s,%s,%s,%s\n",
+					f.protein,
+					f.peptide,
+					f.charge,
+					f.peptide,
+					f.charge,
+					row.Mixture,
+					row.TechRepMixture,
+					row.Run,
+					row.Channel,
+					row.Condition,
+					row.BioReplicate,
+					msstatsIntensity(i.Intensity),
+				))
+			}
+		} else {
+			row, _ := x.design.LookupRun(f.run)
+			lines = append(lines, fmt.Sprintf("%s,%s,%d,NA,NA,L,%s,%s,%s,%d,%s\n",
+				f.protein,
+				f.peptide,
+				f.charge,
+				row.Condition,
+				row.BioReplicate,
+				row.Run,
+				row.Fraction,
+				msstatsIntensity(f.intensity),
+			))
+		}
+
+		for _, i := range lines {
+			_, e = io.WriteString(file, i)
+			if e != nil {
+				msg.WriteToFile(errors.New("cannot write to MSstats report"), "fatal")
+			}
+		}
+	}
+}
+
+// MSstatsDesignReport writes the MSstats or MSstatsTMT input of the workspace following the experimental design
+func (evi Evidence) MSstatsDesignReport(workspace string, design Design, hasDecoys bool) {
+	if evi.PSM == nil {
+		RestorePSM(&evi.PSM)
+	}
+
+	export := NewMSstatsExport(design)
+	export.Add(evi.PSM, hasDecoys)
+	export.Write(fmt.Sprintf("%s%s%s", workspace, string(filepath.Separator), design.ExportName()))
+}
@@ -0,0 +1,114 @@
+package rep
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"philosopher/lib/iso"
+)
+
+func TestReadDesign(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "design")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	lfq := filepath.Join(dir, "lfq.csv")
+	ioutil.WriteFile(lfq, []byte("Run,Condition,BioReplicate\nrun_01.mzML,control,1\n/data/run_02.raw,treated,2\n"), 0644)
+
+	d, err := readDesign(lfq, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row, ok := d.LookupRun("run_02"); !ok || row.Condition != "treated" || row.Fraction != 1 {
+		t.Errorf("the runs must match the spectrum names, got %+v", row)
+	}
+
+	tmt := filepath.Join(dir, "tmt.tsv")
+	ioutil.WriteFile(tmt, []byte("Run\tFraction\tMixture\tTechRepMixture\tChannel\tCondition\tBioReplicate\n"+
+		"run_01\t1\tM1\t1\t126\tcontrol\tc1\nrun_01\t1\tM1\t1\t127N\ttreated\tt1\n"), 0644)
+
+	d, err = readDesign(tmt, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row, ok := d.Lookup("run_01", "127N"); !ok || row.BioReplicate != "t1" {
+		t.Errorf("the channels must be indexed, got %+v", row)
+	}
+	if d.ExportName() != "msstats_tmt.csv" {
+		t.Error("the isobaric designs must be written in the MSstatsTMT format")
+	}
+
+	if _, err := readDesign(lfq, true); err == nil || !strings.Contains(err.Error(), "Mixture, TechRepMixture, Channel") {
+		t.Errorf("the missing isobaric columns must be reported, got %v", err)
+	}
+
+	repeated := filepath.Join(dir, "repeated.csv")
+	ioutil.WriteFile(repeated, []byte("Run,Condition,BioReplicate\nrun_01,control,1\nrun_01.raw,control,2\n"), 0644)
+	if _, err := readDesign(repeated, false); err == nil {
+		t.Error("the repeated runs must be rejected")
+	}
+}
+
+func TestMSstatsExport(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "msstats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	lfq := filepath.Join(dir, "lfq.csv")
+	ioutil.WriteFile(lfq, []byte("Run,Fraction,Condition,BioReplicate\nrun_01.raw,2,control,1\n"), 0644)
+
+	psms := PSMEvidenceList{
+		{Spectrum: "run_01.00010.00010.2", Peptide: "PEPTIDEK", Protein: "sp|P1|A", AssumedCharge: 2, Intensity: 100},
+		{Spectrum: "run_01.00020.00020.2", Peptide: "PEPTIDEK", Protein: "sp|P1|A", AssumedCharge: 2, Intensity: 250},
+		{Spectrum: "run_01.00030.00030.3", Peptide: "PEMTIDEK", ModifiedPeptide: "PEM[147]TIDEK", Protein: "sp|P1|A", AssumedCharge: 3},
+		{Spectrum: "run_01.00040.00040.2", Peptide: "KEDITPEP", Protein: "rev_sp|P1|A", AssumedCharge: 2, Intensity: 10, IsDecoy: true},
+		{Spectrum: "run_09.00050.00050.2", Peptide: "PEPTIDEK", Protein: "sp|P1|A", AssumedCharge: 2, Intensity: 10},
+	}
+
+	export := NewMSstatsExport(ReadDesign(lfq, false))
+	export.Add(psms, false)
+	export.Write(filepath.Join(dir, "lfq_out.csv"))
+
+	b, _ := ioutil.ReadFile(filepath.Join(dir, "lfq_out.csv"))
+	want := "ProteinName,PeptideSequence,PrecursorCharge,FragmentIon,ProductCharge,IsotopeLabelType,Condition,BioReplicate,Run,Fraction,Intensity\n" +
+		"sp|P1|A,PEM[147]TIDEK,3,NA,NA,L,control,1,run_01.raw,2,NA\n" +
+		"sp|P1|A,PEPTIDEK,2,NA,NA,L,control,1,run_01.raw,2,250.0000\n"
+	if string(b) != want {
+		t.Errorf("the MSstats export is incorrect, got\n%s\nwant\n%s", b, want)
+	}
+
+	tmt := filepath.Join(dir, "tmt.csv")
+	ioutil.WriteFile(tmt, []byte("Run,Fraction,Mixture,TechRepMixture,Channel,Condition,BioReplicate\n"+
+		"run_01,1,M1,1,126,control,c1\nrun_01,1,M1,1,treated,treated,t1\n"+
+		"run_02,2,M1,1,126,control,c1\nrun_02,2,M1,1,treated,treated,t1\n"), 0644)
+
+	labels := func(a, b float64) *iso.Labels {
+		return &iso.Labels{Channels: []iso.Channel{{Name: "126", Intensity: a}, {Name: "127N", CustomName: "treated", Intensity: b}}}
+	}
+
+	psms = PSMEvidenceList{
+		{Spectrum: "run_01.00010.00010.2", Peptide: "PEPTIDEK", Protein: "sp|P1|A", AssumedCharge: 2, Labels: labels(10, 20)},
+		{Spectrum: "run_02.00010.00010.2", Peptide: "PEPTIDEK", Protein: "sp|P1|A", AssumedCharge: 2, Labels: labels(100, 0)},
+	}
+
+	export = NewMSstatsExport(ReadDesign(tmt, true))
+	export.Add(psms, false)
+	export.Write(filepath.Join(dir, "tmt_out.csv"))
+
+	b, _ = ioutil.ReadFile(filepath.Join(dir, "tmt_out.csv"))
+	want = "ProteinName,PeptideSequence,Charge,PSM,Mixture,TechRepMixture,Run,Channel,Condition,BioReplicate,Intensity\n" +
+		"sp|P1|A,PEPTIDEK,2,PEPTIDEK_2,M1,1,run_02,126,control,c1,100.0000\n" +
+		"sp|P1|A,PEPTIDEK,2,PEPTIDEK_2,M1,1,run_02,treated,treated,t1,NA\n"
+	if string(b) != want {
+		t.Errorf("the MSstatsTMT export is incorrect, got\n%s\nwant\n%s", b, want)
+	}
+}
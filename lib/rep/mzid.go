@@ -2,1109 +2,926 @@ package rep
 
 import (
 	"fmt"
-	"philosopher/lib/id"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"philosopher/lib/bio"
 	"philosopher/lib/dat"
+	"philosopher/lib/id"
+	"philosopher/lib/iso"
+	"philosopher/lib/met"
+	"philosopher/lib/mod"
+	"philosopher/lib/obo"
 	"philosopher/lib/psi"
+	"philosopher/lib/spc"
 )
 
-// MzIdentMLReport creates a MzIdentML structure to be encoded
-func (e Evidence) MzIdentMLReport(version, database string) {
+// mzIdentML identifiers shared by the document sections
+const (
+	mzidPhilosopherRef    = "AS_Philosopher"
+	mzidSearchEngineRef   = "AS_SearchEngine"
+	mzidSearchDatabaseRef = "SearchDB_1"
+	mzidSearchProtocolRef = "SIP_1"
+	mzidProteinProtocol   = "PDP_1"
+	mzidProteinList       = "PDL_1"
+	mzidAuthorRef         = "Philosopher_Author_FVL"
+	mzidOrganizationRef   = "Nesvilab"
+)
 
-	var mzid psi.MzIdentML
+// mzidSearchEngines are the PSI-MS terms of the supported search engines
+var mzidSearchEngines = map[string][2]string{
+	"msfragger": {"MS:1003014", "MSFragger"},
+	"comet":     {"MS:1002251", "Comet"},
+}
 
-	t := time.Now()
-	//var idCounter = 0
+// MzIdentMLReport creates the mzIdentML 1.2 file with the spectrum identifications and the protein groups, the
+// file is written to the workspace as report.mzid when no output is given
+func (e Evidence) MzIdentMLReport(m met.Data, output string) {
 
-	// collect source file names
-	var sourceMap = make(map[string]uint8)
-	var sources []string
-	for _, i := range e.PSM {
-		s := strings.Split(i.Spectrum, ".")
-		sourceMap[s[0]]++
-	}
+	var pepxml id.PepXML
+	pepxml.Restore()
+	e.Mods = pepxml.Modifications
+	e.AssembleSearchParameters(pepxml.SearchParameters)
 
-	for i := range sourceMap {
-		sources = append(sources, i)
+	if len(m.SearchEngine) == 0 {
+		m.SearchEngine = pepxml.SearchEngine
 	}
 
-	sort.Strings(sources)
-
-	// load the database
 	var dtb dat.Base
 	dtb.Restore()
 
-	// spectra evidence reference map
-	var specRef = make(map[id.SpectrumType]string)
-
-	// peptide evidence reference map
-	var pepRef = make(map[string]string)
-
-	// protein evidence reference map
-	var proRef = make(map[string]string)
-
-	// Header
-	//mzid.Name = "foo"
-	mzid.ID = "Philosopher"
-	mzid.Version = "1.2.0"
-	mzid.CreationDate = t.Format(time.ANSIC)
-	mzid.Xmlns = "http://psidev.info/psi/pi/mzIdentML/1.2"
-	mzid.XmlnsXsi = "http://www.w3.org/2001/XMLSchema-instance"
-	mzid.XsiSchemaLocation = "http://psidev.info/psi/pi/mzIdentML/1.2 http://www.psidev.info/files/mzIdentML1.2.0.xsd"
-
-	// CVlist
-	mzid.CvList.CV = append(mzid.CvList.CV, psi.CV{ID: "PSI-MS", URI: "https://raw.githubusercontent.com/HUPO-PSI/psi-ms-CV/master/psi-ms.obo", FullName: "PSI-MS"})
-	mzid.CvList.CV = append(mzid.CvList.CV, psi.CV{ID: "UNIMOD", URI: "http://www.unimod.org/obo/unimod.obo", FullName: "UNIMOD"})
-	mzid.CvList.CV = append(mzid.CvList.CV, psi.CV{ID: "UO", URI: "https://raw.githubusercontent.com/bio-ontology-research-group/unit-ontology/master/unit.obo", FullName: "UNIT-ONTOLOGY"})
-	mzid.CvList.CV = append(mzid.CvList.CV, psi.CV{ID: "PRIDE", URI: "https://github.com/PRIDE-Utilities/pride-ontology/blob/master/pride_cv.obo", FullName: "PRIDE"})
-	mzid.CvList.Count = len(mzid.CvList.CV)
-
-	// AnalysisSoftwareList
-	aa := &psi.AnalysisSoftware{
-		ID:      "Philosopher",
-		Name:    "Philosopher toolkit",
+	o := obo.NewUniModOntology()
+
+	doc := newMzIdentML(e, m, pepxml.SearchParameters, dtb.Records, o.Terms)
+
+	if len(output) == 0 {
+		output = fmt.Sprintf("%s%sreport.mzid", m.Home, string(filepath.Separator))
+	}
+
+	doc.Write(output)
+}
+
+// mzidMatch links a spectrum identification item to its peptide
+type mzidMatch struct {
+	Peptide string
+	Item    string
+}
+
+// mzidBuilder holds the references shared by the document sections
+type mzidBuilder struct {
+	e         Evidence
+	m         met.Data
+	params    []spc.Parameter
+	terms     []obo.Term
+	records   map[string]dat.Record
+	doc       psi.MzIdentML
+	sequences map[string]string
+	peptides  map[string]string
+	evidences map[[2]string]string
+	ions      map[id.IonFormType][]mzidMatch
+	lists     []string
+}
+
+// newMzIdentML assembles the mzIdentML document from the evidences, the search parameters and the database
+func newMzIdentML(e Evidence, m met.Data, params []spc.Parameter, records []dat.Record, terms []obo.Term) psi.MzIdentML {
+
+	var b = mzidBuilder{
+		e:         e,
+		m:         m,
+		params:    params,
+		terms:     terms,
+		records:   make(map[string]dat.Record),
+		sequences: make(map[string]string),
+		peptides:  make(map[string]string),
+		evidences: make(map[[2]string]string),
+		ions:      make(map[id.IonFormType][]mzidMatch),
+	}
+
+	for _, i := range records {
+		b.records[i.PartHeader] = i
+	}
+
+	b.header()
+	b.spectrumIdentifications()
+	b.proteinDetection()
+	b.protocols()
+	b.inputs(len(records))
+
+	return b.doc
+}
+
+// header sets the document attributes, the vocabularies, the software and the contacts
+func (b *mzidBuilder) header() {
+
+	b.doc.ID = "Philosopher"
+	b.doc.Version = "1.2.0"
+	b.doc.CreationDate = time.Now().Format(time.RFC3339)
+	b.doc.Xmlns = "http://psidev.info/psi/pi/mzIdentML/1.2"
+	b.doc.XmlnsXsi = "http://www.w3.org/2001/XMLSchema-instance"
+	b.doc.XsiSchemaLocation = "http://psidev.info/psi/pi/mzIdentML/1.2 https://raw.githubusercontent.com/HUPO-PSI/mzIdentML/master/schema/mzIdentML1.2.0.xsd"
+
+	b.doc.CvList.CV = []psi.IdentCV{
+		{ID: "PSI-MS", FullName: "Proteomics Standards Initiative Mass Spectrometry Vocabularies", URI: "https://raw.githubusercontent.com/HUPO-PSI/psi-ms-CV/master/psi-ms.obo"},
+		{ID: "UNIMOD", FullName: "UNIMOD", URI: "http://www.unimod.org/obo/unimod.obo"},
+		{ID: "UO", FullName: "UNIT-ONTOLOGY", URI: "https://raw.githubusercontent.com/bio-ontology-research-group/unit-ontology/master/unit.obo"},
+	}
+
+	philosopher := psi.AnalysisSoftware{
+		ID:      mzidPhilosopherRef,
+		Name:    "Philosopher",
 		URI:     "https://philosopher.nesvilab.org",
-		Version: version,
-		ContactRole: psi.ContactRole{
-			ContactRef: "PS_DEV",
-			Role: psi.Role{
-				CVParam: psi.CVParam{
-					CVRef:     "PSI-MS",
-					Accession: "MS:1001267",
-					Name:      "software vendor",
-				},
-			},
-		},
-		SoftwareName: psi.SoftwareName{
-			CVParam: psi.CVParam{
-				CVRef:     "PSI-MS",
-				Accession: "XXXX",
-				Name:      "Philosopher",
-			},
-		},
-		Customizations: psi.Customizations{
-			Value: "No customizations",
+		Version: b.m.Version,
+		ContactRole: &psi.ContactRole{
+			ContactRef: mzidOrganizationRef,
+			Role:       psi.Role{CVParam: psi.CVParam{CVRef: "PSI-MS", Accession: "MS:1001267", Name: "software vendor"}},
 		},
+		SoftwareName: psi.SoftwareName{UserParam: &psi.UserParam{Name: "Philosopher"}},
 	}
-	mzid.AnalysisSoftwareList.AnalysisSoftware = append(mzid.AnalysisSoftwareList.AnalysisSoftware, *aa)
-
-	//Provider
-	provider := &psi.Provider{
-		ID: "PROVIDER",
-		ContactRole: psi.ContactRole{
-			ContactRef: "Philosopher_Author_FVL",
-			Role: psi.Role{
-				CVParam: psi.CVParam{
-					CVRef:     "PSI-MS",
-					Accession: "MS:1001271",
-					Name:      "researcher",
-				},
-			},
-		},
+
+	engine := psi.AnalysisSoftware{ID: mzidSearchEngineRef, Name: b.m.SearchEngine}
+	if v, ok := mzidSearchEngines[strings.ToLower(b.m.SearchEngine)]; ok {
+		engine.SoftwareName.CVParam = &psi.CVParam{CVRef: "PSI-MS", Accession: v[0], Name: v[1]}
+	} else if len(b.m.SearchEngine) > 0 {
+		engine.SoftwareName.UserParam = &psi.UserParam{Name: b.m.SearchEngine}
+	} else {
+		engine.SoftwareName.UserParam = &psi.UserParam{Name: "unknown search engine"}
+	}
+	if len(b.e.Parameters.MSFragger) > 0 {
+		engine.Version = b.e.Parameters.MSFragger
 	}
-	mzid.Provider = *provider
 
-	// AuditCollection
+	b.doc.AnalysisSoftwareList.AnalysisSoftware = []psi.AnalysisSoftware{philosopher, engine}
 
-	auditCol := &psi.AuditCollection{
+	b.doc.Provider = &psi.Provider{
+		ID:                  "PROVIDER",
+		AnalysisSoftwareRef: mzidPhilosopherRef,
+		ContactRole: &psi.ContactRole{
+			ContactRef: mzidAuthorRef,
+			Role:       psi.Role{CVParam: psi.CVParam{CVRef: "PSI-MS", Accession: "MS:1001271", Name: "researcher"}},
+		},
+	}
+
+	b.doc.AuditCollection = &psi.AuditCollection{
 		Person: psi.Person{
-			ID:        "Philosopher_Author_FVL",
+			ID:        mzidAuthorRef,
 			LastName:  "da Veiga Leprevost",
 			FirstName: "Felipe",
 			CVParam: []psi.CVParam{
-				{
-					Name:      "contact email",
-					Value:     "felipevl@umich.edu",
-					CVRef:     "PSI-MS",
-					Accession: "MS:1000589",
-				},
-				{
-					Name:      "contact URL",
-					Value:     "http://nesvilab.org",
-					CVRef:     "PSI-MS",
-					Accession: "MS:1000588",
-				},
-			},
-			Affiliation: []psi.Affiliation{
-				{
-					OrganizationRef: "University of Michigan",
-				},
+				{CVRef: "PSI-MS", Accession: "MS:1000589", Name: "contact email", Value: "felipevl@umich.edu"},
+				{CVRef: "PSI-MS", Accession: "MS:1000588", Name: "contact URL", Value: "http://nesvilab.org"},
 			},
+			Affiliation: []psi.Affiliation{{OrganizationRef: mzidOrganizationRef}},
 		},
 		Organization: psi.Organization{
-			ID:   "Nesvilab",
+			ID:   mzidOrganizationRef,
 			Name: "Proteomics and Integrative Bioinformatics Lab",
 			CVParam: []psi.CVParam{
-				{
-					Name:      "contact name",
-					Value:     "Alexey I. Nesvizhskii",
-					CVRef:     "PSI-MS",
-					Accession: "MS:1000586",
-				},
-				{
-					Name:      "contact address",
-					Value:     "1301 Catherinse St., Ann Arbor, MI",
-					CVRef:     "PSI-MS",
-					Accession: "MS:1000587",
-				},
-				{
-					Name:      "contact URL",
-					Value:     "http://nesvilab.org",
-					CVRef:     "PSI-MS",
-					Accession: "MS:1000588",
-				},
-				{
-					Name:      "contact email",
-					Value:     "nesvi@med.umich.edu",
-					CVRef:     "PSI-MS",
-					Accession: "MS:1000589",
-				},
+				{CVRef: "PSI-MS", Accession: "MS:1000586", Name: "contact name", Value: "Alexey I. Nesvizhskii"},
+				{CVRef: "PSI-MS", Accession: "MS:1000587", Name: "contact address", Value: "1301 Catherine St., Ann Arbor, MI"},
+				{CVRef: "PSI-MS", Accession: "MS:1000588", Name: "contact URL", Value: "http://nesvilab.org"},
+				{CVRef: "PSI-MS", Accession: "MS:1000589", Name: "contact email", Value: "nesvi@med.umich.edu"},
 			},
 		},
 	}
-	mzid.AuditCollection = *auditCol
+}
+
+// spectrumIdentifications creates one spectrum identification list for each source file, each PSM is reported as
+// a spectrum identification result with a single item referencing every protein the peptide maps to
+func (b *mzidBuilder) spectrumIdentifications() {
 
-	// SequenceCollection - DBSequence
-	idCounter := 0
-	var seqs []psi.DBSequence
-	for _, i := range dtb.Records {
+	var results = make(map[string][]psi.SpectrumIdentificationResult)
+	var counter int
 
-		idCounter++
+	for _, i := range b.e.PSM {
 
-		db := &psi.DBSequence{
-			ID:                fmt.Sprintf("DB_%d", idCounter),
-			Accession:         i.ID,
-			SearchDatabaseRef: dtb.FileName,
+		if i.IsDecoy && !b.m.Report.Decoys {
+			continue
+		}
+
+		peptide := b.peptide(i)
+
+		var proteins = []string{i.Protein}
+		var mapped []string
+		for j := range i.MappedProteins {
+			if j != i.Protein {
+				mapped = append(mapped, j)
+			}
+		}
+		sort.Strings(mapped)
+		proteins = append(proteins, mapped...)
+
+		var refs []psi.PeptideEvidenceRef
+		for _, j := range proteins {
+			if ev, ok := b.peptideEvidence(peptide, i, j); ok {
+				refs = append(refs, psi.PeptideEvidenceRef{PeptideEvidenceRef: ev})
+			}
+		}
+
+		if len(refs) == 0 {
+			continue
+		}
+
+		counter++
+		item := fmt.Sprintf("SII_%d", counter)
+
+		rank := i.HitRank
+		if rank < 1 {
+			rank = 1
+		}
+
+		sii := psi.SpectrumIdentificationItem{
+			ID:                       item,
+			ChargeState:              i.AssumedCharge,
+			ExperimentalMassToCharge: mzidMassToCharge(i.PrecursorNeutralMass, i.AssumedCharge),
+			CalculatedMassToCharge:   mzidMassToCharge(i.CalcNeutralPepMass, i.AssumedCharge),
+			PeptideRef:               peptide,
+			Rank:                     rank,
+			PassThreshold:            "true",
+			PeptideEvidenceRef:       refs,
+		}
+		sii.CVParam, sii.UserParam = mzidScores(i)
+
+		cv, up := labelParams(i.Labels)
+		sii.CVParam = append(sii.CVParam, cv...)
+		sii.UserParam = append(sii.UserParam, up...)
+
+		source, spectrumID := mzidSpectrum(i)
+
+		sir := psi.SpectrumIdentificationResult{
+			ID:                         fmt.Sprintf("SIR_%d", counter),
+			SpectrumID:                 spectrumID,
+			SpectrumIdentificationItem: []psi.SpectrumIdentificationItem{sii},
 			CVParam: []psi.CVParam{
-				{
-					CVRef:     "PSI-MS",
-					Accession: "MS:1001088",
-					Name:      "protein description",
-					Value:     i.Description,
-				},
-				{
-					CVRef:     "PSI-MS",
-					Accession: "MS:1001344",
-					Name:      "AA sequence",
-				},
-			},
-			Seq: psi.Seq{
-				Value: i.Sequence,
+				{CVRef: "PSI-MS", Accession: "MS:1000796", Name: "spectrum title", Value: i.Spectrum},
+				{CVRef: "PSI-MS", Accession: "MS:1000016", Name: "scan start time", Value: mzidFloat(i.RetentionTime), UnitCvRef: "UO", UnitAccession: "UO:0000010", UnitName: "second"},
 			},
 		}
 
-		proRef[i.ID] = fmt.Sprintf("DB_%d", idCounter)
-		seqs = append(seqs, *db)
+		results[source] = append(results[source], sir)
+		b.ions[i.IonForm()] = append(b.ions[i.IonForm()], mzidMatch{Peptide: peptide, Item: item})
+	}
+
+	for i := range results {
+		b.lists = append(b.lists, i)
 	}
-	mzid.SequenceCollection.DBSequence = seqs
+	sort.Strings(b.lists)
 
-	// SequenceCollection - Peptide
-	var peps []psi.Peptide
-	for _, i := range e.Peptides {
+	for n, i := range b.lists {
 
-		p := psi.Peptide{
-			ID: i.Sequence,
-			PeptideSequence: psi.PeptideSequence{
-				Value: i.Sequence,
-			},
+		spectra := fmt.Sprintf("SD_%d", n+1)
+		list := fmt.Sprintf("SIL_%d", n+1)
+
+		for j := range results[i] {
+			results[i][j].SpectraDataRef = spectra
 		}
 
-		for _, j := range i.Modifications.IndexSlice {
-			if j.Name != "Unknown" {
-				mod := psi.Modification{
-					//AvgMassDelta:          j.AverageMass,
-					//MonoIsotopicMassDelta: j.MonoIsotopicMass,
-					Residues: j.AminoAcid,
-					Location: strconv.Itoa(j.Position),
-					CVParam: []psi.CVParam{
-						{
-							CVRef:     "UNIMOD",
-							Accession: j.ID,
-							Name:      j.Name,
-						},
-					},
-				}
+		b.doc.AnalysisCollection.SpectrumIdentification = append(b.doc.AnalysisCollection.SpectrumIdentification, psi.SpectrumIdentification{
+			ID:                                fmt.Sprintf("SI_%d", n+1),
+			SpectrumIdentificationProtocolRef: mzidSearchProtocolRef,
+			SpectrumIdentificationListRef:     list,
+			InputSpectra:                      []psi.InputSpectra{{SpectraDataRef: spectra}},
+			SearchDatabaseRef:                 []psi.SearchDatabaseRef{{SearchDatabaseRef: mzidSearchDatabaseRef}},
+		})
+
+		b.doc.DataCollection.AnalysisData.SpectrumIdentificationList = append(b.doc.DataCollection.AnalysisData.SpectrumIdentificationList, psi.SpectrumIdentificationList{
+			ID:                           list,
+			SpectrumIdentificationResult: results[i],
+		})
+
+		b.doc.DataCollection.Inputs.SpectraData = append(b.doc.DataCollection.Inputs.SpectraData, psi.SpectraData{
+			ID:               spectra,
+			Name:             i + ".mzML",
+			Location:         filepath.Join(b.m.Home, i+".mzML"),
+			FileFormat:       &psi.FileFormat{CVParam: psi.CVParam{CVRef: "PSI-MS", Accession: "MS:1000584", Name: "mzML format"}},
+			SpectrumIDFormat: psi.SpectrumIDFormat{CVParam: psi.CVParam{CVRef: "PSI-MS", Accession: "MS:1000776", Name: "scan number only nativeID format"}},
+		})
+	}
+}
 
-				if mod.Residues == "N-term" {
-					mod.Residues = ""
-				}
+// peptide returns the reference of the peptide sequence with its assigned modifications, the peptide is added to
+// the sequence collection on the first occurrence
+func (b *mzidBuilder) peptide(p PSMEvidence) string {
 
-				p.Modification = append(p.Modification, mod)
-			}
+	var mods []psi.Modification
+	var locations []int
+	for _, i := range p.Modifications.IndexSlice {
+		if i.Type != mod.Assigned || i.MassDiff == 0 {
+			continue
 		}
+		m, l := b.modification(p.Peptide, i)
+		mods = append(mods, m)
+		locations = append(locations, l)
+	}
+
+	sort.Sort(mzidModifications{mods, locations})
+
+	key := p.Peptide
+	for n, i := range mods {
+		key += fmt.Sprintf("#%d:%.4f", locations[n], i.MonoIsotopicMassDelta)
+	}
+
+	if v, ok := b.peptides[key]; ok {
+		return v
+	}
+
+	ref := fmt.Sprintf("Pep_%d", len(b.peptides)+1)
+	b.peptides[key] = ref
+
+	b.doc.SequenceCollection.Peptide = append(b.doc.SequenceCollection.Peptide, psi.Peptide{
+		ID:              ref,
+		PeptideSequence: psi.PeptideSequence{Value: p.Peptide},
+		Modification:    mods,
+	})
+
+	return ref
+}
+
+// modification places a modification on the peptide, the terminal modifications are placed on position 0 and
+// after the last residue; masses without a UNIMOD term are reported as unknown modifications
+func (b *mzidBuilder) modification(sequence string, m mod.Modification) (psi.Modification, int) {
+
+	location := m.Position
+	site := m.AminoAcid
+
+	switch m.AminoAcid {
+	case "N-term", "n":
+		location = 0
+		site = "N-term"
+	case "C-term", "c":
+		location = len(sequence) + 1
+		site = "C-term"
+	}
+
+	mo := psi.Modification{
+		Location:              strconv.Itoa(location),
+		MonoIsotopicMassDelta: m.MassDiff,
+	}
+
+	if len(site) == 1 && site[0] >= 'A' && site[0] <= 'Z' {
+		mo.Residues = site
+	}
+
+	if strings.HasPrefix(m.ID, "UNIMOD:") && len(m.Name) > 0 {
+		mo.CVParam = []psi.CVParam{{CVRef: "UNIMOD", Accession: m.ID, Name: m.Name}}
+	} else if t, ok := unimodTerm(b.terms, m.MassDiff, site); ok {
+		mo.CVParam = []psi.CVParam{{CVRef: "UNIMOD", Accession: t.ID, Name: t.Name}}
+	} else {
+		mo.CVParam = []psi.CVParam{{CVRef: "PSI-MS", Accession: "MS:1001460", Name: "unknown modification"}}
+	}
+
+	return mo, location
+}
+
+// mzidModifications sorts the peptide modifications by location
+type mzidModifications struct {
+	mods      []psi.Modification
+	locations []int
+}
+
+func (a mzidModifications) Len() int { return len(a.mods) }
+func (a mzidModifications) Swap(i, j int) {
+	a.mods[i], a.mods[j] = a.mods[j], a.mods[i]
+	a.locations[i], a.locations[j] = a.locations[j], a.locations[i]
+}
+func (a mzidModifications) Less(i, j int) bool {
+	if a.locations[i] != a.locations[j] {
+		return a.locations[i] < a.locations[j]
+	}
+	return a.mods[i].MonoIsotopicMassDelta < a.mods[j].MonoIsotopicMassDelta
+}
+
+// peptideEvidence returns the reference of the peptide on the protein, the flanking residues and the position come
+// from the database sequence when available or from the PSM for the main protein; decoy proteins are only reported
+// with the decoys option
+func (b *mzidBuilder) peptideEvidence(peptide string, p PSMEvidence, protein string) (string, bool) {
 
-		peps = append(peps, p)
+	record, found := b.records[protein]
+
+	isDecoy := p.IsDecoy && protein == p.Protein
+	if found {
+		isDecoy = record.IsDecoy
+	} else if len(b.m.Database.Tag) > 0 && strings.HasPrefix(protein, b.m.Database.Tag) {
+		isDecoy = true
 	}
-	mzid.SequenceCollection.Peptide = peps
 
-	// SequenceCollection - PeptideEvidence
-	var pevs []psi.PeptideEvidence
-	idCounter = 0
-	for _, i := range e.PSM {
+	if isDecoy && !b.m.Report.Decoys {
+		return "", false
+	}
 
-		idCounter++
+	key := [2]string{peptide, protein}
+	if v, ok := b.evidences[key]; ok {
+		return v, true
+	}
 
-		evi := psi.PeptideEvidence{
-			DBSequenceRef: i.ProteinID,
-			ID:            fmt.Sprintf("PepEv_%d", idCounter),
-			IsDecoy:       strconv.FormatBool(i.IsDecoy),
-			PeptideRef:    i.Peptide,
-			Pre:           string(i.PrevAA),
-			Post:          string(i.NextAA),
+	ref := fmt.Sprintf("PepEv_%d", len(b.evidences)+1)
+	b.evidences[key] = ref
+
+	ev := psi.PeptideEvidence{
+		ID:            ref,
+		PeptideRef:    peptide,
+		DBSequenceRef: b.dbSequence(protein),
+		IsDecoy:       strconv.FormatBool(isDecoy),
+	}
+
+	if idx := strings.Index(record.Sequence, p.Peptide); found && len(p.Peptide) > 0 && idx >= 0 {
+		ev.Start = strconv.Itoa(idx + 1)
+		ev.End = idx + len(p.Peptide)
+		ev.Pre = "-"
+		if idx > 0 {
+			ev.Pre = mzidResidue(record.Sequence[idx-1])
+		}
+		ev.Post = "-"
+		if ev.End < len(record.Sequence) {
+			ev.Post = mzidResidue(record.Sequence[ev.End])
 		}
+	} else if protein == p.Protein && p.ProteinStart > 0 {
+		ev.Start = strconv.Itoa(p.ProteinStart)
+		ev.End = p.ProteinEnd
+		ev.Pre = mzidResidue(p.PrevAA)
+		ev.Post = mzidResidue(p.NextAA)
+	}
+
+	b.doc.SequenceCollection.PeptideEvidence = append(b.doc.SequenceCollection.PeptideEvidence, ev)
 
-		pepRef[i.Peptide] = fmt.Sprintf("PepEv_%d", idCounter)
-		pevs = append(pevs, evi)
+	return ref, true
+}
+
+// dbSequence returns the reference of the protein, the database sequence is added to the sequence collection on
+// the first occurrence
+func (b *mzidBuilder) dbSequence(protein string) string {
+
+	if v, ok := b.sequences[protein]; ok {
+		return v
 	}
-	mzid.SequenceCollection.PeptideEvidence = pevs
 
-	// AnalysisCollection
-	idCounter = 0
-	ac := &psi.AnalysisCollection{}
-	for _, i := range sources {
+	ref := fmt.Sprintf("DBSeq_%d", len(b.sequences)+1)
+	b.sequences[protein] = ref
 
-		idCounter++
+	seq := psi.DBSequence{
+		ID:                ref,
+		Accession:         protein,
+		SearchDatabaseRef: mzidSearchDatabaseRef,
+	}
 
-		si := &psi.SpectrumIdentification{
-			SpectrumIdentificationListRef:     fmt.Sprintf("SIL_%d", idCounter),
-			ID:                                fmt.Sprintf("SpecIdent_%d", idCounter),
-			SpectrumIdentificationProtocolRef: fmt.Sprintf("SearchProtocol_%d", idCounter),
-			InputSpectra: []psi.InputSpectra{
-				{
-					SpectraDataRef: i,
-				},
-			},
-			SearchDatabaseRef: []psi.SearchDatabaseRef{
-				{
-					SearchDatabaseRef: dtb.FileName,
-				},
-			},
+	if r, ok := b.records[protein]; ok {
+		if len(r.Sequence) > 0 {
+			seq.Length = strconv.Itoa(len(r.Sequence))
+			seq.Seq = &psi.Seq{Value: r.Sequence}
 		}
+		if len(r.Description) > 0 {
+			seq.CVParam = append(seq.CVParam, psi.CVParam{CVRef: "PSI-MS", Accession: "MS:1001088", Name: "protein description", Value: r.Description})
+		}
+	}
 
-		ac.SpectrumIdentification = append(ac.SpectrumIdentification, *si)
-	}
-
-	ac.ProteinDetection = psi.ProteinDetection{
-		ProteinDetectionProtocolRef: "Philosopher_protocol",
-		ProteinDetectionListRef:     "Protein Groups",
-		ID:                          "Phi_1",
-	}
-	mzid.AnalysisCollection = *ac
-
-	// AnalysisProtocolCollection
-	apc := &psi.AnalysisProtocolCollection{
-		SpectrumIdentificationProtocol: []psi.SpectrumIdentificationProtocol{
-			{
-				AnalysisSoftwareRef: "DatabaseSearch_ID",
-				ID:                  "Search_Protocol_1",
-				SearchType: psi.SearchType{
-					CVParam: psi.CVParam{
-						CVRef:     "PSI-MS",
-						Accession: "MS:1001083",
-						Name:      "ms-ms search",
-					},
-				},
-				AdditionalSearchParams: psi.AdditionalSearchParams{
-					CVParam: []psi.CVParam{
-						{
-							CVRef:     "PSI-MS",
-							Accession: "MS:1001211",
-							Name:      "parent mass type mono",
-						},
-						{
-							CVRef:     "PSI-MS",
-							Accession: "MS:1001256",
-							Name:      "fragment mass type mono",
-						},
-						{
-							CVRef:     "PSI-MS",
-							Accession: "MS:1002492",
-							Name:      "consensus scoring",
-						},
-						{
-							CVRef:     "PSI-MS",
-							Accession: "MS:1002490",
-							Name:      "peptide-level scoring",
-						},
-						{
-							CVRef:     "PSI-MS",
-							Accession: "MS:1002497",
-							Name:      "group PSMs by sequence with modifications",
-						},
-						{
-							CVRef:     "PSI-MS",
-							Accession: "MS:1002491",
-							Name:      "modification localization scoring",
-						},
-					},
-					UserParam: []psi.UserParam{
-						{
-							Name:  "MSFragger",
-							Value: e.Parameters.MSFragger,
-						},
-						{
-							Name:  "database_name",
-							Value: e.Parameters.DatabaseName,
-						},
-						{
-							Name:  "precursor_mass_lower",
-							Value: e.Parameters.PrecursorMassLower,
-						},
-						{
-							Name:  "precursor_mass_upper",
-							Value: e.Parameters.PrecursorMassUpper,
-						},
-						{
-							Name:  "precursor_mass_units",
-							Value: e.Parameters.PrecursorMassUnits,
-						},
-						{
-							Name:  "precursor_true_tolerance",
-							Value: e.Parameters.PrecursorTrueTolerance,
-						},
-						{
-							Name:  "precursor_true_units",
-							Value: e.Parameters.PrecursorTrueUnits,
-						},
-						{
-							Name:  "fragment_mass_tolerance",
-							Value: e.Parameters.FragmentMassTolerance,
-						},
-						{
-							Name:  "fragment_mass_units",
-							Value: e.Parameters.FragmentMassUnits,
-						},
-						{
-							Name:  "calibrate_mass",
-							Value: e.Parameters.CalibrateMass,
-						},
-						{
-							Name:  "ms1_tolerance_mad",
-							Value: e.Parameters.Ms1ToleranceMad,
-						},
-						{
-							Name:  "ms2_tolerance_mad",
-							Value: e.Parameters.Ms2ToleranceMad,
-						},
-						{
-							Name:  "evaluate_mass_calibration",
-							Value: e.Parameters.EvaluateMassCalibration,
-						},
-						{
-							Name:  "isotope_error",
-							Value: e.Parameters.IsotopeError,
-						},
-						{
-							Name:  "mass_offsets",
-							Value: e.Parameters.MassOffsets,
-						},
-						{
-							Name:  "precursor_mass_mode",
-							Value: e.Parameters.PrecursorMassMode,
-						},
-						{
-							Name:  "shifted_ions",
-							Value: e.Parameters.ShiftedIons,
-						},
-						{
-							Name:  "shifted_ions_exclude_ranges",
-							Value: e.Parameters.ShiftedIonsExcludeRanges,
-						},
-						{
-							Name:  "fragment_ion_series",
-							Value: e.Parameters.FragmentIonSeries,
-						},
-						{
-							Name:  "search_enzyme_name",
-							Value: e.Parameters.SearchEnzymeName,
-						},
-						{
-							Name:  "search_enzyme_cutafter",
-							Value: e.Parameters.SearchEnzymeCutafter,
-						},
-						{
-							Name:  "search_enzyme_butnotafter",
-							Value: e.Parameters.SearchEnzymeButnotafter,
-						},
-						{
-							Name:  "num_enzyme_termini",
-							Value: e.Parameters.NumEnzymeTermini,
-						},
-						{
-							Name:  "allowed_missed_cleavage",
-							Value: e.Parameters.AllowedMissedCleavage,
-						},
-						{
-							Name:  "clip_nTerm_M",
-							Value: e.Parameters.ClipNTermM,
-						},
-						{
-							Name:  "allow_multiple_variable_mods_on_residue",
-							Value: e.Parameters.AllowMultipleVariableModsOnResidue,
-						},
-						{
-							Name:  "max_variable_mods_per_mod",
-							Value: e.Parameters.MaxVariableModsPerMod,
-						},
-						{
-							Name:  "max_variable_mods_combinations",
-							Value: e.Parameters.MaxVariableModsCombinations,
-						},
-						{
-							Name:  "output_format",
-							Value: e.Parameters.OutputFormat,
-						},
-						{
-							Name:  "output_report_topN",
-							Value: e.Parameters.OutputReportTopN,
-						},
-						{
-							Name:  "output_max_expect",
-							Value: e.Parameters.OutputMaxExpect,
-						},
-						{
-							Name:  "report_alternative_proteins",
-							Value: e.Parameters.ReportAlternativeProteins,
-						},
-						{
-							Name:  "override_charge",
-							Value: e.Parameters.OverrideCharge,
-						},
-						{
-							Name:  "precursor_charge",
-							Value: e.Parameters.PrecursorCharge,
-						},
-						{
-							Name:  "digest_min_length",
-							Value: e.Parameters.DigestMinLength,
-						},
-						{
-							Name:  "digest_max_length",
-							Value: e.Parameters.DigestMaxLength,
-						},
-						{
-							Name:  "digest_mass_range",
-							Value: e.Parameters.DigestMassRange,
-						},
-						{
-							Name:  "max_fragment_charge",
-							Value: e.Parameters.MaxFragmentCharge,
-						},
-						{
-							Name:  "track_zero_topN",
-							Value: e.Parameters.TrackZeroTopN,
-						},
-						{
-							Name:  "zero_bin_accept_expect",
-							Value: e.Parameters.ZeroBinAcceptExpect,
-						},
-						{
-							Name:  "zero_bin_mult_expect",
-							Value: e.Parameters.ZeroBinMultExpect,
-						},
-						{
-							Name:  "add_topN_complementary",
-							Value: e.Parameters.AddTopNComplementary,
-						},
-						{
-							Name:  "minimum_peaks",
-							Value: e.Parameters.MinimumPeaks,
-						},
-						{
-							Name:  "use_topN_peaks",
-							Value: e.Parameters.UseTopNPeaks,
-						},
-						{
-							Name:  "min_fragments_modelling",
-							Value: e.Parameters.MinFragmentsModelling,
-						},
-						{
-							Name:  "min_matched_fragments",
-							Value: e.Parameters.MinMatchedFragments,
-						},
-						{
-							Name:  "minimum_ratio",
-							Value: e.Parameters.MinimumRatio,
-						},
-						{
-							Name:  "clear_mz_range",
-							Value: e.Parameters.ClearMzRange,
-						},
-						{
-							Name:  "variable_mod_01",
-							Value: e.Parameters.VariableMod01,
-						},
-						{
-							Name:  "variable_mod_02",
-							Value: e.Parameters.VariableMod02,
-						},
-						{
-							Name:  "add_C_cysteine",
-							Value: e.Parameters.Cysteine,
-						},
-						{
-							Name:  "add_Cterm_peptide",
-							Value: e.Parameters.CTermPeptide,
-						},
-						{
-							Name:  "add_Cterm_protein",
-							Value: e.Parameters.CTermProtein,
-						},
-						{
-							Name:  "add_D_aspartic_acid",
-							Value: e.Parameters.AsparticAcid,
-						},
-						{
-							Name:  "add_E_glutamic_acid",
-							Value: e.Parameters.GlutamicAcid,
-						},
-						{
-							Name:  "add_F_phenylalanine",
-							Value: e.Parameters.Phenylalanine,
-						},
-						{
-							Name:  "add_G_glycine",
-							Value: e.Parameters.Glycine,
-						},
-						{
-							Name:  "add_H_histidine",
-							Value: e.Parameters.Histidine,
-						},
-						{
-							Name:  "add_I_isoleucine",
-							Value: e.Parameters.Isoleucine,
-						},
-						{
-							Name:  "add_K_lysine",
-							Value: e.Parameters.Lysine,
-						},
-						{
-							Name:  "add_L_leucine",
-							Value: e.Parameters.Leucine,
-						},
-						{
-							Name:  "add_M_methionine",
-							Value: e.Parameters.Methionine,
-						},
-						{
-							Name:  "add_N_asparagine",
-							Value: e.Parameters.Asparagine,
-						},
-						{
-							Name:  "add_Nterm_peptide",
-							Value: e.Parameters.NTermPeptide,
-						},
-						{
-							Name:  "add_Nterm_protein",
-							Value: e.Parameters.NTermProtein,
-						},
-						{
-							Name:  "add_P_proline",
-							Value: e.Parameters.Proline,
-						},
-						{
-							Name:  "add_Q_glutamine",
-							Value: e.Parameters.GlutamicAcid,
-						},
-						{
-							Name:  "add_R_arginine",
-							Value: e.Parameters.Arginine,
-						},
-						{
-							Name:  "add_S_serine",
-							Value: e.Parameters.Serine,
-						},
-						{
-							Name:  "add_T_threonine",
-							Value: e.Parameters.Threonine,
-						},
-						{
-							Name:  "add_V_valine",
-							Value: e.Parameters.Valine,
-						},
-						{
-							Name:  "add_W_tryptophan",
-							Value: e.Parameters.Tryptophan,
-						},
-						{
-							Name:  "add_Y_tyrosine",
-							Value: e.Parameters.Tyrosine,
-						},
-					},
-				},
-			},
-		},
+	b.doc.SequenceCollection.DBSequence = append(b.doc.SequenceCollection.DBSequence, seq)
+
+	return ref
+}
+
+// proteinDetection creates one ambiguity group for each protein group, the group proteins are the leading
+// hypotheses and their indistinguishable proteins are the non-leading ones
+func (b *mzidBuilder) proteinDetection() {
+
+	var groups = make(map[uint32][]ProteinEvidence)
+	var order []uint32
+
+	for _, i := range b.e.Proteins {
+		if i.IsDecoy && !b.m.Report.Decoys {
+			continue
+		}
+		if _, ok := groups[i.ProteinGroup]; !ok {
+			order = append(order, i.ProteinGroup)
+		}
+		groups[i.ProteinGroup] = append(groups[i.ProteinGroup], i)
+	}
+
+	sort.Slice(order, func(i, j int) bool { return order[i] < order[j] })
+
+	var pdl = psi.ProteinDetectionList{ID: mzidProteinList}
+	var hypotheses int
+
+	for _, i := range order {
+
+		pag := psi.ProteinAmbiguityGroup{ID: fmt.Sprintf("PAG_%d", i)}
+
+		for _, j := range groups[i] {
+
+			if pdh, ok := b.hypothesis(j, j.PartHeader, true, &hypotheses); ok {
+				pag.ProteinDetectionHypothesis = append(pag.ProteinDetectionHypothesis, pdh)
+			}
+
+			var indistinguishable []string
+			for k := range j.IndiProtein {
+				if k != j.PartHeader {
+					indistinguishable = append(indistinguishable, k)
+				}
+			}
+			sort.Strings(indistinguishable)
+
+			for _, k := range indistinguishable {
+				if pdh, ok := b.hypothesis(j, k, false, &hypotheses); ok {
+					pag.ProteinDetectionHypothesis = append(pag.ProteinDetectionHypothesis, pdh)
+				}
+			}
+		}
+
+		if len(pag.ProteinDetectionHypothesis) == 0 {
+			continue
+		}
+
+		pag.ProteinDetectionHypothesis[0].CVParam = append(pag.ProteinDetectionHypothesis[0].CVParam, psi.CVParam{CVRef: "PSI-MS", Accession: "MS:1002403", Name: "group representative"})
+		pag.CVParam = []psi.CVParam{{CVRef: "PSI-MS", Accession: "MS:1002415", Name: "protein group passes threshold", Value: "true"}}
+
+		pdl.ProteinAmbiguityGroup = append(pdl.ProteinAmbiguityGroup, pag)
+	}
+
+	if len(pdl.ProteinAmbiguityGroup) == 0 {
+		return
 	}
 
-	mzid.AnalysisProtocolCollection = *apc
+	pdl.CVParam = []psi.CVParam{{CVRef: "PSI-MS", Accession: "MS:1002404", Name: "count of identified proteins", Value: strconv.Itoa(len(pdl.ProteinAmbiguityGroup))}}
+	b.doc.DataCollection.AnalysisData.ProteinDetectionList = &pdl
 
-	// DataCollection
-	dta := psi.DataCollection{}
+	pd := psi.ProteinDetection{
+		ID:                          "PD_1",
+		ProteinDetectionProtocolRef: mzidProteinProtocol,
+		ProteinDetectionListRef:     mzidProteinList,
+	}
+	for n := range b.lists {
+		pd.InputSpectrumIdentifications = append(pd.InputSpectrumIdentifications, psi.InputSpectrumIdentifications{SpectrumIdentificationListRef: fmt.Sprintf("SIL_%d", n+1)})
+	}
+	b.doc.AnalysisCollection.ProteinDetection = &pd
+}
 
-	for _, i := range sources {
-		sf := &psi.SourceFile{
-			ID:       i,
-			Location: i,
-			Name:     i,
+// hypothesis reports a protein with the peptide evidences supporting it on the given accession, proteins without
+// evidences are not reported
+func (b *mzidBuilder) hypothesis(p ProteinEvidence, accession string, leading bool, counter *int) (psi.ProteinDetectionHypothesis, bool) {
+
+	var pdh psi.ProteinDetectionHypothesis
+
+	dbRef, ok := b.sequences[accession]
+	if !ok {
+		return pdh, false
+	}
+
+	var items = make(map[string][]string)
+	for i := range p.TotalPeptideIons {
+		for _, j := range b.ions[i] {
+			if ev, ok := b.evidences[[2]string{j.Peptide, accession}]; ok {
+				items[ev] = append(items[ev], j.Item)
+			}
 		}
+	}
 
-		dta.Inputs.SourceFile = append(dta.Inputs.SourceFile, *sf)
+	if len(items) == 0 {
+		return pdh, false
 	}
 
-	// DataCollection - Input - SearchDatabase
-	sdb := &psi.SearchDatabase{
-		ID:                   database,
-		NumDatabaseSequences: len(dtb.Records),
-		Location:             database,
-		FileFormat: psi.FileFormat{
-			CVParam: psi.CVParam{
-				CVRef:     "PSI-MS",
-				Accession: "MS:1001348",
-				Name:      "FASTA format",
-			},
-		},
-		DatabaseName: psi.DatabaseName{
-			CVParam: psi.CVParam{
-				CVRef:     "PSI-MS",
-				Accession: "MS:1001073",
-				Name:      "database type amino acid",
-			},
-			UserParam: psi.UserParam{
-				Name: database,
-			},
-		},
+	var evidences []string
+	for i := range items {
+		evidences = append(evidences, i)
 	}
-	mzid.DataCollection.Inputs.SearchDatabase = append(mzid.DataCollection.Inputs.SearchDatabase, *sdb)
-
-	// DataCollection - Input - SpectraData
-	for _, i := range sources {
-		sd := &psi.SpectraData{
-			Location: "./",
-			ID:       i,
-			Name:     i,
-			FileFormat: psi.FileFormat{
-				CVParam: psi.CVParam{
-					CVRef:     "PSI-MS",
-					Accession: "MS:1000584",
-					Name:      "mzML format",
-				},
-			},
+	sort.Strings(evidences)
+
+	*counter++
+	pdh.ID = fmt.Sprintf("PDH_%d", *counter)
+	pdh.Name = accession
+	pdh.DBSquenceRef = dbRef
+	pdh.PassThreshold = "true"
+
+	for _, i := range evidences {
+		sort.Strings(items[i])
+		ph := psi.PeptideHypothesis{PeptideEvidenceRef: i}
+		for _, j := range items[i] {
+			ph.SpectrumIdentificationItemRef = append(ph.SpectrumIdentificationItemRef, psi.SpectrumIdentificationItemRef{SpectrumIdentificationItemRef: j})
 		}
-		mzid.DataCollection.Inputs.SpectraData = append(mzid.DataCollection.Inputs.SpectraData, *sd)
-	}
-
-	// DataCollection - AnalysisData
-	ad := &psi.AnalysisData{
-		SpectrumIdentificationList: []psi.SpectrumIdentificationList{
-			{
-				ID: "SIL_1",
-				FragmentationTable: psi.FragmentationTable{
-					Measure: []psi.Measure{
-						{
-							ID: "Measure_MZ",
-							CVParam: []psi.CVParam{
-								{
-									CVRef:         "PSI-MS",
-									Accession:     "MS:1001225",
-									Name:          "product ion m/z",
-									UnitCvRef:     "PSI-MS",
-									UnitAccession: "MS:1000040",
-									UnitName:      "m/z",
-								},
-							},
-						},
-						{
-							ID: "Measure_Int",
-							CVParam: []psi.CVParam{
-								{
-									CVRef:         "PSI-MS",
-									Accession:     "MS:1001226",
-									Name:          "product ion intensity",
-									UnitCvRef:     "PSI-MS",
-									UnitAccession: "MS:1000131",
-									UnitName:      "number of detector counts",
-								},
-							},
-						},
-						{
-							ID: "Measure_Error",
-							CVParam: []psi.CVParam{
-								{
-									CVRef:         "PSI-MS",
-									Accession:     "MS:1001227",
-									Name:          "product ion m/z error",
-									UnitCvRef:     "PSI-MS",
-									UnitAccession: "MS:1000040",
-									UnitName:      "m/z",
-								},
-							},
-						},
-					},
-				},
+		pdh.PeptideHypothesis = append(pdh.PeptideHypothesis, ph)
+	}
+
+	if leading {
+		pdh.CVParam = append(pdh.CVParam, psi.CVParam{CVRef: "PSI-MS", Accession: "MS:1002401", Name: "leading protein"})
+	} else {
+		pdh.CVParam = append(pdh.CVParam, psi.CVParam{CVRef: "PSI-MS", Accession: "MS:1002402", Name: "non-leading protein"})
+	}
+
+	if p.Coverage > 0 {
+		pdh.CVParam = append(pdh.CVParam, psi.CVParam{CVRef: "PSI-MS", Accession: "MS:1001093", Name: "sequence coverage", Value: fmt.Sprintf("%.2f", p.Coverage)})
+	}
+
+	if len(p.TotalPeptides) > 0 {
+		pdh.CVParam = append(pdh.CVParam, psi.CVParam{CVRef: "PSI-MS", Accession: "MS:1001097", Name: "distinct peptide sequences", Value: strconv.Itoa(len(p.TotalPeptides))})
+	}
+
+	pdh.UserParam = append(pdh.UserParam, psi.UserParam{Name: "protein probability", Value: mzidFloat(p.Probability)})
+
+	return pdh, true
+}
+
+// protocols describes the database search and the protein inference with their FDR thresholds
+func (b *mzidBuilder) protocols() {
+
+	p := b.e.Parameters
+
+	sip := psi.SpectrumIdentificationProtocol{
+		ID:                  mzidSearchProtocolRef,
+		AnalysisSoftwareRef: mzidSearchEngineRef,
+		SearchType:          psi.SearchType{CVParam: psi.CVParam{CVRef: "PSI-MS", Accession: "MS:1001083", Name: "ms-ms search"}},
+		AdditionalSearchParams: &psi.AdditionalSearchParams{
+			CVParam: []psi.CVParam{
+				{CVRef: "PSI-MS", Accession: "MS:1001211", Name: "parent mass type mono"},
+				{CVRef: "PSI-MS", Accession: "MS:1001256", Name: "fragment mass type mono"},
 			},
 		},
 	}
 
-	// DataCollection - SpectrumIdentificationResult
-	idCounter = 0
-	for i := 0; i <= len(sources)-1; i++ {
-		for _, j := range e.PSM {
-			if strings.Contains(j.Spectrum, sources[i]) {
-
-				idCounter++
-
-				sir := &psi.SpectrumIdentificationResult{
-					SpectraDataRef: sources[i],
-					ID:             fmt.Sprintf("Spectrum_%d", idCounter),
-					SpectrumID:     fmt.Sprintf("%d", j.Index),
-					SpectrumIdentificationItem: []psi.SpectrumIdentificationItem{
-						{
-							PassThreshold:            "true",
-							Rank:                     j.HitRank,
-							PeptideRef:               j.Peptide,
-							CalculatedMassToCharge:   j.CalcNeutralPepMass,
-							ChargeState:              j.AssumedCharge,
-							ExperimentalMassToCharge: j.PrecursorNeutralMass,
-							ID:                       fmt.Sprintf("SII_%d", j.HitRank),
-							PeptideEvidenceRef: []psi.PeptideEvidenceRef{
-								{
-									PeptideEvidenceRef: pepRef[j.Peptide],
-								},
-							},
-							Fragmentation: psi.Fragmentation{
-								IonType: []psi.IonType{
-									{
-										CVParam: []psi.CVParam{
-											{},
-										},
-										UserParam: []psi.UserParam{
-											{},
-										},
-									},
-								},
-							},
-							CVParam: []psi.CVParam{
-								{
-									CVRef:     "PSI-MS",
-									Accession: "MS:1000796",
-									Name:      "spectrum title",
-									Value:     j.SpectrumFileName().Str(),
-								},
-								{
-									CVRef:     "PSI-MS",
-									Accession: "MS:1001192",
-									Name:      "Expect value",
-									Value:     fmt.Sprintf("%f", j.Expectation),
-								},
-								{
-									CVRef:     "PSI-MS",
-									Accession: "MS:1000882",
-									Name:      "protein",
-									Value:     j.ProteinID,
-								},
-								{
-									CVRef:     "PSI-MS",
-									Accession: "MS:1000886",
-									Name:      "protein name",
-									Value:     j.ProteinDescription,
-								},
-								{
-									CVRef:     "PSI-MS",
-									Accession: "MS:1000934",
-									Name:      "gene name",
-									Value:     j.GeneName,
-								},
-								{
-									CVRef:     "PSI-MS",
-									Accession: "MS:1000888",
-									Name:      "modified peptide sequence",
-									Value:     j.ModifiedPeptide,
-								},
-								{
-									CVRef:     "PSI-MS",
-									Accession: "MS:1000894",
-									Name:      "retention time",
-									Value:     fmt.Sprintf("%f", j.RetentionTime),
-								},
-								{
-									CVRef:     "PSI-MS",
-									Accession: "MS:1001976",
-									Name:      "delta M",
-									Value:     fmt.Sprintf("%f", j.Massdiff),
-								},
-								{
-									CVRef:     "PSI-MS",
-									Accession: "MS:1002357",
-									Name:      "PSM-level probability",
-									Value:     fmt.Sprintf("%f", j.Probability),
-								},
-								{
-									CVRef:     "PSI-MS",
-									Accession: "MS:1002252",
-									Name:      "Comet:xcorr",
-									Value:     fmt.Sprintf("%f", j.Xcorr),
-								},
-								{
-									CVRef:     "PSI-MS",
-									Accession: "MS:1002253",
-									Name:      "Comet:deltacn",
-									Value:     fmt.Sprintf("%f", j.DeltaCN),
-								},
-								{
-									CVRef:     "PSI-MS",
-									Accession: "MS:1002254",
-									Name:      "Comet:deltacnstar",
-									Value:     fmt.Sprintf("%f", j.DeltaCNStar),
-								},
-								{
-									CVRef:     "PSI-MS",
-									Accession: "MS:1002255",
-									Name:      "Comet:spscore",
-									Value:     fmt.Sprintf("%f", j.SPScore),
-								},
-								{
-									CVRef:     "PSI-MS",
-									Accession: "MS:1002256",
-									Name:      "Comet:sprank",
-									Value:     fmt.Sprintf("%f", j.SPRank),
-								},
-								{
-									CVRef:     "PSI-MS",
-									Accession: "MS:1001331",
-									Name:      "X! Tandem:hyperscore",
-									Value:     fmt.Sprintf("%f", j.Hyperscore),
-								},
-								{
-									CVRef:     "PSI-MS",
-									Accession: "MS:1002217",
-									Name:      "decoy peptide",
-									Value:     fmt.Sprintf("%v", j.IsDecoy),
-								},
-								{
-									CVRef:     "PSI-MS",
-									Accession: "MS:1001843",
-									Name:      "MS1 feature maximum intensity",
-									Value:     fmt.Sprintf("%f", j.Intensity),
-								},
-								{
-									CVRef:     "PSI-MS",
-									Accession: "MS:1001363",
-									Name:      "peptide unique to one protein",
-									Value:     fmt.Sprintf("%v", j.IsUnique),
-								},
-								{
-									CVRef:     "PSI-MS",
-									Accession: "MS:1003015",
-									Name:      "razor peptide",
-									Value:     fmt.Sprintf("%v", j.IsURazor),
-								},
-								{
-									CVRef:     "PSI-MS",
-									Accession: "MS:1002616",
-									Name:      "TMT reagent 126",
-									Value:     fmt.Sprintf("%f", j.Labels.Channel1.Intensity),
-								},
-								{
-									CVRef:     "PSI-MS",
-									Accession: "MS:1002763",
-									Name:      "TMT reagent 127N",
-									Value:     fmt.Sprintf("%f", j.Labels.Channel2.Intensity),
-								},
-								{
-									CVRef:     "PSI-MS",
-									Accession: "MS:1002764",
-									Name:      "TMT reagent 127C",
-									Value:     fmt.Sprintf("%f", j.Labels.Channel3.Intensity),
-								},
-								{
-									CVRef:     "PSI-MS",
-									Accession: "MS:1002765",
-									Name:      "TMT reagent 128N",
-									Value:     fmt.Sprintf("%f", j.Labels.Channel4.Intensity),
-								},
-								{
-									CVRef:     "PSI-MS",
-									Accession: "MS:1002766",
-									Name:      "TMT reagent 128C",
-									Value:     fmt.Sprintf("%f", j.Labels.Channel5.Intensity),
-								},
-								{
-									CVRef:     "PSI-MS",
-									Accession: "MS:1002767",
-									Name:      "TMT reagent 129N",
-									Value:     fmt.Sprintf("%f", j.Labels.Channel6.Intensity),
-								},
-								{
-									CVRef:     "PSI-MS",
-									Accession: "MS:1002768",
-									Name:      "TMT reagent 129C",
-									Value:     fmt.Sprintf("%f", j.Labels.Channel7.Intensity),
-								},
-								{
-									CVRef:     "PSI-MS",
-									Accession: "MS:1002769",
-									Name:      "TMT reagent 130N",
-									Value:     fmt.Sprintf("%f", j.Labels.Channel8.Intensity),
-								},
-								{
-									CVRef:     "PSI-MS",
-									Accession: "MS:1002770",
-									Name:      "TMT reagent 130C",
-									Value:     fmt.Sprintf("%f", j.Labels.Channel9.Intensity),
-								},
-								{
-									CVRef:     "PSI-MS",
-									Accession: "MS:1002621",
-									Name:      "TMT reagent 131",
-									Value:     fmt.Sprintf("%f", j.Labels.Channel10.Intensity),
-								},
-							},
-							UserParam: []psi.UserParam{
-								{
-									Name:  "entry name",
-									Value: j.EntryName,
-								},
-								{
-									Name:  "TMT reagent 126 Label",
-									Value: j.Labels.Channel1.Name,
-								},
-								{
-									Name:  "TMT reagent 17N Label",
-									Value: j.Labels.Channel2.Name,
-								},
-								{
-									Name:  "TMT reagent 127C Label",
-									Value: j.Labels.Channel3.Name,
-								},
-								{
-									Name:  "TMT reagent 128N Label",
-									Value: j.Labels.Channel4.Name,
-								},
-								{
-									Name:  "TMT reagent 128C Label",
-									Value: j.Labels.Channel5.Name,
-								},
-								{
-									Name:  "TMT reagent 129N Label",
-									Value: j.Labels.Channel6.Name,
-								},
-								{
-									Name:  "TMT reagent 129C Label",
-									Value: j.Labels.Channel7.Name,
-								},
-								{
-									Name:  "TMT reagent 130N Label",
-									Value: j.Labels.Channel8.Name,
-								},
-								{
-									Name:  "TMT reagent 130C Label",
-									Value: j.Labels.Channel9.Name,
-								},
-								{
-									Name:  "TMT reagent 131 Label",
-									Value: j.Labels.Channel10.Name,
-								},
-							},
-						},
-					},
-				}
-
-				specRef[j.SpectrumFileName()] = fmt.Sprintf("Spectrum_%d", idCounter)
-				ad.SpectrumIdentificationList[0].SpectrumIdentificationResult = append(ad.SpectrumIdentificationList[0].SpectrumIdentificationResult, *sir)
-			}
+	for _, i := range b.params {
+		if len(strings.TrimSpace(i.Value)) > 0 {
+			sip.AdditionalSearchParams.UserParam = append(sip.AdditionalSearchParams.UserParam, psi.UserParam{Name: i.Name, Value: i.Value})
 		}
 	}
 
-	// DataCollection - ProteinDetectionList
-	idCounter = 0
-	if len(e.Proteins) > 0 {
-		pdl := &psi.ProteinDetectionList{
-			ID: "protein groups",
+	var searched []psi.SearchModification
+	var keys []string
+	for i := range b.e.Mods.Index {
+		keys = append(keys, i)
+	}
+	sort.Strings(keys)
+
+	for _, i := range keys {
+		if m := b.e.Mods.Index[i]; m.MassDiff != 0 {
+			searched = append(searched, b.searchModification(m))
 		}
+	}
 
-		var groupsMap = make(map[int]uint8)
-		var groups []int
+	if len(searched) > 0 {
+		sip.ModificationParams = &psi.ModificationParams{SearchModification: searched}
+	}
 
-		for _, i := range e.Proteins {
-			groupsMap[int(i.ProteinGroup)] = 0
+	if len(p.SearchEnzymeName) > 0 {
+		enzyme := psi.Enzyme{ID: "Enzyme_1", Name: p.SearchEnzymeName, SemiSpecific: p.NumEnzymeTermini == "1"}
+		if v, e := strconv.Atoi(p.AllowedMissedCleavage); e == nil {
+			enzyme.MissedCleavages = v
 		}
+		if strings.Contains(strings.ToLower(p.SearchEnzymeName), "trypsin") {
+			enzyme.EnzymeName = &psi.EnzymeName{CVParam: []psi.CVParam{{CVRef: "PSI-MS", Accession: "MS:1001251", Name: "Trypsin"}}}
+		} else {
+			enzyme.EnzymeName = &psi.EnzymeName{UserParam: []psi.UserParam{{Name: p.SearchEnzymeName}}}
+		}
+		sip.Enzymes = &psi.Enzymes{Enzyme: []psi.Enzyme{enzyme}}
+	}
+
+	if cv := mzidTolerance(p.FragmentMassTolerance, p.FragmentMassUnits); len(cv) > 0 {
+		sip.FragmentTolerance = &psi.FragmentTolerance{CVParam: cv}
+	}
+
+	if cv := mzidTolerance(p.PrecursorTrueTolerance, p.PrecursorTrueUnits); len(cv) > 0 {
+		sip.ParentTolerance = &psi.ParentTolerance{CVParam: cv}
+	}
+
+	if b.m.Filter.PsmFDR > 0 {
+		sip.Threshold.CVParam = append(sip.Threshold.CVParam, psi.CVParam{CVRef: "PSI-MS", Accession: "MS:1002350", Name: "PSM-level global FDR", Value: mzidFloat(b.m.Filter.PsmFDR)})
+	}
+
+	if b.m.Filter.PepFDR > 0 {
+		sip.Threshold.CVParam = append(sip.Threshold.CVParam, psi.CVParam{CVRef: "PSI-MS", Accession: "MS:1001364", Name: "peptide sequence-level global FDR", Value: mzidFloat(b.m.Filter.PepFDR)})
+	}
+
+	if len(sip.Threshold.CVParam) == 0 {
+		sip.Threshold.CVParam = []psi.CVParam{{CVRef: "PSI-MS", Accession: "MS:1001494", Name: "no threshold"}}
+	}
+
+	b.doc.AnalysisProtocolCollection.SpectrumIdentificationProtocol = []psi.SpectrumIdentificationProtocol{sip}
+
+	if b.doc.AnalysisCollection.ProteinDetection == nil {
+		return
+	}
+
+	pdp := psi.ProteinDetectionProtocol{ID: mzidProteinProtocol, AnalysisSoftwareRef: mzidPhilosopherRef}
+
+	if b.m.Filter.PtFDR > 0 {
+		pdp.Threshold.CVParam = []psi.CVParam{{CVRef: "PSI-MS", Accession: "MS:1002369", Name: "protein group-level global FDR", Value: mzidFloat(b.m.Filter.PtFDR)}}
+	} else {
+		pdp.Threshold.CVParam = []psi.CVParam{{CVRef: "PSI-MS", Accession: "MS:1001494", Name: "no threshold"}}
+	}
+
+	b.doc.AnalysisProtocolCollection.ProteinDetectionProtocol = &pdp
+}
+
+// searchModification describes a searched modification, the terminal modifications apply to any residue
+func (b *mzidBuilder) searchModification(m mod.Modification) psi.SearchModification {
+
+	sm := psi.SearchModification{
+		FixedMod:  strconv.FormatBool(!m.Variable),
+		MassDelta: m.MassDiff,
+		Residues:  m.AminoAcid,
+	}
 
-		for i := range groupsMap {
-			groups = append(groups, i)
+	site := m.AminoAcid
+	switch m.AminoAcid {
+	case "N-term", "n":
+		site = "N-term"
+		sm.Residues = "."
+		sm.SpecificityRules = []psi.SpecificityRules{{CVParam: []psi.CVParam{{CVRef: "PSI-MS", Accession: "MS:1001189", Name: "modification specificity peptide N-term"}}}}
+	case "C-term", "c":
+		site = "C-term"
+		sm.Residues = "."
+		sm.SpecificityRules = []psi.SpecificityRules{{CVParam: []psi.CVParam{{CVRef: "PSI-MS", Accession: "MS:1001190", Name: "modification specificity peptide C-term"}}}}
+	}
+
+	if strings.HasPrefix(m.ID, "UNIMOD:") && len(m.Name) > 0 {
+		sm.CVParam = []psi.CVParam{{CVRef: "UNIMOD", Accession: m.ID, Name: m.Name}}
+	} else if t, ok := unimodTerm(b.terms, m.MassDiff, site); ok {
+		sm.CVParam = []psi.CVParam{{CVRef: "UNIMOD", Accession: t.ID, Name: t.Name}}
+	} else {
+		sm.CVParam = []psi.CVParam{{CVRef: "PSI-MS", Accession: "MS:1001460", Name: "unknown modification"}}
+	}
+
+	return sm
+}
+
+// inputs describes the searched database
+func (b *mzidBuilder) inputs(sequences int) {
+
+	location := b.m.Database.Annot
+	if len(location) == 0 {
+		location = b.e.Parameters.DatabaseName
+	}
+	if len(location) == 0 {
+		location = "unknown"
+	}
+
+	b.doc.DataCollection.Inputs.SearchDatabase = []psi.SearchDatabase{
+		{
+			ID:                   mzidSearchDatabaseRef,
+			Name:                 filepath.Base(location),
+			Location:             location,
+			NumDatabaseSequences: sequences,
+			FileFormat:           &psi.FileFormat{CVParam: psi.CVParam{CVRef: "PSI-MS", Accession: "MS:1001348", Name: "FASTA format"}},
+			DatabaseName:         psi.DatabaseName{UserParam: &psi.UserParam{Name: filepath.Base(location)}},
+		},
+	}
+}
+
+// mzidScores reports the PSM scores, the engine specific scores are only reported when set
+func mzidScores(p PSMEvidence) ([]psi.CVParam, []psi.UserParam) {
+
+	var cv = []psi.CVParam{{CVRef: "PSI-MS", Accession: "MS:1002357", Name: "PSM-level probability", Value: mzidFloat(p.Probability)}}
+	var up []psi.UserParam
+
+	for _, i := range []struct {
+		accession string
+		name      string
+		value     float64
+	}{
+		{"MS:1001192", "Expect value", p.Expectation},
+		{"MS:1002252", "Comet:xcorr", p.Xcorr},
+		{"MS:1002253", "Comet:deltacn", p.DeltaCN},
+		{"MS:1002254", "Comet:deltacnstar", p.DeltaCNStar},
+		{"MS:1002255", "Comet:spscore", p.SPScore},
+		{"MS:1002256", "Comet:sprank", p.SPRank},
+		{"MS:1001843", "MS1 feature maximum intensity", p.Intensity},
+	} {
+		if i.value != 0 {
+			cv = append(cv, psi.CVParam{CVRef: "PSI-MS", Accession: i.accession, Name: i.name, Value: mzidFloat(i.value)})
 		}
+	}
+
+	if p.Hyperscore != 0 {
+		up = append(up, psi.UserParam{Name: "hyperscore", Value: mzidFloat(p.Hyperscore)})
+	}
 
-		sort.Ints(groups)
+	if p.Nextscore != 0 {
+		up = append(up, psi.UserParam{Name: "nextscore", Value: mzidFloat(p.Nextscore)})
+	}
 
-		for _, i := range groups {
+	return cv, up
+}
 
-			idCounter++
+// mzidSpectrum returns the source file and the native identifier of the spectrum, the scan number is taken from
+// the spectrum name and the spectrum index is used when the name has no scan
+func mzidSpectrum(p PSMEvidence) (string, string) {
 
-			pag := &psi.ProteinAmbiguityGroup{
-				ID: fmt.Sprintf("%d", i),
-			}
+	parts := strings.Split(p.Spectrum, ".")
+	if len(parts) < 4 {
+		return parts[0], fmt.Sprintf("index=%d", p.Index)
+	}
 
-			for _, j := range e.Proteins {
-				if int(j.ProteinGroup) == i {
-
-					pdh := &psi.ProteinDetectionHypothesis{
-						ID:                j.ProteinSubGroup,
-						PassThreshold:     "true",
-						DBSquenceRef:      proRef[j.ProteinID],
-						PeptideHypothesis: []psi.PeptideHypothesis{},
-						CVParam: []psi.CVParam{
-							{
-								CVRef:     "PSI-MS",
-								Accession: "MS:1000796",
-								Name:      "spectrum title",
-								Value:     "",
-							},
-						},
-						UserParam: []psi.UserParam{
-							{
-								Name:  "original protein header",
-								Value: j.OriginalHeader,
-							},
-							{
-								Name:  "partial header",
-								Value: j.PartHeader,
-							},
-						},
-					}
-
-					for _, k := range j.TotalPeptideIons {
-						peph := &psi.PeptideHypothesis{
-							PeptideEvidenceRef: pepRef[k.Sequence],
-						}
-						_ = k
-
-						for l := range k.Spectra {
-							siir := psi.SpectrumIdentificationItemRef{
-								SpectrumIdentificationItemRef: specRef[l],
-							}
-
-							peph.SpectrumIdentificationItemRef = append(peph.SpectrumIdentificationItemRef, siir)
-						}
-
-						pdh.PeptideHypothesis = append(pdh.PeptideHypothesis, *peph)
-					}
-
-					pag.ProteinDetectionHypothesis = append(pag.ProteinDetectionHypothesis, *pdh)
-				}
-			}
+	source := strings.Join(parts[:len(parts)-3], ".")
+
+	scan, e := strconv.Atoi(parts[len(parts)-3])
+	if e != nil {
+		return source, fmt.Sprintf("index=%d", p.Index)
+	}
+
+	return source, fmt.Sprintf("scan=%d", scan)
+}
 
-			pdl.ProteinAmbiguityGroup = append(pdl.ProteinAmbiguityGroup, *pag)
+// mzidTolerance reports a symmetric search tolerance, MSFragger units are 0 for Daltons and 1 for ppm
+func mzidTolerance(value, units string) []psi.CVParam {
+
+	if _, e := strconv.ParseFloat(value, 64); e != nil {
+		return nil
+	}
+
+	var unit = [2]string{}
+	switch units {
+	case "0":
+		unit = [2]string{"UO:0000221", "dalton"}
+	case "1":
+		unit = [2]string{"UO:0000169", "parts per million"}
+	}
+
+	var cv []psi.CVParam
+	for _, i := range [][2]string{{"MS:1001412", "search tolerance plus value"}, {"MS:1001413", "search tolerance minus value"}} {
+		p := psi.CVParam{CVRef: "PSI-MS", Accession: i[0], Name: i[1], Value: value}
+		if len(unit[0]) > 0 {
+			p.UnitCvRef = "UO"
+			p.UnitAccession = unit[0]
+			p.UnitName = unit[1]
 		}
+		cv = append(cv, p)
+	}
 
-		ad.ProteinDetectionList = *pdl
+	return cv
+}
+
+// mzidMassToCharge converts a neutral mass to the m/z of the charge state
+func mzidMassToCharge(mass float64, charge uint8) float64 {
+
+	if charge == 0 {
+		return mass
 	}
 
-	mzid.DataCollection.AnalysisData = *ad
+	z := float64(charge)
+
+	return (mass + z*bio.Proton) / z
+}
+
+// mzidResidue formats a flanking residue, unknown residues are omitted
+func mzidResidue(r byte) string {
+
+	if (r >= 'A' && r <= 'Z') || r == '-' || r == '?' {
+		return string(r)
+	}
 
-	// Burn!
-	mzid.Write()
+	return ""
+}
+
+// mzidFloat formats a value with the shortest representation
+func mzidFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// tmtReagentAccessions are the PSI-MS terms of the TMT reporter ions
+var tmtReagentAccessions = map[string][2]string{
+	"126":  {"MS:1002616", "TMT reagent 126"},
+	"127N": {"MS:1002763", "TMT reagent 127N"},
+	"127C": {"MS:1002764", "TMT reagent 127C"},
+	"128N": {"MS:1002765", "TMT reagent 128N"},
+	"128C": {"MS:1002766", "TMT reagent 128C"},
+	"129N": {"MS:1002767", "TMT reagent 129N"},
+	"129C": {"MS:1002768", "TMT reagent 129C"},
+	"130N": {"MS:1002769", "TMT reagent 130N"},
+	"130C": {"MS:1002770", "TMT reagent 130C"},
+	"131N": {"MS:1002621", "TMT reagent 131"},
+}
+
+// labelParams reports the channel intensities and names, the channels without a PSI-MS term are reported as user params
+func labelParams(l *iso.Labels) ([]psi.CVParam, []psi.UserParam) {
+
+	var cv []psi.CVParam
+	var up []psi.UserParam
+
+	if l == nil {
+		return cv, up
+	}
+
+	for _, i := range l.Channels {
+
+		if a, ok := tmtReagentAccessions[i.Name]; ok {
+			cv = append(cv, psi.CVParam{
+				CVRef:     "PSI-MS",
+				Accession: a[0],
+				Name:      a[1],
+				Value:     fmt.Sprintf("%f", i.Intensity),
+			})
+		} else {
+			up = append(up, psi.UserParam{
+				Name:  fmt.Sprintf("reagent %s", i.Name),
+				Value: fmt.Sprintf("%f", i.Intensity),
+			})
+		}
+
+		if len(i.CustomName) > 0 {
+			up = append(up, psi.UserParam{
+				Name:  fmt.Sprintf("reagent %s Label", i.Name),
+				Value: i.CustomName,
+			})
+		}
+	}
 
+	return cv, up
 }
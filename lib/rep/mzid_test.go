@@ -0,0 +1,192 @@
+package rep
+
+import (
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"philosopher/lib/dat"
+	"philosopher/lib/id"
+	"philosopher/lib/obo"
+	"philosopher/lib/psi"
+	"philosopher/lib/spc"
+)
+
+// mzidSchema is the HUPO-PSI mzIdentML 1.2 schema stored with the test data
+var mzidSchema = filepath.Join("..", "..", "test", "schema", "mzIdentML1.2.0.xsd")
+
+// validateMzIdentML validates a document against the mzIdentML 1.2 schema
+func validateMzIdentML(t *testing.T, f string) {
+
+	xmllint, err := exec.LookPath("xmllint")
+	if err != nil {
+		t.Skip("xmllint is not available, the mzIdentML schema validation needs libxml2")
+	}
+
+	if _, err := os.Stat(mzidSchema); err != nil {
+		t.Fatalf("the mzIdentML 1.2 schema is missing, %s", err)
+	}
+
+	out, err := exec.Command(xmllint, "--noout", "--schema", mzidSchema, f).CombinedOutput()
+	if err != nil {
+		t.Errorf("the mzIdentML file is not valid:\n%s", out)
+	}
+}
+
+// checkMzIdentMLReferences verifies that every reference points to an identifier declared in the document
+func checkMzIdentMLReferences(t *testing.T, f string) {
+
+	file, e := os.Open(f)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer file.Close()
+
+	var ids = make(map[string]bool)
+	var refs []string
+
+	decoder := xml.NewDecoder(file)
+	for {
+		token, e := decoder.Token()
+		if e == io.EOF {
+			break
+		} else if e != nil {
+			t.Fatal(e)
+		}
+
+		if s, ok := token.(xml.StartElement); ok {
+			for _, i := range s.Attr {
+				if i.Name.Local == "id" {
+					if ids[i.Value] {
+						t.Errorf("duplicated identifier %s", i.Value)
+					}
+					ids[i.Value] = true
+				} else if strings.HasSuffix(i.Name.Local, "_ref") || i.Name.Local == "cvRef" {
+					refs = append(refs, i.Value)
+				}
+			}
+		}
+	}
+
+	for _, i := range refs {
+		if !ids[i] {
+			t.Errorf("reference %s has no identifier", i)
+		}
+	}
+}
+
+func TestMzIdentML(t *testing.T) {
+
+	e, m := mzTabEvidence()
+	m.Database.Tag = "rev_"
+	m.Filter.PsmFDR = 0.01
+	m.Filter.PepFDR = 0.01
+	m.Filter.PtFDR = 0.01
+
+	e.PSM[1].Protein = "rev_sp|P12345|PROT_HUMAN"
+
+	e.Proteins[0].PartHeader = "sp|P12345|PROT_HUMAN"
+	e.Proteins[0].ProteinGroup = 1
+	e.Proteins[0].TotalPeptides = map[string]int{"PEMTIDEK": 1}
+	e.Proteins[0].TotalPeptideIons = map[id.IonFormType]IonEvidence{e.PSM[0].IonForm(): e.Ions[0]}
+	e.Proteins[1].PartHeader = "rev_sp|P12345|PROT_HUMAN"
+	e.Proteins[1].ProteinGroup = 2
+
+	records := []dat.Record{
+		{PartHeader: "sp|P12345|PROT_HUMAN", Description: "Protein", Sequence: "MSKPEMTIDEKAR"},
+		{PartHeader: "sp|Q67890|OTHER_HUMAN", Description: "Other protein", Sequence: "PEMTIDEK"},
+		{PartHeader: "rev_sp|P12345|PROT_HUMAN", Sequence: "RAKEDITMEPKSM", IsDecoy: true},
+	}
+
+	params := []spc.Parameter{{Name: "search_enzyme_name", Value: "stricttrypsin"}, {Name: "mass_offsets", Value: ""}}
+	e.Parameters.SearchEnzymeName = "stricttrypsin"
+	e.Parameters.AllowedMissedCleavage = "2"
+	e.Parameters.PrecursorTrueTolerance = "20"
+	e.Parameters.PrecursorTrueUnits = "1"
+
+	terms := []obo.Term{
+		{ID: "UNIMOD:35", Name: "Oxidation", MonoIsotopicMass: 15.9949, Sites: map[string]uint8{"M": 1}},
+		{ID: "UNIMOD:4", Name: "Carbamidomethyl", MonoIsotopicMass: 57.0215, Sites: map[string]uint8{"C": 1}},
+		{ID: "UNIMOD:737", Name: "TMT6plex", MonoIsotopicMass: 229.1629, Sites: map[string]uint8{"N-term": 1, "K": 1}},
+	}
+
+	dir, err := ioutil.TempDir("", "mzid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "results.mzid")
+
+	doc := newMzIdentML(e, m, params, records, terms)
+	doc.Write(output)
+
+	t.Run("schema", func(t *testing.T) {
+		validateMzIdentML(t, output)
+	})
+
+	checkMzIdentMLReferences(t, output)
+
+	var mzid psi.MzIdentML
+	mzid.Parse(output)
+
+	results := mzid.DataCollection.AnalysisData.SpectrumIdentificationList
+	if len(results) != 1 || len(results[0].SpectrumIdentificationResult) != 1 {
+		t.Fatalf("expected a single target spectrum identification, got %v", results)
+	}
+
+	sir := results[0].SpectrumIdentificationResult[0]
+	if sir.SpectrumID != "scan=1234" || len(sir.SpectrumIdentificationItem[0].PeptideEvidenceRef) != 2 {
+		t.Errorf("spectrum identification is incorrect, got %s with %d evidences", sir.SpectrumID, len(sir.SpectrumIdentificationItem[0].PeptideEvidenceRef))
+	}
+
+	if data := mzid.DataCollection.Inputs.SpectraData; len(data) != 1 || data[0].Location != "/tmp/project/run_02.mzML" {
+		t.Errorf("spectra data is incorrect, got %v", data)
+	}
+
+	evidence := mzid.SequenceCollection.PeptideEvidence[0]
+	if evidence.Pre != "K" || evidence.Post != "A" || evidence.Start != "4" || evidence.End != 11 || evidence.IsDecoy != "false" {
+		t.Errorf("peptide evidence is incorrect, got %+v", evidence)
+	}
+
+	var mods []string
+	for _, i := range mzid.SequenceCollection.Peptide[0].Modification {
+		mods = append(mods, i.Location+"-"+i.CVParam[0].Accession)
+	}
+	if strings.Join(mods, ",") != "0-UNIMOD:737,3-UNIMOD:35" {
+		t.Errorf("peptide modifications are incorrect, got %v", mods)
+	}
+
+	sip := mzid.AnalysisProtocolCollection.SpectrumIdentificationProtocol[0]
+	if sip.Threshold.CVParam[0].Accession != "MS:1002350" || sip.Threshold.CVParam[0].Value != "0.01" {
+		t.Errorf("PSM threshold is incorrect, got %v", sip.Threshold.CVParam)
+	}
+
+	pdl := mzid.DataCollection.AnalysisData.ProteinDetectionList
+	if pdl == nil || len(pdl.ProteinAmbiguityGroup) != 1 {
+		t.Fatalf("expected a single target protein group, got %v", pdl)
+	}
+
+	pdh := pdl.ProteinAmbiguityGroup[0].ProteinDetectionHypothesis
+	if len(pdh) != 2 || pdh[0].Name != "sp|P12345|PROT_HUMAN" || pdh[1].Name != "sp|Q67890|OTHER_HUMAN" {
+		t.Fatalf("expected the leading and the indistinguishable protein, got %v", pdh)
+	}
+
+	if pdh[0].CVParam[0].Accession != "MS:1002401" || pdh[1].CVParam[0].Accession != "MS:1002402" {
+		t.Errorf("leading proteins are incorrect, got %v and %v", pdh[0].CVParam, pdh[1].CVParam)
+	}
+
+	if pdh[0].PeptideHypothesis[0].SpectrumIdentificationItemRef[0].SpectrumIdentificationItemRef != sir.SpectrumIdentificationItem[0].ID {
+		t.Error("the peptide hypothesis must reference the spectrum identification item")
+	}
+
+	content, _ := ioutil.ReadFile(output)
+	if strings.Contains(string(content), "DECOYPEPTIDE") || strings.Contains(string(content), "rev_sp") {
+		t.Error("decoys must not be exported")
+	}
+}
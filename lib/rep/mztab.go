@@ -0,0 +1,732 @@
+package rep
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"philosopher/lib/bio"
+	"philosopher/lib/id"
+	"philosopher/lib/iso"
+	"philosopher/lib/met"
+	"philosopher/lib/mod"
+	"philosopher/lib/msg"
+	"philosopher/lib/obo"
+)
+
+// mzTabNull is the mzTab representation of a missing value
+const mzTabNull = "null"
+
+// mzTab section prefixes
+const (
+	mzTabMetadata = "MTD"
+	mzTabProtein  = "PRT"
+	mzTabPeptide  = "PEP"
+	mzTabPSM      = "PSM"
+)
+
+// mzTab is an mzTab 1.0 document, the metadata keeps the insertion order
+type mzTab struct {
+	Metadata [][2]string
+	Sections []mzTabSection
+}
+
+// mzTabSection is a tabular section, the header prefix is derived from the row prefix
+type mzTabSection struct {
+	Prefix  string
+	Columns []string
+	Rows    [][]string
+}
+
+// searchEngineParams are the PSI-MS terms of the supported search engines
+var searchEngineParams = map[string]string{
+	"msfragger": "[MS, MS:1003014, MSFragger, ]",
+	"comet":     "[MS, MS:1002251, Comet, ]",
+}
+
+// MzTabReport creates the mzTab 1.0 summary file with the protein, peptide and PSM sections, the abundances of each
+// study variable are reported for the label-free and isobaric quantifications; decoys are not exported
+func (e Evidence) MzTabReport(m met.Data) {
+
+	var pepxml id.PepXML
+	pepxml.Restore()
+	e.Mods = pepxml.Modifications
+	e.AssembleSearchParameters(pepxml.SearchParameters)
+
+	if len(m.SearchEngine) == 0 {
+		m.SearchEngine = pepxml.SearchEngine
+	}
+
+	o := obo.NewUniModOntology()
+
+	doc := newMzTab(e, m, o.Terms)
+
+	output := fmt.Sprintf("%s%sreport.mzTab", m.Home, string(filepath.Separator))
+
+	file, err := os.Create(output)
+	if err != nil {
+		msg.WriteFile(errors.New("cannot create the mzTab file"), "fatal")
+	}
+	defer file.Close()
+
+	bw := bufio.NewWriter(file)
+	defer bw.Flush()
+
+	doc.write(bw)
+}
+
+// write prints the metadata followed by the sections, each section is separated by an empty line
+func (t mzTab) write(w io.Writer) {
+
+	var lines []string
+	for _, i := range t.Metadata {
+		lines = append(lines, strings.Join([]string{mzTabMetadata, i[0], i[1]}, "\t"))
+	}
+
+	for _, i := range t.Sections {
+		if len(i.Rows) == 0 {
+			continue
+		}
+		lines = append(lines, "")
+		lines = append(lines, strings.Join(append([]string{i.Prefix[:2] + "H"}, i.Columns...), "\t"))
+		for _, j := range i.Rows {
+			lines = append(lines, strings.Join(append([]string{i.Prefix}, j...), "\t"))
+		}
+	}
+
+	for _, i := range lines {
+		if _, e := io.WriteString(w, i+"\n"); e != nil {
+			msg.WriteToFile(e, "fatal")
+		}
+	}
+}
+
+// mzTabBuilder holds the references shared by the sections
+type mzTabBuilder struct {
+	e             Evidence
+	m             met.Data
+	terms         []obo.Term
+	runs          map[string]int
+	database      string
+	version       string
+	searchEngine  string
+	studyVariable []string
+	isobaric      bool
+}
+
+// newMzTab assembles the mzTab document from the evidences and the meta data
+func newMzTab(e Evidence, m met.Data, terms []obo.Term) mzTab {
+
+	var b = mzTabBuilder{e: e, m: m, terms: terms, runs: make(map[string]int)}
+
+	b.database = mzTabNull
+	if len(m.Database.Annot) > 0 {
+		b.database = filepath.Base(m.Database.Annot)
+	}
+
+	b.version = mzTabNull
+	if len(m.Database.TimeStamp) > 0 {
+		b.version = m.Database.TimeStamp
+	}
+
+	b.searchEngine = fmt.Sprintf("[, , %s, ]", mzTabParamValue(m.SearchEngine))
+	if v, ok := searchEngineParams[strings.ToLower(m.SearchEngine)]; ok {
+		b.searchEngine = v
+	}
+
+	var runs []string
+	for _, i := range e.PSM {
+		source := strings.Split(i.Spectrum, ".")[0]
+		if _, ok := b.runs[source]; !ok {
+			b.runs[source] = 0
+			runs = append(runs, source)
+		}
+	}
+
+	sort.Strings(runs)
+	for i, j := range runs {
+		b.runs[j] = i + 1
+	}
+
+	b.studyVariable = b.studyVariables()
+
+	var t mzTab
+	t.Metadata = b.metadata(runs)
+	t.Sections = append(t.Sections, b.proteinSection(), b.peptideSection(), b.psmSection())
+
+	return t
+}
+
+// studyVariables names the quantified samples, the isobaric channels when available or the workspace otherwise
+func (b *mzTabBuilder) studyVariables() []string {
+
+	var labels []*iso.Labels
+	for _, i := range b.e.Proteins {
+		labels = append(labels, i.URazorLabels)
+	}
+	if ref := referenceLabels(labels, true); ref != nil {
+		b.isobaric = true
+		var names []string
+		for _, i := range ref.Channels {
+			if len(i.CustomName) > 0 {
+				names = append(names, i.CustomName)
+			} else {
+				names = append(names, "Channel "+i.Name)
+			}
+		}
+		return names
+	}
+
+	for _, i := range b.e.Proteins {
+		if i.URazorIntensity > 0 {
+			name := b.m.ProjectName
+			if len(name) == 0 {
+				name = filepath.Base(b.m.Home)
+			}
+			return []string{name}
+		}
+	}
+
+	return nil
+}
+
+// metadata creates the mandatory metadata of the summary mode and the search settings
+func (b *mzTabBuilder) metadata(runs []string) [][2]string {
+
+	var md [][2]string
+	var add = func(k, v string) { md = append(md, [2]string{k, v}) }
+
+	mzTabType := "Identification"
+	if len(b.studyVariable) > 0 {
+		mzTabType = "Quantification"
+	}
+
+	add("mzTab-version", "1.0.0")
+	add("mzTab-mode", "Summary")
+	add("mzTab-type", mzTabType)
+	if len(b.m.UUID) > 0 {
+		add("mzTab-ID", b.m.UUID)
+	}
+	add("description", fmt.Sprintf("Philosopher %s results", b.m.Version))
+
+	dir := b.m.Quantify.Dir
+	if len(dir) == 0 {
+		dir = b.m.Home
+	}
+
+	for i, j := range runs {
+		location, _ := filepath.Abs(filepath.Join(dir, j+".mzML"))
+		add(fmt.Sprintf("ms_run[%d]-format", i+1), "[MS, MS:1000584, mzML format, ]")
+		add(fmt.Sprintf("ms_run[%d]-location", i+1), "file://"+filepath.ToSlash(location))
+		add(fmt.Sprintf("ms_run[%d]-id_format", i+1), "[MS, MS:1000776, scan number only nativeID format, ]")
+	}
+
+	add("software[1]", b.searchEngine)
+	for i, j := range b.searchSettings() {
+		add(fmt.Sprintf("software[1]-setting[%d]", i+1), j)
+	}
+	add("software[2]", fmt.Sprintf("[, , Philosopher, %s]", mzTabParamValue(b.m.Version)))
+
+	add("protein_search_engine_score[1]", "[, , ProteinProphet probability, ]")
+	add("peptide_search_engine_score[1]", "[MS, MS:1002357, PSM-level probability, ]")
+	add("psm_search_engine_score[1]", "[MS, MS:1002357, PSM-level probability, ]")
+	add("psm_search_engine_score[2]", "[MS, MS:1001192, Expect value, ]")
+
+	fixed, variable := b.searchedModifications()
+
+	if len(fixed) == 0 {
+		add("fixed_mod[1]", "[MS, MS:1002453, No fixed modifications searched, ]")
+	}
+	for i, j := range fixed {
+		md = append(md, b.modificationMetadata("fixed_mod", i+1, j)...)
+	}
+
+	if len(variable) == 0 {
+		add("variable_mod[1]", "[MS, MS:1002454, No variable modifications searched, ]")
+	}
+	for i, j := range variable {
+		md = append(md, b.modificationMetadata("variable_mod", i+1, j)...)
+	}
+
+	if len(b.studyVariable) > 0 {
+
+		method := "[MS, MS:1001834, LC-MS label-free quantitation analysis, ]"
+		if b.isobaric && b.m.Quantify.Brand == "itraq" {
+			method = "[MS, MS:1001837, iTRAQ quantitation analysis, ]"
+		} else if b.isobaric && b.m.Quantify.Brand == "tmt" {
+			method = "[MS, MS:1002010, TMT quantitation analysis, ]"
+		} else if b.isobaric {
+			method = "[MS, MS:1002009, isobaric label quantitation analysis, ]"
+		}
+
+		add("quantification_method", method)
+		add("protein-quantification_unit", "[PRIDE, PRIDE:0000330, Arbitrary quantification unit, ]")
+		add("peptide-quantification_unit", "[PRIDE, PRIDE:0000330, Arbitrary quantification unit, ]")
+
+		var runRefs []string
+		for i := range runs {
+			runRefs = append(runRefs, fmt.Sprintf("ms_run[%d]", i+1))
+		}
+
+		for i, j := range b.studyVariable {
+			if b.isobaric {
+				add(fmt.Sprintf("assay[%d]-quantification_reagent", i+1), fmt.Sprintf("[, , %s, ]", mzTabParamValue(j)))
+			} else {
+				add(fmt.Sprintf("assay[%d]-quantification_reagent", i+1), "[MS, MS:1002038, unlabeled sample, ]")
+			}
+			add(fmt.Sprintf("assay[%d]-ms_run_ref", i+1), strings.Join(runRefs, ","))
+		}
+
+		for i, j := range b.studyVariable {
+			add(fmt.Sprintf("study_variable[%d]-assay_refs", i+1), fmt.Sprintf("assay[%d]", i+1))
+			add(fmt.Sprintf("study_variable[%d]-description", i+1), j)
+		}
+	}
+
+	return md
+}
+
+// searchSettings lists the main search engine parameters
+func (b *mzTabBuilder) searchSettings() []string {
+
+	p := b.e.Parameters
+
+	var settings []string
+	for _, i := range [][2]string{
+		{"database_name", p.DatabaseName},
+		{"precursor_true_tolerance", strings.TrimSpace(p.PrecursorTrueTolerance + " " + p.PrecursorTrueUnits)},
+		{"fragment_mass_tolerance", strings.TrimSpace(p.FragmentMassTolerance + " " + p.FragmentMassUnits)},
+		{"search_enzyme_name", p.SearchEnzymeName},
+		{"search_enzyme_cutafter", p.SearchEnzymeCutafter},
+		{"num_enzyme_termini", p.NumEnzymeTermini},
+		{"allowed_missed_cleavage", p.AllowedMissedCleavage},
+		{"isotope_error", p.IsotopeError},
+	} {
+		if len(i[1]) > 0 {
+			settings = append(settings, fmt.Sprintf("%s = %s", i[0], i[1]))
+		}
+	}
+
+	return settings
+}
+
+// searchedModifications returns the fixed and variable modifications declared by the search
+func (b *mzTabBuilder) searchedModifications() ([]mod.Modification, []mod.Modification) {
+
+	var fixed, variable []mod.Modification
+	for _, i := range b.e.Mods.Index {
+		if i.MassDiff == 0 {
+			continue
+		}
+		if i.Variable {
+			variable = append(variable, i)
+		} else {
+			fixed = append(fixed, i)
+		}
+	}
+
+	var order = func(l []mod.Modification) {
+		sort.Slice(l, func(i, j int) bool {
+			if l[i].AminoAcid != l[j].AminoAcid {
+				return l[i].AminoAcid < l[j].AminoAcid
+			}
+			return l[i].MassDiff < l[j].MassDiff
+		})
+	}
+
+	order(fixed)
+	order(variable)
+
+	return fixed, variable
+}
+
+// modificationMetadata describes a searched modification with its site and position
+func (b *mzTabBuilder) modificationMetadata(key string, n int, m mod.Modification) [][2]string {
+
+	param := fmt.Sprintf("[, , %s, %.4f]", mzTabParamValue(m.AminoAcid), m.MassDiff)
+	if t, ok := b.unimod(m.MassDiff, m.AminoAcid); ok {
+		param = fmt.Sprintf("[UNIMOD, %s, %s, ]", t.ID, mzTabParamValue(t.Name))
+	}
+
+	site, position := m.AminoAcid, "Anywhere"
+	switch m.AminoAcid {
+	case "N-term", "n":
+		site, position = "N-term", "Any N-term"
+	case "C-term", "c":
+		site, position = "C-term", "Any C-term"
+	}
+
+	return [][2]string{
+		{fmt.Sprintf("%s[%d]", key, n), param},
+		{fmt.Sprintf("%s[%d]-site", key, n), site},
+		{fmt.Sprintf("%s[%d]-position", key, n), position},
+	}
+}
+
+// unimod finds the closest UNIMOD term of a mass shift on the given site
+func (b *mzTabBuilder) unimod(mass float64, site string) (obo.Term, bool) {
+	return unimodTerm(b.terms, mass, site)
+}
+
+// unimodTerm finds the closest UNIMOD term of a mass shift on the given site, within 20 ppm of the mass
+func unimodTerm(terms []obo.Term, mass float64, site string) (obo.Term, bool) {
+
+	var best obo.Term
+	var gap = math.MaxFloat64
+
+	switch site {
+	case "n":
+		site = "N-term"
+	case "c":
+		site = "C-term"
+	}
+
+	for _, i := range terms {
+		if _, ok := i.Sites[site]; !ok {
+			continue
+		}
+		if d := math.Abs(mass - i.MonoIsotopicMass); d < gap {
+			gap = d
+			best = i
+		}
+	}
+
+	if gap < (20e-6 * math.Abs(mass)) {
+		return best, true
+	}
+
+	return best, false
+}
+
+// modifications prints the modification positions and identifiers, the terminal modifications are placed on
+// position 0 and after the last residue
+func (b *mzTabBuilder) modifications(sequence string, mods mod.ModificationsSlice) string {
+
+	type site struct {
+		Position int
+		ID       string
+	}
+
+	var sites []site
+	for _, i := range mods.IndexSlice {
+
+		if i.Type != mod.Assigned || i.MassDiff == 0 {
+			continue
+		}
+
+		position := i.Position
+		switch i.AminoAcid {
+		case "N-term", "n":
+			position = 0
+		case "C-term", "c":
+			position = len(sequence) + 1
+		}
+
+		identifier := fmt.Sprintf("CHEMMOD:%+.4f", i.MassDiff)
+		if strings.HasPrefix(i.ID, "UNIMOD:") {
+			identifier = i.ID
+		} else if t, ok := b.unimod(i.MassDiff, i.AminoAcid); ok {
+			identifier = t.ID
+		}
+
+		sites = append(sites, site{position, identifier})
+	}
+
+	if len(sites) == 0 {
+		return mzTabNull
+	}
+
+	sort.Slice(sites, func(i, j int) bool {
+		if sites[i].Position != sites[j].Position {
+			return sites[i].Position < sites[j].Position
+		}
+		return sites[i].ID < sites[j].ID
+	})
+
+	var list []string
+	for _, i := range sites {
+		list = append(list, fmt.Sprintf("%d-%s", i.Position, i.ID))
+	}
+
+	return strings.Join(list, ",")
+}
+
+// spectraRef points to the scan of a spectrum on its run
+func (b *mzTabBuilder) spectraRef(spectrum string) string {
+
+	parts := strings.Split(spectrum, ".")
+	if len(parts) < 2 {
+		return mzTabNull
+	}
+
+	scan, e := strconv.Atoi(parts[1])
+	if e != nil {
+		return mzTabNull
+	}
+
+	return fmt.Sprintf("ms_run[%d]:scan=%d", b.runs[parts[0]], scan)
+}
+
+// abundanceColumns names the abundance, deviation and error columns of each study variable
+func (b *mzTabBuilder) abundanceColumns(level string) []string {
+
+	var columns []string
+	for i := range b.studyVariable {
+		columns = append(columns,
+			fmt.Sprintf("%s_abundance_study_variable[%d]", level, i+1),
+			fmt.Sprintf("%s_abundance_stdev_study_variable[%d]", level, i+1),
+			fmt.Sprintf("%s_abundance_std_error_study_variable[%d]", level, i+1))
+	}
+
+	return columns
+}
+
+// abundanceValues prints the study variable abundances, the deviations are not estimated on the summary mode
+func (b *mzTabBuilder) abundanceValues(intensity float64, labels *iso.Labels) []string {
+
+	var values []string
+	for i := range b.studyVariable {
+
+		v := intensity
+		if b.isobaric {
+			v = 0
+			if labels != nil && i < len(labels.Channels) {
+				v = labels.Channels[i].Intensity
+			}
+		}
+
+		values = append(values, mzTabFloat(v, v > 0), mzTabNull, mzTabNull)
+	}
+
+	return values
+}
+
+// proteinSection reports the target proteins with their probabilities and abundances
+func (b *mzTabBuilder) proteinSection() mzTabSection {
+
+	var s = mzTabSection{Prefix: mzTabProtein}
+
+	s.Columns = []string{"accession", "description", "taxid", "species", "database", "database_version", "search_engine", "best_search_engine_score[1]", "ambiguity_members", "modifications", "protein_coverage"}
+	s.Columns = append(s.Columns, b.abundanceColumns("protein")...)
+
+	for _, i := range b.e.Proteins {
+
+		if i.IsDecoy {
+			continue
+		}
+
+		var members []string
+		for j := range i.IndiProtein {
+			if a := mzTabAccession(j); a != i.ProteinID {
+				members = append(members, a)
+			}
+		}
+		sort.Strings(members)
+
+		row := []string{
+			mzTabText(i.ProteinID),
+			mzTabText(i.Description),
+			mzTabNull,
+			mzTabText(i.Organism),
+			b.database,
+			b.version,
+			b.searchEngine,
+			mzTabFloat(i.Probability, true),
+			mzTabText(strings.Join(members, ",")),
+			mzTabNull,
+			mzTabFloat(float64(i.Coverage)/100, i.Coverage > 0),
+		}
+
+		labels := i.URazorLabels
+		if b.m.Quantify.Unique {
+			labels = i.UniqueLabels
+		}
+		row = append(row, b.abundanceValues(i.URazorIntensity, labels)...)
+
+		s.Rows = append(s.Rows, row)
+	}
+
+	return s
+}
+
+// peptideSection reports the target peptide ions, one row for each sequence, modification set and charge state
+func (b *mzTabBuilder) peptideSection() mzTabSection {
+
+	var s = mzTabSection{Prefix: mzTabPeptide}
+
+	s.Columns = []string{"sequence", "accession", "unique", "database", "database_version", "search_engine", "best_search_engine_score[1]", "modifications", "retention_time", "retention_time_window", "charge", "mass_to_charge", "spectra_ref"}
+	s.Columns = append(s.Columns, b.abundanceColumns("peptide")...)
+
+	// the retention time comes from the best PSM of each ion
+	var bestPSM = make(map[id.IonFormType]PSMEvidence)
+	for _, i := range b.e.PSM {
+		if v, ok := bestPSM[i.IonForm()]; !ok || i.Probability > v.Probability {
+			bestPSM[i.IonForm()] = i
+		}
+	}
+
+	for _, i := range b.e.Ions {
+
+		if i.IsDecoy || i.Probability <= 0 {
+			continue
+		}
+
+		var refs []string
+		for j := range i.Spectra {
+			refs = append(refs, b.spectraRef(j.Spectrum))
+		}
+		sort.Strings(refs)
+
+		psm, ok := bestPSM[i.IonForm()]
+
+		row := []string{
+			mzTabText(i.Sequence),
+			mzTabText(i.ProteinID),
+			mzTabBool(i.IsUnique),
+			b.database,
+			b.version,
+			b.searchEngine,
+			mzTabFloat(i.Probability, true),
+			b.modifications(i.Sequence, i.Modifications),
+			mzTabFloat(psm.RetentionTime, ok),
+			mzTabNull,
+			strconv.Itoa(int(i.ChargeState)),
+			mzTabFloat(i.MZ, true),
+			mzTabText(strings.Join(refs, "|")),
+		}
+		row = append(row, b.abundanceValues(i.Intensity, i.Labels)...)
+
+		s.Rows = append(s.Rows, row)
+	}
+
+	return s
+}
+
+// psmSection reports the target PSMs, a PSM mapped to several proteins has one row for each protein
+func (b *mzTabBuilder) psmSection() mzTabSection {
+
+	var s = mzTabSection{Prefix: mzTabPSM}
+
+	s.Columns = []string{"sequence", "PSM_ID", "accession", "unique", "database", "database_version", "search_engine", "search_engine_score[1]", "search_engine_score[2]", "modifications", "retention_time", "charge", "exp_mass_to_charge", "calc_mass_to_charge", "spectra_ref", "pre", "post", "start", "end"}
+
+	var psmID int
+	for _, i := range b.e.PSM {
+
+		if i.IsDecoy {
+			continue
+		}
+
+		psmID++
+
+		var accessions = []string{i.ProteinID}
+		var mapped []string
+		for j := range i.MappedProteins {
+			if a := mzTabAccession(j); j != i.Protein && a != i.ProteinID && len(a) > 0 {
+				mapped = append(mapped, a)
+			}
+		}
+		sort.Strings(mapped)
+		accessions = append(accessions, mapped...)
+
+		charge := float64(i.AssumedCharge)
+
+		for k, j := range accessions {
+
+			pre, post, start, end := mzTabNull, mzTabNull, mzTabNull, mzTabNull
+			if k == 0 {
+				pre, post = mzTabResidue(i.PrevAA), mzTabResidue(i.NextAA)
+				if i.ProteinStart > 0 {
+					start, end = strconv.Itoa(i.ProteinStart), strconv.Itoa(i.ProteinEnd)
+				}
+			}
+
+			row := []string{
+				mzTabText(i.Peptide),
+				strconv.Itoa(psmID),
+				mzTabText(j),
+				mzTabBool(i.IsUnique),
+				b.database,
+				b.version,
+				b.searchEngine,
+				mzTabFloat(i.Probability, true),
+				mzTabScientific(i.Expectation),
+				b.modifications(i.Peptide, i.Modifications),
+				mzTabFloat(i.RetentionTime, true),
+				strconv.Itoa(int(i.AssumedCharge)),
+				mzTabFloat((i.PrecursorNeutralMass+(charge*bio.Proton))/charge, charge > 0),
+				mzTabFloat((i.CalcNeutralPepMass+(charge*bio.Proton))/charge, charge > 0),
+				b.spectraRef(i.Spectrum),
+				pre,
+				post,
+				start,
+				end,
+			}
+
+			s.Rows = append(s.Rows, row)
+		}
+	}
+
+	return s
+}
+
+// mzTabText replaces the empty values and the characters that would break the table
+func mzTabText(s string) string {
+	s = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ").Replace(strings.TrimSpace(s))
+	if len(s) == 0 {
+		return mzTabNull
+	}
+	return s
+}
+
+// mzTabParamValue removes the characters reserved by the parameter notation
+func mzTabParamValue(s string) string {
+	return strings.NewReplacer(",", " ", "[", "(", "]", ")").Replace(s)
+}
+
+// mzTabFloat prints a decimal number, invalid values are null
+func mzTabFloat(v float64, valid bool) string {
+	if !valid || math.IsNaN(v) || math.IsInf(v, 0) {
+		return mzTabNull
+	}
+	return strconv.FormatFloat(v, 'f', 4, 64)
+}
+
+// mzTabScientific prints small numbers such as the expectation values
+func mzTabScientific(v float64) string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return mzTabNull
+	}
+	return strconv.FormatFloat(v, 'E', 4, 64)
+}
+
+// mzTabBool prints the boolean flags as 1 or 0
+func mzTabBool(v bool) string {
+	if v {
+		return "1"
+	}
+	return "0"
+}
+
+// mzTabResidue prints the flanking residue, protein termini are reported as a dash
+func mzTabResidue(aa byte) string {
+	if aa == 0 {
+		return mzTabNull
+	}
+	return string(aa)
+}
+
+// mzTabAccession extracts the accession of a protein name in the UniProt format
+func mzTabAccession(name string) string {
+	parts := strings.Split(name, "|")
+	if len(parts) >= 3 {
+		return parts[1]
+	}
+	return name
+}
@@ -0,0 +1,223 @@
+package rep
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+
+	"philosopher/lib/id"
+	"philosopher/lib/iso"
+	"philosopher/lib/met"
+	"philosopher/lib/mod"
+	"philosopher/lib/obo"
+)
+
+func mzTabEvidence() (Evidence, met.Data) {
+
+	var m met.Data
+	m.Home = "/tmp/project"
+	m.Version = "4.0.0"
+	m.SearchEngine = "MSFragger"
+	m.Database.Annot = "/db/2020-01-01-decoys-reviewed-contam-UP000005640.fas"
+	m.Quantify.Brand = "tmt"
+
+	oxidation := mod.Modification{Index: "M#15.9949", AminoAcid: "M", MassDiff: 15.9949, Variable: true}
+	tmt := mod.Modification{Index: "N-term#229.1629", AminoAcid: "N-term", MassDiff: 229.1629, Variable: true}
+	carbamidomethyl := mod.Modification{Index: "C#57.0215", AminoAcid: "C", MassDiff: 57.0215}
+
+	var e Evidence
+	e.Mods.Index = map[string]mod.Modification{oxidation.Index: oxidation, tmt.Index: tmt, carbamidomethyl.Index: carbamidomethyl}
+
+	siteOx := oxidation
+	siteOx.Position = 3
+	siteTMT := tmt
+	siteTMT.Position = 1
+	mods := mod.ModificationsSlice{IndexSlice: []mod.Modification{siteOx, siteTMT}}
+
+	e.PSM = PSMEvidenceList{
+		{
+			Spectrum:             "run_02.01234.01234.2",
+			Peptide:              "PEMTIDEK",
+			Protein:              "sp|P12345|PROT_HUMAN",
+			ProteinID:            "P12345",
+			MappedProteins:       map[string]int{"sp|Q67890|OTHER_HUMAN": 0},
+			AssumedCharge:        2,
+			PrecursorNeutralMass: 1176.5,
+			CalcNeutralPepMass:   1176.5,
+			RetentionTime:        1200.5,
+			Probability:          0.99,
+			Expectation:          0.0001,
+			PrevAA:               'K',
+			NextAA:               'A',
+			ProteinStart:         10,
+			ProteinEnd:           17,
+			Modifications:        mods,
+		},
+		{
+			Spectrum:      "run_01.00042.00042.3",
+			Peptide:       "DECOYPEPTIDE",
+			ProteinID:     "rev_P12345",
+			AssumedCharge: 3,
+			IsDecoy:       true,
+		},
+	}
+
+	e.Ions = IonEvidenceList{
+		{
+			Sequence:      "PEMTIDEK",
+			ProteinID:     "P12345",
+			ChargeState:   2,
+			MZ:            589.2573,
+			PeptideMass:   1176.5,
+			Probability:   0.99,
+			IsUnique:      true,
+			Modifications: mods,
+			Spectra:       map[id.SpectrumType]int{{Spectrum: "run_02.01234.01234.2"}: 0},
+		},
+	}
+
+	labels := &iso.Labels{Channels: []iso.Channel{
+		{Name: "126", CustomName: "control", Intensity: 1000},
+		{Name: "127N", CustomName: "treated", Intensity: 2000},
+	}}
+
+	e.Proteins = ProteinEvidenceList{
+		{
+			ProteinID:    "P12345",
+			Description:  "Protein",
+			Organism:     "Homo sapiens",
+			Probability:  1,
+			Coverage:     25,
+			IndiProtein:  map[string]struct{}{"sp|Q67890|OTHER_HUMAN": {}},
+			URazorLabels: labels,
+		},
+		{
+			ProteinID: "rev_P12345",
+			IsDecoy:   true,
+		},
+	}
+
+	return e, m
+}
+
+// checkMzTab validates the structure of an mzTab 1.0 summary file
+func checkMzTab(t *testing.T, content string) {
+
+	var mandatory = []string{"mzTab-version", "mzTab-mode", "mzTab-type", "description", "ms_run[1]-location"}
+	var rows = map[string]string{"PRH": "PRT", "PEH": "PEP", "PSH": "PSM"}
+	var metadata = make(map[string]string)
+
+	var header []string
+	var prefix string
+
+	modification := regexp.MustCompile(`^\d+-(UNIMOD|CHEMMOD):[^,]+(,\d+-(UNIMOD|CHEMMOD):[^,]+)*$`)
+	reference := regexp.MustCompile(`ms_run\[\d+\]`)
+
+	for n, line := range strings.Split(strings.TrimRight(content, "\n"), "\n") {
+
+		if len(line) == 0 {
+			header = nil
+			continue
+		}
+
+		cells := strings.Split(line, "\t")
+		for _, i := range cells {
+			if len(i) == 0 {
+				t.Errorf("line %d has an empty cell: %q", n+1, line)
+			}
+		}
+
+		switch cells[0] {
+		case "MTD":
+			if len(cells) != 3 {
+				t.Errorf("line %d: metadata must have a key and a value", n+1)
+				continue
+			}
+			metadata[cells[1]] = cells[2]
+		case "PRH", "PEH", "PSH":
+			header = cells
+			prefix = rows[cells[0]]
+		case "PRT", "PEP", "PSM":
+			if header == nil || cells[0] != prefix {
+				t.Errorf("line %d: %s row without its header", n+1, cells[0])
+				continue
+			}
+			if len(cells) != len(header) {
+				t.Errorf("line %d: %d cells for %d columns", n+1, len(cells), len(header))
+				continue
+			}
+			for i, j := range header {
+				switch j {
+				case "modifications":
+					if cells[i] != mzTabNull && !modification.MatchString(cells[i]) {
+						t.Errorf("line %d: invalid modification %s", n+1, cells[i])
+					}
+				case "spectra_ref":
+					for _, k := range reference.FindAllString(cells[i], -1) {
+						if _, ok := metadata[k+"-location"]; !ok {
+							t.Errorf("line %d: undefined %s", n+1, k)
+						}
+					}
+				}
+			}
+		default:
+			t.Errorf("line %d: unknown prefix %s", n+1, cells[0])
+		}
+	}
+
+	for _, i := range mandatory {
+		if _, ok := metadata[i]; !ok {
+			t.Errorf("missing mandatory metadata %s", i)
+		}
+	}
+}
+
+func TestMzTab(t *testing.T) {
+
+	e, m := mzTabEvidence()
+
+	terms := []obo.Term{
+		{ID: "UNIMOD:35", Name: "Oxidation", MonoIsotopicMass: 15.9949, Sites: map[string]uint8{"M": 1}},
+		{ID: "UNIMOD:4", Name: "Carbamidomethyl", MonoIsotopicMass: 57.0215, Sites: map[string]uint8{"C": 1}},
+		{ID: "UNIMOD:737", Name: "TMT6plex", MonoIsotopicMass: 229.1629, Sites: map[string]uint8{"N-term": 1, "K": 1}},
+	}
+
+	doc := newMzTab(e, m, terms)
+
+	var buf bytes.Buffer
+	doc.write(&buf)
+	content := buf.String()
+
+	checkMzTab(t, content)
+
+	for _, i := range []string{
+		"MTD\tmzTab-type\tQuantification",
+		"MTD\tquantification_method\t[MS, MS:1002010, TMT quantitation analysis, ]",
+		"MTD\tfixed_mod[1]\t[UNIMOD, UNIMOD:4, Carbamidomethyl, ]",
+		"MTD\tvariable_mod[2]-site\tN-term",
+		"MTD\tstudy_variable[2]-description\ttreated",
+		"MTD\tms_run[2]-location\tfile:///tmp/project/run_02.mzML",
+		"0-UNIMOD:737,3-UNIMOD:35",
+		"ms_run[2]:scan=1234",
+	} {
+		if !strings.Contains(content, i) {
+			t.Errorf("expected %q in the mzTab output", i)
+		}
+	}
+
+	if strings.Contains(content, "DECOYPEPTIDE") || strings.Contains(content, "rev_P12345") {
+		t.Error("decoys must not be exported")
+	}
+
+	var psm int
+	for _, i := range strings.Split(content, "\n") {
+		if strings.HasPrefix(i, "PSM\t") {
+			psm++
+		}
+	}
+
+	if psm != 2 {
+		t.Errorf("expected one PSM row for each mapped protein, got %d", psm)
+	}
+}
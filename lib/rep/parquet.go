@@ -0,0 +1,123 @@
+package rep
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+
+	"philosopher/lib/msg"
+
+	"github.com/xitongsys/parquet-go/marshal"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// ParquetReport writes a report table as a Parquet file in the workspace
+func (t *Table) ParquetReport(workspace string, long bool) {
+
+	output := fmt.Sprintf("%s%s%s.parquet", workspace, string(filepath.Separator), t.Name)
+	if e := t.WriteParquet(output, long); e != nil {
+		msg.WriteFile(errors.New("cannot create the Parquet report "+output+", "+e.Error()), "fatal")
+	}
+}
+
+// WriteParquet writes the printed columns with the types of the table fields, NaN values are written as nulls. In
+// the long format the channel columns are replaced by a row per channel with the channel name and its intensity, the
+// intensity column is named apart from the report Intensity column
+func (t *Table) WriteParquet(output string, long bool) error {
+
+	var ids, channels []*Field
+	for _, i := range t.Printed() {
+		if long && len(i.channel) > 0 {
+			channels = append(channels, i)
+		} else {
+			ids = append(ids, i)
+		}
+	}
+
+	var columns []*parquet.SchemaElement
+	for _, i := range ids {
+		columns = append(columns, parquetColumn(i.Name, i.Type))
+	}
+
+	if len(channels) > 0 {
+		columns = append(columns, parquetColumn("Channel", parquet.Type_BYTE_ARRAY), parquetColumn("Channel Intensity", parquet.Type_DOUBLE))
+	}
+
+	file, e := os.Create(output)
+	if e != nil {
+		return e
+	}
+	defer file.Close()
+
+	bw := bufio.NewWriter(file)
+
+	// the rows are buffered by the writer and flushed in row groups
+	children := int32(len(columns))
+
+	root := parquet.NewSchemaElement()
+	root.Name = "schema"
+	root.NumChildren = &children
+	root.RepetitionType = parquet.FieldRepetitionTypePtr(parquet.FieldRepetitionType_REQUIRED)
+
+	w, e := writer.NewParquetWriterFromWriter(bw, append([]*parquet.SchemaElement{root}, columns...), 1)
+	if e != nil {
+		return e
+	}
+	w.MarshalFunc = marshal.MarshalCSV
+
+	for n := 0; n < t.Rows; n++ {
+
+		var row = make([]interface{}, len(ids), len(columns))
+		for i, j := range ids {
+			row[i] = parquetValue(j.Value(n))
+		}
+
+		if len(channels) == 0 {
+			if e := w.Write(row); e != nil {
+				return e
+			}
+			continue
+		}
+
+		for _, i := range channels {
+			if e := w.Write(append(row[:len(ids):len(ids)], i.channel, parquetValue(i.Value(n)))); e != nil {
+				return e
+			}
+		}
+	}
+
+	if e := w.WriteStop(); e != nil {
+		return e
+	}
+
+	return bw.Flush()
+}
+
+// parquetColumn describes an optional column, the text columns are UTF-8 strings
+func parquetColumn(name string, t parquet.Type) *parquet.SchemaElement {
+
+	c := parquet.NewSchemaElement()
+	c.Name = name
+	c.Type = parquet.TypePtr(t)
+	c.RepetitionType = parquet.FieldRepetitionTypePtr(parquet.FieldRepetitionType_OPTIONAL)
+
+	if t == parquet.Type_BYTE_ARRAY {
+		c.ConvertedType = parquet.ConvertedTypePtr(parquet.ConvertedType_UTF8)
+	}
+
+	return c
+}
+
+// parquetValue converts the missing decimals to nulls
+func parquetValue(v interface{}) interface{} {
+
+	if f, ok := v.(float64); ok && math.IsNaN(f) {
+		return nil
+	}
+
+	return v
+}
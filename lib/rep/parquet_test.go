@@ -0,0 +1,131 @@
+package rep
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"philosopher/lib/iso"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+// readParquet reads the columns of a Parquet file back
+func readParquet(t *testing.T, input string) ([]string, []parquet.Type, [][]interface{}) {
+
+	file, err := local.NewLocalFileReader(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	pr, err := reader.NewParquetColumnReader(file, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.ReadStop()
+
+	rows := pr.GetNumRows()
+
+	var names []string
+	var types []parquet.Type
+	var columns [][]interface{}
+
+	for i, j := range pr.Footer.Schema[1:] {
+
+		// the reader encodes the column names, the original ones are kept by the schema handler
+		name := pr.SchemaHandler.Infos[i+1].ExName
+
+		values, _, _, err := pr.ReadColumnByIndex(int64(i), rows)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		names = append(names, name)
+		types = append(types, j.GetType())
+		columns = append(columns, values)
+	}
+
+	return names, types, columns
+}
+
+func TestTableParquet(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "parquet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	labels := []*iso.Labels{
+		{Channels: []iso.Channel{{Name: "126", Intensity: 1000.125}, {Name: "127N", Intensity: 2000.5}}},
+		nil,
+	}
+
+	proteins := []struct {
+		name      string
+		spc       int
+		intensity float64
+		unique    bool
+	}{
+		{"sp|P1|A", 3, 123456.789, true},
+		{"sp|P2|B", 1, math.NaN(), false},
+	}
+
+	table := NewTable("protein", len(proteins))
+	table.Text("Protein", func(n int) string { return proteins[n].name })
+	table.Integer("Spectral Count", func(n int) int { return proteins[n].spc })
+	table.Decimal("Intensity", "%6.f", func(n int) float64 { return proteins[n].intensity })
+	table.Flag("Is Unique", func(n int) bool { return proteins[n].unique })
+	table.Decimal("Purity", "%.2f", func(n int) float64 { return 0 }).Hide(true)
+	table.channelFields(labels[0], false, func(n int) *iso.Labels { return labels[n] })
+
+	wide := filepath.Join(dir, "protein.parquet")
+	if err := table.WriteParquet(wide, false); err != nil {
+		t.Fatal(err)
+	}
+
+	names, types, columns := readParquet(t, wide)
+
+	if want := []string{"Protein", "Spectral Count", "Intensity", "Is Unique", "Channel 126", "Channel 127N"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("the printed columns must be written, got %v", names)
+	}
+
+	want := []parquet.Type{parquet.Type_BYTE_ARRAY, parquet.Type_INT64, parquet.Type_DOUBLE, parquet.Type_BOOLEAN, parquet.Type_DOUBLE, parquet.Type_DOUBLE}
+	if !reflect.DeepEqual(types, want) {
+		t.Errorf("the column types must follow the table fields, got %v", types)
+	}
+
+	// the intensities keep their precision and the missing values are nulls
+	if columns[2][0] != 123456.789 || columns[2][1] != nil {
+		t.Errorf("the intensities are incorrect, got %v", columns[2])
+	}
+
+	if columns[0][1] != "sp|P2|B" || columns[1][0] != int64(3) || columns[3][1] != false || columns[5][0] != 2000.5 || columns[5][1] != 0.0 {
+		t.Errorf("the values are incorrect, got %v", columns)
+	}
+
+	long := filepath.Join(dir, "protein_long.parquet")
+	if err := table.WriteParquet(long, true); err != nil {
+		t.Fatal(err)
+	}
+
+	names, _, columns = readParquet(t, long)
+
+	if want := []string{"Protein", "Spectral Count", "Intensity", "Is Unique", "Channel", "Channel Intensity"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("the long format must replace the channel columns, got %v", names)
+	}
+
+	if want := []interface{}{"126", "127N", "126", "127N"}; !reflect.DeepEqual(columns[4], want) {
+		t.Errorf("the long format must have a row per channel, got %v", columns[4])
+	}
+
+	if want := []interface{}{1000.125, 2000.5, 0.0, 0.0}; !reflect.DeepEqual(columns[5], want) {
+		t.Errorf("the channel intensities are incorrect, got %v", columns[5])
+	}
+}
@@ -1,11 +1,8 @@
 package rep
 
 import (
-	"bufio"
 	"errors"
 	"fmt"
-	"io"
-	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
@@ -13,6 +10,7 @@ import (
 
 	"philosopher/lib/cla"
 	"philosopher/lib/id"
+	"philosopher/lib/iso"
 	"philosopher/lib/mod"
 	"philosopher/lib/msg"
 )
@@ -125,19 +123,10 @@ func (evi *Evidence) AssemblePeptideReport(pep id.PepIDList, decoyTag string) {
 }
 
 // MetaPeptideReport report consist on ion reporting
-func (evi PeptideEvidenceList) MetaPeptideReport(workspace, brand, decoyTag string, channels int, hasDecoys, hasLabels bool) {
+func (evi PeptideEvidenceList) MetaPeptideReport(workspace, decoyTag string, hasDecoys, hasLabels bool) *Table {
 
-	var header string
 	output := fmt.Sprintf("%s%speptide.tsv", workspace, string(filepath.Separator))
 
-	file, e := os.Create(output)
-	bw := bufio.NewWriter(file)
-	if e != nil {
-		msg.WriteFile(errors.New("peptide output file"), "fatal")
-	}
-	defer file.Close()
-	defer bw.Flush()
-
 	// building the printing set tat may or not contain decoys
 	var printSet []*PeptideEvidence
 	for idx, i := range evi {
@@ -150,258 +139,102 @@ func (evi PeptideEvidenceList) MetaPeptideReport(workspace, brand, decoyTag stri
 		}
 	}
 
-	header = "Peptide\tPrev AA\tNext AA\tPeptide Length\tCharges\tProbability\tSpectral Count\tIntensity\tAssigned Modifications\tObserved Modifications\tProtein\tProtein ID\tEntry Name\tGene\tProtein Description\tMapped Genes\tMapped Proteins"
-
-	if brand == "tmt" {
-		switch channels {
-		case 6:
-			header += "\tChannel 126\tChannel 127N\tChannel 128C\tChannel 129N\tChannel 130C\tChannel 131"
-		case 10:
-			header += "\tChannel 126\tChannel 127N\tChannel 127C\tChannel 128N\tChannel 128C\tChannel 129N\tChannel 129C\tChannel 130N\tChannel 130C\tChannel 131N"
-		case 11:
-			header += "\tChannel 126\tChannel 127N\tChannel 127C\tChannel 128N\tChannel 128C\tChannel 129N\tChannel 129C\tChannel 130N\tChannel 130C\tChannel 131N\tChannel 131C"
-		case 16:
-			header += "\tChannel 126\tChannel 127N\tChannel 127C\tChannel 128N\tChannel 128C\tChannel 129N\tChannel 129C\tChannel 130N\tChannel 130C\tChannel 131N\tChannel 131C\tChannel 132N\tChannel 132C\tChannel 133N\tChannel 133C\tChannel 134N"
-		case 18:
-			header += "\tChannel 126\tChannel 127N\tChannel 127C\tChannel 128N\tChannel 128C\tChannel 129N\tChannel 129C\tChannel 130N\tChannel 130C\tChannel 131N\tChannel 131C\tChannel 132N\tChannel 132C\tChannel 133N\tChannel 133C\tChannel 134N\tChannel 134C\tChannel 135N"
-		default:
-			header += ""
-		}
-	} else if brand == "itraq" {
-		switch channels {
-		case 4:
-			header += "\tChannel 114\tChannel 115\tChannel 116\tChannel 117"
-		case 8:
-			header += "\tChannel 113\tChannel 114\tChannel 115\tChannel 116\tChannel 117\tChannel 118\tChannel 119\tChannel 121"
-		default:
-			header += ""
+	// append decoy tags on the gene and proteinID names
+	for _, i := range printSet {
+		if i.IsDecoy {
+			i.ProteinID = decoyTag + i.ProteinID
+			i.GeneName = decoyTag + i.GeneName
+			i.EntryName = decoyTag + i.EntryName
 		}
 	}
 
-	header += "\n"
-
-	// verify if the structure has labels, if so, replace the original channel names by them.
-	if hasLabels {
-
-		var c1, c2, c3, c4, c5, c6, c7, c8, c9, c10, c11, c12, c13, c14, c15, c16, c17, c18 string
-
-		for _, i := range printSet {
-			if len(i.Labels.Channel1.CustomName) >= 1 {
-				c1 = i.Labels.Channel1.CustomName
-				c2 = i.Labels.Channel2.CustomName
-				c3 = i.Labels.Channel3.CustomName
-				c4 = i.Labels.Channel4.CustomName
-				c5 = i.Labels.Channel5.CustomName
-				c6 = i.Labels.Channel6.CustomName
-				c7 = i.Labels.Channel7.CustomName
-				c8 = i.Labels.Channel8.CustomName
-				c9 = i.Labels.Channel9.CustomName
-				c10 = i.Labels.Channel10.CustomName
-				c11 = i.Labels.Channel11.CustomName
-				c12 = i.Labels.Channel12.CustomName
-				c13 = i.Labels.Channel13.CustomName
-				c14 = i.Labels.Channel14.CustomName
-				c15 = i.Labels.Channel15.CustomName
-				c16 = i.Labels.Channel16.CustomName
-				c17 = i.Labels.Channel17.CustomName
-				c18 = i.Labels.Channel18.CustomName
-				break
-			}
+	// peptides with intensities from match-between-runs ions are flagged
+	var hasTransfers bool
+	for _, i := range printSet {
+		if i.IsTransferred {
+			hasTransfers = true
+			break
 		}
-
-		header = strings.Replace(header, "Channel "+printSet[10].Labels.Channel1.Name, c1, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].Labels.Channel2.Name, c2, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].Labels.Channel3.Name, c3, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].Labels.Channel4.Name, c4, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].Labels.Channel5.Name, c5, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].Labels.Channel6.Name, c6, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].Labels.Channel7.Name, c7, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].Labels.Channel8.Name, c8, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].Labels.Channel9.Name, c9, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].Labels.Channel10.Name, c10, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].Labels.Channel11.Name, c11, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].Labels.Channel12.Name, c12, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].Labels.Channel13.Name, c13, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].Labels.Channel14.Name, c14, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].Labels.Channel15.Name, c15, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].Labels.Channel16.Name, c16, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].Labels.Channel17.Name, c17, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].Labels.Channel18.Name, c18, -1)
-	}
-
-	//_, e = io.WriteString(file, header)
-	_, e = io.WriteString(bw, header)
-	if e != nil {
-		msg.WriteToFile(errors.New("cannot print PSM to file"), "fatal")
 	}
 
-	for _, i := range printSet {
-
-		assL, obs := getModsList(i.Modifications.ToMap().Index)
-
-		var mappedProteins []string
-		for j := range i.MappedProteins {
-			if j != i.Protein {
-				mappedProteins = append(mappedProteins, j)
-			}
-		}
+	t := NewTable("peptide", len(printSet))
 
+	t.Text("Peptide", func(n int) string { return printSet[n].Sequence })
+	t.Text("Prev AA", func(n int) string { return string(printSet[n].PrevAA) })
+	t.Text("Next AA", func(n int) string { return string(printSet[n].NextAA) })
+	t.Integer("Peptide Length", func(n int) int { return len(printSet[n].Sequence) })
+	t.Text("Charges", func(n int) string {
 		var cs []string
-		for j := range i.ChargeState {
+		for j := range printSet[n].ChargeState {
 			cs = append(cs, strconv.Itoa(int(j)))
 		}
-
+		sort.Strings(cs)
+		return strings.Join(cs, ", ")
+	})
+	t.Decimal("Probability", "%.4f", func(n int) float64 { return printSet[n].Probability })
+	t.Integer("Spectral Count", func(n int) int { return printSet[n].Spc })
+	t.Decimal("Intensity", "%f", func(n int) float64 { return printSet[n].Intensity })
+	t.Text("Assigned Modifications", func(n int) string {
+		assL, _ := getModsList(printSet[n].Modifications.ToMap().Index)
+		sort.Strings(assL)
+		return strings.Join(assL, ", ")
+	})
+	t.Text("Observed Modifications", func(n int) string {
+		_, obs := getModsList(printSet[n].Modifications.ToMap().Index)
+		sort.Strings(obs)
+		return strings.Join(obs, ", ")
+	})
+	t.Text("Protein", func(n int) string { return printSet[n].Protein })
+	t.Text("Protein ID", func(n int) string { return printSet[n].ProteinID })
+	t.Text("Entry Name", func(n int) string { return printSet[n].EntryName })
+	t.Text("Gene", func(n int) string { return printSet[n].GeneName })
+	t.Text("Protein Description", func(n int) string { return printSet[n].ProteinDescription })
+	t.Text("Mapped Genes", func(n int) string {
 		var mappedGenes []string
-		for j := range i.MappedGenes {
-			if j != i.GeneName && len(j) > 0 {
+		for j := range printSet[n].MappedGenes {
+			if j != printSet[n].GeneName && len(j) > 0 {
 				mappedGenes = append(mappedGenes, j)
 			}
 		}
-
 		sort.Strings(mappedGenes)
+		return strings.Join(mappedGenes, ", ")
+	})
+	t.Text("Mapped Proteins", func(n int) string {
+		var mappedProteins []string
+		for j := range printSet[n].MappedProteins {
+			if j != printSet[n].Protein {
+				mappedProteins = append(mappedProteins, j)
+			}
+		}
 		sort.Strings(mappedProteins)
-		sort.Strings(assL)
-		sort.Strings(obs)
-		sort.Strings(cs)
+		return strings.Join(mappedProteins, ", ")
+	})
 
-		// append decoy tags on the gene and proteinID names
-		if i.IsDecoy {
-			i.ProteinID = decoyTag + i.ProteinID
-			i.GeneName = decoyTag + i.GeneName
-			i.EntryName = decoyTag + i.EntryName
-		}
+	t.Flag("Match Between Runs", func(n int) bool { return printSet[n].IsTransferred }).Hide(!hasTransfers)
 
-		line := fmt.Sprintf("%s\t%s\t%s\t%d\t%s\t%.4f\t%d\t%f\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s",
-			i.Sequence,
-			string(i.PrevAA),
-			string(i.NextAA),
-			len(i.Sequence),
-			strings.Join(cs, ", "),
-			i.Probability,
-			i.Spc,
-			i.Intensity,
-			strings.Join(assL, ", "),
-			strings.Join(obs, ", "),
-			i.Protein,
-			i.ProteinID,
-			i.EntryName,
-			i.GeneName,
-			i.ProteinDescription,
-			strings.Join(mappedGenes, ", "),
-			strings.Join(mappedProteins, ", "),
-		)
-
-		switch channels {
-		case 4:
-			line = fmt.Sprintf("%s\t%.4f\t%.4f\t%.4f\t%.4f",
-				line,
-				i.Labels.Channel1.Intensity,
-				i.Labels.Channel2.Intensity,
-				i.Labels.Channel3.Intensity,
-				i.Labels.Channel4.Intensity,
-			)
-		case 6:
-			line = fmt.Sprintf("%s\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f",
-				line,
-				i.Labels.Channel1.Intensity,
-				i.Labels.Channel2.Intensity,
-				i.Labels.Channel5.Intensity,
-				i.Labels.Channel6.Intensity,
-				i.Labels.Channel9.Intensity,
-				i.Labels.Channel10.Intensity,
-			)
-		case 8:
-			line = fmt.Sprintf("%s\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f",
-				line,
-				i.Labels.Channel1.Intensity,
-				i.Labels.Channel2.Intensity,
-				i.Labels.Channel3.Intensity,
-				i.Labels.Channel4.Intensity,
-				i.Labels.Channel5.Intensity,
-				i.Labels.Channel6.Intensity,
-				i.Labels.Channel7.Intensity,
-				i.Labels.Channel8.Intensity,
-			)
-		case 10:
-			line = fmt.Sprintf("%s\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f",
-				line,
-				i.Labels.Channel1.Intensity,
-				i.Labels.Channel2.Intensity,
-				i.Labels.Channel3.Intensity,
-				i.Labels.Channel4.Intensity,
-				i.Labels.Channel5.Intensity,
-				i.Labels.Channel6.Intensity,
-				i.Labels.Channel7.Intensity,
-				i.Labels.Channel8.Intensity,
-				i.Labels.Channel9.Intensity,
-				i.Labels.Channel10.Intensity,
-			)
-		case 11:
-			line = fmt.Sprintf("%s\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f",
-				line,
-				i.Labels.Channel1.Intensity,
-				i.Labels.Channel2.Intensity,
-				i.Labels.Channel3.Intensity,
-				i.Labels.Channel4.Intensity,
-				i.Labels.Channel5.Intensity,
-				i.Labels.Channel6.Intensity,
-				i.Labels.Channel7.Intensity,
-				i.Labels.Channel8.Intensity,
-				i.Labels.Channel9.Intensity,
-				i.Labels.Channel10.Intensity,
-				i.Labels.Channel11.Intensity,
-			)
-		case 16:
-			line = fmt.Sprintf("%s\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f",
-				line,
-				i.Labels.Channel1.Intensity,
-				i.Labels.Channel2.Intensity,
-				i.Labels.Channel3.Intensity,
-				i.Labels.Channel4.Intensity,
-				i.Labels.Channel5.Intensity,
-				i.Labels.Channel6.Intensity,
-				i.Labels.Channel7.Intensity,
-				i.Labels.Channel8.Intensity,
-				i.Labels.Channel9.Intensity,
-				i.Labels.Channel10.Intensity,
-				i.Labels.Channel11.Intensity,
-				i.Labels.Channel12.Intensity,
-				i.Labels.Channel13.Intensity,
-				i.Labels.Channel14.Intensity,
-				i.Labels.Channel15.Intensity,
-				i.Labels.Channel16.Intensity,
-			)
-		case 18:
-			line = fmt.Sprintf("%s\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f",
-				line,
-				i.Labels.Channel1.Intensity,
-				i.Labels.Channel2.Intensity,
-				i.Labels.Channel3.Intensity,
-				i.Labels.Channel4.Intensity,
-				i.Labels.Channel5.Intensity,
-				i.Labels.Channel6.Intensity,
-				i.Labels.Channel7.Intensity,
-				i.Labels.Channel8.Intensity,
-				i.Labels.Channel9.Intensity,
-				i.Labels.Channel10.Intensity,
-				i.Labels.Channel11.Intensity,
-				i.Labels.Channel12.Intensity,
-				i.Labels.Channel13.Intensity,
-				i.Labels.Channel14.Intensity,
-				i.Labels.Channel15.Intensity,
-				i.Labels.Channel16.Intensity,
-				i.Labels.Channel17.Intensity,
-				i.Labels.Channel18.Intensity,
-			)
-		default:
-			header += ""
-		}
+	// the channels are taken from the labeled evidences, custom names replace the channel names when available
+	var labels []*iso.Labels
+	for _, i := range printSet {
+		labels = append(labels, i.Labels)
+	}
 
-		line += "\n"
+	if ref := referenceLabels(labels, hasLabels); ref != nil {
+		t.channelFields(ref, hasLabels, func(n int) *iso.Labels { return printSet[n].Labels })
+	}
 
-		_, e = io.WriteString(bw, line)
-		if e != nil {
-			msg.WriteToFile(errors.New("cannot print Peptides to file"), "fatal")
-		}
+	// MS1 labeled partners
+	var ms1Labels []*MS1Labels
+	for _, i := range printSet {
+		ms1Labels = append(ms1Labels, i.MS1Labels)
+	}
+
+	if ms1Ref := ms1LabelReference(ms1Labels); ms1Ref != nil {
+		t.ms1LabelFields(ms1Ref, false, func(n int) *MS1Labels { return printSet[n].MS1Labels })
 	}
+
+	if e := t.WriteTSV(output); e != nil {
+		msg.WriteToFile(errors.New("cannot print Peptides to file"), "fatal")
+	}
+
+	return t
 }
@@ -0,0 +1,39 @@
+package rep
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"philosopher/lib/iso"
+)
+
+func TestPeptideReportChannels(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "peptide")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a custom reagent has no brand, the channels come from the labels
+	labels := &iso.Labels{Channels: []iso.Channel{{Name: "L1", Intensity: 10}, {Name: "L2", Intensity: 20}}}
+	evi := PeptideEvidenceList{{Sequence: "PEPTIDEK", Labels: labels}, {Sequence: "SAMPLEK"}}
+
+	evi.MetaPeptideReport(dir, "rev_", false, false)
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "peptide.tsv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if !strings.HasSuffix(lines[0], "\tChannel L1\tChannel L2") {
+		t.Errorf("the channels must be reported from the labels, got %s", lines[0])
+	}
+	if !strings.HasSuffix(lines[1], "\t10.0000\t20.0000") || !strings.HasSuffix(lines[2], "\t0.0000\t0.0000") {
+		t.Errorf("the channel values are incorrect, got %s", strings.Join(lines[1:], "\n"))
+	}
+}
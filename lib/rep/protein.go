@@ -12,6 +12,7 @@ import (
 
 	"philosopher/lib/dat"
 	"philosopher/lib/id"
+	"philosopher/lib/iso"
 	"philosopher/lib/mod"
 	"philosopher/lib/msg"
 )
@@ -233,19 +234,10 @@ func (evi *Evidence) AssembleProteinReport(pro id.ProtIDList, weight float64, de
 }
 
 // MetaProteinReport creates the TSV Protein report
-func (eviProteins ProteinEvidenceList) MetaProteinReport(workspace, brand, decoyTag string, channels int, hasDecoys, hasRazor, uniqueOnly, hasLabels bool) {
+func (eviProteins ProteinEvidenceList) MetaProteinReport(workspace, decoyTag string, hasDecoys, hasRazor, uniqueOnly, hasLabels bool) *Table {
 
-	var header string
 	output := fmt.Sprintf("%s%sprotein.tsv", workspace, string(filepath.Separator))
 
-	// create result file
-	file, e := os.Create(output)
-	bw := bufio.NewWriter(file)
-	if e != nil {
-		msg.WriteFile(errors.New("cannot create protein report"), "error")
-	}
-	defer file.Close()
-	defer bw.Flush()
 	// building the printing set tat may or not contain decoys
 	var printSet []*ProteinEvidence
 	for idx, i := range eviProteins {
@@ -258,297 +250,115 @@ func (eviProteins ProteinEvidenceList) MetaProteinReport(workspace, brand, decoy
 		}
 	}
 
-	header = "Protein\tProtein ID\tEntry Name\tGene\tLength\tOrganism\tProtein Description\tProtein Existence\tProtein Probability\tTop Peptide Probability\tTotal Peptides\tUnique Peptides\tRazor Peptides\tTotal Spectral Count\tUnique Spectral Count\tRazor Spectral Count\tTotal Intensity\tUnique Intensity\tRazor Intensity\tRazor Assigned Modifications\tRazor Observed Modifications\tIndistinguishable Proteins"
-
-	if brand == "tmt" {
-		switch channels {
-		case 6:
-			header += "\tChannel 126\tChannel 127N\tChannel 128C\tChannel 129N\tChannel 130C\tChannel 131"
-		case 10:
-			header += "\tChannel 126\tChannel 127N\tChannel 127C\tChannel 128N\tChannel 128C\tChannel 129N\tChannel 129C\tChannel 130N\tChannel 130C\tChannel 131N"
-		case 11:
-			header += "\tChannel 126\tChannel 127N\tChannel 127C\tChannel 128N\tChannel 128C\tChannel 129N\tChannel 129C\tChannel 130N\tChannel 130C\tChannel 131N\tChannel 131C"
-		case 16:
-			header += "\tChannel 126\tChannel 127N\tChannel 127C\tChannel 128N\tChannel 128C\tChannel 129N\tChannel 129C\tChannel 130N\tChannel 130C\tChannel 131N\tChannel 131C\tChannel 132N\tChannel 132C\tChannel 133N\tChannel 133C\tChannel 134N"
-		case 18:
-			header += "\tChannel 126\tChannel 127N\tChannel 127C\tChannel 128N\tChannel 128C\tChannel 129N\tChannel 129C\tChannel 130N\tChannel 130C\tChannel 131N\tChannel 131C\tChannel 132N\tChannel 132C\tChannel 133N\tChannel 133C\tChannel 134N\tChannel 134C\tChannel 135N"
-		default:
-			header += ""
-		}
-	} else if brand == "itraq" {
-		switch channels {
-		case 4:
-			header += "\tChannel 114\tChannel 115\tChannel 116\tChannel 117"
-		case 8:
-			header += "\tChannel 113\tChannel 114\tChannel 115\tChannel 116\tChannel 117\tChannel 118\tChannel 119\tChannel 121"
-		default:
-			header += ""
+	// append decoy tags on the gene and proteinID names
+	for _, i := range printSet {
+		if i.IsDecoy {
+			i.ProteinID = decoyTag + i.ProteinID
+			i.GeneNames = decoyTag + i.GeneNames
+			i.EntryName = decoyTag + i.EntryName
 		}
 	}
 
-	header += "\n"
-
-	// verify if the structure has labels, if so, replace the original channel names by them.
-	if hasLabels {
-
-		var c1, c2, c3, c4, c5, c6, c7, c8, c9, c10, c11, c12, c13, c14, c15, c16, c17, c18 string
-
-		for _, i := range printSet {
-			if len(i.UniqueLabels.Channel1.CustomName) >= 1 {
-				c1 = i.UniqueLabels.Channel1.CustomName
-				c2 = i.UniqueLabels.Channel2.CustomName
-				c3 = i.UniqueLabels.Channel3.CustomName
-				c4 = i.UniqueLabels.Channel4.CustomName
-				c5 = i.UniqueLabels.Channel5.CustomName
-				c6 = i.UniqueLabels.Channel6.CustomName
-				c7 = i.UniqueLabels.Channel7.CustomName
-				c8 = i.UniqueLabels.Channel8.CustomName
-				c9 = i.UniqueLabels.Channel9.CustomName
-				c10 = i.UniqueLabels.Channel10.CustomName
-				c11 = i.UniqueLabels.Channel11.CustomName
-				c12 = i.UniqueLabels.Channel12.CustomName
-				c13 = i.UniqueLabels.Channel13.CustomName
-				c14 = i.UniqueLabels.Channel14.CustomName
-				c15 = i.UniqueLabels.Channel15.CustomName
-				c16 = i.UniqueLabels.Channel16.CustomName
-				c17 = i.UniqueLabels.Channel17.CustomName
-				c18 = i.UniqueLabels.Channel18.CustomName
+	// proteins with intensities from match-between-runs ions report the number of transferred ions and the absolute
+	// quantification is reported when the iBAQ was calculated
+	var hasTransfers, hasIBAQ bool
+	for _, i := range printSet {
+		for _, j := range i.TotalPeptideIons {
+			if j.IsTransferred {
+				hasTransfers = true
 				break
 			}
 		}
-
-		header = strings.Replace(header, "Channel "+printSet[10].UniqueLabels.Channel1.Name, c1, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].UniqueLabels.Channel2.Name, c2, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].UniqueLabels.Channel3.Name, c3, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].UniqueLabels.Channel4.Name, c4, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].UniqueLabels.Channel5.Name, c5, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].UniqueLabels.Channel6.Name, c6, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].UniqueLabels.Channel7.Name, c7, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].UniqueLabels.Channel8.Name, c8, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].UniqueLabels.Channel9.Name, c9, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].UniqueLabels.Channel10.Name, c10, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].UniqueLabels.Channel11.Name, c11, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].UniqueLabels.Channel12.Name, c12, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].UniqueLabels.Channel13.Name, c13, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].UniqueLabels.Channel14.Name, c14, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].UniqueLabels.Channel15.Name, c15, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].UniqueLabels.Channel16.Name, c16, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].UniqueLabels.Channel17.Name, c17, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].UniqueLabels.Channel18.Name, c18, -1)
-	}
-
-	_, e = io.WriteString(bw, header)
-	if e != nil {
-		msg.WriteToFile(e, "fatal")
+		if i.IBAQ > 0 {
+			hasIBAQ = true
+		}
 	}
 
-	for _, i := range printSet {
-
+	t := NewTable("protein", len(printSet))
+
+	t.Text("Protein", func(n int) string { return printSet[n].PartHeader })
+	t.Text("Protein ID", func(n int) string { return printSet[n].ProteinID })
+	t.Text("Entry Name", func(n int) string { return printSet[n].EntryName })
+	t.Text("Gene", func(n int) string { return printSet[n].GeneNames })
+	t.Integer("Length", func(n int) int { return printSet[n].Length })
+	t.Text("Organism", func(n int) string { return printSet[n].Organism })
+	t.Text("Protein Description", func(n int) string { return printSet[n].Description })
+	t.Text("Protein Existence", func(n int) string { return printSet[n].ProteinExistence })
+	t.Decimal("Protein Probability", "%.4f", func(n int) float64 { return printSet[n].Probability })
+	t.Decimal("Top Peptide Probability", "%.4f", func(n int) float64 { return printSet[n].TopPepProb })
+	t.Integer("Total Peptides", func(n int) int { return len(printSet[n].TotalPeptides) })
+	t.Integer("Unique Peptides", func(n int) int { return len(printSet[n].UniquePeptides) })
+	t.Integer("Razor Peptides", func(n int) int { return len(printSet[n].URazorPeptides) })
+	t.Integer("Total Spectral Count", func(n int) int { return printSet[n].TotalSpC })
+	t.Integer("Unique Spectral Count", func(n int) int { return printSet[n].UniqueSpC })
+	t.Integer("Razor Spectral Count", func(n int) int { return printSet[n].URazorSpC })
+	t.Decimal("Total Intensity", "%6.f", func(n int) float64 { return printSet[n].TotalIntensity })
+	t.Decimal("Unique Intensity", "%6.f", func(n int) float64 { return printSet[n].UniqueIntensity })
+	t.Decimal("Razor Intensity", "%6.f", func(n int) float64 { return printSet[n].URazorIntensity })
+	t.Text("Razor Assigned Modifications", func(n int) string {
+		assL, _ := getModsList(printSet[n].Modifications.ToMap().Index)
+		sort.Strings(assL)
+		return strings.Join(assL, ", ")
+	})
+	t.Text("Razor Observed Modifications", func(n int) string {
+		_, obs := getModsList(printSet[n].Modifications.ToMap().Index)
+		sort.Strings(obs)
+		return strings.Join(obs, ", ")
+	})
+	t.Text("Indistinguishable Proteins", func(n int) string {
 		var ip []string
-		for k := range i.IndiProtein {
+		for k := range printSet[n].IndiProtein {
 			ip = append(ip, k)
 		}
-
-		assL, obs := getModsList(i.Modifications.ToMap().Index)
-
-		sort.Strings(assL)
-		sort.Strings(obs)
 		sort.Strings(ip)
-
-		// change between Unique+Razor and Unique only based on parameter defined on labelquant
-		var reportIntensities [18]float64
-		if uniqueOnly || !hasRazor {
-			if i.UniqueLabels != nil {
-				reportIntensities[0] = i.UniqueLabels.Channel1.Intensity
-				reportIntensities[1] = i.UniqueLabels.Channel2.Intensity
-				reportIntensities[2] = i.UniqueLabels.Channel3.Intensity
-				reportIntensities[3] = i.UniqueLabels.Channel4.Intensity
-				reportIntensities[4] = i.UniqueLabels.Channel5.Intensity
-				reportIntensities[5] = i.UniqueLabels.Channel6.Intensity
-				reportIntensities[6] = i.UniqueLabels.Channel7.Intensity
-				reportIntensities[7] = i.UniqueLabels.Channel8.Intensity
-				reportIntensities[8] = i.UniqueLabels.Channel9.Intensity
-				reportIntensities[9] = i.UniqueLabels.Channel10.Intensity
-				reportIntensities[10] = i.UniqueLabels.Channel11.Intensity
-				reportIntensities[11] = i.UniqueLabels.Channel12.Intensity
-				reportIntensities[12] = i.UniqueLabels.Channel13.Intensity
-				reportIntensities[13] = i.UniqueLabels.Channel14.Intensity
-				reportIntensities[14] = i.UniqueLabels.Channel15.Intensity
-				reportIntensities[15] = i.UniqueLabels.Channel16.Intensity
-				reportIntensities[16] = i.UniqueLabels.Channel17.Intensity
-				reportIntensities[17] = i.UniqueLabels.Channel18.Intensity
-			}
-		} else {
-			if i.URazorLabels != nil {
-				reportIntensities[0] = i.URazorLabels.Channel1.Intensity
-				reportIntensities[1] = i.URazorLabels.Channel2.Intensity
-				reportIntensities[2] = i.URazorLabels.Channel3.Intensity
-				reportIntensities[3] = i.URazorLabels.Channel4.Intensity
-				reportIntensities[4] = i.URazorLabels.Channel5.Intensity
-				reportIntensities[5] = i.URazorLabels.Channel6.Intensity
-				reportIntensities[6] = i.URazorLabels.Channel7.Intensity
-				reportIntensities[7] = i.URazorLabels.Channel8.Intensity
-				reportIntensities[8] = i.URazorLabels.Channel9.Intensity
-				reportIntensities[9] = i.URazorLabels.Channel10.Intensity
-				reportIntensities[10] = i.URazorLabels.Channel11.Intensity
-				reportIntensities[11] = i.URazorLabels.Channel12.Intensity
-				reportIntensities[12] = i.URazorLabels.Channel13.Intensity
-				reportIntensities[13] = i.URazorLabels.Channel14.Intensity
-				reportIntensities[14] = i.URazorLabels.Channel15.Intensity
-				reportIntensities[15] = i.URazorLabels.Channel16.Intensity
-				reportIntensities[16] = i.URazorLabels.Channel17.Intensity
-				reportIntensities[17] = i.URazorLabels.Channel18.Intensity
+		return strings.Join(ip, ", ")
+	})
+
+	t.Integer("Match Between Runs Ions", func(n int) int {
+		var transferred int
+		for _, j := range printSet[n].TotalPeptideIons {
+			if j.IsTransferred {
+				transferred++
 			}
 		}
+		return transferred
+	}).Hide(!hasTransfers)
 
-		// append decoy tags on the gene and proteinID names
-		if i.IsDecoy {
-			i.ProteinID = decoyTag + i.ProteinID
-			i.GeneNames = decoyTag + i.GeneNames
-			i.EntryName = decoyTag + i.EntryName
-		}
+	t.Decimal("iBAQ", "%.4f", func(n int) float64 { return printSet[n].IBAQ }).Hide(!hasIBAQ)
+	t.Decimal("riBAQ", "%.8f", func(n int) float64 { return printSet[n].RIBAQ }).Hide(!hasIBAQ)
+	t.Decimal("Copy Number", "%.0f", func(n int) float64 { return printSet[n].CopyNumber }).Hide(!hasIBAQ)
 
-		// proteins with almost no evidences, and completely shared with decoys are eliminated from the analysis,
-		// in most cases proteins with one small peptide shared with a decoy
-		line := fmt.Sprintf("%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\t%.4f\t%.4f\t%d\t%d\t%d\t%d\t%d\t%d\t%6.f\t%6.f\t%6.f\t%s\t%s\t%s",
-			i.PartHeader,             // Protein
-			i.ProteinID,              // Protein ID
-			i.EntryName,              // Entry Name
-			i.GeneNames,              // Genes
-			i.Length,                 // Length
-			i.Organism,               // Organism
-			i.Description,            // Description
-			i.ProteinExistence,       // Protein Existence
-			i.Probability,            // Protein Probability
-			i.TopPepProb,             // Top Peptide Probability
-			len(i.TotalPeptides),     // Total Peptides
-			len(i.UniquePeptides),    // Unique Peptides
-			len(i.URazorPeptides),    // Razor Peptides
-			i.TotalSpC,               // Total Spectral Count
-			i.UniqueSpC,              // Unique Spectral Count
-			i.URazorSpC,              // Razor Spectral Count
-			i.TotalIntensity,         // Total Intensity
-			i.UniqueIntensity,        // Unique Intensity
-			i.URazorIntensity,        // Razor Intensity
-			strings.Join(assL, ", "), // Razor Assigned Modifications
-			strings.Join(obs, ", "),  // Razor Observed Modifications
-			strings.Join(ip, ", "),   // Indistinguishable Proteins
-		)
-
-		switch channels {
-		case 4:
-			line = fmt.Sprintf("%s\t%.4f\t%.4f\t%.4f\t%.4f",
-				line,
-				reportIntensities[0],
-				reportIntensities[1],
-				reportIntensities[2],
-				reportIntensities[3],
-			)
-		case 6:
-			line = fmt.Sprintf("%s\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f",
-				line,
-				reportIntensities[0],
-				reportIntensities[1],
-				reportIntensities[4],
-				reportIntensities[5],
-				reportIntensities[8],
-				reportIntensities[9],
-			)
-		case 8:
-			line = fmt.Sprintf("%s\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f",
-				line,
-				reportIntensities[0],
-				reportIntensities[1],
-				reportIntensities[2],
-				reportIntensities[3],
-				reportIntensities[4],
-				reportIntensities[5],
-				reportIntensities[6],
-				reportIntensities[7],
-			)
-		case 10:
-			line = fmt.Sprintf("%s\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f",
-				line,
-				reportIntensities[0],
-				reportIntensities[1],
-				reportIntensities[2],
-				reportIntensities[3],
-				reportIntensities[4],
-				reportIntensities[5],
-				reportIntensities[6],
-				reportIntensities[7],
-				reportIntensities[8],
-				reportIntensities[9],
-			)
-		case 11:
-			line = fmt.Sprintf("%s\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f",
-				line,
-				reportIntensities[0],
-				reportIntensities[1],
-				reportIntensities[2],
-				reportIntensities[3],
-				reportIntensities[4],
-				reportIntensities[5],
-				reportIntensities[6],
-				reportIntensities[7],
-				reportIntensities[8],
-				reportIntensities[9],
-				reportIntensities[10],
-			)
-		case 16:
-			line = fmt.Sprintf("%s\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f",
-				line,
-				reportIntensities[0],
-				reportIntensities[1],
-				reportIntensities[2],
-				reportIntensities[3],
-				reportIntensities[4],
-				reportIntensities[5],
-				reportIntensities[6],
-				reportIntensities[7],
-				reportIntensities[8],
-				reportIntensities[9],
-				reportIntensities[10],
-				reportIntensities[11],
-				reportIntensities[12],
-				reportIntensities[13],
-				reportIntensities[14],
-				reportIntensities[15],
-			)
-		case 18:
-			line = fmt.Sprintf("%s\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f",
-				line,
-				reportIntensities[0],
-				reportIntensities[1],
-				reportIntensities[2],
-				reportIntensities[3],
-				reportIntensities[4],
-				reportIntensities[5],
-				reportIntensities[6],
-				reportIntensities[7],
-				reportIntensities[8],
-				reportIntensities[9],
-				reportIntensities[10],
-				reportIntensities[11],
-				reportIntensities[12],
-				reportIntensities[13],
-				reportIntensities[14],
-				reportIntensities[15],
-				reportIntensities[16],
-				reportIntensities[17],
-			)
-		default:
-			header += ""
-		}
+	// the channels are taken from the labeled evidences, custom names replace the channel names when available
+	var labels []*iso.Labels
+	for _, i := range printSet {
+		labels = append(labels, i.UniqueLabels, i.URazorLabels)
+	}
 
-		line += "\n"
+	// change between Unique+Razor and Unique only based on parameter defined on labelquant
+	if ref := referenceLabels(labels, hasLabels); ref != nil {
+		t.channelFields(ref, hasLabels, func(n int) *iso.Labels {
+			if uniqueOnly || !hasRazor {
+				return printSet[n].UniqueLabels
+			}
+			return printSet[n].URazorLabels
+		})
+	}
 
-		_, e = io.WriteString(bw, line)
-		if e != nil {
-			msg.WriteToFile(e, "fatal")
-		}
+	// MS1 labeled partners
+	var ms1Labels []*MS1Labels
+	for _, i := range printSet {
+		ms1Labels = append(ms1Labels, i.MS1Labels)
+	}
+
+	if ms1Ref := ms1LabelReference(ms1Labels); ms1Ref != nil {
+		t.ms1LabelFields(ms1Ref, false, func(n int) *MS1Labels { return printSet[n].MS1Labels })
+	}
 
+	if e := t.WriteTSV(output); e != nil {
+		msg.WriteToFile(e, "fatal")
 	}
+
+	return t
 }
 
 // ProteinFastaReport saves to disk a filtered FASTA file with FDR aproved proteins
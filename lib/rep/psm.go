@@ -1,11 +1,8 @@
 package rep
 
 import (
-	"bufio"
 	"errors"
 	"fmt"
-	"io"
-	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
@@ -18,6 +15,7 @@ import (
 	"philosopher/lib/cla"
 	"philosopher/lib/dat"
 	"philosopher/lib/id"
+	"philosopher/lib/iso"
 )
 
 // AssemblePSMReport creates the PSM structure for reporting
@@ -129,28 +127,19 @@ func (evi *Evidence) AssemblePSMReport(pep id.PepIDList, decoyTag string) {
 }
 
 // MetaPSMReport report all psms from study that passed the FDR filter
-func (evi PSMEvidenceList) MetaPSMReport(workspace, brand, decoyTag string, channels int, hasDecoys, isComet, hasLoc, hasIonMob, hasLabels bool) {
-	var header string
+func (evi PSMEvidenceList) MetaPSMReport(workspace, decoyTag string, hasDecoys, isComet, hasLoc, hasIonMob, hasLabels bool) *Table {
+
 	var modMap = make(map[string]string)
 	var modList []string
 	var hasCompVolt bool
 	var hasPurity bool
 	var hasSpectralSim bool
 	var hasRtScore bool
+	var hasAlignment bool
 
 	output := fmt.Sprintf("%s%spsm.tsv", workspace, string(filepath.Separator))
 
-	// create result file
-	file, e := os.Create(output)
-	bw := bufio.NewWriter(file)
-	if e != nil {
-		msg.WriteFile(errors.New("cannot create report file, "+e.Error()), "fatal")
-	}
-	defer file.Close()
-	defer bw.Flush()
-
 	// building the printing set tat may or not contain decoys
-	//var printSet PSMEvidenceList
 	var printSet []*PSMEvidence
 	for i := range evi {
 
@@ -164,12 +153,7 @@ func (evi PSMEvidenceList) MetaPSMReport(workspace, brand, decoyTag string, chan
 
 		if evi[i].PTM != nil {
 			for k := range evi[i].PTM.LocalizedPTMMassDiff {
-				_, ok := modMap[k]
-				if !ok {
-					modMap[k] = ""
-				} else {
-					modMap[k] = ""
-				}
+				modMap[k] = ""
 			}
 		}
 
@@ -185,6 +169,10 @@ func (evi PSMEvidenceList) MetaPSMReport(workspace, brand, decoyTag string, chan
 			hasPurity = true
 		}
 
+		if evi[i].AlignedRetentionTime > 0 {
+			hasAlignment = true
+		}
+
 		if evi[i].MSFraggerLoc != nil && len(evi[i].MSFraggerLoc.MSFragerLocalization) > 0 {
 			hasLoc = true
 		}
@@ -205,459 +193,199 @@ func (evi PSMEvidenceList) MetaPSMReport(workspace, brand, decoyTag string, chan
 
 	sort.Strings(modList)
 
-	header = "Spectrum\tSpectrum File\tPeptide\tModified Peptide\tPrev AA\tNext AA\tPeptide Length\tCharge\tRetention\tObserved Mass\tCalibrated Observed Mass\tObserved M/Z\tCalibrated Observed M/Z\tCalculated Peptide Mass\tCalculated M/Z\tDelta Mass"
-
-	if isComet {
-		header += "\tXCorr\tDeltaCN\tDeltaCNStar\tSPScore\tSPRank"
-	}
-
-	if hasSpectralSim {
-		header += "\tSpectralSim"
-	}
-
-	if hasRtScore {
-		header += "\tRTScore"
-	}
-
-	header += "\tExpectation\tHyperscore\tNextscore\tPeptideProphet Probability\tNumber of Enzymatic Termini\tNumber of Missed Cleavages\tProtein Start\tProtein End\tIntensity\tAssigned Modifications\tObserved Modifications"
-
-	if len(modList) > 0 {
-		for _, i := range modList {
-			if strings.Contains(i, "STY:79.966331") {
-				i = "STY:79.9663"
-			}
-			header += "\t" + i + "\t" + i + " Best Localization"
+	// append decoy tags on the gene and proteinID names
+	for _, i := range printSet {
+		if i.IsDecoy {
+			i.ProteinID = decoyTag + i.ProteinID
+			i.GeneName = decoyTag + i.GeneName
+			i.EntryName = decoyTag + i.EntryName
 		}
 	}
 
-	if hasLoc {
-		header += "\tMSFragger Localization\tBest Score with Delta Mass\tBest Score without Delta Mass"
-	}
-
-	if hasIonMob {
-		header += "\tIon Mobility"
+	mz := func(mass float64, charge uint8) float64 {
+		return (mass + (float64(charge) * bio.Proton)) / float64(charge)
 	}
 
-	if hasCompVolt {
-		header += "\tCompensation Voltage"
-	}
+	t := NewTable("psm", len(printSet))
+
+	t.Text("Spectrum", func(n int) string { return printSet[n].Spectrum })
+	t.Text("Spectrum File", func(n int) string { return printSet[n].SpectrumFile })
+	t.Text("Peptide", func(n int) string { return printSet[n].Peptide })
+	t.Text("Modified Peptide", func(n int) string { return printSet[n].ModifiedPeptide })
+	t.Text("Prev AA", func(n int) string { return string(printSet[n].PrevAA) })
+	t.Text("Next AA", func(n int) string { return string(printSet[n].NextAA) })
+	t.Integer("Peptide Length", func(n int) int { return len(printSet[n].Peptide) })
+	t.Integer("Charge", func(n int) int { return int(printSet[n].AssumedCharge) })
+	t.Decimal("Retention", "%.4f", func(n int) float64 { return printSet[n].RetentionTime })
+	t.Decimal("Observed Mass", "%.4f", func(n int) float64 { return printSet[n].UncalibratedPrecursorNeutralMass })
+	t.Decimal("Calibrated Observed Mass", "%.4f", func(n int) float64 { return printSet[n].PrecursorNeutralMass })
+	t.Decimal("Observed M/Z", "%.4f", func(n int) float64 {
+		return mz(printSet[n].UncalibratedPrecursorNeutralMass, printSet[n].AssumedCharge)
+	})
+	t.Decimal("Calibrated Observed M/Z", "%.4f", func(n int) float64 {
+		return mz(printSet[n].PrecursorNeutralMass, printSet[n].AssumedCharge)
+	})
+	t.Decimal("Calculated Peptide Mass", "%.4f", func(n int) float64 { return printSet[n].CalcNeutralPepMass })
+	t.Decimal("Calculated M/Z", "%.4f", func(n int) float64 { return mz(printSet[n].CalcNeutralPepMass, printSet[n].AssumedCharge) })
+	t.Decimal("Delta Mass", "%.4f", func(n int) float64 { return printSet[n].Massdiff })
+
+	t.Decimal("XCorr", "%.4f", func(n int) float64 { return printSet[n].Xcorr }).Hide(!isComet)
+	t.Decimal("DeltaCN", "%.4f", func(n int) float64 { return printSet[n].DeltaCN }).Hide(!isComet)
+	t.Decimal("DeltaCNStar", "%.4f", func(n int) float64 { return printSet[n].DeltaCNStar }).Hide(!isComet)
+	t.Decimal("SPScore", "%.4f", func(n int) float64 { return printSet[n].SPScore }).Hide(!isComet)
+	t.Decimal("SPRank", "%.4f", func(n int) float64 { return printSet[n].SPRank }).Hide(!isComet)
+	t.Decimal("SpectralSim", "%.4f", func(n int) float64 { return printSet[n].SpectralSim }).Hide(!hasSpectralSim)
+	t.Decimal("RTScore", "%.4f", func(n int) float64 { return printSet[n].Rtscore }).Hide(!hasRtScore)
+
+	t.Decimal("Expectation", "%.14f", func(n int) float64 { return printSet[n].Expectation })
+	t.Decimal("Hyperscore", "%.4f", func(n int) float64 { return printSet[n].Hyperscore })
+	t.Decimal("Nextscore", "%.4f", func(n int) float64 { return printSet[n].Nextscore })
+	t.Decimal("PeptideProphet Probability", "%.4f", func(n int) float64 { return printSet[n].Probability })
+	t.Integer("Number of Enzymatic Termini", func(n int) int { return int(printSet[n].NumberOfEnzymaticTermini) })
+	t.Integer("Number of Missed Cleavages", func(n int) int { return int(printSet[n].NumberOfMissedCleavages) })
+	t.Integer("Protein Start", func(n int) int { return printSet[n].ProteinStart })
+	t.Integer("Protein End", func(n int) int { return printSet[n].ProteinEnd })
+	t.Decimal("Intensity", "%.4f", func(n int) float64 { return printSet[n].Intensity })
+	t.Text("Assigned Modifications", func(n int) string {
+		assL, _ := getModsList(printSet[n].Modifications.ToMap().Index)
+		sort.Strings(assL)
+		return strings.Join(assL, ", ")
+	})
+	t.Text("Observed Modifications", func(n int) string {
+		_, obs := getModsList(printSet[n].Modifications.ToMap().Index)
+		sort.Strings(obs)
+		return strings.Join(obs, ", ")
+	})
 
-	if hasPurity {
-		header += "\tPurity"
-	}
+	r := regexp.MustCompile(`\d\.\d{3}`)
+	for _, j := range modList {
 
-	header += "\tIs Unique\tProtein\tProtein ID\tEntry Name\tGene\tProtein Description\tMapped Genes\tMapped Proteins"
-
-	if brand == "tmt" {
-		switch channels {
-		case 6:
-			header += "\tQuan Usage\tChannel 126\tChannel 127N\tChannel 128C\tChannel 129N\tChannel 130C\tChannel 131N"
-		case 10:
-			header += "\tQuan Usage\tChannel 126\tChannel 127N\tChannel 127C\tChannel 128N\tChannel 128C\tChannel 129N\tChannel 129C\tChannel 130N\tChannel 130C\tChannel 131N"
-		case 11:
-			header += "\tQuan Usage\tChannel 126\tChannel 127N\tChannel 127C\tChannel 128N\tChannel 128C\tChannel 129N\tChannel 129C\tChannel 130N\tChannel 130C\tChannel 131N\tChannel 131C"
-		case 16:
-			header += "\tQuan Usage\tChannel 126\tChannel 127N\tChannel 127C\tChannel 128N\tChannel 128C\tChannel 129N\tChannel 129C\tChannel 130N\tChannel 130C\tChannel 131N\tChannel 131C\tChannel 132N\tChannel 132C\tChannel 133N\tChannel 133C\tChannel 134N"
-		case 18:
-			header += "\tQuan Usage\tChannel 126\tChannel 127N\tChannel 127C\tChannel 128N\tChannel 128C\tChannel 129N\tChannel 129C\tChannel 130N\tChannel 130C\tChannel 131N\tChannel 131C\tChannel 132N\tChannel 132C\tChannel 133N\tChannel 133C\tChannel 134N\tChannel 134C\tChannel 135N"
-		default:
-			header += ""
-		}
-	} else if brand == "itraq" {
-		switch channels {
-		case 4:
-			header += "\tQuan Usage\tChannel 114\tChannel 115\tChannel 116\tChannel 117"
-		case 8:
-			header += "\tQuan Usage\tChannel 113\tChannel 114\tChannel 115\tChannel 116\tChannel 117\tChannel 118\tChannel 119\tChannel 121"
-		default:
-			header += ""
+		name := j
+		if strings.Contains(name, "STY:79.966331") {
+			name = "STY:79.9663"
 		}
-	}
 
-	header += "\n"
-
-	// verify if the structure has labels, if so, replace the original channel names by them.
-	if hasLabels {
-
-		var c1, c2, c3, c4, c5, c6, c7, c8, c9, c10, c11, c12, c13, c14, c15, c16, c17, c18 string
-
-		for _, i := range printSet {
-			if len(i.Labels.Channel1.CustomName) >= 1 {
-				c1 = i.Labels.Channel1.CustomName
-				c2 = i.Labels.Channel2.CustomName
-				c3 = i.Labels.Channel3.CustomName
-				c4 = i.Labels.Channel4.CustomName
-				c5 = i.Labels.Channel5.CustomName
-				c6 = i.Labels.Channel6.CustomName
-				c7 = i.Labels.Channel7.CustomName
-				c8 = i.Labels.Channel8.CustomName
-				c9 = i.Labels.Channel9.CustomName
-				c10 = i.Labels.Channel10.CustomName
-				c11 = i.Labels.Channel11.CustomName
-				c12 = i.Labels.Channel12.CustomName
-				c13 = i.Labels.Channel13.CustomName
-				c14 = i.Labels.Channel14.CustomName
-				c15 = i.Labels.Channel15.CustomName
-				c16 = i.Labels.Channel16.CustomName
-				c17 = i.Labels.Channel17.CustomName
-				c18 = i.Labels.Channel18.CustomName
-				break
+		j := j
+		t.Text(name, func(n int) string {
+			if printSet[n].PTM == nil {
+				return ""
 			}
-		}
-
-		header = strings.Replace(header, "Channel "+printSet[10].Labels.Channel1.Name, c1, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].Labels.Channel2.Name, c2, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].Labels.Channel3.Name, c3, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].Labels.Channel4.Name, c4, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].Labels.Channel5.Name, c5, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].Labels.Channel6.Name, c6, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].Labels.Channel7.Name, c7, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].Labels.Channel8.Name, c8, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].Labels.Channel9.Name, c9, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].Labels.Channel10.Name, c10, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].Labels.Channel11.Name, c11, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].Labels.Channel12.Name, c12, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].Labels.Channel13.Name, c13, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].Labels.Channel14.Name, c14, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].Labels.Channel15.Name, c15, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].Labels.Channel16.Name, c16, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].Labels.Channel17.Name, c17, -1)
-		header = strings.Replace(header, "Channel "+printSet[10].Labels.Channel18.Name, c18, -1)
-	}
-
-	_, e = io.WriteString(bw, header)
-	if e != nil {
-		msg.WriteToFile(errors.New("cannot print PSM to file"), "fatal")
+			return printSet[n].PTM.LocalizedPTMMassDiff[j]
+		})
+		t.Text(name+" Best Localization", func(n int) string {
+			var matches []string
+			if printSet[n].PTM != nil {
+				matches = r.FindAllString(printSet[n].PTM.LocalizedPTMMassDiff[j], -1)
+			}
+			return uti.GetMaxNumber(matches)
+		})
 	}
 
-	for _, i := range printSet {
-
-		assL, obs := getModsList(i.Modifications.ToMap().Index)
-
-		var mappedProteins []string
-		for j := range i.MappedProteins {
-			if j != i.Protein && len(j) > 0 {
-				mappedProteins = append(mappedProteins, j)
-			}
+	localization := func(n int) *id.MSFraggerLoc {
+		if printSet[n].MSFraggerLoc == nil {
+			return &id.MSFraggerLoc{}
 		}
-
+		return printSet[n].MSFraggerLoc
+	}
+	t.Text("MSFragger Localization", func(n int) string { return localization(n).MSFragerLocalization }).Hide(!hasLoc)
+	t.Text("Best Score with Delta Mass", func(n int) string { return localization(n).MSFraggerLocalizationScoreWithPTM }).Hide(!hasLoc)
+	t.Text("Best Score without Delta Mass", func(n int) string { return localization(n).MSFraggerLocalizationScoreWithoutPTM }).Hide(!hasLoc)
+
+	t.Decimal("Ion Mobility", "%.4f", func(n int) float64 { return printSet[n].IonMobility }).Hide(!hasIonMob)
+	t.Text("Compensation Voltage", func(n int) string { return printSet[n].CompensationVoltage }).Hide(!hasCompVolt)
+	t.Decimal("Purity", "%.2f", func(n int) float64 { return printSet[n].Purity }).Hide(!hasPurity)
+	t.Decimal("Aligned Retention", "%.4f", func(n int) float64 { return printSet[n].AlignedRetentionTime }).Hide(!hasAlignment)
+
+	t.Flag("Is Unique", func(n int) bool { return printSet[n].IsUnique })
+	t.Text("Protein", func(n int) string { return printSet[n].Protein })
+	t.Text("Protein ID", func(n int) string { return printSet[n].ProteinID })
+	t.Text("Entry Name", func(n int) string { return printSet[n].EntryName })
+	t.Text("Gene", func(n int) string { return printSet[n].GeneName })
+	t.Text("Protein Description", func(n int) string { return printSet[n].ProteinDescription })
+	t.Text("Mapped Genes", func(n int) string {
 		var mappedGenes []string
-		for j := range i.MappedGenes {
-			if j != i.GeneName && len(j) > 0 {
+		for j := range printSet[n].MappedGenes {
+			if j != printSet[n].GeneName && len(j) > 0 {
 				mappedGenes = append(mappedGenes, j)
 			}
 		}
-
 		sort.Strings(mappedGenes)
-		sort.Strings(mappedProteins)
-		sort.Strings(assL)
-		sort.Strings(obs)
-
-		// append decoy tags on the gene and proteinID names
-		if i.IsDecoy {
-			i.ProteinID = decoyTag + i.ProteinID
-			i.GeneName = decoyTag + i.GeneName
-			i.EntryName = decoyTag + i.EntryName
-		}
-
-		line := fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%s\t%d\t%d\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f",
-			i.Spectrum,
-			i.SpectrumFile,
-			i.Peptide,
-			i.ModifiedPeptide,
-			string(i.PrevAA),
-			string(i.NextAA),
-			len(i.Peptide),
-			i.AssumedCharge,
-			i.RetentionTime,
-			i.UncalibratedPrecursorNeutralMass,
-			i.PrecursorNeutralMass,
-			((i.UncalibratedPrecursorNeutralMass + (float64(i.AssumedCharge) * bio.Proton)) / float64(i.AssumedCharge)),
-			((i.PrecursorNeutralMass + (float64(i.AssumedCharge) * bio.Proton)) / float64(i.AssumedCharge)),
-			i.CalcNeutralPepMass,
-			((i.CalcNeutralPepMass + (float64(i.AssumedCharge) * bio.Proton)) / float64(i.AssumedCharge)),
-			i.Massdiff,
-		)
-
-		if isComet {
-			line = fmt.Sprintf("%s\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f",
-				line,
-				i.Xcorr,
-				i.DeltaCN,
-				i.DeltaCNStar,
-				i.SPScore,
-				i.SPRank,
-			)
-		}
-
-		if hasSpectralSim {
-			line = fmt.Sprintf("%s\t%.4f",
-				line,
-				i.SpectralSim,
-			)
-		}
-
-		if hasRtScore {
-			line = fmt.Sprintf("%s\t%.4f",
-				line,
-				i.Rtscore,
-			)
-		}
-
-		line = fmt.Sprintf("%s\t%.14f\t%.4f\t%.4f\t%.4f\t%d\t%d\t%d\t%d\t%.4f\t%s\t%s",
-			line,
-			i.Expectation,
-			i.Hyperscore,
-			i.Nextscore,
-			i.Probability,
-			i.NumberOfEnzymaticTermini,
-			i.NumberOfMissedCleavages,
-			i.ProteinStart,
-			i.ProteinEnd,
-			i.Intensity,
-			strings.Join(assL, ", "),
-			strings.Join(obs, ", "),
-		)
-
-		if len(modList) > 0 {
-			for _, j := range modList {
-
-				r := regexp.MustCompile(`\d\.\d{3}`)
-				PTM := i.PTM
-				if PTM == nil {
-					PTM = &id.PTM{LocalizedPTMSites: map[string]int{}, LocalizedPTMMassDiff: map[string]string{}}
-				}
-				matches := r.FindAllString(PTM.LocalizedPTMMassDiff[j], -1)
-				max := uti.GetMaxNumber(matches)
-
-				line = fmt.Sprintf("%s\t%s\t%s",
-					line,
-					PTM.LocalizedPTMMassDiff[j],
-					max,
-				)
-			}
-		}
-
-		if hasLoc {
-			MSFraggerLoc := i.MSFraggerLoc
-			if MSFraggerLoc == nil {
-				MSFraggerLoc = &id.MSFraggerLoc{}
+		return strings.Join(mappedGenes, ", ")
+	})
+	t.Text("Mapped Proteins", func(n int) string {
+		var mappedProteins []string
+		for j := range printSet[n].MappedProteins {
+			if j != printSet[n].Protein && len(j) > 0 {
+				mappedProteins = append(mappedProteins, j)
 			}
-			line = fmt.Sprintf("%s\t%s\t%s\t%s",
-				line,
-				MSFraggerLoc.MSFragerLocalization,
-				MSFraggerLoc.MSFraggerLocalizationScoreWithPTM,
-				MSFraggerLoc.MSFraggerLocalizationScoreWithoutPTM)
-		}
-
-		if hasIonMob {
-			line = fmt.Sprintf("%s\t%.4f",
-				line,
-				i.IonMobility,
-			)
 		}
+		sort.Strings(mappedProteins)
+		return strings.Join(mappedProteins, ", ")
+	})
 
-		if hasCompVolt {
-			line = fmt.Sprintf("%s\t%s",
-				line,
-				i.CompensationVoltage,
-			)
-		}
+	// the channels are taken from the labeled evidences, custom names replace the channel names when available
+	var labels []*iso.Labels
+	for _, i := range printSet {
+		labels = append(labels, i.Labels)
+	}
+	ref := referenceLabels(labels, hasLabels)
 
-		if hasPurity {
-			line = fmt.Sprintf("%s\t%.2f",
-				line,
-				i.Purity,
-			)
-		}
+	if ref != nil {
+		t.Flag("Quan Usage", func(n int) bool { return printSet[n].Labels != nil && printSet[n].Labels.IsUsed })
+		t.channelFields(ref, hasLabels, func(n int) *iso.Labels { return printSet[n].Labels })
+	}
 
-		line = fmt.Sprintf("%s\t%t\t%s\t%s\t%s\t%s\t%s\t%s\t%s",
-			line,
-			i.IsUnique,
-			i.Protein,
-			i.ProteinID,
-			i.EntryName,
-			i.GeneName,
-			i.ProteinDescription,
-			strings.Join(mappedGenes, ", "),
-			strings.Join(mappedProteins, ", "),
-		)
-
-		if brand == "tmt" {
-			switch channels {
-			case 6:
-				line = fmt.Sprintf("%s\t%t\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f",
-					line,
-					i.Labels.IsUsed,
-					i.Labels.Channel1.Intensity,
-					i.Labels.Channel2.Intensity,
-					i.Labels.Channel5.Intensity,
-					i.Labels.Channel6.Intensity,
-					i.Labels.Channel9.Intensity,
-					i.Labels.Channel10.Intensity,
-				)
-			case 10:
-				line = fmt.Sprintf("%s\t%t\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f",
-					line,
-					i.Labels.IsUsed,
-					i.Labels.Channel1.Intensity,
-					i.Labels.Channel2.Intensity,
-					i.Labels.Channel3.Intensity,
-					i.Labels.Channel4.Intensity,
-					i.Labels.Channel5.Intensity,
-					i.Labels.Channel6.Intensity,
-					i.Labels.Channel7.Intensity,
-					i.Labels.Channel8.Intensity,
-					i.Labels.Channel9.Intensity,
-					i.Labels.Channel10.Intensity,
-				)
-			case 11:
-				line = fmt.Sprintf("%s\t%t\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f",
-					line,
-					i.Labels.IsUsed,
-					i.Labels.Channel1.Intensity,
-					i.Labels.Channel2.Intensity,
-					i.Labels.Channel3.Intensity,
-					i.Labels.Channel4.Intensity,
-					i.Labels.Channel5.Intensity,
-					i.Labels.Channel6.Intensity,
-					i.Labels.Channel7.Intensity,
-					i.Labels.Channel8.Intensity,
-					i.Labels.Channel9.Intensity,
-					i.Labels.Channel10.Intensity,
-					i.Labels.Channel11.Intensity,
-				)
-			case 16:
-				line = fmt.Sprintf("%s\t%t\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f",
-					line,
-					i.Labels.IsUsed,
-					i.Labels.Channel1.Intensity,
-					i.Labels.Channel2.Intensity,
-					i.Labels.Channel3.Intensity,
-					i.Labels.Channel4.Intensity,
-					i.Labels.Channel5.Intensity,
-					i.Labels.Channel6.Intensity,
-					i.Labels.Channel7.Intensity,
-					i.Labels.Channel8.Intensity,
-					i.Labels.Channel9.Intensity,
-					i.Labels.Channel10.Intensity,
-					i.Labels.Channel11.Intensity,
-					i.Labels.Channel12.Intensity,
-					i.Labels.Channel13.Intensity,
-					i.Labels.Channel14.Intensity,
-					i.Labels.Channel15.Intensity,
-					i.Labels.Channel16.Intensity,
-				)
-			case 18:
-				line = fmt.Sprintf("%s\t%t\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f",
-					line,
-					i.Labels.IsUsed,
-					i.Labels.Channel1.Intensity,
-					i.Labels.Channel2.Intensity,
-					i.Labels.Channel3.Intensity,
-					i.Labels.Channel4.Intensity,
-					i.Labels.Channel5.Intensity,
-					i.Labels.Channel6.Intensity,
-					i.Labels.Channel7.Intensity,
-					i.Labels.Channel8.Intensity,
-					i.Labels.Channel9.Intensity,
-					i.Labels.Channel10.Intensity,
-					i.Labels.Channel11.Intensity,
-					i.Labels.Channel12.Intensity,
-					i.Labels.Channel13.Intensity,
-					i.Labels.Channel14.Intensity,
-					i.Labels.Channel15.Intensity,
-					i.Labels.Channel16.Intensity,
-					i.Labels.Channel17.Intensity,
-					i.Labels.Channel18.Intensity,
-				)
-			default:
-				header += ""
-			}
-		} else if brand == "itraq" {
-			switch channels {
-			case 4:
-				line = fmt.Sprintf("%s\t%t\t%.4f\t%.4f\t%.4f\t%.4f",
-					line,
-					i.Labels.IsUsed,
-					i.Labels.Channel1.Intensity,
-					i.Labels.Channel2.Intensity,
-					i.Labels.Channel3.Intensity,
-					i.Labels.Channel4.Intensity,
-				)
-			case 8:
-				line = fmt.Sprintf("%s\t%t\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f",
-					line,
-					i.Labels.IsUsed,
-					i.Labels.Channel1.Intensity,
-					i.Labels.Channel2.Intensity,
-					i.Labels.Channel3.Intensity,
-					i.Labels.Channel4.Intensity,
-					i.Labels.Channel5.Intensity,
-					i.Labels.Channel6.Intensity,
-					i.Labels.Channel7.Intensity,
-					i.Labels.Channel8.Intensity,
-				)
-			default:
-				header += ""
-			}
-		}
+	// MS1 labeled partners
+	var ms1Labels []*MS1Labels
+	for _, i := range printSet {
+		ms1Labels = append(ms1Labels, i.MS1Labels)
+	}
+	ms1Ref := ms1LabelReference(ms1Labels)
 
-		line += "\n"
+	if ms1Ref != nil {
+		t.ms1LabelFields(ms1Ref, true, func(n int) *MS1Labels { return printSet[n].MS1Labels })
+	}
 
-		_, e = io.WriteString(bw, line)
-		if e != nil {
-			msg.WriteToFile(e, "fatal")
-		}
+	if e := t.WriteTSV(output); e != nil {
+		msg.WriteFile(errors.New("cannot create report file, "+e.Error()), "fatal")
 	}
+
+	return t
 }
 
 // PSMLocalizationReport report ptm localization based on PTMProphet outputs
-func (evi *Evidence) PSMLocalizationReport(workspace, decoyTag string, hasRazor, hasDecoys bool) {
+func (evi *Evidence) PSMLocalizationReport(workspace, decoyTag string, hasRazor, hasDecoys bool) *Table {
 
 	output := fmt.Sprintf("%s%slocalization.tsv", workspace, string(filepath.Separator))
 
-	// create result file
-	file, e := os.Create(output)
-	if e != nil {
-		msg.WriteFile(e, "fatal")
-	}
-	defer file.Close()
-
-	_, e = io.WriteString(file, "Spectrum\tPeptide\tModified Peptide\tCharge\tRetention\tModification\tNumber of Sites\tObserved Mass Localization\n")
-	if e != nil {
-		msg.WriteToFile(e, "fatal")
+	// a row per localized modification, the printing set may or not contain decoys
+	type localization struct {
+		psm  PSMEvidence
+		mass string
 	}
 
-	// building the printing set tat may or not contain decoys
-	var printSet PSMEvidenceList
+	var printSet []localization
 	for _, i := range evi.PSM {
-		if !hasDecoys {
-			if !i.IsDecoy {
-				printSet = append(printSet, i)
-			}
-		} else {
-			printSet = append(printSet, i)
-		}
-	}
-	for _, i := range printSet {
-		if i.PTM != nil {
+		if (hasDecoys || !i.IsDecoy) && i.PTM != nil {
 			for j := range i.PTM.LocalizedPTMMassDiff {
-				line := fmt.Sprintf("%s\t%s\t%s\t%d\t%.4f\t%s\t%d\t%s\n",
-					i.SpectrumFileName().Str(),
-					i.Peptide,
-					i.ModifiedPeptide,
-					i.AssumedCharge,
-					i.RetentionTime,
-					j,
-					i.PTM.LocalizedPTMSites[j],
-					i.PTM.LocalizedPTMMassDiff[j],
-				)
-				_, e = io.WriteString(file, line)
-				if e != nil {
-					msg.WriteToFile(e, "fatal")
-				}
+				printSet = append(printSet, localization{i, j})
 			}
 		}
 	}
+
+	t := NewTable("localization", len(printSet))
+	t.Text("Spectrum", func(n int) string { return printSet[n].psm.SpectrumFileName().Str() })
+	t.Text("Peptide", func(n int) string { return printSet[n].psm.Peptide })
+	t.Text("Modified Peptide", func(n int) string { return printSet[n].psm.ModifiedPeptide })
+	t.Integer("Charge", func(n int) int { return int(printSet[n].psm.AssumedCharge) })
+	t.Decimal("Retention", "%.4f", func(n int) float64 { return printSet[n].psm.RetentionTime })
+	t.Text("Modification", func(n int) string { return printSet[n].mass })
+	t.Integer("Number of Sites", func(n int) int { return printSet[n].psm.PTM.LocalizedPTMSites[printSet[n].mass] })
+	t.Text("Observed Mass Localization", func(n int) string { return printSet[n].psm.PTM.LocalizedPTMMassDiff[printSet[n].mass] })
+
+	if e := t.WriteTSV(output); e != nil {
+		msg.WriteFile(e, "fatal")
+	}
+
+	return t
 }
@@ -0,0 +1,685 @@
+package rep
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"philosopher/lib/iso"
+	"philosopher/lib/met"
+	"philosopher/lib/msg"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+	"gonum.org/v1/plot/vg/vgsvg"
+)
+
+// qcPanel is a section of the QC dashboard, a plot and an optional table
+type qcPanel struct {
+	Title  string
+	Note   string
+	SVG    template.HTML
+	Header []string
+	Rows   [][]string
+}
+
+// qcDashboard holds the workspace summary and the QC panels
+type qcDashboard struct {
+	Project string
+	UUID    string
+	Version string
+	Created string
+	Panels  []qcPanel
+}
+
+// qcCounts are the target and decoy counts of an evidence level
+type qcCounts struct {
+	Level   string
+	Targets int
+	Decoys  int
+}
+
+const (
+	// qcMassError is the precursor mass error window of the QC dashboard, in ppm
+	qcMassError = 50
+	// qcIsotopeSpacing is the mass difference between the precursor isotopes
+	qcIsotopeSpacing = 1.0033548
+)
+
+// qcTemplate renders the dashboard as a single HTML file with inline SVG plots
+var qcTemplate = template.Must(template.New("qc").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Philosopher QC - {{.Project}}</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.6em; margin-bottom: 0.2em; }
+.meta { color: #666; margin-bottom: 2em; }
+.panel { border: 1px solid #ddd; border-radius: 4px; padding: 1em; margin-bottom: 1.5em; }
+.panel h2 { font-size: 1.2em; margin-top: 0; }
+.note { color: #666; font-style: italic; }
+svg { max-width: 100%; height: auto; }
+table { border-collapse: collapse; margin-top: 1em; }
+th, td { border: 1px solid #ddd; padding: 0.3em 0.8em; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+th { background: #f4f4f4; }
+</style>
+</head>
+<body>
+<h1>Philosopher QC - {{.Project}}</h1>
+<div class="meta">Workspace {{.UUID}} &middot; Philosopher {{.Version}} &middot; {{.Created}}</div>
+{{range .Panels}}<div class="panel">
+<h2>{{.Title}}</h2>
+{{if .Note}}<p class="note">{{.Note}}</p>
+{{end}}{{.SVG}}
+{{if .Header}}<table>
+<tr>{{range .Header}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+{{end}}</div>
+{{end}}</body>
+</html>
+`))
+
+// QCReport writes qc.html, a self-contained dashboard with the identification and quantification summaries
+func QCReport(m met.Data) {
+
+	var e Evidence
+	RestorePSM(&e.PSM)
+	RestoreIon(&e.Ions)
+	RestorePeptide(&e.Peptides)
+
+	if len(m.Filter.Pox) > 0 || m.Filter.Inference {
+		RestoreProtein(&e.Proteins)
+	}
+
+	if m.Filter.Gene {
+		RestoreGene(&e.Genes)
+	}
+
+	dashboard := newQCDashboard(m, e)
+
+	output := fmt.Sprintf("%s%sqc.html", m.Home, string(filepath.Separator))
+	if err := dashboard.write(output); err != nil {
+		msg.WriteFile(errors.New("cannot create the QC dashboard, "+err.Error()), "fatal")
+	}
+}
+
+// write renders the dashboard to a file
+func (d qcDashboard) write(output string) error {
+
+	file, e := os.Create(output)
+	if e != nil {
+		return e
+	}
+	defer file.Close()
+
+	return qcTemplate.Execute(file, d)
+}
+
+// newQCDashboard builds the QC panels from the evidences
+func newQCDashboard(m met.Data, e Evidence) qcDashboard {
+
+	d := qcDashboard{
+		Project: m.ProjectName,
+		UUID:    m.UUID,
+		Version: m.Version,
+		Created: time.Now().Format("2006-01-02 15:04"),
+	}
+
+	var targets []PSMEvidence
+	for _, i := range e.PSM {
+		if !i.IsDecoy {
+			targets = append(targets, i)
+		}
+	}
+
+	d.Panels = append(d.Panels,
+		qcIdentifications(e),
+		qcRuns(targets),
+		qcFDR(e.PSM, m.Filter.PsmFDR),
+		qcMassErrors(targets),
+		qcRetentionTimes(targets),
+		qcProfile("Charge states", "charge", targets, func(p PSMEvidence) int { return int(p.AssumedCharge) }),
+		qcProfile("Missed cleavages", "missed cleavages", targets, func(p PSMEvidence) int { return int(p.NumberOfMissedCleavages) }),
+		qcModifications(targets),
+		qcChannels(targets),
+		qcIntensities(targets),
+	)
+
+	return d
+}
+
+// newQCPlot creates a plot with the dashboard style
+func newQCPlot(title, x, y string) *plot.Plot {
+
+	p, e := plot.New()
+	if e != nil {
+		msg.Plotter(e, "fatal")
+	}
+
+	p.Title.Text = title
+	p.X.Label.Text = x
+	p.Y.Label.Text = y
+	p.Add(plotter.NewGrid())
+
+	return p
+}
+
+// qcSVG draws a plot as inline SVG
+func qcSVG(p *plot.Plot) template.HTML {
+
+	c := vgsvg.New(18*vg.Centimeter, 9*vg.Centimeter)
+	p.Draw(draw.New(c))
+
+	var b bytes.Buffer
+	if _, e := c.WriteTo(&b); e != nil {
+		msg.Plotter(e, "fatal")
+	}
+
+	svg := b.String()
+	if i := strings.Index(svg, "<svg"); i > 0 {
+		svg = svg[i:]
+	}
+
+	return template.HTML(svg)
+}
+
+// qcIdentifications counts the target and decoy evidences of each level
+func qcIdentifications(e Evidence) qcPanel {
+
+	var counts []qcCounts
+
+	add := func(level string, n int, decoy func(int) bool) {
+		if n == 0 {
+			return
+		}
+		c := qcCounts{Level: level}
+		for i := 0; i < n; i++ {
+			if decoy(i) {
+				c.Decoys++
+			} else {
+				c.Targets++
+			}
+		}
+		counts = append(counts, c)
+	}
+
+	add("PSMs", len(e.PSM), func(i int) bool { return e.PSM[i].IsDecoy })
+	add("Ions", len(e.Ions), func(i int) bool { return e.Ions[i].IsDecoy })
+	add("Peptides", len(e.Peptides), func(i int) bool { return e.Peptides[i].IsDecoy })
+	add("Proteins", len(e.Proteins), func(i int) bool { return e.Proteins[i].IsDecoy })
+	add("Genes", len(e.Genes), func(i int) bool { return e.Genes[i].IsDecoy })
+
+	panel := qcPanel{Title: "Identifications", Header: []string{"Level", "Targets", "Decoys"}}
+	if len(counts) == 0 {
+		panel.Note = "No identifications found"
+		return panel
+	}
+
+	var values plotter.Values
+	var names []string
+	for _, i := range counts {
+		values = append(values, float64(i.Targets))
+		names = append(names, i.Level)
+		panel.Rows = append(panel.Rows, []string{i.Level, strconv.Itoa(i.Targets), strconv.Itoa(i.Decoys)})
+	}
+
+	p := newQCPlot("Target identifications", "", "count")
+	bars, _ := plotter.NewBarChart(values, vg.Points(30))
+	bars.Color = plotutil.Color(0)
+	p.Add(bars)
+	p.NominalX(names...)
+	panel.SVG = qcSVG(p)
+
+	return panel
+}
+
+// qcRuns counts the PSMs, peptides and proteins identified in each run
+func qcRuns(psms []PSMEvidence) qcPanel {
+
+	panel := qcPanel{Title: "Identifications per run", Header: []string{"Run", "PSMs", "Peptides", "Proteins"}}
+
+	var runs []string
+	var spectra = make(map[string]int)
+	var peptides = make(map[string]map[string]bool)
+	var proteins = make(map[string]map[string]bool)
+
+	for _, i := range psms {
+		run := strings.Split(i.Spectrum, ".")[0]
+		if _, ok := spectra[run]; !ok {
+			runs = append(runs, run)
+			peptides[run] = make(map[string]bool)
+			proteins[run] = make(map[string]bool)
+		}
+		spectra[run]++
+		peptides[run][i.Peptide] = true
+		if len(i.Protein) > 0 {
+			proteins[run][i.Protein] = true
+		}
+	}
+
+	if len(runs) == 0 {
+		panel.Note = "No target PSMs found"
+		return panel
+	}
+
+	sort.Strings(runs)
+
+	var psmCounts, peptideCounts plotter.Values
+	for _, i := range runs {
+		psmCounts = append(psmCounts, float64(spectra[i]))
+		peptideCounts = append(peptideCounts, float64(len(peptides[i])))
+		panel.Rows = append(panel.Rows, []string{i, strconv.Itoa(spectra[i]), strconv.Itoa(len(peptides[i])), strconv.Itoa(len(proteins[i]))})
+	}
+
+	p := newQCPlot("PSMs and peptides per run", "", "count")
+
+	width := vg.Points(12)
+	psmBars, _ := plotter.NewBarChart(psmCounts, width)
+	psmBars.Color = plotutil.Color(0)
+	psmBars.Offset = -width / 2
+
+	peptideBars, _ := plotter.NewBarChart(peptideCounts, width)
+	peptideBars.Color = plotutil.Color(1)
+	peptideBars.Offset = width / 2
+
+	p.Add(psmBars, peptideBars)
+	p.Legend.Add("PSMs", psmBars)
+	p.Legend.Add("Peptides", peptideBars)
+	p.Legend.Top = true
+	p.NominalX(runs...)
+	panel.SVG = qcSVG(p)
+
+	return panel
+}
+
+// qcFDR follows the estimated PSM FDR as the PSMs are accepted by decreasing probability
+func qcFDR(psms PSMEvidenceList, threshold float64) qcPanel {
+
+	panel := qcPanel{Title: "FDR convergence"}
+
+	sorted := make([]PSMEvidence, len(psms))
+	copy(sorted, psms)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Probability > sorted[j].Probability })
+
+	var targets, decoys int
+	var points plotter.XYs
+	for n, i := range sorted {
+
+		if i.IsDecoy {
+			decoys++
+		} else {
+			targets++
+		}
+
+		if targets > 0 && (n%qcStride(len(sorted)) == 0 || n == len(sorted)-1) {
+			points = append(points, plotter.XY{X: float64(targets), Y: 100 * float64(decoys) / float64(targets)})
+		}
+	}
+
+	if decoys == 0 || len(points) == 0 {
+		panel.Note = "No decoy PSMs in the filtered results, the FDR cannot be followed"
+		return panel
+	}
+
+	panel.Note = fmt.Sprintf("%d target and %d decoy PSMs", targets, decoys)
+
+	p := newQCPlot("Estimated FDR by accepted target PSMs", "target PSMs", "FDR (%)")
+	line, _ := plotter.NewLine(points)
+	line.Color = plotutil.Color(0)
+	p.Add(line)
+
+	if threshold > 0 {
+		limit, _ := plotter.NewLine(plotter.XYs{{X: 0, Y: 100 * threshold}, {X: float64(targets), Y: 100 * threshold}})
+		limit.Color = plotutil.Color(1)
+		limit.Dashes = []vg.Length{vg.Points(4), vg.Points(4)}
+		p.Add(limit)
+		p.Legend.Add("threshold", limit)
+	}
+
+	panel.SVG = qcSVG(p)
+
+	return panel
+}
+
+// qcStride keeps at most a thousand points on the line plots
+func qcStride(n int) int {
+	if n <= 1000 {
+		return 1
+	}
+	return n / 1000
+}
+
+// qcHistogram plots the distribution of the values
+func qcHistogram(title, x string, values plotter.Values) template.HTML {
+
+	p := newQCPlot(title, x, "PSMs")
+	h, e := plotter.NewHist(values, 50)
+	if e != nil {
+		msg.Plotter(e, "fatal")
+	}
+	h.FillColor = plotutil.Color(0)
+	p.Add(h)
+
+	return qcSVG(p)
+}
+
+// qcMassErrors plots the precursor mass errors in ppm, after the isotope error correction
+func qcMassErrors(psms []PSMEvidence) qcPanel {
+
+	panel := qcPanel{Title: "Precursor mass error"}
+
+	var values plotter.Values
+	var outside int
+	for _, i := range psms {
+
+		if i.CalcNeutralPepMass == 0 {
+			continue
+		}
+
+		delta := i.Massdiff
+		isotope := math.Round(delta / qcIsotopeSpacing)
+		if math.Abs(isotope) <= 3 {
+			delta -= isotope * qcIsotopeSpacing
+		}
+
+		ppm := delta / i.CalcNeutralPepMass * 1e6
+		if math.Abs(ppm) > qcMassError {
+			outside++
+			continue
+		}
+
+		values = append(values, ppm)
+	}
+
+	if len(values) == 0 {
+		panel.Note = fmt.Sprintf("No target PSMs within %d ppm", qcMassError)
+		return panel
+	}
+
+	panel.Note = fmt.Sprintf("%d PSMs within %d ppm, %d outside the window", len(values), qcMassError, outside)
+	panel.SVG = qcHistogram("Precursor mass error", "mass error (ppm)", values)
+
+	return panel
+}
+
+// qcRetentionTimes plots the retention times of the identified PSMs
+func qcRetentionTimes(psms []PSMEvidence) qcPanel {
+
+	panel := qcPanel{Title: "Retention time"}
+
+	var values plotter.Values
+	for _, i := range psms {
+		if i.RetentionTime > 0 {
+			values = append(values, i.RetentionTime/60)
+		}
+	}
+
+	if len(values) == 0 {
+		panel.Note = "No retention times found"
+		return panel
+	}
+
+	panel.SVG = qcHistogram("Identified PSMs by retention time", "retention time (min)", values)
+
+	return panel
+}
+
+// qcProfile counts the PSMs for each value of a property
+func qcProfile(title, x string, psms []PSMEvidence, property func(PSMEvidence) int) qcPanel {
+
+	panel := qcPanel{Title: title, Header: []string{strings.Title(x), "PSMs", "Fraction"}}
+
+	var counts = make(map[int]int)
+	for _, i := range psms {
+		counts[property(i)]++
+	}
+
+	if len(counts) == 0 {
+		panel.Note = "No target PSMs found"
+		return panel
+	}
+
+	var keys []int
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+
+	var values plotter.Values
+	var names []string
+	for _, k := range keys {
+		values = append(values, float64(counts[k]))
+		names = append(names, strconv.Itoa(k))
+		panel.Rows = append(panel.Rows, []string{strconv.Itoa(k), strconv.Itoa(counts[k]), fmt.Sprintf("%.1f%%", 100*float64(counts[k])/float64(len(psms)))})
+	}
+
+	p := newQCPlot(title, x, "PSMs")
+	bars, _ := plotter.NewBarChart(values, vg.Points(20))
+	bars.Color = plotutil.Color(0)
+	p.Add(bars)
+	p.NominalX(names...)
+	panel.SVG = qcSVG(p)
+
+	return panel
+}
+
+// qcModifications plots the mass modification histogram with the bins of the modification report
+func qcModifications(psms []PSMEvidence) qcPanel {
+
+	panel := qcPanel{Title: "Mass modifications", Note: "PSMs per 0.1 Da bin between -500 and 500 Da, the zero bin is not shown"}
+
+	const binsize = 0.1
+	const amplitude = 500
+
+	bin := func(mass float64) (int, bool) {
+		b := int(math.Round(mass / binsize))
+		return b, b != 0 && math.Abs(mass) <= amplitude
+	}
+
+	var observed = make(map[int]int)
+	var assigned = make(map[int]int)
+
+	for _, i := range psms {
+
+		if b, ok := bin(i.Massdiff); ok {
+			observed[b]++
+		}
+
+		var seen = make(map[int]bool)
+		for _, j := range i.Modifications.IndexSlice {
+			if b, ok := bin(j.MassDiff); ok && !seen[b] {
+				assigned[b]++
+				seen[b] = true
+			}
+		}
+	}
+
+	if len(observed) == 0 && len(assigned) == 0 {
+		panel.Note = "No mass modifications found"
+		return panel
+	}
+
+	series := func(counts map[int]int) plotter.XYs {
+		var keys []int
+		for k := range counts {
+			keys = append(keys, k)
+		}
+		sort.Ints(keys)
+
+		var xys plotter.XYs
+		for _, k := range keys {
+			x := float64(k) * binsize
+			xys = append(xys, plotter.XY{X: x - binsize/2, Y: 0}, plotter.XY{X: x, Y: float64(counts[k])}, plotter.XY{X: x + binsize/2, Y: 0})
+		}
+		return xys
+	}
+
+	p := newQCPlot("Distribution of mass modifications", "mass bins (Da)", "PSMs")
+
+	for n, i := range []struct {
+		name   string
+		counts map[int]int
+	}{{"Observed", observed}, {"Assigned", assigned}} {
+		if len(i.counts) == 0 {
+			continue
+		}
+		line, _ := plotter.NewLine(series(i.counts))
+		line.Color = plotutil.Color(n)
+		p.Add(line)
+		p.Legend.Add(i.name, line)
+	}
+	p.Legend.Top = true
+
+	panel.SVG = qcSVG(p)
+
+	return panel
+}
+
+// qcBoxPlots draws a box plot for each group of intensities, on the log2 scale
+func qcBoxPlots(title, x string, names []string, groups []plotter.Values) template.HTML {
+
+	p := newQCPlot(title, x, "log2 intensity")
+
+	for n, i := range groups {
+		if len(i) == 0 {
+			continue
+		}
+		box, e := plotter.NewBoxPlot(vg.Points(16), float64(n), i)
+		if e != nil {
+			msg.Plotter(e, "fatal")
+		}
+		box.BoxStyle.Color = plotutil.Color(n)
+		p.Add(box)
+	}
+
+	p.NominalX(names...)
+
+	return qcSVG(p)
+}
+
+// qcChannels summarizes the isobaric channel intensities of the PSMs
+func qcChannels(psms []PSMEvidence) qcPanel {
+
+	panel := qcPanel{Title: "Isobaric channels", Header: []string{"Channel", "Quantified PSMs", "Median log2 intensity", "Intensity share"}}
+
+	var labels []*iso.Labels
+	for i := range psms {
+		labels = append(labels, psms[i].Labels)
+	}
+
+	ref := referenceLabels(labels, true)
+	if ref == nil {
+		panel.Note = "No isobaric labels found"
+		return panel
+	}
+
+	var names []string
+	for _, i := range ref.Channels {
+		if len(i.CustomName) > 0 {
+			names = append(names, i.CustomName)
+		} else {
+			names = append(names, i.Name)
+		}
+	}
+
+	groups := make([]plotter.Values, len(ref.Channels))
+	sums := make([]float64, len(ref.Channels))
+	var total float64
+
+	for _, i := range psms {
+		if i.Labels == nil {
+			continue
+		}
+		for n, j := range i.Labels.Channels {
+			if n < len(groups) && j.Intensity > 0 {
+				groups[n] = append(groups[n], math.Log2(j.Intensity))
+				sums[n] += j.Intensity
+				total += j.Intensity
+			}
+		}
+	}
+
+	if total == 0 {
+		panel.Note = "No isobaric intensities found"
+		return panel
+	}
+
+	for n, i := range names {
+		panel.Rows = append(panel.Rows, []string{i, strconv.Itoa(len(groups[n])), fmt.Sprintf("%.2f", qcMedian(groups[n])), fmt.Sprintf("%.1f%%", 100*sums[n]/total)})
+	}
+
+	panel.SVG = qcBoxPlots("Channel intensities", "channel", names, groups)
+
+	return panel
+}
+
+// qcIntensities summarizes the precursor intensities of each run
+func qcIntensities(psms []PSMEvidence) qcPanel {
+
+	panel := qcPanel{Title: "Precursor intensities", Header: []string{"Run", "Quantified PSMs", "Median log2 intensity"}}
+
+	var index = make(map[string]int)
+	var runs []string
+	var groups []plotter.Values
+
+	for _, i := range psms {
+		if i.Intensity <= 0 {
+			continue
+		}
+		run := strings.Split(i.Spectrum, ".")[0]
+		n, ok := index[run]
+		if !ok {
+			n = len(runs)
+			index[run] = n
+			runs = append(runs, run)
+			groups = append(groups, nil)
+		}
+		groups[n] = append(groups[n], math.Log2(i.Intensity))
+	}
+
+	if len(runs) == 0 {
+		panel.Note = "No precursor intensities found"
+		return panel
+	}
+
+	for n, i := range runs {
+		panel.Rows = append(panel.Rows, []string{i, strconv.Itoa(len(groups[n])), fmt.Sprintf("%.2f", qcMedian(groups[n]))})
+	}
+
+	panel.SVG = qcBoxPlots("Precursor intensities per run", "run", runs, groups)
+
+	return panel
+}
+
+// qcMedian returns the median of the values
+func qcMedian(values plotter.Values) float64 {
+
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
@@ -0,0 +1,87 @@
+package rep
+
+import (
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestQCDashboard(t *testing.T) {
+
+	e, m := mzTabEvidence()
+	m.UUID = "workspace-1"
+	m.ProjectName = "cohort"
+	m.Filter.PsmFDR = 0.01
+
+	e.PSM[0].Intensity = 1e6
+	e.PSM[0].Labels = e.Proteins[0].URazorLabels
+	e.PSM[0].NumberOfMissedCleavages = 1
+	e.PSM[0].Massdiff = 1.0033548 + 0.005
+	e.PSM[1].Probability = 0.2
+
+	d := newQCDashboard(m, e)
+
+	var titles []string
+	var plots int
+	for _, i := range d.Panels {
+		titles = append(titles, i.Title)
+		if len(i.SVG) > 0 {
+			plots++
+
+			decoder := xml.NewDecoder(strings.NewReader(string(i.SVG)))
+			for {
+				_, err := decoder.Token()
+				if err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatalf("the %s plot is not well formed: %v", i.Title, err)
+				}
+			}
+		}
+	}
+
+	want := "Identifications,Identifications per run,FDR convergence,Precursor mass error,Retention time,Charge states,Missed cleavages,Mass modifications,Isobaric channels,Precursor intensities"
+	if strings.Join(titles, ",") != want {
+		t.Errorf("unexpected panels, got %v", titles)
+	}
+
+	if plots != len(d.Panels) {
+		t.Errorf("every panel must have a plot with the fixture, got %d of %d", plots, len(d.Panels))
+	}
+
+	if d.Panels[0].Rows[0][1] != "1" || d.Panels[0].Rows[0][2] != "1" {
+		t.Errorf("the PSM counts are incorrect, got %v", d.Panels[0].Rows[0])
+	}
+
+	if d.Panels[3].Note != "1 PSMs within 50 ppm, 0 outside the window" {
+		t.Errorf("the isotope error must be corrected, got %q", d.Panels[3].Note)
+	}
+
+	dir, err := ioutil.TempDir("", "qc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "qc.html")
+	if err := d.write(output); err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ := ioutil.ReadFile(output)
+	html := string(b)
+
+	if strings.Count(html, "<svg") != plots {
+		t.Errorf("expected %d inline plots", plots)
+	}
+
+	for _, i := range []string{"<script", "<link", "src=", "&lt;svg"} {
+		if strings.Contains(html, i) {
+			t.Errorf("the dashboard must be self-contained, found %s", i)
+		}
+	}
+}
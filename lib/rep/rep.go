@@ -20,10 +20,12 @@ type Evidence struct {
 	Ions            IonEvidenceList
 	Peptides        PeptideEvidenceList
 	Proteins        ProteinEvidenceList
+	Genes           GeneEvidenceList
 	Mods            mod.Modifications
 	Modifications   ModificationEvidence
 	CombinedProtein CombinedProteinEvidenceList
 	CombinedPeptide CombinedPeptideEvidenceList
+	CombinedGene    CombinedGeneEvidenceList
 }
 
 // SearchParametersEvidence ...
@@ -134,6 +136,11 @@ type PSMEvidence struct {
 	UncalibratedPrecursorNeutralMass float64
 	PrecursorNeutralMass             float64
 	RetentionTime                    float64
+	AlignedRetentionTime             float64
+	ApexRetentionTime                float64
+	Area                             float64
+	FWHM                             float64
+	IsotopeCorrelation               float64
 	CalcNeutralPepMass               float64
 	RawMassdiff                      float64
 	Massdiff                         float64
@@ -159,6 +166,7 @@ type PSMEvidence struct {
 	PTM                              *id.PTM
 	MSFraggerLoc                     *id.MSFraggerLoc
 	Labels                           *iso.Labels
+	MS1Labels                        *MS1Labels
 	Modifications                    mod.ModificationsSlice
 	MappedProteins                   map[string]int
 	MappedGenes                      map[string]struct{}
@@ -217,11 +225,18 @@ type IonEvidence struct {
 	Probability              float64
 	Expectation              float64
 	SummedLabelIntensity     float64
+	AlignedRetentionTime     float64
+	ApexRetentionTime        float64
+	Area                     float64
+	FWHM                     float64
+	IsotopeCorrelation       float64
 	IsUnique                 bool
 	IsURazor                 bool
 	IsDecoy                  bool
+	IsTransferred            bool // quantified by match-between-runs
 	Labels                   *iso.Labels
 	PhosphoLabels            *iso.Labels
+	MS1Labels                *MS1Labels
 	Modifications            mod.ModificationsSlice
 	Spectra                  map[id.SpectrumType]int
 	MappedProteins           map[string]int
@@ -262,12 +277,14 @@ type PeptideEvidence struct {
 	IsUnique               bool
 	IsURazor               bool
 	IsDecoy                bool
+	IsTransferred          bool // intensity includes match-between-runs ions
 	ChargeState            map[uint8]uint8
 	Spectra                map[id.SpectrumType]uint8
 	MappedProteins         map[string]int
 	MappedGenes            map[string]struct{}
 	Labels                 *iso.Labels
 	PhosphoLabels          *iso.Labels
+	MS1Labels              *MS1Labels
 	Modifications          mod.ModificationsSlice
 }
 
@@ -307,6 +324,9 @@ type ProteinEvidence struct {
 	TotalIntensity         float64
 	UniqueIntensity        float64
 	URazorIntensity        float64 // Unique + razor
+	IBAQ                   float64
+	RIBAQ                  float64
+	CopyNumber             float64
 	Probability            float64
 	TopPepProb             float64
 	IsDecoy                bool
@@ -323,6 +343,7 @@ type ProteinEvidence struct {
 	PhosphoTotalLabels     *iso.Labels
 	PhosphoUniqueLabels    *iso.Labels
 	PhosphoURazorLabels    *iso.Labels // Unique + razor
+	MS1Labels              *MS1Labels  // Unique + razor ions
 	Modifications          mod.ModificationsSlice
 }
 
@@ -333,6 +354,38 @@ func (a ProteinEvidenceList) Len() int           { return len(a) }
 func (a ProteinEvidenceList) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a ProteinEvidenceList) Less(i, j int) bool { return a[i].ProteinGroup < a[j].ProteinGroup }
 
+// GeneEvidence represents the proteins and isoforms collapsed to a single gene
+type GeneEvidence struct {
+	GeneName          string
+	Organism          string
+	Description       string
+	TotalSpC          int
+	UniqueSpC         int
+	URazorSpC         int // Unique + razor
+	TotalIntensity    float64
+	UniqueIntensity   float64
+	URazorIntensity   float64 // Unique + razor
+	Probability       float64
+	TopPepProb        float64
+	IsDecoy           bool
+	Proteins          map[string]struct{}
+	ProteinIDs        map[string]struct{}
+	SupportingSpectra map[id.SpectrumType]int
+	TotalPeptides     map[string]int
+	UniquePeptides    map[string]int
+	URazorPeptides    map[string]int // Unique + razor
+	TotalPeptideIons  map[id.IonFormType]uint8
+	UniquePeptideIons map[id.IonFormType]uint8
+	URazorPeptideIons map[id.IonFormType]uint8 // Unique + razor
+}
+
+// GeneEvidenceList list
+type GeneEvidenceList []GeneEvidence
+
+func (a GeneEvidenceList) Len() int           { return len(a) }
+func (a GeneEvidenceList) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a GeneEvidenceList) Less(i, j int) bool { return a[i].Probability > a[j].Probability }
+
 // CombinedProteinEvidence represents all combined proteins detected
 type CombinedProteinEvidence struct {
 	GroupNumber            uint32
@@ -361,9 +414,14 @@ type CombinedProteinEvidence struct {
 	TotalIntensity         map[string]float64
 	UniqueIntensity        map[string]float64
 	UrazorIntensity        map[string]float64
+	MaxLFQIntensity        map[string]float64
+	IBAQ                   map[string]float64
+	RIBAQ                  map[string]float64
+	CopyNumber             map[string]float64
 	TotalLabels            map[string]iso.Labels
 	UniqueLabels           map[string]iso.Labels
 	URazorLabels           map[string]iso.Labels // Unique + razor
+	MS1Labels              map[string]MS1Labels
 	PeptideIons            []id.PeptideIonIdentification
 }
 
@@ -387,6 +445,7 @@ type CombinedPeptideEvidence struct {
 	AssignedMassDiffs  map[string]uint8
 	Spc                map[string]int
 	Intensity          map[string]float64
+	MS1Labels          map[string]MS1Labels
 }
 
 // CombinedPeptideEvidenceList is a list of Combined Peptide Evidences
@@ -396,6 +455,32 @@ func (a CombinedPeptideEvidenceList) Len() int           { return len(a) }
 func (a CombinedPeptideEvidenceList) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a CombinedPeptideEvidenceList) Less(i, j int) bool { return a[i].Sequence < a[j].Sequence }
 
+// CombinedGeneEvidence represents all combined genes detected
+type CombinedGeneEvidence struct {
+	GeneName        string
+	Organism        string
+	Description     string
+	Probability     float64
+	TopPepProb      float64
+	Proteins        map[string]struct{}
+	TotalSpc        map[string]int
+	UniqueSpc       map[string]int
+	UrazorSpc       map[string]int
+	TotalPeptides   map[string]map[string]bool
+	UniquePeptides  map[string]map[string]bool
+	UrazorPeptides  map[string]map[string]bool
+	TotalIntensity  map[string]float64
+	UniqueIntensity map[string]float64
+	UrazorIntensity map[string]float64
+}
+
+// CombinedGeneEvidenceList is a list of Combined Gene Evidences
+type CombinedGeneEvidenceList []CombinedGeneEvidence
+
+func (a CombinedGeneEvidenceList) Len() int           { return len(a) }
+func (a CombinedGeneEvidenceList) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a CombinedGeneEvidenceList) Less(i, j int) bool { return a[i].GeneName < a[j].GeneName }
+
 // ModificationEvidence represents the list of modifications and the mod bins
 type ModificationEvidence struct {
 	MassBins []MassBin
@@ -427,8 +512,7 @@ func Run(m met.Data) {
 	var isComet bool
 	var hasLoc bool
 	var hasLabels bool
-	var isoBrand string
-	var isoChannels int
+	var isobaric bool
 
 	if len(m.Comet.Param) > 0 {
 		isComet = true
@@ -438,71 +522,123 @@ func Run(m met.Data) {
 		hasLoc = true
 	}
 
-	if m.Quantify.Brand == "tmt" {
-		isoBrand = "tmt"
-	} else if m.Quantify.Brand == "itraq" {
-		isoBrand = "itraq"
-	}
-
-	if len(m.Quantify.Plex) > 0 {
-		isoChannels, _ = strconv.Atoi(m.Quantify.Plex)
+	// the channels are reported from the labels, custom reagents have no brand
+	if len(m.Quantify.Brand) > 0 || len(m.Quantify.Reagent) > 0 {
+		isobaric = true
 	}
 
 	if len(m.Quantify.Annot) > 0 {
 		hasLabels = true
 	}
 
+	// the custom layouts and the Parquet files are written from the report tables
+	var template *Template
+	if len(m.Report.Template) > 0 {
+		t := ReadTemplate(m.Report.Template)
+		template = &t
+	}
+
+	var export = func(t *Table) {
+		if template != nil {
+			template.Apply(m.Home, t)
+		}
+		if m.Report.Parquet {
+			t.ParquetReport(m.Home, m.Report.Long)
+		}
+	}
+
 	logrus.Info("Creating reports")
 	{
 		var repoPSM PSMEvidenceList
 		RestorePSM(&repoPSM)
 		// PSM
-		repoPSM.MetaPSMReport(m.Home, isoBrand, m.Database.Tag, isoChannels, m.Report.Decoys, isComet, hasLoc, m.Report.IonMob, hasLabels)
+		export(repoPSM.MetaPSMReport(m.Home, m.Database.Tag, m.Report.Decoys, isComet, hasLoc, m.Report.IonMob, hasLabels))
 	}
 	{
 		var repoIons IonEvidenceList
 		RestoreIon(&repoIons)
 		// Ion
-		repoIons.MetaIonReport(m.Home, isoBrand, m.Database.Tag, isoChannels, m.Report.Decoys, hasLabels)
+		export(repoIons.MetaIonReport(m.Home, m.Database.Tag, m.Report.Decoys, hasLabels))
 	}
 	{
 		// Peptide
 		var repoPeptides PeptideEvidenceList
 		RestorePeptide(&repoPeptides)
-		repoPeptides.MetaPeptideReport(m.Home, isoBrand, m.Database.Tag, isoChannels, m.Report.Decoys, hasLabels)
+		export(repoPeptides.MetaPeptideReport(m.Home, m.Database.Tag, m.Report.Decoys, hasLabels))
 	}
-	// Protein
+	// Protein, labelquant sums the channels and freequant takes the top 3 ions unless told otherwise. The rollup
+	// options are kept with the quantification parameters in the workspace meta data
+	defaultRollup := "topN"
+	if isobaric {
+		defaultRollup = "sum"
+	}
+
 	if len(m.Filter.Pox) > 0 || m.Filter.Inference {
+		if rollup := m.Quantify.RollupName(defaultRollup); len(rollup) > 0 {
+			logrus.Info("Protein intensities rolled up with ", rollup)
+		}
+
 		var repoProteins ProteinEvidenceList
 		RestoreProtein(&repoProteins)
-		repoProteins.MetaProteinReport(m.Home, isoBrand, m.Database.Tag, isoChannels, m.Report.Decoys, m.Filter.Razor, m.Quantify.Unique, hasLabels)
+		export(repoProteins.MetaProteinReport(m.Home, m.Database.Tag, m.Report.Decoys, m.Filter.Razor, m.Quantify.Unique, hasLabels))
 		repoProteins.ProteinFastaReport(m.Home, m.Report.Decoys)
 	}
 
+	// Impurity correction
+	if len(m.Quantify.ImpurityMatrix) > 0 {
+		ImpurityReport(m.Home, m.Quantify.ImpurityChannels, m.Quantify.ImpurityMatrix)
+	}
+
+	// Gene
+	if m.Filter.Gene {
+		var repoGenes GeneEvidenceList
+		RestoreGene(&repoGenes)
+		export(repoGenes.MetaGeneReport(m.Home, m.Report.Decoys))
+	}
+
 	// Modifications
 	repo := New()
 	if len(repo.Modifications.MassBins) > 0 {
 		if repo.PSM == nil {
 			RestorePSM(&repo.PSM)
 		}
-		repo.ModificationReport(m.Home)
+		export(repo.ModificationReport(m.Home))
 
 		if m.PTMProphet.InputFiles != nil || len(m.PTMProphet.InputFiles) > 0 {
-			repo.PSMLocalizationReport(m.Home, m.Filter.Tag, m.Filter.Razor, m.Report.Decoys)
+			export(repo.PSMLocalizationReport(m.Home, m.Filter.Tag, m.Filter.Razor, m.Report.Decoys))
+			export(repo.SiteReport(m.Home, m.Report.Decoys))
 		}
 
 		repo.PlotMassHist()
 	}
 
+	// QC dashboard
+	QCReport(m)
+
 	// MSstats
 	if m.Report.MSstats {
-		repo.MetaMSstatsReport(m.Home, isoBrand, isoChannels, m.Report.Decoys)
+		repo.MetaMSstatsReport(m.Home, m.Report.Decoys)
+		if len(m.Report.Design) > 0 {
+			repo.MSstatsDesignReport(m.Home, ReadDesign(m.Report.Design, isobaric), m.Report.Decoys)
+		}
 	}
 
 	// MzID
 	if m.Report.MZID {
 		repo.RestoreGranular()
-		repo.MzIdentMLReport(m.Version, m.Database.Annot)
+		repo.MzIdentMLReport(m, m.Report.MzIDFile)
+	}
+
+	// mzTab
+	if m.Report.MzTab {
+		repo.RestoreGranular()
+		repo.MzTabReport(m)
+	}
+
+	// SQLite
+	if len(m.Report.SQLite) > 0 {
+		repo.RestoreGranular()
+		repo.SQLiteReport(m, m.Report.SQLite)
 	}
 
 }
@@ -530,3 +666,55 @@ func getModsList(m map[string]mod.Modification) ([]string, []string) {
 
 	return a, o
 }
+
+// referenceLabels returns the first labels with channels, labels with custom channel names are preferred when requested
+func referenceLabels(labels []*iso.Labels, hasLabels bool) *iso.Labels {
+
+	var ref *iso.Labels
+
+	for _, i := range labels {
+		if i == nil || len(i.Channels) == 0 {
+			continue
+		}
+
+		if ref == nil {
+			ref = i
+		}
+
+		if !hasLabels || len(i.Channels[0].CustomName) > 0 {
+			return i
+		}
+	}
+
+	return ref
+}
+
+// channelHeader creates the channel columns, custom names replace the channel names when requested
+func channelHeader(l *iso.Labels, hasLabels bool) string {
+
+	var header string
+	for _, i := range l.Channels {
+		if hasLabels && len(i.CustomName) > 0 {
+			header += "\t" + i.CustomName
+		} else {
+			header += "\tChannel " + i.Name
+		}
+	}
+
+	return header
+}
+
+// channelValues prints the channel intensities, missing labels or channels are reported as zero
+func channelValues(l *iso.Labels, channels int) string {
+
+	var line string
+	for i := 0; i < channels; i++ {
+		var v float64
+		if l != nil && i < len(l.Channels) {
+			v = l.Channels[i].Intensity
+		}
+		line += fmt.Sprintf("\t%.4f", v)
+	}
+
+	return line
+}
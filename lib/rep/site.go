@@ -0,0 +1,355 @@
+package rep
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"philosopher/lib/dat"
+	"philosopher/lib/iso"
+	"philosopher/lib/msg"
+	"philosopher/lib/sys"
+)
+
+// siteFlank is the number of residues reported on each side of a modified site
+const siteFlank = 7
+
+// siteMaxMultiplicity groups the peptides carrying three or more modifications of the same kind
+const siteMaxMultiplicity = 3
+
+// SiteEvidence is a modified residue of a protein, the sites are split by the number of modifications of the same
+// kind carried by the peptides, three or more modifications share a single site
+type SiteEvidence struct {
+	Protein      string
+	ProteinID    string
+	EntryName    string
+	GeneName     string
+	Position     int
+	AminoAcid    string
+	Modification string
+	Multiplicity int
+	Probability  float64
+	Window       string
+	Spectra      int
+	Intensity    float64
+	Peptides     map[string]struct{}
+	Labels       *iso.Labels
+	ions         map[string]float64
+}
+
+// SiteEvidenceList is a list of sites sorted by protein and position
+type SiteEvidenceList []SiteEvidence
+
+func (a SiteEvidenceList) Len() int      { return len(a) }
+func (a SiteEvidenceList) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a SiteEvidenceList) Less(i, j int) bool {
+	if a[i].Protein != a[j].Protein {
+		return a[i].Protein < a[j].Protein
+	}
+	if a[i].Position != a[j].Position {
+		return a[i].Position < a[j].Position
+	}
+	if a[i].Modification != a[j].Modification {
+		return a[i].Modification < a[j].Modification
+	}
+	return a[i].Multiplicity < a[j].Multiplicity
+}
+
+// Key identifies the site across data sets
+func (s SiteEvidence) Key() string {
+	return fmt.Sprintf("%s\t%d\t%s\t%d", s.Protein, s.Position, s.Modification, s.Multiplicity)
+}
+
+// Class is the site call from the best localization probability, class I sites are above 0.75 and class II sites
+// above 0.5
+func (s SiteEvidence) Class() string {
+	return SiteClass(s.Probability)
+}
+
+// MultiplicityName prints the multiplicity, three or more modifications are reported together
+func (s SiteEvidence) MultiplicityName() string {
+	if s.Multiplicity >= siteMaxMultiplicity {
+		return "3+"
+	}
+	return strconv.Itoa(s.Multiplicity)
+}
+
+// SiteClass calls the localization class of a probability
+func SiteClass(p float64) string {
+	switch {
+	case p > 0.75:
+		return "I"
+	case p > 0.5:
+		return "II"
+	}
+	return "III"
+}
+
+// parsePTMPeptide reads the PTMProphet peptide, the candidate residues are followed by their localization
+// probability, e.g. PEPS(0.998)T(0.002)IDEK
+func parsePTMPeptide(ptm string) (string, []float64, error) {
+
+	var sequence strings.Builder
+	var probabilities []float64
+
+	for i := 0; i < len(ptm); i++ {
+
+		if ptm[i] != '(' {
+			sequence.WriteByte(ptm[i])
+			probabilities = append(probabilities, math.NaN())
+			continue
+		}
+
+		end := strings.IndexByte(ptm[i:], ')')
+		if end < 0 || len(probabilities) == 0 {
+			return "", nil, fmt.Errorf("malformed PTMProphet peptide %s", ptm)
+		}
+
+		p, e := strconv.ParseFloat(ptm[i+1:i+end], 64)
+		if e != nil {
+			return "", nil, fmt.Errorf("malformed PTMProphet peptide %s", ptm)
+		}
+
+		probabilities[len(probabilities)-1] = p
+		i += end
+	}
+
+	return sequence.String(), probabilities, nil
+}
+
+// siteWindow returns the sequence around a protein position, the protein ends are padded with underscores
+func siteWindow(sequence string, position int) string {
+
+	var window strings.Builder
+	for i := position - 1 - siteFlank; i <= position-1+siteFlank; i++ {
+		if i < 0 || i >= len(sequence) {
+			window.WriteByte('_')
+		} else {
+			window.WriteByte(sequence[i])
+		}
+	}
+
+	return window.String()
+}
+
+// psmSites localizes the modifications of a PSM, each modification is placed on the most probable candidate
+// residues and the number of modifications is the rounded sum of the probabilities
+func psmSites(i PSMEvidence) []SiteEvidence {
+
+	var sites []SiteEvidence
+
+	if i.PTM == nil || i.ProteinStart == 0 {
+		return sites
+	}
+
+	var ptms []string
+	for k := range i.PTM.LocalizedPTMMassDiff {
+		ptms = append(ptms, k)
+	}
+	sort.Strings(ptms)
+
+	for _, k := range ptms {
+
+		sequence, probabilities, e := parsePTMPeptide(i.PTM.LocalizedPTMMassDiff[k])
+		if e != nil {
+			msg.Custom(e, "warning")
+			continue
+		}
+
+		var sum float64
+		var candidates []int
+		for n, p := range probabilities {
+			if !math.IsNaN(p) {
+				sum += p
+				candidates = append(candidates, n)
+			}
+		}
+
+		multiplicity := int(math.Round(sum))
+		if multiplicity < 1 {
+			multiplicity = 1
+		}
+		if multiplicity > len(candidates) {
+			multiplicity = len(candidates)
+		}
+
+		sort.SliceStable(candidates, func(a, b int) bool {
+			return probabilities[candidates[a]] > probabilities[candidates[b]]
+		})
+
+		group := multiplicity
+		if group > siteMaxMultiplicity {
+			group = siteMaxMultiplicity
+		}
+
+		for _, n := range candidates[:multiplicity] {
+			sites = append(sites, SiteEvidence{
+				Protein:      i.Protein,
+				ProteinID:    i.ProteinID,
+				EntryName:    i.EntryName,
+				GeneName:     i.GeneName,
+				Position:     i.ProteinStart + n,
+				AminoAcid:    string(sequence[n]),
+				Modification: strings.TrimPrefix(k, "PTMProphet_"),
+				Multiplicity: group,
+				Probability:  probabilities[n],
+			})
+		}
+	}
+
+	return sites
+}
+
+// SiteLevel collapses the localized PSMs into modified sites, the intensities are summed from the most intense
+// spectrum of each ion and the isobaric channels are summed from all spectra
+func SiteLevel(psms PSMEvidenceList, sequences map[string]string, hasDecoys bool) SiteEvidenceList {
+
+	var index = make(map[string]int)
+	var list SiteEvidenceList
+
+	for _, i := range psms {
+
+		if i.IsDecoy && !hasDecoys {
+			continue
+		}
+
+		peptide := i.ModifiedPeptide
+		if len(peptide) == 0 {
+			peptide = i.Peptide
+		}
+		ion := fmt.Sprintf("%s#%d", peptide, i.AssumedCharge)
+
+		for _, s := range psmSites(i) {
+
+			n, ok := index[s.Key()]
+			if !ok {
+				s.Peptides = make(map[string]struct{})
+				s.ions = make(map[string]float64)
+				s.Window = siteWindow(sequences[s.Protein], s.Position)
+				n = len(list)
+				index[s.Key()] = n
+				list = append(list, s)
+			}
+
+			site := &list[n]
+			site.Spectra++
+			site.Peptides[peptide] = struct{}{}
+
+			if s.Probability > site.Probability {
+				site.Probability = s.Probability
+			}
+
+			if i.Intensity > site.ions[ion] {
+				site.ions[ion] = i.Intensity
+			}
+
+			if i.Labels != nil && len(i.Labels.Channels) > 0 {
+				if site.Labels == nil {
+					site.Labels = &iso.Labels{Channels: make([]iso.Channel, len(i.Labels.Channels))}
+					copy(site.Labels.Channels, i.Labels.Channels)
+					for c := range site.Labels.Channels {
+						site.Labels.Channels[c].Intensity = 0
+					}
+				}
+				for c := range site.Labels.Channels {
+					if c < len(i.Labels.Channels) {
+						site.Labels.Channels[c].Intensity += i.Labels.Channels[c].Intensity
+					}
+				}
+			}
+		}
+	}
+
+	for i := range list {
+		for _, v := range list[i].ions {
+			list[i].Intensity += v
+		}
+	}
+
+	sort.Sort(list)
+
+	return list
+}
+
+// SiteSequences maps the proteins to their sequences, taken from the protein evidences and the workspace database
+func SiteSequences(proteins ProteinEvidenceList, workspace string) map[string]string {
+
+	var sequences = make(map[string]string)
+
+	var database dat.Base
+	sys.Restore(&database, fmt.Sprintf("%s%s%s", workspace, string(filepath.Separator), sys.DBBin()), true)
+
+	for _, i := range database.Records {
+		sequences[i.PartHeader] = i.Sequence
+	}
+
+	for _, i := range proteins {
+		if len(i.Sequence) > 0 {
+			sequences[i.PartHeader] = i.Sequence
+		}
+	}
+
+	return sequences
+}
+
+// SiteReport reports the PTMProphet localizations at the protein site level
+func (evi *Evidence) SiteReport(workspace string, hasDecoys bool) *Table {
+
+	if evi.PSM == nil {
+		RestorePSM(&evi.PSM)
+	}
+
+	sites := SiteLevel(evi.PSM, SiteSequences(evi.Proteins, "."), hasDecoys)
+
+	t := siteTable(sites)
+
+	output := fmt.Sprintf("%s%ssite.tsv", workspace, string(filepath.Separator))
+	if e := t.WriteTSV(output); e != nil {
+		msg.WriteToFile(e, "fatal")
+	}
+
+	return t
+}
+
+// siteTable lays out the site report, the channels follow the labels of the first quantified site
+func siteTable(sites SiteEvidenceList) *Table {
+
+	var labels []*iso.Labels
+	for _, i := range sites {
+		labels = append(labels, i.Labels)
+	}
+	ref := referenceLabels(labels, false)
+
+	t := NewTable("site", len(sites))
+
+	t.Text("Protein", func(n int) string { return sites[n].Protein })
+	t.Text("Protein ID", func(n int) string { return sites[n].ProteinID })
+	t.Text("Entry Name", func(n int) string { return sites[n].EntryName })
+	t.Text("Gene", func(n int) string { return sites[n].GeneName })
+	t.Integer("Position", func(n int) int { return sites[n].Position })
+	t.Text("Amino Acid", func(n int) string { return sites[n].AminoAcid })
+	t.Text("Modification", func(n int) string { return sites[n].Modification })
+	t.Text("Multiplicity", func(n int) string { return sites[n].MultiplicityName() })
+	t.Decimal("Localization Probability", "%.4f", func(n int) float64 { return sites[n].Probability })
+	t.Text("Class", func(n int) string { return sites[n].Class() })
+	t.Text("Sequence Window", func(n int) string { return sites[n].Window })
+	t.Integer("Spectral Count", func(n int) int { return sites[n].Spectra })
+	t.Text("Peptides", func(n int) string {
+		var peptides []string
+		for j := range sites[n].Peptides {
+			peptides = append(peptides, j)
+		}
+		sort.Strings(peptides)
+		return strings.Join(peptides, ", ")
+	})
+	t.Decimal("Intensity", "%.4f", func(n int) float64 { return sites[n].Intensity })
+
+	if ref != nil {
+		t.channelFields(ref, true, func(n int) *iso.Labels { return sites[n].Labels })
+	}
+
+	return t
+}
@@ -0,0 +1,121 @@
+package rep
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"philosopher/lib/id"
+	"philosopher/lib/iso"
+)
+
+func TestParsePTMPeptide(t *testing.T) {
+
+	sequence, probabilities, err := parsePTMPeptide("PEPS(0.998)T(0.002)IDEK")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sequence != "PEPSTIDEK" || probabilities[3] != 0.998 || probabilities[4] != 0.002 || !math.IsNaN(probabilities[0]) {
+		t.Errorf("the PTMProphet peptide is incorrect, got %s %v", sequence, probabilities)
+	}
+
+	for _, i := range []string{"(0.5)PEPTIDE", "PEPS(0.9", "PEPS(high)"} {
+		if _, _, err := parsePTMPeptide(i); err == nil {
+			t.Errorf("%s must be rejected", i)
+		}
+	}
+}
+
+func TestSiteLevel(t *testing.T) {
+
+	sequence := "MKAAPEPSTIDEKGGSYSPEPK"
+	sequences := map[string]string{"sp|P1|A": sequence}
+
+	phospho := func(ptm string) *id.PTM {
+		return &id.PTM{
+			LocalizedPTMSites:    map[string]int{"PTMProphet_STY79.9663": 2},
+			LocalizedPTMMassDiff: map[string]string{"PTMProphet_STY79.9663": ptm},
+		}
+	}
+
+	labels := func(a, b float64) *iso.Labels {
+		return &iso.Labels{Channels: []iso.Channel{{Name: "126", Intensity: a}, {Name: "127N", Intensity: b}}}
+	}
+
+	psms := PSMEvidenceList{
+		{Spectrum: "run.1.1.2", Peptide: "PEPSTIDEK", ModifiedPeptide: "PEPS[167]TIDEK", Protein: "sp|P1|A", ProteinStart: 5, AssumedCharge: 2, Intensity: 100, PTM: phospho("PEPS(0.998)T(0.002)IDEK"), Labels: labels(1, 2)},
+		{Spectrum: "run.2.2.2", Peptide: "PEPSTIDEK", ModifiedPeptide: "PEPS[167]TIDEK", Protein: "sp|P1|A", ProteinStart: 5, AssumedCharge: 2, Intensity: 300, PTM: phospho("PEPS(0.6)T(0.4)IDEK"), Labels: labels(10, 20)},
+		{Spectrum: "run.3.3.3", Peptide: "PEPSTIDEK", ModifiedPeptide: "PEPS[167]TIDEK", Protein: "sp|P1|A", ProteinStart: 5, AssumedCharge: 3, Intensity: 50, PTM: phospho("PEPS(0.7)T(0.3)IDEK")},
+		{Spectrum: "run.4.4.2", Peptide: "GGSYSPEPK", ModifiedPeptide: "GGS[167]YS[167]PEPK", Protein: "sp|P1|A", ProteinStart: 14, AssumedCharge: 2, Intensity: 20, PTM: phospho("GGS(0.99)Y(0.4)S(0.61)PEPK")},
+		{Spectrum: "run.7.7.2", Peptide: "GGSYSPEPK", Protein: "sp|P1|A", ProteinStart: 14, AssumedCharge: 2, Intensity: 5, PTM: phospho("GGS(1)Y(1)S(1)PEPK")},
+		{Spectrum: "run.8.8.3", Peptide: "MKAAPEPSTIDEKGGSYSPEPK", Protein: "sp|P1|A", ProteinStart: 1, AssumedCharge: 3, Intensity: 5, PTM: phospho("MKAAPEPS(1)T(1)IDEKGGS(1)Y(1)S(1)PEPK")},
+		{Spectrum: "run.5.5.2", Peptide: "KEDITSPEP", Protein: "rev_sp|P1|A", ProteinStart: 1, AssumedCharge: 2, Intensity: 20, IsDecoy: true, PTM: phospho("KEDITS(1)PEP")},
+		{Spectrum: "run.6.6.2", Peptide: "PEPSTIDEK", Protein: "sp|P1|A", AssumedCharge: 2, PTM: phospho("PEPS(1)TIDEK")},
+	}
+
+	sites := SiteLevel(psms, sequences, false)
+	if len(sites) != 8 {
+		t.Fatalf("expected 8 sites, got %d", len(sites))
+	}
+
+	// the triply and the five times modified peptides share the 3+ sites
+	var multiple = make(map[int]SiteEvidence)
+	var single SiteEvidenceList
+	for _, i := range sites {
+		if i.MultiplicityName() == "3+" {
+			multiple[i.Position] = i
+		} else {
+			single = append(single, i)
+		}
+	}
+	if len(multiple) != 5 || multiple[16].Spectra != 2 || multiple[8].Spectra != 1 {
+		t.Errorf("the 3+ sites must be combined by residue, got %+v", multiple)
+	}
+	sites = single
+
+	s := sites[0]
+	if s.Position != 8 || s.AminoAcid != "S" || s.Multiplicity != 1 || s.Probability != 0.998 || s.Class() != "I" {
+		t.Errorf("the singly phosphorylated site is incorrect, got %+v", s)
+	}
+	if s.Window != "MKAAPEPSTIDEKGG" || s.Modification != "STY79.9663" || s.Spectra != 3 {
+		t.Errorf("the site window is incorrect, got %s %s %d", s.Window, s.Modification, s.Spectra)
+	}
+	if s.Intensity != 350 || s.Labels.Channels[0].Intensity != 11 || s.Labels.Channels[1].Intensity != 22 {
+		t.Errorf("the site must sum the most intense spectrum of each ion, got %f %v", s.Intensity, s.Labels.Channels)
+	}
+
+	if sites[1].Position != 16 || sites[2].Position != 18 || sites[2].MultiplicityName() != "2" || sites[2].Class() != "II" {
+		t.Errorf("the doubly phosphorylated sites are incorrect, got %+v %+v", sites[1], sites[2])
+	}
+
+	if SiteClass(0.5) != "III" || SiteClass(0.75) != "II" || SiteClass(0.76) != "I" {
+		t.Error("the site classes are incorrect")
+	}
+
+	if w := siteWindow(sequence, 2); w != "______MKAAPEPST" {
+		t.Errorf("the protein start must be padded, got %s", w)
+	}
+
+	dir, err := ioutil.TempDir("", "site")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "site.tsv")
+	if err := siteTable(sites).WriteTSV(output); err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ := ioutil.ReadFile(output)
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if !strings.HasSuffix(lines[0], "\tIntensity\tChannel 126\tChannel 127N") || len(lines) != 4 {
+		t.Errorf("the site report header is incorrect, got %s", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "sp|P1|A\t\t\t\t8\tS\tSTY79.9663\t1\t0.9980\tI\tMKAAPEPSTIDEKGG\t3\tPEPS[167]TIDEK\t350.0000\t11.0000\t22.0000") {
+		t.Errorf("the site report line is incorrect, got %s", lines[1])
+	}
+}
@@ -0,0 +1,519 @@
+package rep
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"philosopher/lib/dat"
+	"philosopher/lib/id"
+	"philosopher/lib/iso"
+	"philosopher/lib/met"
+	"philosopher/lib/mod"
+	"philosopher/lib/msg"
+
+	// pure Go SQLite driver, the release builds are made without cgo
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema creates the evidence tables, every table belongs to a workspace so that several workspaces can be
+// loaded in the same database; the PSMs reference their ions, the ions their peptides and the peptides and
+// proteins their protein groups
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS workspace (
+	id INTEGER PRIMARY KEY,
+	uuid TEXT NOT NULL UNIQUE,
+	project TEXT,
+	home TEXT,
+	version TEXT,
+	search_engine TEXT,
+	database TEXT,
+	exported TEXT
+);
+
+CREATE TABLE IF NOT EXISTS database_record (
+	id INTEGER PRIMARY KEY,
+	workspace_id INTEGER NOT NULL REFERENCES workspace(id) ON DELETE CASCADE,
+	part_header TEXT NOT NULL,
+	protein_id TEXT,
+	entry_name TEXT,
+	protein_name TEXT,
+	gene_names TEXT,
+	organism TEXT,
+	description TEXT,
+	protein_existence TEXT,
+	sequence TEXT,
+	length INTEGER,
+	is_decoy INTEGER,
+	is_contaminant INTEGER,
+	UNIQUE (workspace_id, part_header)
+);
+
+CREATE TABLE IF NOT EXISTS protein_group (
+	id INTEGER PRIMARY KEY,
+	workspace_id INTEGER NOT NULL REFERENCES workspace(id) ON DELETE CASCADE,
+	group_number INTEGER NOT NULL,
+	UNIQUE (workspace_id, group_number)
+);
+
+CREATE TABLE IF NOT EXISTS protein (
+	id INTEGER PRIMARY KEY,
+	workspace_id INTEGER NOT NULL REFERENCES workspace(id) ON DELETE CASCADE,
+	protein_group_id INTEGER NOT NULL REFERENCES protein_group(id) ON DELETE CASCADE,
+	database_record_id INTEGER REFERENCES database_record(id) ON DELETE SET NULL,
+	subgroup TEXT,
+	part_header TEXT,
+	protein_id TEXT,
+	entry_name TEXT,
+	gene_names TEXT,
+	description TEXT,
+	organism TEXT,
+	length INTEGER,
+	coverage REAL,
+	probability REAL,
+	top_peptide_probability REAL,
+	total_spc INTEGER,
+	unique_spc INTEGER,
+	razor_spc INTEGER,
+	total_intensity REAL,
+	unique_intensity REAL,
+	razor_intensity REAL,
+	is_decoy INTEGER,
+	is_contaminant INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS indistinguishable_protein (
+	protein_id INTEGER NOT NULL REFERENCES protein(id) ON DELETE CASCADE,
+	part_header TEXT NOT NULL,
+	PRIMARY KEY (protein_id, part_header)
+);
+
+CREATE TABLE IF NOT EXISTS peptide (
+	id INTEGER PRIMARY KEY,
+	workspace_id INTEGER NOT NULL REFERENCES workspace(id) ON DELETE CASCADE,
+	protein_group_id INTEGER REFERENCES protein_group(id) ON DELETE SET NULL,
+	sequence TEXT NOT NULL,
+	protein TEXT,
+	protein_id TEXT,
+	gene_name TEXT,
+	spc INTEGER,
+	intensity REAL,
+	probability REAL,
+	is_unique INTEGER,
+	is_razor INTEGER,
+	is_decoy INTEGER,
+	UNIQUE (workspace_id, sequence)
+);
+
+CREATE TABLE IF NOT EXISTS ion (
+	id INTEGER PRIMARY KEY,
+	workspace_id INTEGER NOT NULL REFERENCES workspace(id) ON DELETE CASCADE,
+	peptide_id INTEGER REFERENCES peptide(id) ON DELETE SET NULL,
+	sequence TEXT NOT NULL,
+	modified_sequence TEXT,
+	charge INTEGER,
+	mz REAL,
+	peptide_mass REAL,
+	probability REAL,
+	expectation REAL,
+	intensity REAL,
+	is_unique INTEGER,
+	is_razor INTEGER,
+	is_decoy INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS psm (
+	id INTEGER PRIMARY KEY,
+	workspace_id INTEGER NOT NULL REFERENCES workspace(id) ON DELETE CASCADE,
+	ion_id INTEGER REFERENCES ion(id) ON DELETE SET NULL,
+	spectrum TEXT NOT NULL,
+	source TEXT,
+	peptide TEXT,
+	modified_peptide TEXT,
+	charge INTEGER,
+	retention_time REAL,
+	precursor_neutral_mass REAL,
+	calculated_neutral_mass REAL,
+	mass_difference REAL,
+	probability REAL,
+	expectation REAL,
+	hyperscore REAL,
+	nextscore REAL,
+	xcorr REAL,
+	intensity REAL,
+	purity REAL,
+	protein TEXT,
+	is_unique INTEGER,
+	is_razor INTEGER,
+	is_decoy INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS psm_protein (
+	psm_id INTEGER NOT NULL REFERENCES psm(id) ON DELETE CASCADE,
+	part_header TEXT NOT NULL,
+	is_primary INTEGER,
+	PRIMARY KEY (psm_id, part_header)
+);
+
+CREATE TABLE IF NOT EXISTS modification (
+	id INTEGER PRIMARY KEY,
+	psm_id INTEGER NOT NULL REFERENCES psm(id) ON DELETE CASCADE,
+	type TEXT,
+	amino_acid TEXT,
+	position INTEGER,
+	mass_difference REAL,
+	name TEXT,
+	accession TEXT,
+	is_variable INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS label (
+	id INTEGER PRIMARY KEY,
+	workspace_id INTEGER NOT NULL REFERENCES workspace(id) ON DELETE CASCADE,
+	psm_id INTEGER REFERENCES psm(id) ON DELETE CASCADE,
+	ion_id INTEGER REFERENCES ion(id) ON DELETE CASCADE,
+	peptide_id INTEGER REFERENCES peptide(id) ON DELETE CASCADE,
+	protein_id INTEGER REFERENCES protein(id) ON DELETE CASCADE,
+	kind TEXT NOT NULL,
+	scope TEXT,
+	channel TEXT NOT NULL,
+	custom_name TEXT,
+	mz REAL,
+	intensity REAL
+);
+
+CREATE INDEX IF NOT EXISTS psm_ion ON psm(ion_id);
+CREATE INDEX IF NOT EXISTS ion_peptide ON ion(peptide_id);
+CREATE INDEX IF NOT EXISTS peptide_group ON peptide(protein_group_id);
+CREATE INDEX IF NOT EXISTS protein_group_members ON protein(protein_group_id);
+CREATE INDEX IF NOT EXISTS modification_psm ON modification(psm_id);
+CREATE INDEX IF NOT EXISTS label_psm ON label(psm_id);
+CREATE INDEX IF NOT EXISTS label_ion ON label(ion_id);
+CREATE INDEX IF NOT EXISTS label_peptide ON label(peptide_id);
+CREATE INDEX IF NOT EXISTS label_protein ON label(protein_id);
+`
+
+// SQLiteReport writes the evidences of the workspace to a SQLite database, the workspace replaces its previous
+// export and the other workspaces in the database are kept
+func (e Evidence) SQLiteReport(m met.Data, output string) {
+
+	var dtb dat.Base
+	dtb.Restore()
+
+	db, err := openSQLite(output)
+	if err != nil {
+		msg.WriteFile(err, "fatal")
+	}
+	defer db.Close()
+
+	if err := writeSQLite(db, e, m, dtb.Records); err != nil {
+		msg.Custom(fmt.Errorf("cannot write the SQLite database: %w", err), "fatal")
+	}
+}
+
+// openSQLite opens the database with the foreign keys enforced on every connection
+func openSQLite(path string) (*sql.DB, error) {
+
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// sqliteWriter inserts the rows of one workspace, the first error stops the remaining inserts
+type sqliteWriter struct {
+	tx         *sql.Tx
+	statements map[string]*sql.Stmt
+	workspace  int64
+	err        error
+}
+
+// insert runs the insert statement and returns the identifier of the new row
+func (w *sqliteWriter) insert(query string, args ...interface{}) int64 {
+
+	if w.err != nil {
+		return 0
+	}
+
+	stmt, ok := w.statements[query]
+	if !ok {
+		stmt, w.err = w.tx.Prepare(query)
+		if w.err != nil {
+			return 0
+		}
+		w.statements[query] = stmt
+	}
+
+	res, err := stmt.Exec(args...)
+	if err != nil {
+		w.err = err
+		return 0
+	}
+
+	n, err := res.LastInsertId()
+	if err != nil {
+		w.err = err
+	}
+
+	return n
+}
+
+// nullID stores the missing references as NULL
+func nullID(id int64, ok bool) interface{} {
+	if !ok {
+		return nil
+	}
+	return id
+}
+
+// writeSQLite creates the tables and loads the workspace evidences in a single transaction
+func writeSQLite(db *sql.DB, e Evidence, m met.Data, records []dat.Record) error {
+
+	if len(m.UUID) == 0 {
+		return errors.New("the workspace has no identifier")
+	}
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	w := sqliteWriter{tx: tx, statements: make(map[string]*sql.Stmt)}
+
+	if _, err := tx.Exec("DELETE FROM workspace WHERE uuid = ?", m.UUID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	w.workspace = w.insert("INSERT INTO workspace (uuid, project, home, version, search_engine, database, exported) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		m.UUID, m.ProjectName, m.Home, m.Version, m.SearchEngine, m.Database.Annot, time.Now().Format(time.RFC3339))
+
+	recordIDs := w.records(records)
+	groupIDs := w.proteins(e.Proteins, recordIDs, m.Report.Decoys)
+	peptideIDs := w.peptides(e.Peptides, groupIDs, m.Report.Decoys)
+	ionIDs := w.ions(e.Ions, peptideIDs, m.Report.Decoys)
+	w.psms(e.PSM, ionIDs, m.Report.Decoys)
+
+	if w.err != nil {
+		tx.Rollback()
+		return w.err
+	}
+
+	return tx.Commit()
+}
+
+// records loads the database sequences, indexed by their part header
+func (w *sqliteWriter) records(records []dat.Record) map[string]int64 {
+
+	var ids = make(map[string]int64)
+
+	for _, i := range records {
+		if _, ok := ids[i.PartHeader]; ok {
+			continue
+		}
+		ids[i.PartHeader] = w.insert("INSERT INTO database_record (workspace_id, part_header, protein_id, entry_name, protein_name, gene_names, organism, description, protein_existence, sequence, length, is_decoy, is_contaminant) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			w.workspace, i.PartHeader, i.ID, i.EntryName, i.ProteinName, i.GeneNames, i.Organism, i.Description, i.ProteinExistence, i.Sequence, len(i.Sequence), i.IsDecoy, i.IsContaminant)
+	}
+
+	return ids
+}
+
+// proteins loads the protein groups and their proteins, the groups are indexed by the part header of each protein
+// and of its indistinguishable proteins
+func (w *sqliteWriter) proteins(proteins ProteinEvidenceList, records map[string]int64, decoys bool) map[string]int64 {
+
+	var groups = make(map[uint32]int64)
+	var groupIDs = make(map[string]int64)
+
+	for _, i := range proteins {
+
+		if i.IsDecoy && !decoys {
+			continue
+		}
+
+		group, ok := groups[i.ProteinGroup]
+		if !ok {
+			group = w.insert("INSERT INTO protein_group (workspace_id, group_number) VALUES (?, ?)", w.workspace, i.ProteinGroup)
+			groups[i.ProteinGroup] = group
+		}
+
+		record, found := records[i.PartHeader]
+
+		protein := w.insert("INSERT INTO protein (workspace_id, protein_group_id, database_record_id, subgroup, part_header, protein_id, entry_name, gene_names, description, organism, length, coverage, probability, top_peptide_probability, total_spc, unique_spc, razor_spc, total_intensity, unique_intensity, razor_intensity, is_decoy, is_contaminant) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			w.workspace, group, nullID(record, found), i.ProteinSubGroup, i.PartHeader, i.ProteinID, i.EntryName, i.GeneNames, i.Description, i.Organism, i.Length, i.Coverage, i.Probability, i.TopPepProb, i.TotalSpC, i.UniqueSpC, i.URazorSpC, i.TotalIntensity, i.UniqueIntensity, i.URazorIntensity, i.IsDecoy, i.IsContaminant)
+
+		groupIDs[i.PartHeader] = group
+
+		var members []string
+		for j := range i.IndiProtein {
+			if j != i.PartHeader {
+				members = append(members, j)
+			}
+		}
+		sort.Strings(members)
+
+		for _, j := range members {
+			w.insert("INSERT INTO indistinguishable_protein (protein_id, part_header) VALUES (?, ?)", protein, j)
+			if _, ok := groupIDs[j]; !ok {
+				groupIDs[j] = group
+			}
+		}
+
+		w.labels("protein_id", protein, "total", i.TotalLabels)
+		w.labels("protein_id", protein, "unique", i.UniqueLabels)
+		w.labels("protein_id", protein, "razor", i.URazorLabels)
+		w.ms1Labels("protein_id", protein, i.MS1Labels)
+	}
+
+	return groupIDs
+}
+
+// peptides loads the peptide sequences, each peptide references the group of its protein
+func (w *sqliteWriter) peptides(peptides PeptideEvidenceList, groups map[string]int64, decoys bool) map[string]int64 {
+
+	var ids = make(map[string]int64)
+
+	for _, i := range peptides {
+
+		if i.IsDecoy && !decoys {
+			continue
+		}
+
+		if _, ok := ids[i.Sequence]; ok {
+			continue
+		}
+
+		group, ok := groups[i.Protein]
+
+		ids[i.Sequence] = w.insert("INSERT INTO peptide (workspace_id, protein_group_id, sequence, protein, protein_id, gene_name, spc, intensity, probability, is_unique, is_razor, is_decoy) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			w.workspace, nullID(group, ok), i.Sequence, i.Protein, i.ProteinID, i.GeneName, i.Spc, i.Intensity, i.Probability, i.IsUnique, i.IsURazor, i.IsDecoy)
+
+		w.labels("peptide_id", ids[i.Sequence], "", i.Labels)
+		w.ms1Labels("peptide_id", ids[i.Sequence], i.MS1Labels)
+	}
+
+	return ids
+}
+
+// ions loads the peptide ions, indexed by their sequence, mass and charge
+func (w *sqliteWriter) ions(ions IonEvidenceList, peptides map[string]int64, decoys bool) map[id.IonFormType]int64 {
+
+	var ids = make(map[id.IonFormType]int64)
+
+	for _, i := range ions {
+
+		if i.IsDecoy && !decoys {
+			continue
+		}
+
+		peptide, ok := peptides[i.Sequence]
+
+		ion := w.insert("INSERT INTO ion (workspace_id, peptide_id, sequence, modified_sequence, charge, mz, peptide_mass, probability, expectation, intensity, is_unique, is_razor, is_decoy) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			w.workspace, nullID(peptide, ok), i.Sequence, i.ModifiedSequence, i.ChargeState, i.MZ, i.PeptideMass, i.Probability, i.Expectation, i.Intensity, i.IsUnique, i.IsURazor, i.IsDecoy)
+
+		ids[i.IonForm()] = ion
+
+		w.labels("ion_id", ion, "", i.Labels)
+		w.ms1Labels("ion_id", ion, i.MS1Labels)
+	}
+
+	return ids
+}
+
+// psms loads the PSMs with the proteins they map to and their modifications
+func (w *sqliteWriter) psms(psms PSMEvidenceList, ions map[id.IonFormType]int64, decoys bool) {
+
+	for _, i := range psms {
+
+		if i.IsDecoy && !decoys {
+			continue
+		}
+
+		ion, ok := ions[i.IonForm()]
+		source := strings.Split(i.Spectrum, ".")[0]
+
+		psm := w.insert("INSERT INTO psm (workspace_id, ion_id, spectrum, source, peptide, modified_peptide, charge, retention_time, precursor_neutral_mass, calculated_neutral_mass, mass_difference, probability, expectation, hyperscore, nextscore, xcorr, intensity, purity, protein, is_unique, is_razor, is_decoy) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			w.workspace, nullID(ion, ok), i.Spectrum, source, i.Peptide, i.ModifiedPeptide, i.AssumedCharge, i.RetentionTime, i.PrecursorNeutralMass, i.CalcNeutralPepMass, i.Massdiff, i.Probability, i.Expectation, i.Hyperscore, i.Nextscore, i.Xcorr, i.Intensity, i.Purity, i.Protein, i.IsUnique, i.IsURazor, i.IsDecoy)
+
+		var mapped = map[string]bool{i.Protein: true}
+		for j := range i.MappedProteins {
+			if j != i.Protein {
+				mapped[j] = false
+			}
+		}
+
+		var proteins []string
+		for j := range mapped {
+			if len(j) > 0 {
+				proteins = append(proteins, j)
+			}
+		}
+		sort.Strings(proteins)
+
+		for _, j := range proteins {
+			w.insert("INSERT INTO psm_protein (psm_id, part_header, is_primary) VALUES (?, ?, ?)", psm, j, mapped[j])
+		}
+
+		for _, j := range i.Modifications.IndexSlice {
+
+			if j.MassDiff == 0 {
+				continue
+			}
+
+			kind := "assigned"
+			if j.Type == mod.Observed {
+				kind = "observed"
+			}
+
+			w.insert("INSERT INTO modification (psm_id, type, amino_acid, position, mass_difference, name, accession, is_variable) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+				psm, kind, j.AminoAcid, j.Position, j.MassDiff, j.Name, j.ID, j.Variable)
+		}
+
+		w.labels("psm_id", psm, "", i.Labels)
+		w.ms1Labels("psm_id", psm, i.MS1Labels)
+	}
+}
+
+// labels loads the isobaric channel intensities of an evidence, the column names the evidence table
+func (w *sqliteWriter) labels(column string, evidence int64, scope string, labels *iso.Labels) {
+
+	if labels == nil {
+		return
+	}
+
+	query := fmt.Sprintf("INSERT INTO label (workspace_id, %s, kind, scope, channel, custom_name, mz, intensity) VALUES (?, ?, 'isobaric', ?, ?, ?, ?, ?)", column)
+
+	for _, i := range labels.Channels {
+		w.insert(query, w.workspace, evidence, scope, i.Name, i.CustomName, i.Mz, i.Intensity)
+	}
+}
+
+// ms1Labels loads the MS1 label channel intensities of an evidence, the column names the evidence table
+func (w *sqliteWriter) ms1Labels(column string, evidence int64, labels *MS1Labels) {
+
+	if labels == nil {
+		return
+	}
+
+	query := fmt.Sprintf("INSERT INTO label (workspace_id, %s, kind, scope, channel, intensity) VALUES (?, ?, 'ms1', ?, ?, ?)", column)
+
+	for n, i := range labels.Channels {
+		if n < len(labels.Intensities) {
+			w.insert(query, w.workspace, evidence, labels.Scheme, i, labels.Intensities[n])
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package rep
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"philosopher/lib/dat"
+)
+
+// countRows returns the number of rows of a table
+func countRows(t *testing.T, db *sql.DB, query string, args ...interface{}) int {
+
+	var n int
+	if err := db.QueryRow(query, args...).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+
+	return n
+}
+
+func TestSQLite(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := openSQLite(filepath.Join(dir, "results.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	e, m := mzTabEvidence()
+	m.UUID = "workspace-1"
+
+	e.Proteins[0].PartHeader = "sp|P12345|PROT_HUMAN"
+	e.Proteins[0].ProteinGroup = 1
+	e.Peptides = PeptideEvidenceList{{Sequence: "PEMTIDEK", Protein: "sp|P12345|PROT_HUMAN", Spc: 1, Labels: e.Proteins[0].URazorLabels}}
+
+	records := []dat.Record{
+		{PartHeader: "sp|P12345|PROT_HUMAN", ID: "P12345", Sequence: "MSKPEMTIDEKAR"},
+		{PartHeader: "sp|Q67890|OTHER_HUMAN", ID: "Q67890", Sequence: "PEMTIDEK"},
+	}
+
+	for _, i := range []string{"workspace-1", "workspace-1", "workspace-2"} {
+		m.UUID = i
+		if err := writeSQLite(db, e, m, records); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := countRows(t, db, "SELECT COUNT(*) FROM workspace"); n != 2 {
+		t.Errorf("expected 2 workspaces, got %d", n)
+	}
+
+	var tables = map[string]int{
+		"database_record":           2,
+		"protein_group":             1,
+		"protein":                   1,
+		"indistinguishable_protein": 1,
+		"peptide":                   1,
+		"ion":                       1,
+		"psm":                       1,
+		"psm_protein":               2,
+		"modification":              2,
+		"label":                     4,
+	}
+
+	for table, expected := range tables {
+		if n := countRows(t, db, "SELECT COUNT(*) FROM "+table); n != 2*expected {
+			t.Errorf("expected %d rows in %s, got %d", 2*expected, table, n)
+		}
+	}
+
+	rows, err := db.Query("PRAGMA foreign_key_check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows.Next() {
+		t.Error("the database has broken foreign keys")
+	}
+	rows.Close()
+
+	query := `SELECT COUNT(*) FROM psm
+		JOIN ion ON psm.ion_id = ion.id
+		JOIN peptide ON ion.peptide_id = peptide.id
+		JOIN protein_group ON peptide.protein_group_id = protein_group.id
+		JOIN protein ON protein.protein_group_id = protein_group.id
+		JOIN database_record ON protein.database_record_id = database_record.id
+		JOIN workspace ON psm.workspace_id = workspace.id
+		WHERE workspace.uuid = ? AND database_record.protein_id = 'P12345'`
+
+	if n := countRows(t, db, query, "workspace-1"); n != 1 {
+		t.Errorf("the PSM must reach its protein through the ion, peptide and protein group, got %d rows", n)
+	}
+
+	if n := countRows(t, db, "SELECT COUNT(*) FROM psm WHERE is_decoy = 1"); n != 0 {
+		t.Errorf("decoys must not be exported, got %d", n)
+	}
+
+	if _, err := db.Exec("DELETE FROM workspace WHERE uuid = ?", "workspace-2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := countRows(t, db, "SELECT COUNT(*) FROM label"); n != tables["label"] {
+		t.Errorf("the labels of a deleted workspace must be removed, got %d", n)
+	}
+}
@@ -0,0 +1,180 @@
+package rep
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"philosopher/lib/iso"
+
+	"github.com/xitongsys/parquet-go/parquet"
+)
+
+// Field is a report column, the values keep their type for the custom layouts and the Parquet files, and are printed
+// with the column format on the TSV reports
+type Field struct {
+	Name    string
+	Type    parquet.Type
+	hidden  bool
+	channel string
+	printer func(v interface{}) string
+	value   func(n int) interface{}
+}
+
+// Table is a report laid out as a registry of typed columns, the values are read from the evidences on demand
+type Table struct {
+	Name   string
+	Rows   int
+	Fields []*Field
+}
+
+// NewTable creates a report table with the given number of rows
+func NewTable(name string, rows int) *Table {
+	return &Table{Name: name, Rows: rows}
+}
+
+func (t *Table) add(f *Field) *Field {
+	t.Fields = append(t.Fields, f)
+	return f
+}
+
+// Text registers a text column
+func (t *Table) Text(name string, value func(n int) string) *Field {
+	return t.add(&Field{
+		Name:    name,
+		Type:    parquet.Type_BYTE_ARRAY,
+		printer: func(v interface{}) string { return v.(string) },
+		value:   func(n int) interface{} { return value(n) },
+	})
+}
+
+// Integer registers an integer column
+func (t *Table) Integer(name string, value func(n int) int) *Field {
+	return t.add(&Field{
+		Name:    name,
+		Type:    parquet.Type_INT64,
+		printer: func(v interface{}) string { return fmt.Sprintf("%d", v) },
+		value:   func(n int) interface{} { return int64(value(n)) },
+	})
+}
+
+// Decimal registers a decimal column printed with the given verb
+func (t *Table) Decimal(name, verb string, value func(n int) float64) *Field {
+	return t.add(&Field{
+		Name:    name,
+		Type:    parquet.Type_DOUBLE,
+		printer: func(v interface{}) string { return fmt.Sprintf(verb, v) },
+		value:   func(n int) interface{} { return value(n) },
+	})
+}
+
+// Flag registers a boolean column
+func (t *Table) Flag(name string, value func(n int) bool) *Field {
+	return t.add(&Field{
+		Name:    name,
+		Type:    parquet.Type_BOOLEAN,
+		printer: func(v interface{}) string { return fmt.Sprintf("%t", v) },
+		value:   func(n int) interface{} { return value(n) },
+	})
+}
+
+// Hide keeps the column out of the TSV and Parquet reports, the custom layouts can still use it
+func (f *Field) Hide(hide bool) *Field {
+	f.hidden = hide
+	return f
+}
+
+// Channel marks the column as the intensity of a channel, used by the long Parquet layout
+func (f *Field) Channel(name string) *Field {
+	f.channel = name
+	return f
+}
+
+// Printer replaces the printing of the column values on the TSV reports
+func (f *Field) Printer(printer func(v interface{}) string) *Field {
+	f.printer = printer
+	return f
+}
+
+// Value returns the value of a row
+func (f *Field) Value(n int) interface{} {
+	return f.value(n)
+}
+
+// Print returns the value of a row as printed on the TSV reports
+func (f *Field) Print(n int) string {
+	return f.printer(f.value(n))
+}
+
+// Printed returns the columns of the TSV and Parquet reports
+func (t *Table) Printed() []*Field {
+
+	var fields []*Field
+	for _, i := range t.Fields {
+		if !i.hidden {
+			fields = append(fields, i)
+		}
+	}
+
+	return fields
+}
+
+// WriteTSV writes the printed columns as a tab separated report
+func (t *Table) WriteTSV(output string) error {
+
+	file, e := os.Create(output)
+	if e != nil {
+		return e
+	}
+	defer file.Close()
+
+	bw := bufio.NewWriter(file)
+
+	fields := t.Printed()
+
+	var header = make([]string, len(fields))
+	for i, j := range fields {
+		header[i] = j.Name
+	}
+
+	if _, e := io.WriteString(bw, strings.Join(header, "\t")+"\n"); e != nil {
+		return e
+	}
+
+	var line = make([]string, len(fields))
+	for n := 0; n < t.Rows; n++ {
+
+		for i, j := range fields {
+			line[i] = j.Print(n)
+		}
+
+		if _, e := io.WriteString(bw, strings.Join(line, "\t")+"\n"); e != nil {
+			return e
+		}
+	}
+
+	return bw.Flush()
+}
+
+// channelFields registers the channel intensities, custom names replace the channel names when requested and
+// missing labels or channels are reported as zero
+func (t *Table) channelFields(ref *iso.Labels, hasLabels bool, labels func(n int) *iso.Labels) {
+
+	for c, i := range ref.Channels {
+
+		name, channel := "Channel "+i.Name, i.Name
+		if hasLabels && len(i.CustomName) > 0 {
+			name, channel = i.CustomName, i.CustomName
+		}
+
+		c := c
+		t.Decimal(name, "%.4f", func(n int) float64 {
+			if l := labels(n); l != nil && c < len(l.Channels) {
+				return l.Channels[c].Intensity
+			}
+			return 0
+		}).Channel(channel)
+	}
+}
@@ -0,0 +1,314 @@
+package rep
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"philosopher/lib/msg"
+
+	"github.com/xitongsys/parquet-go/parquet"
+	"gopkg.in/yaml.v2"
+)
+
+// TemplateColumn is a column of a custom report layout, it copies a report column or computes an expression where
+// the report columns are referenced as {Column Name}. The column "*" adds every report column not listed elsewhere
+type TemplateColumn struct {
+	Column     string `yaml:"column"`
+	Name       string `yaml:"name"`
+	Precision  *int   `yaml:"precision"`
+	Expression string `yaml:"expression"`
+}
+
+// Template is a custom layout for the reports, each report level lists its columns in order
+type Template struct {
+	Name      string           `yaml:"name"`
+	Delimiter string           `yaml:"delimiter"`
+	Precision *int             `yaml:"precision"`
+	PSM       []TemplateColumn `yaml:"psm"`
+	Ion       []TemplateColumn `yaml:"ion"`
+	Peptide   []TemplateColumn `yaml:"peptide"`
+	Protein   []TemplateColumn `yaml:"protein"`
+	Gene      []TemplateColumn `yaml:"gene"`
+}
+
+// templateColumn is a template column resolved against a report table
+type templateColumn struct {
+	name      string
+	field     *Field
+	expr      expression
+	precision *int
+}
+
+// computedPrecision is the number of decimals of the computed columns when the template sets none
+const computedPrecision = 4
+
+// ReadTemplate reads a report template, the template name defaults to the file name
+func ReadTemplate(path string) Template {
+
+	var t Template
+
+	b, e := ioutil.ReadFile(path)
+	if e != nil {
+		msg.ReadFile(errors.New("cannot open the report template"), "fatal")
+	}
+
+	e = yaml.UnmarshalStrict(b, &t)
+	if e != nil {
+		msg.Custom(fmt.Errorf("malformed report template: %s", e), "fatal")
+	}
+
+	if len(t.Name) == 0 {
+		t.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	if _, e := t.delimiter(); e != nil {
+		msg.Custom(e, "fatal")
+	}
+
+	return t
+}
+
+// delimiter returns the field separator, tab by default
+func (t Template) delimiter() (rune, error) {
+
+	switch t.Delimiter {
+	case "", "tab", "\t":
+		return '\t', nil
+	case "comma":
+		return ',', nil
+	case "semicolon":
+		return ';', nil
+	}
+
+	r, n := utf8.DecodeRuneInString(t.Delimiter)
+	if n != len(t.Delimiter) || r == '"' || r == '\n' || r == '\r' {
+		return 0, fmt.Errorf("invalid report template delimiter %q", t.Delimiter)
+	}
+
+	return r, nil
+}
+
+// columns returns the template columns of a report level
+func (t Template) columns(report string) []TemplateColumn {
+
+	switch report {
+	case "psm":
+		return t.PSM
+	case "ion":
+		return t.Ion
+	case "peptide":
+		return t.Peptide
+	case "protein":
+		return t.Protein
+	case "gene":
+		return t.Gene
+	}
+
+	return nil
+}
+
+// Apply writes the custom layout of a report next to the standard one, the values are taken from the report table
+// so the precision applies to the unrounded values and the columns left out of the report are available
+func (t Template) Apply(workspace string, table *Table) {
+
+	columns := t.columns(table.Name)
+	if len(columns) == 0 {
+		return
+	}
+
+	delimiter, _ := t.delimiter()
+	extension := "csv"
+	if delimiter == '\t' {
+		extension = "tsv"
+	}
+
+	output := fmt.Sprintf("%s%s%s_%s.%s", workspace, string(filepath.Separator), table.Name, t.Name, extension)
+	if e := t.apply(table, output, columns, delimiter); e != nil {
+		msg.Custom(e, "fatal")
+	}
+}
+
+// resolve matches the template columns with the report fields
+func (t Template) resolve(table *Table, columns []TemplateColumn) ([]templateColumn, error) {
+
+	var index = make(map[string]int)
+	for i, j := range table.Fields {
+		if _, ok := index[j.Name]; !ok {
+			index[j.Name] = i
+		}
+	}
+
+	var listed = make(map[string]bool)
+	for _, i := range columns {
+		if len(i.Expression) == 0 && i.Column != "*" {
+			listed[i.source()] = true
+		}
+	}
+
+	var resolved []templateColumn
+	for _, i := range columns {
+
+		if len(i.Expression) > 0 {
+
+			if len(i.Name) == 0 {
+				return nil, fmt.Errorf("the computed column %q of the %s template needs a name", i.Expression, table.Name)
+			}
+
+			expr, e := parseExpression(i.Expression, index)
+			if e != nil {
+				return nil, fmt.Errorf("the %s template column %s is invalid, %s", table.Name, i.Name, e)
+			}
+
+			precision := i.Precision
+			if precision == nil {
+				precision = t.Precision
+			}
+			if precision == nil {
+				p := computedPrecision
+				precision = &p
+			}
+
+			resolved = append(resolved, templateColumn{name: i.Name, expr: expr, precision: precision})
+			continue
+		}
+
+		if i.Column == "*" {
+			for _, j := range table.Printed() {
+				if !listed[j.Name] {
+					resolved = append(resolved, templateColumn{name: j.Name, field: j, precision: t.fieldPrecision(i, j)})
+				}
+			}
+			continue
+		}
+
+		n, ok := index[i.source()]
+		if !ok {
+			var names []string
+			for _, j := range table.Fields {
+				names = append(names, j.Name)
+			}
+			return nil, fmt.Errorf("the column %q is not in the %s report, the available columns are: %s", i.source(), table.Name, strings.Join(names, ", "))
+		}
+
+		name := i.Name
+		if len(name) == 0 {
+			name = i.source()
+		}
+
+		resolved = append(resolved, templateColumn{name: name, field: table.Fields[n], precision: t.fieldPrecision(i, table.Fields[n])})
+	}
+
+	return resolved, nil
+}
+
+// fieldPrecision returns the number of decimals of a copied column, the column precision applies to any numeric
+// column and the template precision to the decimal ones
+func (t Template) fieldPrecision(c TemplateColumn, f *Field) *int {
+
+	if c.Precision != nil && (f.Type == parquet.Type_DOUBLE || f.Type == parquet.Type_INT64) {
+		return c.Precision
+	}
+
+	if c.Precision == nil && f.Type == parquet.Type_DOUBLE {
+		return t.Precision
+	}
+
+	return nil
+}
+
+// source returns the report column copied by the template column
+func (c TemplateColumn) source() string {
+	if len(c.Column) > 0 {
+		return c.Column
+	}
+	return c.Name
+}
+
+// format prints the value of a row, copied columns without precision are printed as in the report
+func (c templateColumn) format(table *Table, n int) string {
+
+	if c.expr != nil {
+		v := c.expr(func(i int) interface{} { return table.Fields[i].Value(n) })
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return ""
+		}
+		return strconv.FormatFloat(v, 'f', *c.precision, 64)
+	}
+
+	if c.precision == nil {
+		return c.field.Print(n)
+	}
+
+	v := numeric(c.field.Value(n))
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return c.field.Print(n)
+	}
+
+	return strconv.FormatFloat(v, 'f', *c.precision, 64)
+}
+
+// apply writes a report table with its custom layout
+func (t Template) apply(table *Table, output string, columns []TemplateColumn, delimiter rune) error {
+
+	resolved, e := t.resolve(table, columns)
+	if e != nil {
+		return e
+	}
+
+	out, e := os.Create(output)
+	if e != nil {
+		return e
+	}
+	defer out.Close()
+
+	bw := bufio.NewWriter(out)
+
+	// the tab separated layouts follow the standard reports, the other delimiters are quoted when needed
+	var write func(record []string) error
+	if delimiter == '\t' {
+		write = func(record []string) error {
+			_, e := io.WriteString(bw, strings.Join(record, "\t")+"\n")
+			return e
+		}
+	} else {
+		cw := csv.NewWriter(bw)
+		cw.Comma = delimiter
+		write = func(record []string) error {
+			e := cw.Write(record)
+			cw.Flush()
+			return e
+		}
+	}
+
+	record := make([]string, len(resolved))
+	for i, j := range resolved {
+		record[i] = j.name
+	}
+
+	if e := write(record); e != nil {
+		return e
+	}
+
+	for n := 0; n < table.Rows; n++ {
+
+		for i, j := range resolved {
+			record[i] = j.format(table, n)
+		}
+
+		if e := write(record); e != nil {
+			return e
+		}
+	}
+
+	return bw.Flush()
+}
@@ -0,0 +1,168 @@
+package rep
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"philosopher/lib/iso"
+)
+
+func TestExpression(t *testing.T) {
+
+	columns := map[string]int{"Observed Mass": 0, "Calculated Peptide Mass": 1, "Intensity": 2}
+	row := func(values ...interface{}) func(int) interface{} {
+		return func(i int) interface{} { return values[i] }
+	}
+
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{"({Observed Mass} - {Calculated Peptide Mass}) / {Calculated Peptide Mass} * 1e6", 10},
+		{"log2({Intensity})", 10},
+		{"-{Calculated Peptide Mass} + 2 * 3", -994},
+		{"abs(1 - 2.5e-1 * 8)", 1},
+	}
+
+	for _, i := range tests {
+		expr, e := parseExpression(i.input, columns)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if v := expr(row(1000.01, int64(1000), "1024")); math.Abs(v-i.want) > 1e-6 {
+			t.Errorf("%s: got %f, want %f", i.input, v, i.want)
+		}
+	}
+
+	if expr, _ := parseExpression("{Intensity} / {Observed Mass}", columns); !math.IsNaN(expr(row("", "", 5.0))) {
+		t.Error("empty values must not be computed")
+	}
+
+	for _, i := range []string{"{Missing} * 2", "exp(1)", "(1 + 2", "1 +", "2 2"} {
+		if _, e := parseExpression(i, columns); e == nil {
+			t.Errorf("%q must be rejected", i)
+		}
+	}
+}
+
+func TestTemplate(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "template")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	psm := []struct {
+		spectrum, peptide, proteins string
+		charge                      int
+		observed, calculated, prob  float64
+	}{
+		{"run.00001.00001.2", "PEPTIDEK", "sp|P1|A, sp|P2|B", 2, 1000.01, 1000, 0.99871},
+		{"run.00002.00002.3", "PEMTIDEK", "", 3, math.NaN(), 800.5, 0.50123},
+	}
+
+	table := NewTable("psm", len(psm))
+	table.Text("Spectrum", func(n int) string { return psm[n].spectrum })
+	table.Text("Peptide", func(n int) string { return psm[n].peptide })
+	table.Integer("Charge", func(n int) int { return psm[n].charge })
+	table.Decimal("Observed Mass", "%.4f", func(n int) float64 { return psm[n].observed }).Printer(func(v interface{}) string {
+		if math.IsNaN(v.(float64)) {
+			return ""
+		}
+		return fmt.Sprintf("%.4f", v)
+	})
+	table.Decimal("Calculated Peptide Mass", "%.4f", func(n int) float64 { return psm[n].calculated })
+	table.Decimal("PeptideProphet Probability", "%.4f", func(n int) float64 { return psm[n].prob })
+	table.Text("Mapped Proteins", func(n int) string { return psm[n].proteins })
+	table.Decimal("Purity", "%.2f", func(n int) float64 { return 0.75 }).Hide(true)
+
+	precision := 2
+	template := filepath.Join(dir, "lab.yml")
+	content := `delimiter: comma
+psm:
+  - column: Peptide
+    name: Sequence
+  - name: PeptideProphet Probability
+    precision: 3
+  - name: Mass Error (ppm)
+    expression: ({Observed Mass} - {Calculated Peptide Mass}) / {Calculated Peptide Mass} * 1e6
+  - column: "*"
+`
+	if err := ioutil.WriteFile(template, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := ReadTemplate(template)
+	tmpl.Precision = &precision
+	tmpl.Apply(dir, table)
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "psm_lab.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the precision applies to the unrounded values, integers and text are kept as in the report and the columns
+	// left out of the report are only written when listed
+	want := "Sequence,PeptideProphet Probability,Mass Error (ppm),Spectrum,Charge,Observed Mass,Calculated Peptide Mass,Mapped Proteins\n" +
+		"PEPTIDEK,0.999,10.00,run.00001.00001.2,2,1000.01,1000.00,\"sp|P1|A, sp|P2|B\"\n" +
+		"PEMTIDEK,0.501,,run.00002.00002.3,3,,800.50,\n"
+
+	if string(b) != want {
+		t.Errorf("the custom layout is incorrect, got\n%s\nwant\n%s", b, want)
+	}
+
+	hidden := Template{Name: "purity", PSM: []TemplateColumn{{Column: "Spectrum"}, {Column: "Purity", Precision: &precision}}}
+	hidden.Apply(dir, table)
+	if b, _ := ioutil.ReadFile(filepath.Join(dir, "psm_purity.tsv")); !strings.HasSuffix(string(b), "run.00002.00002.3\t0.75\n") {
+		t.Errorf("the columns left out of the report must be available, got\n%s", b)
+	}
+
+	tmpl.Apply(dir, NewTable("ion", 0))
+	if _, err := os.Stat(filepath.Join(dir, "ion_lab.csv")); !os.IsNotExist(err) {
+		t.Error("the levels without columns must not be written")
+	}
+
+	unknown := Template{Name: "bad", PSM: []TemplateColumn{{Column: "Hyperscore"}}}
+	err = unknown.apply(table, filepath.Join(dir, "bad.tsv"), unknown.PSM, '\t')
+	if err == nil || !strings.Contains(err.Error(), "available columns are: Spectrum, Peptide") {
+		t.Errorf("unknown columns must be reported with the available ones, got %v", err)
+	}
+}
+
+func TestTemplateRawValues(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "template")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	labels := &iso.Labels{Channels: []iso.Channel{{Name: "126", Intensity: 10.123456}}}
+	proteins := ProteinEvidenceList{{PartHeader: "sp|P1|A", TotalSpC: 3, TotalIntensity: 1234.5678, UniqueLabels: labels}}
+	table := proteins.MetaProteinReport(dir, "rev_", false, false, false, false)
+
+	precision := 2
+	tmpl := Template{Name: "raw", Protein: []TemplateColumn{
+		{Column: "Protein"},
+		{Column: "Total Intensity", Precision: &precision},
+		{Column: "Total Spectral Count", Precision: &precision},
+		{Column: "Channel 126", Precision: &precision},
+	}}
+	tmpl.Apply(dir, table)
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "protein_raw.tsv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "Protein\tTotal Intensity\tTotal Spectral Count\tChannel 126\nsp|P1|A\t1234.57\t3.00\t10.12\n"
+	if string(b) != want {
+		t.Errorf("the precision must apply to the report values, got\n%s\nwant\n%s", b, want)
+	}
+}
@@ -527,6 +527,52 @@ func (evi *Evidence) UpdateLayerswithDatabase(decoyTag string) {
 	}
 }
 
+// UpdatePeptidePositions sets the peptide start and end positions, and the flanking residues
+// based on the database index instead of the first string match on the reference protein
+func (evi *Evidence) UpdatePeptidePositions(idx *dat.PeptideIndex) {
+
+	type prevNextAA struct {
+		prev byte
+		next byte
+	}
+	var pepPrevNextAA = make(map[string]prevNextAA)
+	var cache = make(map[string][]dat.PeptideMatch)
+
+	for i := range evi.PSM {
+
+		matches, ok := cache[evi.PSM[i].Peptide]
+		if !ok {
+			matches = idx.Map(evi.PSM[i].Peptide)
+			cache[evi.PSM[i].Peptide] = matches
+		}
+
+		for _, j := range matches {
+			if j.Protein == evi.PSM[i].Protein {
+				evi.PSM[i].ProteinStart = j.Start
+				evi.PSM[i].ProteinEnd = j.End
+				evi.PSM[i].PrevAA = j.PrevAA
+				evi.PSM[i].NextAA = j.NextAA
+				pepPrevNextAA[evi.PSM[i].Peptide] = prevNextAA{j.PrevAA, j.NextAA}
+				break
+			}
+		}
+	}
+
+	for i := range evi.Ions {
+		if pnAA, ok := pepPrevNextAA[evi.Ions[i].Sequence]; ok {
+			evi.Ions[i].PrevAA = pnAA.prev
+			evi.Ions[i].NextAA = pnAA.next
+		}
+	}
+
+	for i := range evi.Peptides {
+		if pnAA, ok := pepPrevNextAA[evi.Peptides[i].Sequence]; ok {
+			evi.Peptides[i].PrevAA = pnAA.prev
+			evi.Peptides[i].NextAA = pnAA.next
+		}
+	}
+}
+
 // UpdateSupportingSpectra pushes back from PSM to Protein the new supporting spectra from razor results
 func (evi *Evidence) UpdateSupportingSpectra() {
 
@@ -158,6 +158,12 @@ func ProBin() string {
 	return p
 }
 
+// GeneBin file
+func GeneBin() string {
+	p := fmt.Sprintf("%s%sgene.bin", MetaDir(), string(filepath.Separator))
+	return p
+}
+
 // DBBin file
 func DBBin() string {
 	p := fmt.Sprintf("%s%sdb.bin", MetaDir(), string(filepath.Separator))
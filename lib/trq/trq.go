@@ -6,46 +6,29 @@ import (
 	"philosopher/lib/msg"
 )
 
-// New builds a new Labelled spectra object
-func New(plex string) iso.Labels {
-
-	var o iso.Labels
+// NewReagent builds the iTRAQ reagent definition for the given plex
+func NewReagent(plex string) iso.Reagent {
 
 	if plex == "4" {
 
-		o.Channel1.Name = "114"
-		o.Channel2.Name = "115"
-		o.Channel3.Name = "116"
-		o.Channel4.Name = "117"
-
-		o.Channel1.Mz = 114.1112
-		o.Channel2.Mz = 115.1083
-		o.Channel3.Mz = 116.1116
-		o.Channel4.Mz = 117.1150
+		return iso.NewReagent("iTRAQ4",
+			[]string{"114", "115", "116", "117"},
+			[]float64{114.1112, 115.1083, 116.1116, 117.1150})
 
 	} else if plex == "8" {
 
-		o.Channel1.Name = "113"
-		o.Channel2.Name = "114"
-		o.Channel3.Name = "115"
-		o.Channel4.Name = "116"
-		o.Channel5.Name = "117"
-		o.Channel6.Name = "118"
-		o.Channel7.Name = "119"
-		o.Channel8.Name = "121"
-
-		o.Channel1.Mz = 113.1078
-		o.Channel2.Mz = 114.1112
-		o.Channel3.Mz = 115.1082
-		o.Channel4.Mz = 116.1116
-		o.Channel5.Mz = 117.1149
-		o.Channel6.Mz = 118.1120
-		o.Channel7.Mz = 119.1153
-		o.Channel8.Mz = 121.1220
-
-	} else {
-		msg.Custom(errors.New("unknown multiplex setting, please define the plex number used in your experiment"), "error")
+		return iso.NewReagent("iTRAQ8",
+			[]string{"113", "114", "115", "116", "117", "118", "119", "121"},
+			[]float64{113.1078, 114.1112, 115.1082, 116.1116, 117.1149, 118.1120, 119.1153, 121.1220})
+
 	}
 
-	return o
+	msg.Custom(errors.New("unknown multiplex setting, please define the plex number used in your experiment or provide a reagent definition file"), "error")
+
+	return iso.Reagent{}
+}
+
+// New builds a new Labelled spectra object
+func New(plex string) iso.Labels {
+	return NewReagent(plex).Labels()
 }
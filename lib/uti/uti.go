@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"philosopher/lib/msg"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -205,3 +206,22 @@ func RemoveDuplicateStrings(slice []string) []string {
 	copy(list2, list)
 	return list2
 }
+
+// Median returns the median of a list of values, the input list is not modified
+func Median(values []float64) float64 {
+
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+
+	return sorted[mid]
+}
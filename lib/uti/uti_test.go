@@ -20,4 +20,9 @@ func TestUti(t *testing.T) {
 		t.Errorf("Aminoacid name is incorrect, got %f, want %f", y, 5.3557876867)
 	}
 
+	z := uti.Median([]float64{4, 1, 3, 2})
+	if z != 2.5 {
+		t.Errorf("Median is incorrect, got %f, want %f", z, 2.5)
+	}
+
 }
@@ -3,6 +3,7 @@ package wmm
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"philosopher/lib/met"
 )
 
@@ -19,6 +20,11 @@ func Run(m met.Data) met.Data {
 		text = writeMSFragger(m.MSFragger, text)
 	}
 
+	// Isobaric quantification
+	if len(m.Quantify.ImpurityMatrix) > 0 {
+		text = writeImpurityCorrection(m.Quantify, text)
+	}
+
 	// Philosopher
 	//text = writePhilosopher(m.Filter, m.Quantify, text)
 
@@ -70,6 +76,16 @@ func writeMSFragger(d met.MSFragger, text string) string {
 	return text
 }
 
+func writeImpurityCorrection(q met.Quantify, text string) string {
+
+	text = fmt.Sprintf("%sReporter ion intensities from %d channels were corrected for isotopic impurities in each spectrum using the reagent lot purity table (%s), by solving the impurity matrix with a non-negative least squares constraint.", text, len(q.ImpurityChannels), filepath.Base(q.Impurity))
+
+	// appending new line before returning
+	text = text + "\n"
+
+	return text
+}
+
 func writePhilosopher(f met.Filter, q met.Quantify, text string) string {
 
 	/* PHILOSOPHER